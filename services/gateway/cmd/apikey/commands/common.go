@@ -2,20 +2,26 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 
 	"github.com/kexi/telegram-bot-gateway/internal/config"
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
 	"github.com/kexi/telegram-bot-gateway/internal/pkg/apikey"
+	"github.com/kexi/telegram-bot-gateway/internal/pubsub"
 	"github.com/kexi/telegram-bot-gateway/internal/repository"
 )
 
-// initDB initializes the database connection
-func initDB() (*gorm.DB, error) {
+// loadConfig loads the gateway configuration
+func loadConfig() (*config.Config, error) {
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
 		configPath = "configs/config.json"
@@ -26,6 +32,16 @@ func initDB() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
+	return cfg, nil
+}
+
+// initDB initializes the database connection
+func initDB() (*gorm.DB, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := repository.NewDatabase(&cfg.Database, "release")
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -34,6 +50,67 @@ func initDB() (*gorm.DB, error) {
 	return db, nil
 }
 
+// initRedis initializes the Redis connection
+func initRedis(ctx context.Context) (*redis.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Address,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		redisClient.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return redisClient, nil
+}
+
+// notifyChatPermissionChange clears cached permission checks for an API key on a chat
+// and tells running gateways, which drop WebSocket subscriptions that are no longer allowed.
+// Failures are reported as warnings since the database change has already been made.
+func notifyChatPermissionChange(ctx context.Context, apiKeyID, chatID uint) {
+	redisClient, err := initRedis(ctx)
+	if err != nil {
+		info("Warning: %v (cached permissions expire within 5 minutes)", err)
+		return
+	}
+	defer redisClient.Close()
+
+	if err := middleware.InvalidateChatPermissionCache(ctx, redisClient, &apiKeyID, nil, chatID); err != nil {
+		info("Warning: failed to clear permission cache: %v", err)
+	}
+
+	broker := pubsub.NewMessageBroker(redisClient)
+	if err := broker.PublishPermissionChange(ctx, &pubsub.PermissionChangeEvent{
+		ChatID:    chatID,
+		APIKeyID:  &apiKeyID,
+		Timestamp: time.Now(),
+	}); err != nil {
+		info("Warning: failed to publish permission change: %v", err)
+	}
+}
+
+// notifyBotPermissionChange clears the cached bot grants of an API key so running
+// gateways apply the change immediately
+func notifyBotPermissionChange(ctx context.Context, apiKeyID uint) {
+	redisClient, err := initRedis(ctx)
+	if err != nil {
+		info("Warning: %v (cached permissions expire within 5 minutes)", err)
+		return
+	}
+	defer redisClient.Close()
+
+	if err := middleware.InvalidateBotPermissionCache(ctx, redisClient, apiKeyID); err != nil {
+		info("Warning: failed to clear permission cache: %v", err)
+	}
+}
+
 // getContext returns a context with timeout
 func getContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 30*time.Second)
@@ -194,6 +271,51 @@ func getFlagValue(args []string, flag ...string) string {
 	return ""
 }
 
+// encodeScopes validates a comma-separated scope list and encodes it for domain.APIKey.Scopes.
+// "all" and an empty list leave the key unrestricted.
+func encodeScopes(list string) (string, error) {
+	if list == "" || list == "all" {
+		return "", nil
+	}
+
+	var scopes []string
+	for _, scope := range strings.Split(list, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	if err := middleware.ValidateScopes(scopes); err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(scopes)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// describeScopes formats the stored scopes of an API key for display
+func describeScopes(raw string) string {
+	scopes, err := middleware.ParseScopes(raw)
+	if err != nil {
+		return "invalid (" + raw + ")"
+	}
+	if len(scopes) == 0 {
+		return "all"
+	}
+	return strings.Join(scopes, ", ")
+}
+
+// describeRateLimit formats the hourly read and send quotas of an API key for display
+func describeRateLimit(apiKey *domain.APIKey) string {
+	sendRateLimit := apiKey.SendRateLimit
+	if sendRateLimit == 0 {
+		sendRateLimit = apiKey.RateLimit
+	}
+	return fmt.Sprintf("%d reads/hour, %d sends/hour", apiKey.RateLimit, sendRateLimit)
+}
+
 func init() {
 	// Disable log timestamps for cleaner output
 	log.SetFlags(0)
@@ -17,7 +17,9 @@ Required:
 
 Options:
   --description <desc>      Description of the API key
-  --rate-limit <n>          Requests per hour (default: 1000)
+  --rate-limit <n>          Reads (GET requests) per hour (default: 1000)
+  --send-rate-limit <n>     Sends (all other requests) per hour (default: same as --rate-limit)
+  --scopes <list>           Comma-separated scopes (default: all)
   --expires <duration>      Expiration time (e.g., 1y, 30d, 24h)
   --help, -h                Show this help message
 
@@ -25,6 +27,11 @@ Examples:
   apikey create --name "Production Service"
   apikey create --name "Dev API" --rate-limit 5000 --expires 1y
   apikey create --name "Test" --description "Testing purposes" --expires 30d
+  apikey create --name "Notifier" --scopes messages:send,chats:read
+  apikey create --name "Dashboard" --rate-limit 10000 --send-rate-limit 100
+
+Scopes: bots:read, chats:read, messages:read, messages:send, messages:manage,
+        webhooks:manage, ws:subscribe
 `
 
 func CreateAPIKey(args []string) {
@@ -41,8 +48,14 @@ func CreateAPIKey(args []string) {
 
 	description := getFlagValue(args, "--description", "-d")
 	rateLimitStr := getFlagValue(args, "--rate-limit", "-r")
+	sendRateLimitStr := getFlagValue(args, "--send-rate-limit")
 	expiresStr := getFlagValue(args, "--expires", "-e")
 
+	scopes, err := encodeScopes(getFlagValue(args, "--scopes", "-s"))
+	if err != nil {
+		fatal("Invalid scopes: %v", err)
+	}
+
 	rateLimit := 1000
 	if rateLimitStr != "" {
 		_, err := fmt.Sscanf(rateLimitStr, "%d", &rateLimit)
@@ -51,6 +64,13 @@ func CreateAPIKey(args []string) {
 		}
 	}
 
+	sendRateLimit := 0
+	if sendRateLimitStr != "" {
+		if _, err := fmt.Sscanf(sendRateLimitStr, "%d", &sendRateLimit); err != nil || sendRateLimit <= 0 {
+			fatal("Invalid send rate limit: %s", sendRateLimitStr)
+		}
+	}
+
 	var expiresAt *time.Time
 	if expiresStr != "" {
 		duration, err := parseDuration(expiresStr)
@@ -85,13 +105,15 @@ func CreateAPIKey(args []string) {
 	defer cancel()
 
 	apiKey := &domain.APIKey{
-		Key:         plainKey, // Store full key for lookup
-		HashedKey:   hashedKey,
-		Name:        name,
-		Description: description,
-		RateLimit:   rateLimit,
-		IsActive:    true,
-		ExpiresAt:   expiresAt,
+		Key:           apiKeyService.VisiblePrefix(plainKey),
+		HashedKey:     hashedKey,
+		Name:          name,
+		Description:   description,
+		Scopes:        scopes,
+		RateLimit:     rateLimit,
+		SendRateLimit: sendRateLimit,
+		IsActive:      true,
+		ExpiresAt:     expiresAt,
 	}
 
 	if err := apiKeyRepo.Create(ctx, apiKey); err != nil {
@@ -110,7 +132,8 @@ func CreateAPIKey(args []string) {
 	if description != "" {
 		info("  Description: %s", description)
 	}
-	info("  Rate Limit:  %d requests/hour", rateLimit)
+	info("  Scopes:      %s", describeScopes(scopes))
+	info("  Rate Limit:  %s", describeRateLimit(apiKey))
 	if expiresAt != nil {
 		info("  Expires:     %s", expiresAt.Format("2006-01-02 15:04:05"))
 	} else {
@@ -61,7 +61,8 @@ func GetAPIKey(args []string) {
 	if apiKey.Description != "" {
 		info("Description: %s", apiKey.Description)
 	}
-	info("Rate Limit:  %d requests/hour", apiKey.RateLimit)
+	info("Scopes:      %s", describeScopes(apiKey.Scopes))
+	info("Rate Limit:  %s", describeRateLimit(apiKey))
 	info("Active:      %t", apiKey.IsActive)
 	if apiKey.ExpiresAt != nil {
 		info("Expires:     %s", apiKey.ExpiresAt.Format("2006-01-02 15:04:05"))
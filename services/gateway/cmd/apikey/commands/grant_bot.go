@@ -10,20 +10,21 @@ import (
 const grantBotUsage = `Grant bot usage permission to an API key
 
 Usage:
-  apikey grant-bot <apikey-id> <bot-id>
+  apikey grant-bot <apikey-id> <bot-id|all>
 
 Arguments:
   <apikey-id>               API key ID
-  <bot-id>                  Bot ID
+  <bot-id>                  Bot ID, or "all" to allow every bot
 
 Options:
   --help, -h                Show this help message
 
 Examples:
   apikey grant-bot 1 2
+  apikey grant-bot 1 all
 
-Note: Once ANY bot permission is granted, the API key can ONLY use explicitly allowed bots.
-      If no bot permissions exist, all bots are allowed (default behavior).
+Note: An API key can ONLY use the bots it is granted. A key without bot grants
+      cannot send through any bot unless it is granted "all".
 `
 
 func GrantBotPermission(args []string) {
@@ -37,6 +38,12 @@ func GrantBotPermission(args []string) {
 		fatal("Invalid API key ID: %s", args[0])
 	}
 
+	if args[1] == "all" {
+		setAllBots(uint(apiKeyID), true)
+		success("Granted all bots: API key %d can now use every bot", apiKeyID)
+		return
+	}
+
 	botID, err := strconv.ParseUint(args[1], 10, 64)
 	if err != nil {
 		fatal("Invalid bot ID: %s", args[1])
@@ -68,7 +75,34 @@ func GrantBotPermission(args []string) {
 		fatal("Failed to grant bot permission: %v", err)
 	}
 
+	notifyBotPermissionChange(ctx, uint(apiKeyID))
+
 	success("Granted bot permission: API key %d can now use bot %d", apiKeyID, botID)
-	info("")
-	info("⚠️  Note: This API key is now restricted to explicitly allowed bots only.")
+}
+
+// setAllBots lets an API key use every bot, or only its granted bots again
+func setAllBots(apiKeyID uint, allBots bool) {
+	db, err := initDB()
+	if err != nil {
+		fatal("Failed to initialize database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	apiKeyRepo, _, _, _, _, _ := initRepositories(db)
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	apiKey, err := apiKeyRepo.GetByID(ctx, apiKeyID)
+	if err != nil {
+		fatal("Failed to get API key: %v", err)
+	}
+
+	apiKey.AllBots = allBots
+	if err := apiKeyRepo.Update(ctx, apiKey); err != nil {
+		fatal("Failed to update API key: %v", err)
+	}
 }
@@ -112,6 +112,8 @@ func GrantChatPermission(args []string) {
 		success("Granted chat permission for API key %d on chat %d (Telegram ID: %d)", apiKeyID, chat.ID, telegramChatID)
 	}
 
+	notifyChatPermissionChange(ctx, uint(apiKeyID), chat.ID)
+
 	var perms []string
 	if canRead {
 		perms = append(perms, "read")
@@ -24,6 +24,8 @@ Examples:
 
 Note: Once ANY feedback permission is granted, the API key can ONLY receive feedback from explicitly allowed chats.
       If no feedback permissions exist, all chats can send feedback (default behavior).
+      Messages are pushed to the key's feedback webhook (PUT /api/v1/feedback/webhook) and
+      only from chats the key can read.
 `
 
 func GrantFeedbackPermission(args []string) {
@@ -8,19 +8,20 @@ import (
 const revokeBotUsage = `Revoke bot usage permission from an API key
 
 Usage:
-  apikey revoke-bot <apikey-id> <bot-id>
+  apikey revoke-bot <apikey-id> <bot-id|all>
 
 Arguments:
   <apikey-id>               API key ID
-  <bot-id>                  Bot ID
+  <bot-id>                  Bot ID, or "all" to limit the key to its granted bots again
 
 Options:
   --help, -h                Show this help message
 
 Examples:
   apikey revoke-bot 1 2
+  apikey revoke-bot 1 all
 
-Note: If this was the last bot permission, the API key will again be able to use ALL bots.
+Note: If this was the last bot permission, the API key can no longer send through any bot.
 `
 
 func RevokeBotPermission(args []string) {
@@ -34,6 +35,12 @@ func RevokeBotPermission(args []string) {
 		fatal("Invalid API key ID: %s", args[0])
 	}
 
+	if args[1] == "all" {
+		setAllBots(uint(apiKeyID), false)
+		success("Revoked all bots: API key %d can now only use its granted bots", apiKeyID)
+		return
+	}
+
 	botID, err := strconv.ParseUint(args[1], 10, 64)
 	if err != nil {
 		fatal("Invalid bot ID: %s", args[1])
@@ -59,5 +66,7 @@ func RevokeBotPermission(args []string) {
 		fatal("Failed to revoke bot permission: %v", err)
 	}
 
+	notifyBotPermissionChange(ctx, uint(apiKeyID))
+
 	success("Revoked bot permission: API key %d can no longer use bot %d", apiKeyID, botID)
 }
@@ -77,5 +77,7 @@ func RevokeChatPermission(args []string) {
 		fatal("Failed to revoke chat permission: %v", err)
 	}
 
+	notifyChatPermissionChange(ctx, uint(apiKeyID), chat.ID)
+
 	success("Revoked chat permission for API key %d on chat %d (Telegram ID: %d)", apiKeyID, chat.ID, telegramChatID)
 }
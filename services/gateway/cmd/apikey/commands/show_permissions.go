@@ -80,8 +80,11 @@ func ShowPermissions(args []string) {
 
 	// Bot permissions
 	botPerms, err := botPermRepo.ListByAPIKey(ctx, uint(apiKeyID))
-	if err == nil && len(botPerms) > 0 {
-		info("Bot Restrictions (can ONLY use these bots):")
+	if apiKey.AllBots {
+		info("Bot Permissions: ALL bots (apikey revoke-bot %d all to restrict)", apiKeyID)
+		info("")
+	} else if err == nil && len(botPerms) > 0 {
+		info("Bot Permissions (can ONLY use these bots):")
 		info("  Bot ID | Username")
 		info("  -------|------------------")
 		for _, perm := range botPerms {
@@ -89,7 +92,7 @@ func ShowPermissions(args []string) {
 		}
 		info("")
 	} else {
-		info("Bot Restrictions: None (can use ALL bots)")
+		info("Bot Permissions: None (cannot send through any bot)")
 		info("")
 	}
 
@@ -114,7 +117,7 @@ func ShowPermissions(args []string) {
 
 	info("Command examples:")
 	info("  Grant chat access:     apikey grant-chat %d <chat-id> --read --send", apiKeyID)
-	info("  Restrict to bot:       apikey grant-bot %d <bot-id>", apiKeyID)
+	info("  Allow bot:             apikey grant-bot %d <bot-id>", apiKeyID)
 	info("  Allow feedback:        apikey grant-feedback %d <chat-id>", apiKeyID)
 }
 
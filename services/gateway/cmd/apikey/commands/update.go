@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const updateUsage = `Update an API key
+
+Usage:
+  apikey update <id> [options]
+
+Arguments:
+  <id>                      API key ID to update
+
+Options:
+  --name <name>             New name
+  --description <desc>      New description
+  --rate-limit <n>          Reads (GET requests) per hour
+  --send-rate-limit <n>     Sends (all other requests) per hour, 0 for the same as --rate-limit
+  --scopes <list>           Comma-separated scopes replacing the current ones ("all" to remove restrictions)
+  --help, -h                Show this help message
+
+Examples:
+  apikey update 1 --name "Billing Service"
+  apikey update 1 --scopes messages:read,ws:subscribe
+  apikey update 1 --scopes all
+  apikey update 1 --send-rate-limit 100
+
+Scopes: bots:read, chats:read, messages:read, messages:send, messages:manage,
+        webhooks:manage, ws:subscribe
+`
+
+func UpdateAPIKey(args []string) {
+	if hasFlag(args, "--help", "-h") || len(args) == 0 {
+		fmt.Print(updateUsage)
+		return
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		fatal("Invalid API key ID: %s", args[0])
+	}
+
+	name := getFlagValue(args, "--name", "-n")
+	description := getFlagValue(args, "--description", "-d")
+	rateLimitStr := getFlagValue(args, "--rate-limit", "-r")
+	sendRateLimitStr := getFlagValue(args, "--send-rate-limit")
+	scopesStr := getFlagValue(args, "--scopes", "-s")
+	if name == "" && description == "" && rateLimitStr == "" && sendRateLimitStr == "" && scopesStr == "" {
+		fatal("Nothing to update\n\n%s", updateUsage)
+	}
+
+	// Validate before touching the database
+	rateLimit := 0
+	if rateLimitStr != "" {
+		if _, err := fmt.Sscanf(rateLimitStr, "%d", &rateLimit); err != nil || rateLimit <= 0 {
+			fatal("Invalid rate limit: %s", rateLimitStr)
+		}
+	}
+	sendRateLimit := 0
+	if sendRateLimitStr != "" {
+		if _, err := fmt.Sscanf(sendRateLimitStr, "%d", &sendRateLimit); err != nil || sendRateLimit < 0 {
+			fatal("Invalid send rate limit: %s", sendRateLimitStr)
+		}
+	}
+	scopes, err := encodeScopes(scopesStr)
+	if err != nil {
+		fatal("Invalid scopes: %v", err)
+	}
+
+	// Initialize database
+	db, err := initDB()
+	if err != nil {
+		fatal("Failed to initialize database: %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	apiKeyRepo, _, _, _, _, _ := initRepositories(db)
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	apiKey, err := apiKeyRepo.GetByID(ctx, uint(id))
+	if err != nil {
+		fatal("Failed to get API key: %v", err)
+	}
+
+	if name != "" {
+		apiKey.Name = name
+	}
+	if description != "" {
+		apiKey.Description = description
+	}
+	if rateLimit > 0 {
+		apiKey.RateLimit = rateLimit
+	}
+	if sendRateLimitStr != "" {
+		apiKey.SendRateLimit = sendRateLimit
+	}
+	if scopesStr != "" {
+		apiKey.Scopes = scopes
+	}
+
+	if err := apiKeyRepo.Update(ctx, apiKey); err != nil {
+		fatal("Failed to update API key: %v", err)
+	}
+
+	success("API key %d (%s) updated successfully", apiKey.ID, apiKey.Name)
+	info("  Scopes:      %s", describeScopes(apiKey.Scopes))
+	info("  Rate Limit:  %s", describeRateLimit(apiKey))
+}
@@ -16,6 +16,7 @@ Commands:
   create                  Create a new API key
   list                    List all API keys
   get <id>                Get API key details
+  update <id>             Update name, description, rate limits or scopes
   revoke <id>             Revoke (deactivate) an API key
   delete <id>             Delete an API key
 
@@ -37,6 +38,9 @@ Examples:
   # Create an API key
   apikey create --name "Production Service" --rate-limit 5000 --expires 1y
 
+  # Create an API key that can only send messages
+  apikey create --name "Notifier" --scopes messages:send
+
   # List all API keys
   apikey list
 
@@ -65,6 +69,8 @@ func main() {
 		commands.ListAPIKeys(os.Args[2:])
 	case "get", "show":
 		commands.GetAPIKey(os.Args[2:])
+	case "update":
+		commands.UpdateAPIKey(os.Args[2:])
 	case "revoke":
 		commands.RevokeAPIKey(os.Args[2:])
 	case "delete", "rm":
@@ -9,6 +9,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/kexi/telegram-bot-gateway/internal/config"
+	"github.com/kexi/telegram-bot-gateway/internal/pkg/encryption"
 	"github.com/kexi/telegram-bot-gateway/internal/repository"
 	"github.com/kexi/telegram-bot-gateway/internal/service"
 )
@@ -45,8 +46,13 @@ func initBotService(db *gorm.DB) (*service.BotService, error) {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
+	keyring, err := encryption.NewKeyring(cfg.Encryption.PrimaryKeyID, cfg.Encryption.Keys, cfg.Encryption.LegacySecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize token encryption: %w", err)
+	}
+
 	botRepo := repository.NewBotRepository(db)
-	botService := service.NewBotService(botRepo, cfg.Auth.JWT.Secret, cfg.Telegram.WebhookBaseURL, nil)
+	botService := service.NewBotService(botRepo, keyring, cfg.Telegram.WebhookBaseURL, cfg.Telegram.APIBaseURL, nil)
 
 	return botService, nil
 }
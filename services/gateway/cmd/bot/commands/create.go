@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
 	"github.com/kexi/telegram-bot-gateway/internal/service"
 )
 
@@ -13,6 +14,10 @@ func Create(args []string) {
 	token := getFlagValue(args, "--token")
 	displayName := getFlagValue(args, "--display-name")
 	description := getFlagValue(args, "--description")
+	mode := getFlagValue(args, "--mode")
+	if mode == "" {
+		mode = domain.BotModeWebhook
+	}
 
 	if username == "" {
 		fatal("--username is required")
@@ -44,9 +49,14 @@ func Create(args []string) {
 		Token:       token,
 		DisplayName: displayName,
 		Description: description,
+		Mode:        mode,
 	}
 
-	info("Creating bot and registering webhook with Telegram...")
+	if mode == domain.BotModePolling {
+		info("Creating bot in polling mode...")
+	} else {
+		info("Creating bot and registering webhook with Telegram...")
+	}
 	bot, err := botService.CreateBot(ctx, req)
 	if err != nil {
 		fatal("Failed to create bot: %v", err)
@@ -57,6 +67,11 @@ func Create(args []string) {
 	fmt.Printf("  ID:           %d\n", bot.ID)
 	fmt.Printf("  Username:     %s\n", bot.Username)
 	fmt.Printf("  Display Name: %s\n", bot.DisplayName)
-	fmt.Printf("  Webhook URL:  %s\n", bot.WebhookURL)
-	fmt.Printf("\nThe webhook has been registered with Telegram.\n")
+	fmt.Printf("  Mode:         %s\n", bot.IngestionMode)
+	if bot.IngestionMode == domain.BotModePolling {
+		fmt.Printf("\nThe gateway will fetch updates with getUpdates.\n")
+	} else {
+		fmt.Printf("  Webhook URL:  %s\n", bot.WebhookURL)
+		fmt.Printf("\nThe webhook has been registered with Telegram.\n")
+	}
 }
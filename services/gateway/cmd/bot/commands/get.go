@@ -45,6 +45,7 @@ func Get(args []string) {
 	fmt.Printf("  Display Name: %s\n", bot.DisplayName)
 	fmt.Printf("  Description:  %s\n", bot.Description)
 	fmt.Printf("  Active:       %v\n", bot.IsActive)
+	fmt.Printf("  Mode:         %s\n", bot.IngestionMode)
 	fmt.Printf("  Webhook URL:  %s\n", bot.WebhookURL)
 	fmt.Println()
 }
@@ -0,0 +1,29 @@
+package commands
+
+// RotateEncryption re-encrypts all bot tokens under the primary encryption key
+func RotateEncryption(args []string) {
+	// Initialize database and service
+	db, err := initDB()
+	if err != nil {
+		fatal("Failed to initialize database: %v", err)
+	}
+
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	botService, err := initBotService(db)
+	if err != nil {
+		fatal("Failed to initialize bot service: %v", err)
+	}
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	rotated, err := botService.RotateEncryption(ctx)
+	if err != nil {
+		fatal("Failed to rotate encryption after %d tokens: %v", rotated, err)
+	}
+
+	success("Re-encrypted %d bot tokens under the primary key", rotated)
+	info("Keys that are no longer primary can now be removed from encryption.keys")
+}
@@ -19,9 +19,10 @@ func Update(args []string) {
 	displayName := getFlagValue(args, "--display-name")
 	description := getFlagValue(args, "--description")
 	activeStr := getFlagValue(args, "--active")
+	mode := getFlagValue(args, "--mode")
 
-	if displayName == "" && description == "" && activeStr == "" {
-		fatal("At least one of --display-name, --description, or --active must be provided")
+	if displayName == "" && description == "" && activeStr == "" && mode == "" {
+		fatal("At least one of --display-name, --description, --active, or --mode must be provided")
 	}
 
 	active := true
@@ -51,9 +52,18 @@ func Update(args []string) {
 	ctx, cancel := getContext()
 	defer cancel()
 
-	err = botService.UpdateBot(ctx, uint(id), displayName, description, active)
-	if err != nil {
-		fatal("Failed to update bot: %v", err)
+	if displayName != "" || description != "" || activeStr != "" {
+		err = botService.UpdateBot(ctx, uint(id), displayName, description, active)
+		if err != nil {
+			fatal("Failed to update bot: %v", err)
+		}
+	}
+
+	if mode != "" {
+		if err := botService.SetIngestionMode(ctx, uint(id), mode); err != nil {
+			fatal("Failed to switch ingestion mode: %v", err)
+		}
+		info("Ingestion mode set to %s", mode)
 	}
 
 	success("Bot updated successfully")
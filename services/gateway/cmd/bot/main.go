@@ -13,23 +13,26 @@ Usage:
   bot <command> [arguments] [flags]
 
 Commands:
-  create              Create a new bot and register webhook with Telegram
+  create              Create a new bot and register webhook with Telegram (or use polling)
   list, ls            List all bots
   get, show <id>      Get bot details by ID
   update <id>         Update bot information
   delete, rm <id>     Delete a bot and deregister webhook
   show-token <id>     Display decrypted bot token
+  rotate-encryption   Re-encrypt all bot tokens under the primary encryption key
 
 Flags for 'create':
   --username <name>        Bot username (required)
   --token <token>          Bot token from @BotFather (required)
   --display-name <name>    Display name (optional)
   --description <text>     Description (optional)
+  --mode <webhook|polling> Update ingestion mode (default: webhook)
 
 Flags for 'update':
   --display-name <name>    New display name
   --description <text>     New description
   --active <true|false>    Set active status
+  --mode <webhook|polling> Switch update ingestion mode
 
 Flags for 'delete':
   --force                  Required to confirm deletion
@@ -39,11 +42,14 @@ Environment:
 
 Examples:
   bot create --username my_bot --token "123456:ABC-DEF" --display-name "My Bot"
+  bot create --username dev_bot --token "123456:ABC-DEF" --mode polling
   bot list
   bot get 1
   bot update 1 --display-name "New Name" --active true
+  bot update 1 --mode polling
   bot show-token 1
   bot delete 1 --force
+  bot rotate-encryption
 `
 
 func main() {
@@ -68,6 +74,8 @@ func main() {
 		commands.Delete(args)
 	case "show-token":
 		commands.ShowToken(args)
+	case "rotate-encryption":
+		commands.RotateEncryption(args)
 	case "help", "-h", "--help":
 		fmt.Println(usage)
 	default:
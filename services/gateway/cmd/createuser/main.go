@@ -15,6 +15,7 @@ func main() {
 	username := flag.String("username", "admin", "Admin username")
 	password := flag.String("password", "", "Admin password (required)")
 	email := flag.String("email", "", "Admin email (optional)")
+	roleName := flag.String("role", "", "Role to assign, e.g. admin (optional)")
 	flag.Parse()
 
 	if *password == "" {
@@ -39,6 +40,7 @@ func main() {
 	// Initialize repositories and services
 	userRepo := repository.NewUserRepository(db)
 	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
 
 	jwtService := jwt.NewService(
 		cfg.Auth.JWT.Secret,
@@ -58,6 +60,20 @@ func main() {
 	}
 
 	log.Printf("✓ Created user: %s (ID: %d)", user.Username, user.ID)
-	log.Println("✓ Now assign admin role manually:")
-	log.Printf("   INSERT INTO user_roles (user_id, role_id) VALUES (%d, 1);", user.ID)
+
+	if *roleName == "" {
+		log.Println("✓ No role assigned; grant access with:")
+		log.Printf("   ./bin/role assign %s admin", user.Username)
+		return
+	}
+
+	role, err := roleRepo.GetByName(ctx, *roleName)
+	if err != nil {
+		log.Fatalf("Role %q not found (run migrations first): %v", *roleName, err)
+	}
+	if err := roleRepo.AssignToUser(ctx, user.ID, role); err != nil {
+		log.Fatalf("Failed to assign role: %v", err)
+	}
+
+	log.Printf("✓ Assigned role: %s", role.Name)
 }
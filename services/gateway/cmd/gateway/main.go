@@ -13,12 +13,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"github.com/soheilhy/cmux"
-	"google.golang.org/grpc"
 
 	"github.com/kexi/telegram-bot-gateway/internal/config"
+	grpcapi "github.com/kexi/telegram-bot-gateway/internal/grpc"
 	"github.com/kexi/telegram-bot-gateway/internal/handler"
 	"github.com/kexi/telegram-bot-gateway/internal/middleware"
 	"github.com/kexi/telegram-bot-gateway/internal/pkg/apikey"
+	"github.com/kexi/telegram-bot-gateway/internal/pkg/encryption"
+	"github.com/kexi/telegram-bot-gateway/internal/pkg/blobstore"
 	"github.com/kexi/telegram-bot-gateway/internal/pkg/jwt"
 	"github.com/kexi/telegram-bot-gateway/internal/pubsub"
 	"github.com/kexi/telegram-bot-gateway/internal/repository"
@@ -76,6 +78,11 @@ func main() {
 		cfg.Auth.APIKey.Length,
 	)
 
+	tokenKeyring, err := encryption.NewKeyring(cfg.Encryption.PrimaryKeyID, cfg.Encryption.Keys, cfg.Encryption.LegacySecret)
+	if err != nil {
+		log.Fatalf("Failed to initialize token encryption: %v", err)
+	}
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
@@ -84,28 +91,63 @@ func main() {
 	messageRepo := repository.NewMessageRepository(db)
 	chatPermRepo := repository.NewChatPermissionRepository(db)
 	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	botPermRepo := repository.NewAPIKeyBotPermissionRepository(db)
+	feedbackPermRepo := repository.NewAPIKeyFeedbackPermissionRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
 	webhookRepo := repository.NewWebhookRepository(db)
 	webhookDeliveryRepo := repository.NewWebhookDeliveryRepository(db)
+	callbackQueryRepo := repository.NewCallbackQueryRepository(db)
+	updateEventRepo := repository.NewUpdateEventRepository(db)
+	mediaFileRepo := repository.NewMediaFileRepository(db)
+	outboundRepo := repository.NewOutboundMessageRepository(db)
+
+	// Initialize blob storage for received media files
+	mediaStore, err := newMediaStore(&cfg.Media)
+	if err != nil {
+		log.Fatalf("Failed to initialize media storage: %v", err)
+	}
+	log.Printf("✓ Media storage: %s", cfg.Media.Storage)
 
 	// Initialize message broker and real-time components
 	messageBroker := pubsub.NewMessageBroker(redisClient)
-	wsHub := websocket.NewHub(messageBroker)
+	wsHub := websocket.NewHub(messageBroker, chatPermRepo, redisClient)
 
 	// Initialize business services
 	authService := service.NewAuthService(userRepo, refreshTokenRepo, jwtService)
-	botService := service.NewBotService(botRepo, cfg.Auth.JWT.Secret, cfg.Telegram.WebhookBaseURL, redisClient)
+	botService := service.NewBotService(botRepo, tokenKeyring, cfg.Telegram.WebhookBaseURL, cfg.Telegram.APIBaseURL, redisClient)
 	chatService := service.NewChatService(chatRepo, botRepo)
 	messageService := service.NewMessageService(messageRepo, chatRepo)
+	floodControl := service.NewFloodControl(redisClient, service.FloodLimits{
+		BotMessagesPerSecond:   cfg.Outbound.BotMessagesPerSecond,
+		ChatInterval:           cfg.Outbound.ChatInterval.Duration(),
+		GroupMessagesPerMinute: cfg.Outbound.GroupMessagesPerMinute,
+	})
+	eventBuilder := service.NewEventBuilder(botRepo, chatRepo, messageRepo, mediaFileRepo, callbackQueryRepo, updateEventRepo)
+	outboundService := service.NewOutboundService(botService, messageService, outboundRepo, floodControl, service.SendPolicy{
+		MaxAttempts: cfg.Outbound.MaxAttempts,
+		MaxSyncWait: cfg.Outbound.MaxSyncWait.Duration(),
+	}, messageBroker, eventBuilder)
+	scheduleService := service.NewScheduleService(outboundService, outboundRepo, chatPermRepo, botPermRepo, apiKeyRepo, userRepo)
+	callbackService := service.NewCallbackQueryService(callbackQueryRepo, botService)
+	updateEventService := service.NewUpdateEventService(updateEventRepo)
+	mediaService := service.NewMediaService(mediaFileRepo, botService, mediaStore, cfg.Media.MaxFileSize)
 	// apiKeySvc removed - API key management moved to CLI tool
 	webhookService := service.NewWebhookService(webhookRepo, chatRepo)
+	webhookDispatcher := service.NewWebhookDispatcher(webhookRepo, webhookDeliveryRepo, chatPermRepo, feedbackPermRepo, messageBroker)
+	webhookSender := service.NewWebhookSender(webhookService, cfg.WebhookDelivery.Timeout.Duration())
+	webhookDeliveryService := service.NewWebhookDeliveryService(webhookDeliveryRepo, webhookRepo, webhookSender, messageBroker)
 
 	// Initialize handlers
 	authHandler := handler.NewAuthHandler(authService)
 	botHandler := handler.NewBotHandler(botService)
-	chatHandler := handler.NewChatHandler(chatService, messageService, chatRepo, botService)
+	chatHandler := handler.NewChatHandler(chatService, messageService, chatRepo, outboundService, updateEventService)
+	messageHandler := handler.NewMessageHandler(outboundService, messageService, mediaService, chatRepo, chatPermRepo, redisClient)
 	// apiKeyHandler removed - API key management moved to CLI tool
-	webhookHandler := handler.NewWebhookHandler(webhookService)
-	telegramHandler := handler.NewTelegramHandler(botService, chatService, messageService, messageBroker)
+	scheduleHandler := handler.NewScheduleHandler(scheduleService, chatRepo)
+	callbackHandler := handler.NewCallbackQueryHandler(callbackService, chatPermRepo, botPermRepo, redisClient)
+	webhookHandler := handler.NewWebhookHandler(webhookService, chatPermRepo, redisClient)
+	webhookDeliveryHandler := handler.NewWebhookDeliveryHandler(webhookDeliveryService)
+	telegramHandler := handler.NewTelegramHandler(botService, chatService, messageService, callbackService, updateEventService, mediaService, outboundService, chatRepo, messageBroker, webhookDispatcher, eventBuilder)
 	wsHandler := handler.NewWebSocketHandler(wsHub)
 
 	// Initialize rate limiter
@@ -116,6 +158,13 @@ func main() {
 		cfg.RateLimit.CleanupInterval.Duration(),
 	)
 
+	// Hourly read and send quotas of API keys and users
+	quotaLimiter := middleware.NewQuotaLimiter(rateLimiter, middleware.Quota{
+		Read: cfg.RateLimit.UserRequestsPerHour,
+		Send: cfg.RateLimit.UserSendsPerHour,
+	}, userRepo, redisClient)
+	usageHandler := handler.NewUsageHandler(quotaLimiter)
+
 	// Setup Gin router
 	router := gin.Default()
 
@@ -145,12 +194,24 @@ func main() {
 		}
 
 		// Protected endpoints (require authentication)
+		authenticate := middleware.AuthMiddleware(jwtService, apiKeyService, apiKeyRepo)
+
+		// Quota usage of the caller (does not count against the quota itself)
+		v1.GET("/usage", authenticate, middleware.RateLimitMiddleware(rateLimiter), usageHandler.GetUsage)
+
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(jwtService, apiKeyService, apiKeyRepo))
-		protected.Use(middleware.PerUserRateLimitMiddleware(rateLimiter))
+		protected.Use(authenticate)
+		protected.Use(middleware.PerUserRateLimitMiddleware(quotaLimiter))
 		{
+			// Role permissions of JWT users (manage with ./bin/role) and scopes of API keys (./bin/apikey)
+			requirePermission := func(permission string) gin.HandlerFunc {
+				return middleware.RequirePermission(permission, roleRepo, redisClient)
+			}
+			requireScope := middleware.RequireScope
+
 			// Bot management - READ-ONLY (write operations: ./bin/bot)
 			bots := protected.Group("/bots")
+			bots.Use(requirePermission("bots:read"), requireScope(middleware.ScopeBotsRead))
 			{
 				bots.GET("", botHandler.ListBots)
 				bots.GET("/:id", botHandler.GetBot)
@@ -159,18 +220,76 @@ func main() {
 			// Chat management
 			chats := protected.Group("/chats")
 			{
-				chats.GET("", chatHandler.ListChats)
-				chats.GET("/:id", chatHandler.GetChat)
+				readChats := requirePermission("chats:read")
+				readChatsScope := requireScope(middleware.ScopeChatsRead)
+				chats.GET("", readChats, readChatsScope, chatHandler.ListChats)
+				chats.GET("/:id", readChats, readChatsScope,
+					middleware.ChatACLMiddleware(middleware.PermissionRead, chatPermRepo, chatRepo, redisClient),
+					chatHandler.GetChat,
+				)
 
 				// Message endpoints with ACL
-				chats.GET("/:id/messages",
+				readMessages := requirePermission("messages:read")
+				readMessagesScope := requireScope(middleware.ScopeMessagesRead)
+				chats.GET("/:id/messages", readMessages, readMessagesScope,
 					middleware.ChatACLMiddleware(middleware.PermissionRead, chatPermRepo, chatRepo, redisClient),
 					chatHandler.GetMessages,
 				)
-				chats.POST("/:id/messages",
-					middleware.ChatACLMiddleware(middleware.PermissionSend, chatPermRepo, chatRepo, redisClient),
-					chatHandler.SendMessage,
+				chats.GET("/:id/events", readChats, readChatsScope,
+					middleware.ChatACLMiddleware(middleware.PermissionRead, chatPermRepo, chatRepo, redisClient),
+					chatHandler.GetEvents,
+				)
+				// Outbound operations also require the API key's grant for the chat's bot
+				sendMessages := requirePermission("messages:send")
+				sendMessagesScope := requireScope(middleware.ScopeMessagesSend)
+				sendACL := middleware.ChatACLMiddlewareWithBotCheck(middleware.PermissionSend, chatPermRepo, chatRepo, botPermRepo, redisClient)
+				chats.POST("/:id/messages", sendMessages, sendMessagesScope, sendACL, chatHandler.SendMessage)
+
+				// Rich outbound messages (JSON with URL/file_id, or multipart uploads)
+				chats.POST("/:id/photo", sendMessages, sendMessagesScope, sendACL, messageHandler.SendPhoto)
+				chats.POST("/:id/document", sendMessages, sendMessagesScope, sendACL, messageHandler.SendDocument)
+				chats.POST("/:id/voice", sendMessages, sendMessagesScope, sendACL, messageHandler.SendVoice)
+				chats.POST("/:id/location", sendMessages, sendMessagesScope, sendACL, messageHandler.SendLocation)
+				chats.POST("/:id/media-group", sendMessages, sendMessagesScope, sendACL, messageHandler.SendMediaGroup)
+
+				// Message moderation: edits need can_send, deletions and pins need can_manage
+				manageMessages := requirePermission("messages:manage")
+				manageMessagesScope := requireScope(middleware.ScopeMessagesManage)
+				manageACL := middleware.ChatACLMiddlewareWithBotCheck(middleware.PermissionManage, chatPermRepo, chatRepo, botPermRepo, redisClient)
+				chats.PUT("/:id/messages/:message_id", sendMessages, sendMessagesScope, sendACL, messageHandler.EditMessage)
+				chats.DELETE("/:id/messages/:message_id", manageMessages, manageMessagesScope, manageACL, messageHandler.DeleteMessage)
+				chats.GET("/:id/messages/:message_id/media", readMessages, readMessagesScope,
+					middleware.ChatACLMiddleware(middleware.PermissionRead, chatPermRepo, chatRepo, redisClient),
+					messageHandler.GetMessageMedia,
 				)
+				chats.GET("/:id/messages/:message_id/edits", readMessages, readMessagesScope,
+					middleware.ChatACLMiddleware(middleware.PermissionRead, chatPermRepo, chatRepo, redisClient),
+					messageHandler.GetMessageEdits,
+				)
+				chats.POST("/:id/messages/:message_id/pin", manageMessages, manageMessagesScope, manageACL, messageHandler.PinMessage)
+				chats.DELETE("/:id/messages/:message_id/pin", manageMessages, manageMessagesScope, manageACL, messageHandler.UnpinMessage)
+				chats.POST("/:id/forward", sendMessages, sendMessagesScope, sendACL, messageHandler.ForwardMessage)
+				chats.POST("/:id/copy", sendMessages, sendMessagesScope, sendACL, messageHandler.CopyMessage)
+			}
+
+			// Delivery status of messages sent with "async": true or "send_at"
+			protected.GET("/outbound/:id", requirePermission("messages:send"), requireScope(middleware.ScopeMessagesSend), messageHandler.GetOutboundMessage)
+
+			// Messages scheduled with "send_at" by the caller
+			scheduled := protected.Group("/scheduled-messages")
+			scheduled.Use(requirePermission("messages:send"), requireScope(middleware.ScopeMessagesSend))
+			{
+				scheduled.GET("", scheduleHandler.ListScheduledMessages)
+				scheduled.GET("/:id", scheduleHandler.GetScheduledMessage)
+				scheduled.PATCH("/:id", scheduleHandler.RescheduleMessage)
+				scheduled.DELETE("/:id", scheduleHandler.CancelScheduledMessage)
+			}
+
+			// Inline keyboard callback queries
+			callbackQueries := protected.Group("/callback-queries")
+			{
+				callbackQueries.GET("/:query_id", requirePermission("messages:read"), requireScope(middleware.ScopeMessagesRead), callbackHandler.GetCallbackQuery)
+				callbackQueries.POST("/:query_id/answer", requirePermission("messages:send"), requireScope(middleware.ScopeMessagesSend), callbackHandler.AnswerCallbackQuery)
 			}
 
 			// API key management - DISABLED (use CLI: ./bin/apikey)
@@ -185,16 +304,37 @@ func main() {
 
 			// Webhook management
 			webhooks := protected.Group("/webhooks")
+			webhooks.Use(requireScope(middleware.ScopeWebhooksManage))
 			{
-				webhooks.POST("", webhookHandler.CreateWebhook)
-				webhooks.GET("", webhookHandler.ListWebhooks)
-				webhooks.GET("/:id", webhookHandler.GetWebhook)
-				webhooks.PUT("/:id", webhookHandler.UpdateWebhook)
-				webhooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+				webhooks.POST("", requirePermission("webhooks:create"), webhookHandler.CreateWebhook)
+				webhooks.GET("", requirePermission("webhooks:read"), webhookHandler.ListWebhooks)
+				webhooks.GET("/:id", requirePermission("webhooks:read"), webhookHandler.GetWebhook)
+				webhooks.PUT("/:id", requirePermission("webhooks:update"), webhookHandler.UpdateWebhook)
+				webhooks.DELETE("/:id", requirePermission("webhooks:delete"), webhookHandler.DeleteWebhook)
+				webhooks.GET("/:id/deliveries", requirePermission("webhooks:read"), webhookDeliveryHandler.ListDeliveries)
+				webhooks.GET("/:id/health", requirePermission("webhooks:read"), webhookDeliveryHandler.GetWebhookHealth)
+				webhooks.POST("/:id/test", requirePermission("webhooks:update"), webhookDeliveryHandler.TestWebhook)
+				webhooks.POST("/:id/rotate-secret", requirePermission("webhooks:update"), webhookHandler.RotateWebhookSecret)
+
+				// Deliveries whose retries were used up
+				webhooks.GET("/dead-letters", requirePermission("webhooks:read"), webhookDeliveryHandler.ListDeadLetters)
+				webhooks.GET("/dead-letters/:id", requirePermission("webhooks:read"), webhookDeliveryHandler.GetDeadLetter)
+				webhooks.POST("/dead-letters/replay", requirePermission("webhooks:update"), webhookDeliveryHandler.ReplayDeadLetters)
+				webhooks.POST("/dead-letters/:id/replay", requirePermission("webhooks:update"), webhookDeliveryHandler.ReplayDeadLetter)
+			}
+
+			// Feedback channel of the calling API key
+			feedback := protected.Group("/feedback")
+			feedback.Use(requireScope(middleware.ScopeWebhooksManage))
+			{
+				feedback.GET("/webhook", webhookHandler.GetFeedbackWebhook)
+				feedback.PUT("/webhook", webhookHandler.SetFeedbackWebhook)
+				feedback.DELETE("/webhook", webhookHandler.DeleteFeedbackWebhook)
+				feedback.POST("/webhook/rotate-secret", webhookHandler.RotateFeedbackWebhookSecret)
 			}
 
 			// WebSocket endpoint
-			protected.GET("/ws", wsHandler.HandleWebSocket)
+			protected.GET("/ws", requirePermission("messages:read"), requireScope(middleware.ScopeWSSubscribe), wsHandler.HandleWebSocket)
 		}
 
 		// Telegram webhook receiver (no auth - validated by webhook secret)
@@ -210,19 +350,56 @@ func main() {
 	log.Println("✓ WebSocket hub started")
 
 	// Start webhook workers
+	circuitBreakers := worker.NewCircuitBreakers(messageBroker)
 	for i := 0; i < cfg.WebhookDelivery.WorkerCount; i++ {
 		webhookWorker := worker.NewWebhookWorker(
 			i+1,
 			messageBroker,
 			webhookService,
+			webhookSender,
 			messageService,
+			eventBuilder,
 			webhookDeliveryRepo,
+			circuitBreakers,
 			cfg.WebhookDelivery.MaxRetries,
 		)
 		go webhookWorker.Start(workerCtx)
 	}
 	log.Printf("✓ Started %d webhook workers", cfg.WebhookDelivery.WorkerCount)
 
+	// Start webhook retry scheduler for failed deliveries
+	// Pending deliveries untouched for longer than an attempt can take are recovered as lost
+	webhookRetryScheduler := worker.NewWebhookRetryScheduler(
+		messageBroker,
+		webhookDeliveryRepo,
+		cfg.WebhookDelivery.RetryPollInterval.Duration(),
+		cfg.WebhookDelivery.Timeout.Duration()+time.Minute,
+	)
+	go webhookRetryScheduler.Start(workerCtx)
+	log.Println("✓ Webhook retry scheduler started")
+
+	// Start getUpdates poller for bots in polling mode
+	updatePoller := worker.NewUpdatePoller(botService, telegramHandler, cfg.Telegram.PollingTimeout.Duration())
+	go updatePoller.Start(workerCtx)
+	log.Println("✓ Update poller started")
+
+	// Start media worker
+	mediaWorker := worker.NewMediaWorker(mediaService)
+	go mediaWorker.Start(workerCtx)
+	log.Println("✓ Media worker started")
+
+	// Start outbound workers for queued sends
+	for i := 0; i < cfg.Outbound.WorkerCount; i++ {
+		outboundWorker := worker.NewOutboundWorker(outboundService, cfg.Outbound.PollInterval.Duration(), cfg.Outbound.BatchSize)
+		go outboundWorker.Start(workerCtx)
+	}
+	log.Printf("✓ Started %d outbound workers", cfg.Outbound.WorkerCount)
+
+	// Start schedule worker for messages sent with send_at
+	scheduleWorker := worker.NewScheduleWorker(scheduleService, cfg.Outbound.PollInterval.Duration(), cfg.Outbound.BatchSize)
+	go scheduleWorker.Start(workerCtx)
+	log.Println("✓ Schedule worker started")
+
 	// Create HTTP server
 	httpServer := &http.Server{
 		Addr:         cfg.Server.HTTP.Address,
@@ -232,9 +409,23 @@ func main() {
 		IdleTimeout:  cfg.Server.HTTP.IdleTimeout.Duration(),
 	}
 
-	// Create gRPC server (placeholder - will be implemented when needed)
-	grpcServer := grpc.NewServer()
-	// TODO: Register gRPC services here when implementing gRPC API
+	// Create gRPC server with the message, chat and bot services registered
+	grpcServer := grpcapi.NewServer(
+		cfg.Server.GRPC.Address,
+		jwtService,
+		apiKeyService,
+		apiKeyRepo,
+		messageService,
+		chatService,
+		botService,
+		outboundService,
+		callbackService,
+		chatRepo,
+		chatPermRepo,
+		botPermRepo,
+		messageBroker,
+		redisClient,
+	)
 
 	// Start servers
 	if cfg.Server.UseSharedPort {
@@ -324,11 +515,27 @@ func main() {
 	}
 
 	// Shutdown gRPC server
-	grpcServer.GracefulStop()
+	grpcServer.Stop()
 
 	log.Println("✓ Servers stopped gracefully")
 }
 
+// newMediaStore creates the blob store configured for media files
+func newMediaStore(cfg *config.MediaConfig) (blobstore.Store, error) {
+	if cfg.Storage == "s3" {
+		return blobstore.NewS3Store(blobstore.S3Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Region:          cfg.S3.Region,
+			Bucket:          cfg.S3.Bucket,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+			UsePathStyle:    cfg.S3.UsePathStyle,
+			Prefix:          cfg.S3.Prefix,
+		})
+	}
+	return blobstore.NewLocalStore(cfg.LocalPath)
+}
+
 // initDefaultUser creates a default admin user if none exists
 func initDefaultUser(authService *service.AuthService) {
 	ctx := context.Background()
@@ -42,6 +42,22 @@ func main() {
 		migrations := []string{
 			"migrations/001_initial_schema.sql",
 			"migrations/003_bot_webhook_secret.sql",
+			"migrations/004_webhook_delivery_event_type.sql",
+			"migrations/005_bot_ingestion_mode.sql",
+			"migrations/006_message_edits.sql",
+			"migrations/007_callback_queries.sql",
+			"migrations/008_update_events.sql",
+			"migrations/009_media_files.sql",
+			"migrations/010_feedback_webhooks.sql",
+			"migrations/011_rbac_permissions.sql",
+			"migrations/012_rate_limit_quotas.sql",
+			"migrations/013_outbound_messages.sql",
+			"migrations/014_scheduled_messages.sql",
+			"migrations/015_webhook_dead_letters.sql",
+			"migrations/016_webhook_health.sql",
+			"migrations/017_webhook_secret_rotation.sql",
+			"migrations/018_webhook_event_envelope.sql",
+			"migrations/019_api_key_all_bots.sql",
 		}
 		for _, migration := range migrations {
 			if _, err := os.Stat(migration); os.IsNotExist(err) {
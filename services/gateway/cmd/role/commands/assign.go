@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"fmt"
+)
+
+const assignUsage = `Assign a role to a user
+
+Usage:
+  role assign <username> <role>
+
+Arguments:
+  <username>                Username
+  <role>                    Role name
+
+Options:
+  --help, -h                Show this help message
+
+Examples:
+  role assign alice operator
+
+Note: Role names in existing access tokens are refreshed on the next token refresh;
+      permissions apply immediately.
+`
+
+func AssignRole(args []string) {
+	if hasFlag(args, "--help", "-h") || len(args) < 2 {
+		fmt.Print(assignUsage)
+		return
+	}
+
+	roleRepo, userRepo, closeDB := initRepositories()
+	defer closeDB()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	user, err := userRepo.GetByUsername(ctx, args[0])
+	if err != nil {
+		fatal("User not found: %s", args[0])
+	}
+	role := getRole(ctx, roleRepo, args[1])
+
+	if err := roleRepo.AssignToUser(ctx, user.ID, role); err != nil {
+		fatal("Failed to assign role: %v", err)
+	}
+
+	notifyUserPermissionChange(ctx, user.ID)
+
+	success("Assigned role %s to user %s", role.Name, user.Username)
+}
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/kexi/telegram-bot-gateway/internal/config"
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// loadConfig loads the gateway configuration
+func loadConfig() (*config.Config, error) {
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		configPath = "configs/config.json"
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// initDB initializes the database connection
+func initDB() (*gorm.DB, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := repository.NewDatabase(&cfg.Database, "release")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return db, nil
+}
+
+// initRepositories connects to the database and returns the role and user repositories
+// with a function closing the connection
+func initRepositories() (repository.RoleRepository, repository.UserRepository, func()) {
+	db, err := initDB()
+	if err != nil {
+		fatal("Failed to initialize database: %v", err)
+	}
+
+	closeDB := func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}
+	return repository.NewRoleRepository(db), repository.NewUserRepository(db), closeDB
+}
+
+// initRedis initializes the Redis connection
+func initRedis(ctx context.Context) (*redis.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Address,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		redisClient.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return redisClient, nil
+}
+
+// notifyUserPermissionChange clears the cached permissions of users so running gateways
+// apply role changes immediately. Failures are reported as warnings since the database
+// change has already been made.
+func notifyUserPermissionChange(ctx context.Context, userIDs ...uint) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	redisClient, err := initRedis(ctx)
+	if err != nil {
+		info("Warning: %v (cached permissions expire within 5 minutes)", err)
+		return
+	}
+	defer redisClient.Close()
+
+	if err := middleware.InvalidateUserPermissionCache(ctx, redisClient, userIDs...); err != nil {
+		info("Warning: failed to clear permission cache: %v", err)
+	}
+}
+
+// getRole loads a role by name or exits
+func getRole(ctx context.Context, roleRepo repository.RoleRepository, name string) *domain.Role {
+	role, err := roleRepo.GetByName(ctx, name)
+	if err != nil {
+		fatal("Role not found: %s", name)
+	}
+	return role
+}
+
+// getPermissions loads permissions by name or exits. Names may be comma-separated.
+func getPermissions(ctx context.Context, roleRepo repository.RoleRepository, names []string) []*domain.Permission {
+	var permissions []*domain.Permission
+	for _, arg := range names {
+		for _, name := range strings.Split(arg, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			permission, err := roleRepo.GetPermissionByName(ctx, name)
+			if err != nil {
+				fatal("Permission not found: %s (see 'role permissions')", name)
+			}
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
+
+// permissionNames returns the names of a role's permissions
+func permissionNames(role *domain.Role) string {
+	if len(role.Permissions) == 0 {
+		return "(none)"
+	}
+
+	names := make([]string, len(role.Permissions))
+	for i, permission := range role.Permissions {
+		names[i] = permission.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// getContext returns a context with timeout
+func getContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
+// fatal logs an error and exits
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// success prints a success message
+func success(format string, args ...interface{}) {
+	fmt.Printf("✓ "+format+"\n", args...)
+}
+
+// info prints an info message
+func info(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
+
+// hasFlag checks if a flag is present in args
+func hasFlag(args []string, flag ...string) bool {
+	for _, arg := range args {
+		for _, f := range flag {
+			if arg == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getFlagValue gets the value of a flag
+func getFlagValue(args []string, flag ...string) string {
+	for i, arg := range args {
+		for _, f := range flag {
+			if arg == f && i+1 < len(args) {
+				return args[i+1]
+			}
+		}
+	}
+	return ""
+}
+
+func init() {
+	// Disable log timestamps for cleaner output
+	log.SetFlags(0)
+}
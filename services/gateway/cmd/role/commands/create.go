@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+)
+
+const createUsage = `Create a role
+
+Usage:
+  role create <name> [options]
+
+Arguments:
+  <name>                    Role name
+
+Options:
+  --description <text>      Description
+  --permissions <list>      Comma-separated permissions to grant
+  --help, -h                Show this help message
+
+Examples:
+  role create sender --description "Send messages" --permissions messages:send,chats:read
+`
+
+func CreateRole(args []string) {
+	if hasFlag(args, "--help", "-h") || len(args) < 1 {
+		fmt.Print(createUsage)
+		return
+	}
+
+	roleRepo, _, closeDB := initRepositories()
+	defer closeDB()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	var permissions []*domain.Permission
+	if list := getFlagValue(args, "--permissions"); list != "" {
+		permissions = getPermissions(ctx, roleRepo, []string{list})
+	}
+
+	role := &domain.Role{
+		Name:        args[0],
+		Description: getFlagValue(args, "--description"),
+	}
+	if err := roleRepo.Create(ctx, role); err != nil {
+		fatal("Failed to create role: %v", err)
+	}
+
+	for _, permission := range permissions {
+		if err := roleRepo.AddPermission(ctx, role, permission); err != nil {
+			fatal("Failed to grant %s: %v", permission.Name, err)
+		}
+	}
+
+	success("Created role %s (ID: %d) with %d permissions", role.Name, role.ID, len(permissions))
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+)
+
+const deleteUsage = `Delete a role
+
+Usage:
+  role delete <name> --force
+
+Arguments:
+  <name>                    Role name
+
+Options:
+  --force                   Required to confirm deletion
+  --help, -h                Show this help message
+
+Note: Users holding the role lose its permissions immediately.
+`
+
+func DeleteRole(args []string) {
+	if hasFlag(args, "--help", "-h") || len(args) < 1 {
+		fmt.Print(deleteUsage)
+		return
+	}
+
+	if !hasFlag(args, "--force") {
+		fatal("Deleting a role requires --force")
+	}
+
+	roleRepo, _, closeDB := initRepositories()
+	defer closeDB()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	role := getRole(ctx, roleRepo, args[0])
+
+	userIDs, err := roleRepo.ListUserIDs(ctx, role.ID)
+	if err != nil {
+		fatal("Failed to list users of role: %v", err)
+	}
+
+	if err := roleRepo.Delete(ctx, role.ID); err != nil {
+		fatal("Failed to delete role: %v", err)
+	}
+
+	notifyUserPermissionChange(ctx, userIDs...)
+
+	success("Deleted role %s (%d users affected)", role.Name, len(userIDs))
+}
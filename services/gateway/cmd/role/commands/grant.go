@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+)
+
+const grantUsage = `Add permissions to a role
+
+Usage:
+  role grant <role> <permission>...
+
+Arguments:
+  <role>                    Role name
+  <permission>              Permission name, e.g. messages:send (see 'role permissions')
+
+Options:
+  --help, -h                Show this help message
+
+Examples:
+  role grant operator messages:manage
+  role grant auditor chats:read messages:read
+`
+
+func GrantPermissions(args []string) {
+	if hasFlag(args, "--help", "-h") || len(args) < 2 {
+		fmt.Print(grantUsage)
+		return
+	}
+
+	roleRepo, _, closeDB := initRepositories()
+	defer closeDB()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	role := getRole(ctx, roleRepo, args[0])
+	permissions := getPermissions(ctx, roleRepo, args[1:])
+
+	for _, permission := range permissions {
+		if err := roleRepo.AddPermission(ctx, role, permission); err != nil {
+			fatal("Failed to grant %s: %v", permission.Name, err)
+		}
+		success("Granted %s to role %s", permission.Name, role.Name)
+	}
+
+	userIDs, err := roleRepo.ListUserIDs(ctx, role.ID)
+	if err != nil {
+		info("Warning: failed to list users of role: %v (cached permissions expire within 5 minutes)", err)
+		return
+	}
+	notifyUserPermissionChange(ctx, userIDs...)
+}
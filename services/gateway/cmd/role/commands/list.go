@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"fmt"
+)
+
+const listUsage = `List roles and their permissions
+
+Usage:
+  role list
+
+Options:
+  --help, -h                Show this help message
+`
+
+func ListRoles(args []string) {
+	if hasFlag(args, "--help", "-h") {
+		fmt.Print(listUsage)
+		return
+	}
+
+	roleRepo, _, closeDB := initRepositories()
+	defer closeDB()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	roles, err := roleRepo.List(ctx)
+	if err != nil {
+		fatal("Failed to list roles: %v", err)
+	}
+
+	if len(roles) == 0 {
+		info("No roles found")
+		return
+	}
+
+	fmt.Println()
+	for _, role := range roles {
+		info("%s (ID: %d)", role.Name, role.ID)
+		if role.Description != "" {
+			info("  %s", role.Description)
+		}
+		info("  Permissions: %s", permissionNames(&role))
+		fmt.Println()
+	}
+}
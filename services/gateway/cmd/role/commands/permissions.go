@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"fmt"
+)
+
+const permissionsUsage = `List all permissions that can be granted to roles
+
+Usage:
+  role permissions
+
+Options:
+  --help, -h                Show this help message
+`
+
+func ListPermissions(args []string) {
+	if hasFlag(args, "--help", "-h") {
+		fmt.Print(permissionsUsage)
+		return
+	}
+
+	roleRepo, _, closeDB := initRepositories()
+	defer closeDB()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	permissions, err := roleRepo.ListPermissions(ctx)
+	if err != nil {
+		fatal("Failed to list permissions: %v", err)
+	}
+
+	fmt.Println("\nPermission           | Description")
+	fmt.Println("---------------------|----------------------------------------")
+	for _, permission := range permissions {
+		fmt.Printf("%-20s | %s\n", permission.Name, permission.Description)
+	}
+	fmt.Println()
+}
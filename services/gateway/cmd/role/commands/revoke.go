@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"fmt"
+)
+
+const revokeUsage = `Remove permissions from a role
+
+Usage:
+  role revoke <role> <permission>...
+
+Arguments:
+  <role>                    Role name
+  <permission>              Permission name, e.g. messages:send
+
+Options:
+  --help, -h                Show this help message
+
+Examples:
+  role revoke operator messages:manage
+`
+
+func RevokePermissions(args []string) {
+	if hasFlag(args, "--help", "-h") || len(args) < 2 {
+		fmt.Print(revokeUsage)
+		return
+	}
+
+	roleRepo, _, closeDB := initRepositories()
+	defer closeDB()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	role := getRole(ctx, roleRepo, args[0])
+	permissions := getPermissions(ctx, roleRepo, args[1:])
+
+	for _, permission := range permissions {
+		if err := roleRepo.RemovePermission(ctx, role, permission); err != nil {
+			fatal("Failed to revoke %s: %v", permission.Name, err)
+		}
+		success("Revoked %s from role %s", permission.Name, role.Name)
+	}
+
+	userIDs, err := roleRepo.ListUserIDs(ctx, role.ID)
+	if err != nil {
+		info("Warning: failed to list users of role: %v (cached permissions expire within 5 minutes)", err)
+		return
+	}
+	notifyUserPermissionChange(ctx, userIDs...)
+}
@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
+)
+
+const setQuotaUsage = `Set the hourly request quotas of a user
+
+Usage:
+  role set-quota <username> [options]
+
+Arguments:
+  <username>                Username
+
+Options:
+  --rate-limit <n>          Reads (GET requests) per hour, 0 for the configured default
+  --send-rate-limit <n>     Sends (all other requests) per hour, 0 for the configured default
+  --help, -h                Show this help message
+
+Examples:
+  role set-quota alice --rate-limit 5000 --send-rate-limit 500
+  role set-quota alice --send-rate-limit 0
+
+Defaults come from rate_limit.user_requests_per_hour and rate_limit.user_sends_per_hour.
+`
+
+func SetQuota(args []string) {
+	if hasFlag(args, "--help", "-h") || len(args) < 1 {
+		fmt.Print(setQuotaUsage)
+		return
+	}
+
+	rateLimitStr := getFlagValue(args, "--rate-limit")
+	sendRateLimitStr := getFlagValue(args, "--send-rate-limit")
+	if rateLimitStr == "" && sendRateLimitStr == "" {
+		fatal("Nothing to update\n\n%s", setQuotaUsage)
+	}
+
+	_, userRepo, closeDB := initRepositories()
+	defer closeDB()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	user, err := userRepo.GetByUsername(ctx, args[0])
+	if err != nil {
+		fatal("User not found: %s", args[0])
+	}
+
+	rateLimit := parseQuota(rateLimitStr, user.RateLimit)
+	sendRateLimit := parseQuota(sendRateLimitStr, user.SendRateLimit)
+
+	if err := userRepo.UpdateQuota(ctx, user.ID, rateLimit, sendRateLimit); err != nil {
+		fatal("Failed to update quota: %v", err)
+	}
+
+	// Running gateways cache quotas for 5 minutes
+	if redisClient, err := initRedis(ctx); err != nil {
+		info("Warning: %v (cached quotas expire within 5 minutes)", err)
+	} else {
+		if err := middleware.InvalidateUserQuotaCache(ctx, redisClient, user.ID); err != nil {
+			info("Warning: failed to clear quota cache: %v", err)
+		}
+		redisClient.Close()
+	}
+
+	success("Updated quota of user %s", user.Username)
+	info("  Reads: %s", describeQuota(rateLimit))
+	info("  Sends: %s", describeQuota(sendRateLimit))
+}
+
+// parseQuota parses a quota flag value, keeping current when the flag is not given
+func parseQuota(value string, current int) int {
+	if value == "" {
+		return current
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		fatal("Invalid quota: %s", value)
+	}
+	return n
+}
+
+// describeQuota formats an hourly quota of a user
+func describeQuota(limit int) string {
+	if limit == 0 {
+		return "configured default"
+	}
+	return fmt.Sprintf("%d requests/hour", limit)
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+const showUserUsage = `Show the roles, permissions and quotas of a user
+
+Usage:
+  role show-user <username>
+
+Arguments:
+  <username>                Username
+
+Options:
+  --help, -h                Show this help message
+`
+
+func ShowUser(args []string) {
+	if hasFlag(args, "--help", "-h") || len(args) < 1 {
+		fmt.Print(showUserUsage)
+		return
+	}
+
+	roleRepo, userRepo, closeDB := initRepositories()
+	defer closeDB()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	user, err := userRepo.GetByUsername(ctx, args[0])
+	if err != nil {
+		fatal("User not found: %s", args[0])
+	}
+
+	user, err = userRepo.WithRoles(ctx, user.ID)
+	if err != nil {
+		fatal("Failed to load roles: %v", err)
+	}
+
+	permissions, err := roleRepo.ListUserPermissions(ctx, user.ID)
+	if err != nil {
+		fatal("Failed to load permissions: %v", err)
+	}
+
+	info("\nUser: %s (ID: %d)", user.Username, user.ID)
+	if len(user.Roles) == 0 {
+		info("Roles: None (no access to role-protected endpoints)")
+	} else {
+		info("Roles:")
+		for _, role := range user.Roles {
+			info("  - %s", role.Name)
+		}
+	}
+
+	if len(permissions) > 0 {
+		info("Permissions: %s", strings.Join(permissions, ", "))
+	}
+	info("Quota: reads %s, sends %s", describeQuota(user.RateLimit), describeQuota(user.SendRateLimit))
+	fmt.Println()
+}
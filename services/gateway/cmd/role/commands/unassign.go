@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+)
+
+const unassignUsage = `Remove a role from a user
+
+Usage:
+  role unassign <username> <role>
+
+Arguments:
+  <username>                Username
+  <role>                    Role name
+
+Options:
+  --help, -h                Show this help message
+
+Examples:
+  role unassign alice operator
+`
+
+func UnassignRole(args []string) {
+	if hasFlag(args, "--help", "-h") || len(args) < 2 {
+		fmt.Print(unassignUsage)
+		return
+	}
+
+	roleRepo, userRepo, closeDB := initRepositories()
+	defer closeDB()
+
+	ctx, cancel := getContext()
+	defer cancel()
+
+	user, err := userRepo.GetByUsername(ctx, args[0])
+	if err != nil {
+		fatal("User not found: %s", args[0])
+	}
+	role := getRole(ctx, roleRepo, args[1])
+
+	if err := roleRepo.RemoveFromUser(ctx, user.ID, role); err != nil {
+		fatal("Failed to remove role: %v", err)
+	}
+
+	notifyUserPermissionChange(ctx, user.ID)
+
+	success("Removed role %s from user %s", role.Name, user.Username)
+}
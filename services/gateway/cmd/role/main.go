@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kexi/telegram-bot-gateway/cmd/role/commands"
+)
+
+const usage = `Role Management CLI
+
+Roles grant permissions such as "messages:send" or "chats:read" to users logged in
+with a JWT. API keys are authorized by their chat and bot grants (./bin/apikey).
+
+Usage:
+  role <command> [arguments]
+
+Commands:
+  list                              List roles and their permissions
+  permissions                       List all permissions
+  create <name>                     Create a role
+  delete <name>                     Delete a role
+
+  grant <role> <permission>...      Add permissions to a role
+  revoke <role> <permission>...     Remove permissions from a role
+
+  assign <username> <role>          Assign a role to a user
+  unassign <username> <role>        Remove a role from a user
+  show-user <username>              Show the roles, permissions and quotas of a user
+  set-quota <username> [options]    Set the hourly read and send quotas of a user
+
+Options:
+  --help, -h              Show this help message
+
+Examples:
+  # Create a role that may only send messages
+  role create sender --description "Send messages" --permissions messages:send,chats:read
+
+  # Let operators delete and pin messages
+  role grant operator messages:manage
+
+  # Make alice an operator
+  role assign alice operator
+
+  # Let alice send at most 500 requests per hour
+  role set-quota alice --send-rate-limit 500
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(1)
+	}
+
+	command := os.Args[1]
+
+	switch command {
+	case "list", "ls":
+		commands.ListRoles(os.Args[2:])
+	case "permissions":
+		commands.ListPermissions(os.Args[2:])
+	case "create":
+		commands.CreateRole(os.Args[2:])
+	case "delete", "rm":
+		commands.DeleteRole(os.Args[2:])
+	case "grant":
+		commands.GrantPermissions(os.Args[2:])
+	case "revoke":
+		commands.RevokePermissions(os.Args[2:])
+	case "assign":
+		commands.AssignRole(os.Args[2:])
+	case "unassign":
+		commands.UnassignRole(os.Args[2:])
+	case "show-user":
+		commands.ShowUser(os.Args[2:])
+	case "set-quota":
+		commands.SetQuota(os.Args[2:])
+	case "help", "--help", "-h":
+		fmt.Print(usage)
+	default:
+		fmt.Printf("Unknown command: %s\n\n", command)
+		fmt.Print(usage)
+		os.Exit(1)
+	}
+}
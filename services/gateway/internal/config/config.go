@@ -50,9 +50,12 @@ type Config struct {
 	Database        DatabaseConfig        `json:"database"`
 	Redis           RedisConfig           `json:"redis"`
 	Auth            AuthConfig            `json:"auth"`
+	Encryption      EncryptionConfig      `json:"encryption"`
 	Telegram        TelegramConfig        `json:"telegram"`
 	WebhookDelivery WebhookDeliveryConfig `json:"webhook_delivery"`
 	RateLimit       RateLimitConfig       `json:"rate_limit"`
+	Media           MediaConfig           `json:"media"`
+	Outbound        OutboundConfig        `json:"outbound"`
 }
 
 // ServerConfig holds server configuration
@@ -118,25 +121,70 @@ type APIKeyConfig struct {
 	Length int    `json:"length"` // Length of random part (default: 32)
 }
 
+// EncryptionConfig holds the keys that encrypt bot tokens at rest.
+// Add a key and make it primary to rotate, then run "bot rotate-encryption".
+type EncryptionConfig struct {
+	PrimaryKeyID  string            `json:"primary_key_id"` // Key new tokens are encrypted under
+	Keys          map[string]string `json:"keys"`           // Key ID -> base64-encoded 32-byte key
+	LegacySecret  string            `json:"legacy_secret"`  // Decrypts tokens stored without a key ID; defaults to auth.jwt.secret
+	DisableLegacy bool              `json:"disable_legacy"` // Stop decrypting tokens without a key ID once they have been re-encrypted
+}
+
 // TelegramConfig holds Telegram API settings
 type TelegramConfig struct {
-	WebhookBaseURL string   `json:"webhook_base_url"` // e.g., "https://your-domain.com"
+	WebhookBaseURL string   `json:"webhook_base_url"` // e.g., "https://your-domain.com"; required for bots in webhook mode
+	APIBaseURL     string   `json:"api_base_url"`     // Bot API endpoint, defaults to "https://api.telegram.org"
 	Timeout        Duration `json:"timeout"`
+	PollingTimeout Duration `json:"polling_timeout"` // getUpdates long-poll timeout for bots in polling mode
 }
 
 // WebhookDeliveryConfig holds webhook worker settings
 type WebhookDeliveryConfig struct {
-	WorkerCount int      `json:"worker_count"`
-	MaxRetries  int      `json:"max_retries"`
-	Timeout     Duration `json:"timeout"`
-	QueueName   string   `json:"queue_name"`
+	WorkerCount       int      `json:"worker_count"`
+	MaxRetries        int      `json:"max_retries"`
+	Timeout           Duration `json:"timeout"`
+	QueueName         string   `json:"queue_name"`
+	RetryPollInterval Duration `json:"retry_poll_interval"` // How often failed deliveries whose backoff has passed are queued again
 }
 
 // RateLimitConfig holds rate limiting settings
 type RateLimitConfig struct {
-	RequestsPerSecond int      `json:"requests_per_second"`
-	Burst             int      `json:"burst"`
-	CleanupInterval   Duration `json:"cleanup_interval"`
+	RequestsPerSecond   int      `json:"requests_per_second"`    // Per client on public auth endpoints
+	Burst               int      `json:"burst"`
+	CleanupInterval     Duration `json:"cleanup_interval"`
+	UserRequestsPerHour int      `json:"user_requests_per_hour"` // Read quota of users without their own
+	UserSendsPerHour    int      `json:"user_sends_per_hour"`    // Send quota of users without their own
+}
+
+// MediaConfig holds settings for storing files received by bots
+type MediaConfig struct {
+	Storage     string        `json:"storage"`       // "local" or "s3"
+	MaxFileSize int64         `json:"max_file_size"` // Bytes; the Bot API only serves files up to 20 MB
+	LocalPath   string        `json:"local_path"`    // Directory for "local" storage
+	S3          S3MediaConfig `json:"s3"`
+}
+
+// S3MediaConfig holds the settings of an S3-compatible bucket
+type S3MediaConfig struct {
+	Endpoint        string `json:"endpoint"` // Defaults to AWS S3 in Region
+	Region          string `json:"region"`
+	Bucket          string `json:"bucket"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	UsePathStyle    bool   `json:"use_path_style"` // Required by MinIO and most self-hosted stores
+	Prefix          string `json:"prefix"`         // Prepended to every object key
+}
+
+// OutboundConfig holds settings for sending messages through the Bot API
+type OutboundConfig struct {
+	WorkerCount            int      `json:"worker_count"`              // Workers delivering queued sends
+	PollInterval           Duration `json:"poll_interval"`             // How often each worker checks the queue
+	BatchSize              int      `json:"batch_size"`                // Sends claimed per poll
+	MaxAttempts            int      `json:"max_attempts"`              // Bot API calls per send before transient failures give up
+	MaxSyncWait            Duration `json:"max_sync_wait"`             // Longest a synchronous send waits for flood control or a retry
+	BotMessagesPerSecond   int      `json:"bot_messages_per_second"`   // Telegram allows about 30
+	ChatInterval           Duration `json:"chat_interval"`             // Telegram allows about one message per second per chat
+	GroupMessagesPerMinute int      `json:"group_messages_per_minute"` // Telegram allows about 20
 }
 
 // Load loads configuration from a JSON file
@@ -253,9 +301,21 @@ func (c *Config) setDefaults() {
 		c.Auth.APIKey.Length = 32
 	}
 
+	if c.Encryption.DisableLegacy {
+		c.Encryption.LegacySecret = ""
+	} else if c.Encryption.LegacySecret == "" {
+		c.Encryption.LegacySecret = c.Auth.JWT.Secret
+	}
+
+	if c.Telegram.APIBaseURL == "" {
+		c.Telegram.APIBaseURL = "https://api.telegram.org"
+	}
 	if c.Telegram.Timeout == 0 {
 		c.Telegram.Timeout = Duration(30 * time.Second)
 	}
+	if c.Telegram.PollingTimeout == 0 {
+		c.Telegram.PollingTimeout = Duration(30 * time.Second)
+	}
 
 	if c.WebhookDelivery.WorkerCount == 0 {
 		c.WebhookDelivery.WorkerCount = 10
@@ -269,6 +329,9 @@ func (c *Config) setDefaults() {
 	if c.WebhookDelivery.QueueName == "" {
 		c.WebhookDelivery.QueueName = "webhook_deliveries"
 	}
+	if c.WebhookDelivery.RetryPollInterval == 0 {
+		c.WebhookDelivery.RetryPollInterval = Duration(time.Second)
+	}
 
 	if c.RateLimit.RequestsPerSecond == 0 {
 		c.RateLimit.RequestsPerSecond = 100
@@ -279,6 +342,47 @@ func (c *Config) setDefaults() {
 	if c.RateLimit.CleanupInterval == 0 {
 		c.RateLimit.CleanupInterval = Duration(1 * time.Minute)
 	}
+	if c.RateLimit.UserRequestsPerHour == 0 {
+		c.RateLimit.UserRequestsPerHour = 1000
+	}
+	if c.RateLimit.UserSendsPerHour == 0 {
+		c.RateLimit.UserSendsPerHour = 1000
+	}
+
+	if c.Media.Storage == "" {
+		c.Media.Storage = "local"
+	}
+	if c.Media.MaxFileSize == 0 {
+		c.Media.MaxFileSize = 20 * 1024 * 1024
+	}
+	if c.Media.LocalPath == "" {
+		c.Media.LocalPath = "./data/media"
+	}
+
+	if c.Outbound.WorkerCount == 0 {
+		c.Outbound.WorkerCount = 4
+	}
+	if c.Outbound.PollInterval == 0 {
+		c.Outbound.PollInterval = Duration(500 * time.Millisecond)
+	}
+	if c.Outbound.BatchSize == 0 {
+		c.Outbound.BatchSize = 20
+	}
+	if c.Outbound.MaxAttempts == 0 {
+		c.Outbound.MaxAttempts = 5
+	}
+	if c.Outbound.MaxSyncWait == 0 {
+		c.Outbound.MaxSyncWait = Duration(10 * time.Second)
+	}
+	if c.Outbound.BotMessagesPerSecond == 0 {
+		c.Outbound.BotMessagesPerSecond = 30
+	}
+	if c.Outbound.ChatInterval == 0 {
+		c.Outbound.ChatInterval = Duration(time.Second)
+	}
+	if c.Outbound.GroupMessagesPerMinute == 0 {
+		c.Outbound.GroupMessagesPerMinute = 20
+	}
 }
 
 // validate checks if the configuration is valid
@@ -304,8 +408,21 @@ func (c *Config) validate() error {
 		return fmt.Errorf("JWT secret must be at least 32 characters")
 	}
 
-	if c.Telegram.WebhookBaseURL == "" {
-		return fmt.Errorf("telegram webhook base URL is required")
+	if c.Encryption.PrimaryKeyID == "" {
+		return fmt.Errorf("encryption primary key ID is required")
+	}
+	if _, ok := c.Encryption.Keys[c.Encryption.PrimaryKeyID]; !ok {
+		return fmt.Errorf("encryption key %q is not configured", c.Encryption.PrimaryKeyID)
+	}
+
+	switch c.Media.Storage {
+	case "local":
+	case "s3":
+		if c.Media.S3.Bucket == "" {
+			return fmt.Errorf("media S3 bucket is required")
+		}
+	default:
+		return fmt.Errorf("media storage must be \"local\" or \"s3\"")
 	}
 
 	return nil
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event payload schema versions
+const (
+	EventSchemaFlat     = 1 // Flat webhook payload of earlier releases
+	EventSchemaEnvelope = 2 // EventEnvelope
+)
+
+// EventEnvelope is the versioned shape of an event, the same for webhooks, WebSocket
+// clients and gRPC streams
+type EventEnvelope struct {
+	Version       int                 `json:"version"`    // EventSchemaEnvelope
+	Event         string              `json:"event"`      // Event type, e.g. "new_message" or "my_chat_member"
+	ID            string              `json:"id"`         // Same for every consumer of the event
+	CreatedAt     time.Time           `json:"created_at"` // When the event happened
+	Bot           *EventBot           `json:"bot,omitempty"`
+	Chat          *EventChat          `json:"chat,omitempty"` // Missing for events without a chat, such as inline queries
+	Message       *EventMessage       `json:"message,omitempty"`
+	CallbackQuery *EventCallbackQuery `json:"callback_query,omitempty"` // Set for "callback_query" events
+	Update        *EventUpdate        `json:"update,omitempty"`         // Set for update events
+	Raw           json.RawMessage     `json:"raw,omitempty"`            // Telegram message or callback query, when requested
+}
+
+// WithoutRaw returns the envelope without its raw Telegram object
+func (e *EventEnvelope) WithoutRaw() *EventEnvelope {
+	if e.Raw == nil {
+		return e
+	}
+	stripped := *e
+	stripped.Raw = nil
+	return &stripped
+}
+
+// EventBot is the bot that received or sent an event
+type EventBot struct {
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// EventChat is the chat an event belongs to
+type EventChat struct {
+	ID         uint   `json:"id"`
+	TelegramID int64  `json:"telegram_id"`
+	Type       string `json:"type"` // "private", "group", "supergroup", "channel"
+	Title      string `json:"title,omitempty"`
+	Username   string `json:"username,omitempty"`
+	FirstName  string `json:"first_name,omitempty"`
+	LastName   string `json:"last_name,omitempty"`
+}
+
+// EventUser is a Telegram user
+type EventUser struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+}
+
+// EventMessage is a stored message
+type EventMessage struct {
+	ID         uint        `json:"id"`
+	TelegramID int64       `json:"telegram_id"`
+	Direction  string      `json:"direction"` // "incoming", "outgoing"
+	Type       string      `json:"type"`      // "text", "photo", "video", etc.
+	Text       string      `json:"text,omitempty"`
+	From       *EventUser  `json:"from,omitempty"`
+	ReplyTo    *EventReply `json:"reply_to,omitempty"`
+	Media      *EventMedia `json:"media,omitempty"`
+	SentAt     time.Time   `json:"sent_at"`
+	EditedAt   *time.Time  `json:"edited_at,omitempty"`
+	IsDeleted  bool        `json:"is_deleted,omitempty"`
+}
+
+// EventReply is the message a message replies to
+type EventReply struct {
+	TelegramID int64      `json:"telegram_id"`
+	MessageID  *uint      `json:"message_id,omitempty"` // Stored message, when the gateway has it
+	Text       string     `json:"text,omitempty"`
+	From       *EventUser `json:"from,omitempty"`
+}
+
+// EventMedia is the file attached to a message
+type EventMedia struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	Status       string `json:"status"` // Download state, see MediaStatusPending
+}
+
+// EventCallbackQuery is an inline keyboard button press
+type EventCallbackQuery struct {
+	ID              string    `json:"id"`
+	Data            string    `json:"data,omitempty"`
+	From            EventUser `json:"from"`
+	ChatInstance    string    `json:"chat_instance,omitempty"`
+	InlineMessageID string    `json:"inline_message_id,omitempty"` // Set for buttons on inline-mode messages, which have no chat
+	APIKeyID        *uint     `json:"api_key_id,omitempty"`        // API key that sent the keyboard
+}
+
+// EventUpdate is a Telegram update that is not a message or callback query
+type EventUpdate struct {
+	From *EventUser      `json:"from,omitempty"`
+	Data json.RawMessage `json:"data"` // Telegram object of the update, e.g. a ChatMemberUpdated
+}
@@ -6,13 +6,15 @@ import (
 
 // User represents a gateway user
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Username  string    `gorm:"uniqueIndex;not null;size:100" json:"username"`
-	Email     string    `gorm:"uniqueIndex;size:255" json:"email,omitempty"`
-	Password  string    `gorm:"not null;size:255" json:"-"` // bcrypt hash
-	IsActive  bool      `gorm:"default:true" json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Username      string    `gorm:"uniqueIndex;not null;size:100" json:"username"`
+	Email         string    `gorm:"uniqueIndex;size:255" json:"email,omitempty"`
+	Password      string    `gorm:"not null;size:255" json:"-"` // bcrypt hash
+	IsActive      bool      `gorm:"default:true" json:"is_active"`
+	RateLimit     int       `gorm:"default:0" json:"rate_limit"`      // Reads per hour; 0 uses the configured default
+	SendRateLimit int       `gorm:"default:0" json:"send_rate_limit"` // Sends per hour; 0 uses the configured default
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 
 	// Relationships
 	Roles          []Role          `gorm:"many2many:user_roles;" json:"roles,omitempty"`
@@ -56,6 +58,8 @@ type Bot struct {
 	IsActive      bool      `gorm:"default:true" json:"is_active"`
 	WebhookURL    string    `gorm:"size:512" json:"webhook_url,omitempty"`    // Set when registered with Telegram
 	WebhookSecret string    `gorm:"uniqueIndex;size:64" json:"-"`              // Random secret for webhook URL
+	IngestionMode string    `gorm:"not null;size:20;default:webhook" json:"ingestion_mode"` // "webhook" or "polling"
+	LastUpdateID  int64     `gorm:"not null;default:0" json:"-"`                             // Last update processed in polling mode
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 
@@ -63,6 +67,12 @@ type Bot struct {
 	Chats []Chat `gorm:"foreignKey:BotID" json:"-"`
 }
 
+// Bot ingestion modes
+const (
+	BotModeWebhook = "webhook" // Telegram pushes updates to the gateway webhook URL
+	BotModePolling = "polling" // The gateway pulls updates with getUpdates
+)
+
 // Chat represents a Telegram chat associated with a bot
 type Chat struct {
 	ID         uint      `gorm:"primaryKey" json:"id"`
@@ -103,18 +113,20 @@ type ChatPermission struct {
 
 // APIKey represents a static API key for machine-to-machine auth
 type APIKey struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Key         string    `gorm:"uniqueIndex;not null;size:100" json:"key"` // Visible part (e.g., "tgw_abc123...")
-	HashedKey   string    `gorm:"uniqueIndex;not null;size:255" json:"-"`   // argon2id hash
-	Name        string    `gorm:"not null;size:100" json:"name"`
-	Description string    `gorm:"type:text" json:"description,omitempty"`
-	Scopes      string    `gorm:"type:text" json:"scopes,omitempty"` // JSON array of scopes
-	RateLimit   int       `gorm:"default:1000" json:"rate_limit"`    // Requests per hour
-	IsActive    bool      `gorm:"default:true" json:"is_active"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
-	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Key           string     `gorm:"uniqueIndex;not null;size:100" json:"key"` // Visible part (e.g., "tgw_abc123...")
+	HashedKey     string     `gorm:"uniqueIndex;not null;size:255" json:"-"`   // argon2id hash
+	Name          string     `gorm:"not null;size:100" json:"name"`
+	Description   string     `gorm:"type:text" json:"description,omitempty"`
+	Scopes        string     `gorm:"type:text" json:"scopes,omitempty"` // JSON array of scopes
+	RateLimit     int        `gorm:"default:1000" json:"rate_limit"`    // Reads per hour
+	SendRateLimit int        `gorm:"default:0" json:"send_rate_limit"`  // Sends per hour; 0 uses RateLimit
+	AllBots       bool       `gorm:"default:false" json:"all_bots"`     // May use every bot without bot grants
+	IsActive      bool       `gorm:"default:true" json:"is_active"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 
 	// Relationships
 	ChatPermissions     []ChatPermission             `gorm:"foreignKey:APIKeyID" json:"-"`
@@ -122,7 +134,7 @@ type APIKey struct {
 	FeedbackPermissions []APIKeyFeedbackPermission   `gorm:"foreignKey:APIKeyID" json:"-"`
 }
 
-// APIKeyBotPermission restricts which bots an API key can use
+// APIKeyBotPermission allows an API key to use a bot
 type APIKeyBotPermission struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	APIKeyID  uint      `gorm:"not null;uniqueIndex:idx_apikey_bot" json:"api_key_id"`
@@ -158,32 +170,162 @@ type Message struct {
 	FromFirstName   string    `gorm:"size:255" json:"from_first_name,omitempty"`
 	FromLastName    string    `gorm:"size:255" json:"from_last_name,omitempty"`
 	Direction       string    `gorm:"not null;size:20;index:idx_chat_messages" json:"direction"` // "incoming", "outgoing"
+	SentByAPIKeyID  *uint     `gorm:"index" json:"sent_by_api_key_id,omitempty"` // API key that sent an outgoing message
 	MessageType     string    `gorm:"not null;size:50" json:"message_type"` // "text", "photo", "video", etc.
 	Text            string    `gorm:"type:text" json:"text,omitempty"`
 	RawData         string    `gorm:"type:longtext" json:"-"` // Full Telegram message JSON
 	ReplyToMessageID *int64   `gorm:"index" json:"reply_to_message_id,omitempty"`
 	SentAt          time.Time `gorm:"not null;index:idx_chat_messages" json:"sent_at"`
+	EditedAt        *time.Time `json:"edited_at,omitempty"`           // Last edit, NULL if never edited
+	IsDeleted       bool      `gorm:"default:false" json:"is_deleted"` // Deleted from the Telegram chat
 	CreatedAt       time.Time `json:"created_at"`
 
 	// Relationships
-	Chat Chat `gorm:"foreignKey:ChatID" json:"chat,omitempty"`
+	Chat  Chat          `gorm:"foreignKey:ChatID" json:"chat,omitempty"`
+	Edits []MessageEdit `gorm:"foreignKey:MessageID" json:"-"`
+}
+
+// MessageEdit records the text a message had before an edit
+type MessageEdit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MessageID uint      `gorm:"not null;index" json:"message_id"`
+	Text      string    `gorm:"type:text" json:"text"`        // Text before the edit
+	EditedAt  time.Time `gorm:"not null" json:"edited_at"`    // When the text was replaced
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CallbackQuery represents an inline keyboard button press
+type CallbackQuery struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	QueryID         string     `gorm:"uniqueIndex;not null;size:64" json:"query_id"` // Telegram's callback query ID
+	BotID           uint       `gorm:"not null;index" json:"bot_id"`
+	ChatID          *uint      `gorm:"index" json:"chat_id,omitempty"`    // NULL for inline-mode messages
+	MessageID       *uint      `gorm:"index" json:"message_id,omitempty"` // Stored message carrying the keyboard
+	InlineMessageID string     `gorm:"size:255" json:"inline_message_id,omitempty"`
+	APIKeyID        *uint      `gorm:"index" json:"api_key_id,omitempty"` // API key that sent the keyboard
+	FromUserID      int64      `gorm:"not null" json:"from_user_id"`
+	FromUsername    string     `gorm:"size:100" json:"from_username,omitempty"`
+	FromFirstName   string     `gorm:"size:255" json:"from_first_name,omitempty"`
+	ChatInstance    string     `gorm:"size:100" json:"chat_instance,omitempty"`
+	Data            string     `gorm:"type:text" json:"data,omitempty"`
+	GameShortName   string     `gorm:"size:255" json:"game_short_name,omitempty"`
+	RawData         string     `gorm:"type:longtext" json:"-"` // Full Telegram callback query JSON
+	AnsweredAt      *time.Time `json:"answered_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+
+	// Relationships
+	Bot     Bot      `gorm:"foreignKey:BotID" json:"-"`
+	Chat    *Chat    `gorm:"foreignKey:ChatID" json:"-"`
+	Message *Message `gorm:"foreignKey:MessageID" json:"-"`
 }
 
+// UpdateEvent records a Telegram update that is not a message or callback query,
+// e.g. membership changes, join requests, reactions, polls and inline queries
+type UpdateEvent struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	BotID        uint      `gorm:"not null;index" json:"bot_id"`
+	ChatID       *uint     `gorm:"index:idx_chat_update_events" json:"chat_id,omitempty"` // NULL for updates without a chat (inline queries, polls)
+	MessageID    *uint     `gorm:"index" json:"message_id,omitempty"`                     // Stored message the update refers to (reactions)
+	EventType    string    `gorm:"not null;size:50;index" json:"event_type"`              // Update field name, e.g. "chat_member"
+	FromUserID   *int64    `json:"from_user_id,omitempty"`
+	FromUsername string    `gorm:"size:100" json:"from_username,omitempty"`
+	RawData      string    `gorm:"type:longtext" json:"-"` // Update object JSON
+	OccurredAt   time.Time `gorm:"not null;index:idx_chat_update_events" json:"occurred_at"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Relationships
+	Bot  Bot   `gorm:"foreignKey:BotID" json:"-"`
+	Chat *Chat `gorm:"foreignKey:ChatID" json:"-"`
+}
+
+// MediaFile records a file attached to a stored message and its copy in blob storage
+type MediaFile struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	MessageID    uint       `gorm:"not null;uniqueIndex" json:"message_id"`
+	BotID        uint       `gorm:"not null;index" json:"bot_id"`
+	FileID       string     `gorm:"not null;size:255" json:"file_id"`     // Telegram file_id, only valid for this bot
+	FileUniqueID string     `gorm:"size:100;index" json:"file_unique_id"` // Stable across bots
+	FileName     string     `gorm:"size:255" json:"file_name,omitempty"`
+	MimeType     string     `gorm:"size:100" json:"mime_type,omitempty"`
+	FileSize     int64      `json:"file_size,omitempty"`
+	StorageKey   string     `gorm:"size:512" json:"-"`                              // Key in the blob store, set once stored
+	Status       string     `gorm:"not null;size:20;default:pending" json:"status"` // "pending", "stored", "failed", "too_large"
+	Error        string     `gorm:"type:text" json:"error,omitempty"`
+	StoredAt     *time.Time `json:"stored_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Relationships
+	Message Message `gorm:"foreignKey:MessageID" json:"-"`
+	Bot     Bot     `gorm:"foreignKey:BotID" json:"-"`
+}
+
+// Media file download states
+const (
+	MediaStatusPending  = "pending"   // Waiting to be downloaded
+	MediaStatusStored   = "stored"    // Copied to blob storage
+	MediaStatusFailed   = "failed"    // Download failed; retried when the file is requested
+	MediaStatusTooLarge = "too_large" // Larger than the configured limit or the Bot API download limit
+)
+
+// OutboundMessage is a Bot API send queued for asynchronous delivery
+type OutboundMessage struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ChatID         uint       `gorm:"not null;index" json:"chat_id"`
+	BotID          uint       `gorm:"not null;index" json:"bot_id"`
+	SourceChatID   *uint      `json:"source_chat_id,omitempty"`       // Chat a forwarded or copied message comes from
+	Method         string     `gorm:"not null;size:50" json:"method"` // Bot API method, e.g. "sendMessage"
+	Params         string     `gorm:"type:text;not null" json:"-"`    // JSON-encoded Bot API params
+	SentByAPIKeyID *uint      `gorm:"index" json:"sent_by_api_key_id,omitempty"`
+	SentByUserID   *uint      `gorm:"index" json:"sent_by_user_id,omitempty"`
+	Status         string     `gorm:"not null;size:20;default:queued;index:idx_outbound_due" json:"status"` // "scheduled", "queued", "sending", "sent", "failed", "canceled"
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt  time.Time  `gorm:"index:idx_outbound_due" json:"next_attempt_at"`
+	ScheduledAt    *time.Time `json:"scheduled_at,omitempty"` // Requested send time of a scheduled message
+	MessageIDs     string     `gorm:"type:text" json:"-"`     // JSON array of the stored message IDs, set once sent
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relationships
+	Chat       Chat  `gorm:"foreignKey:ChatID" json:"-"`
+	SourceChat *Chat `gorm:"foreignKey:SourceChatID" json:"-"`
+}
+
+// Outbound message delivery states
+const (
+	OutboundStatusScheduled = "scheduled" // Waiting for its send time
+	OutboundStatusQueued    = "queued"    // Waiting for its turn or a retry
+	OutboundStatusSending   = "sending"   // Claimed by a worker
+	OutboundStatusSent      = "sent"      // Accepted by Telegram and recorded
+	OutboundStatusFailed    = "failed"    // Rejected by Telegram, out of attempts, or no longer allowed
+	OutboundStatusCanceled  = "canceled"  // Scheduled message canceled before its send time
+)
+
 // Webhook represents a registered webhook endpoint
 type Webhook struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
 	URL         string    `gorm:"not null;size:512" json:"url"`
 	Secret      string    `gorm:"not null;size:255" json:"-"` // For HMAC signing
-	Scope       string    `gorm:"not null;size:20" json:"scope"` // "chat" or "reply"
+	PreviousSecret string `gorm:"size:255" json:"-"` // Replaced secret, still signed with until PreviousSecretExpiresAt
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+	Scope       string    `gorm:"not null;size:20" json:"scope"` // "chat", "reply" or "feedback"
 	ChatID      *uint     `gorm:"index" json:"chat_id,omitempty"` // NULL for global webhooks
 	ReplyToMessageID *int64 `json:"reply_to_message_id,omitempty"` // For "reply" scope
+	APIKeyID    *uint     `gorm:"uniqueIndex" json:"api_key_id,omitempty"` // Owner of a "feedback" webhook
 	Events      string    `gorm:"type:text" json:"events,omitempty"` // JSON array of event types
 	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	SchemaVersion int     `gorm:"not null;default:1" json:"schema_version"` // Payload schema, see EventSchemaFlat
+	IncludeRaw  bool      `gorm:"not null;default:false" json:"include_raw"` // Add the raw Telegram object to envelopes
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"` // Time of the last failed delivery attempt
+	LastFailure string    `gorm:"type:text" json:"last_failure,omitempty"` // Error of the last failed delivery attempt
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 
 	// Relationships
 	Chat       *Chat              `gorm:"foreignKey:ChatID" json:"chat,omitempty"`
+	APIKey     *APIKey            `gorm:"foreignKey:APIKeyID" json:"-"`
 	Deliveries []WebhookDelivery  `gorm:"foreignKey:WebhookID" json:"-"`
 }
 
@@ -191,18 +333,28 @@ type Webhook struct {
 type WebhookDelivery struct {
 	ID           uint       `gorm:"primaryKey" json:"id"`
 	WebhookID    uint       `gorm:"not null;index:idx_webhook_deliveries" json:"webhook_id"`
-	MessageID    uint       `gorm:"not null;index" json:"message_id"`
+	MessageID    *uint      `gorm:"index" json:"message_id,omitempty"` // NULL for update events without a message
+	CallbackQueryID *uint   `gorm:"index" json:"callback_query_id,omitempty"` // Set for "callback_query" events
+	UpdateEventID *uint     `gorm:"index" json:"update_event_id,omitempty"`   // Set for non-message update events
+	EventType    string     `gorm:"not null;size:50;default:new_message" json:"event_type"` // "new_message", "edited_message", "channel_post"
 	Status       string     `gorm:"not null;size:20;index:idx_webhook_deliveries" json:"status"` // "pending", "delivered", "failed"
 	AttemptCount int        `gorm:"default:0" json:"attempt_count"`
 	LastError    string     `gorm:"type:text" json:"last_error,omitempty"`
 	NextRetryAt  *time.Time `gorm:"index:idx_webhook_deliveries" json:"next_retry_at,omitempty"`
+	ResponseStatus *int     `json:"response_status,omitempty"` // HTTP status of the last attempt, NULL when no response was received
+	ResponseBody string     `gorm:"type:text" json:"response_body,omitempty"` // Start of the response body of the last attempt
+	LatencyMs    *int64     `json:"latency_ms,omitempty"` // Duration of the last attempt
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
 	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+	FailedAt     *time.Time `gorm:"index" json:"failed_at,omitempty"` // Set when the retries were used up and the delivery became a dead letter
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 
 	// Relationships
-	Webhook Webhook `gorm:"foreignKey:WebhookID" json:"webhook,omitempty"`
-	Message Message `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+	Webhook       Webhook        `gorm:"foreignKey:WebhookID" json:"webhook,omitempty"`
+	Message       *Message       `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+	CallbackQuery *CallbackQuery `gorm:"foreignKey:CallbackQueryID" json:"callback_query,omitempty"`
+	UpdateEvent   *UpdateEvent   `gorm:"foreignKey:UpdateEventID" json:"update_event,omitempty"`
 }
 
 // RefreshToken represents a JWT refresh token
@@ -229,6 +381,10 @@ func (APIKey) TableName() string                     { return "api_keys" }
 func (APIKeyBotPermission) TableName() string        { return "api_key_bot_permissions" }
 func (APIKeyFeedbackPermission) TableName() string   { return "api_key_feedback_permissions" }
 func (Message) TableName() string                    { return "messages" }
+func (MessageEdit) TableName() string                { return "message_edits" }
+func (CallbackQuery) TableName() string              { return "callback_queries" }
+func (UpdateEvent) TableName() string                { return "update_events" }
+func (MediaFile) TableName() string                  { return "media_files" }
 func (Webhook) TableName() string                    { return "webhooks" }
 func (WebhookDelivery) TableName() string            { return "webhook_deliveries" }
 func (RefreshToken) TableName() string               { return "refresh_tokens" }
@@ -0,0 +1,145 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/kexi/telegram-bot-gateway/api/proto"
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+// BotServiceServer implements the gRPC BotService
+type BotServiceServer struct {
+	pb.UnimplementedBotServiceServer
+	botService *service.BotService
+}
+
+// NewBotServiceServer creates a new bot service server
+func NewBotServiceServer(botService *service.BotService) *BotServiceServer {
+	return &BotServiceServer{
+		botService: botService,
+	}
+}
+
+// ListBots lists the bots visible to the caller
+func (s *BotServiceServer) ListBots(ctx context.Context, req *pb.ListBotsRequest) (*pb.ListBotsResponse, error) {
+	authCtx, err := authFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := int(req.Offset)
+	if offset < 0 {
+		offset = 0
+	}
+	limit := int(req.Limit)
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	filter := middleware.VisibleBotFilter(authCtx)
+	filter.Search = req.Search
+	filter.IsActive = req.IsActive
+
+	bots, total, err := s.botService.ListBotsFiltered(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list bots: %v", err)
+	}
+
+	pbBots := make([]*pb.Bot, len(bots))
+	for i := range bots {
+		pbBots[i] = toPBBot(&bots[i])
+	}
+
+	return &pb.ListBotsResponse{
+		Bots:  pbBots,
+		Total: int32(total),
+	}, nil
+}
+
+// GetBot retrieves bot details
+func (s *BotServiceServer) GetBot(ctx context.Context, req *pb.GetBotRequest) (*pb.Bot, error) {
+	authCtx, err := authFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bot, err := s.botService.GetVisibleBot(ctx, uint(req.BotId), middleware.VisibleBotFilter(authCtx))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "bot not found")
+	}
+
+	return toPBBot(bot), nil
+}
+
+// CreateBot registers a new bot (admin users only)
+func (s *BotServiceServer) CreateBot(ctx context.Context, req *pb.CreateBotRequest) (*pb.Bot, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if req.Username == "" || req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "username and token are required")
+	}
+
+	bot, err := s.botService.CreateBot(ctx, &service.CreateBotRequest{
+		Username:    req.Username,
+		Token:       req.Token,
+		DisplayName: req.DisplayName,
+		Description: req.Description,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create bot: %v", err)
+	}
+
+	return toPBBot(bot), nil
+}
+
+// DeleteBot removes a bot (admin users only)
+func (s *BotServiceServer) DeleteBot(ctx context.Context, req *pb.DeleteBotRequest) (*pb.DeleteBotResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.botService.DeleteBot(ctx, uint(req.BotId)); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to delete bot: %v", err)
+	}
+
+	return &pb.DeleteBotResponse{
+		Success: true,
+		Message: "Bot deleted successfully",
+	}, nil
+}
+
+// requireAdmin only lets JWT-authenticated users with the admin role through
+func requireAdmin(ctx context.Context) error {
+	authCtx, err := authFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !authCtx.IsAPIKey {
+		for _, role := range authCtx.Roles {
+			if role == "admin" {
+				return nil
+			}
+		}
+	}
+
+	return status.Error(codes.PermissionDenied, "admin role required")
+}
+
+// toPBBot converts a bot DTO to its protobuf representation
+func toPBBot(bot *service.BotDTO) *pb.Bot {
+	return &pb.Bot{
+		Id:          uint64(bot.ID),
+		Username:    bot.Username,
+		DisplayName: bot.DisplayName,
+		Description: bot.Description,
+		IsActive:    bot.IsActive,
+		WebhookUrl:  bot.WebhookURL,
+	}
+}
@@ -0,0 +1,118 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/kexi/telegram-bot-gateway/api/proto"
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+// ChatServiceServer implements the gRPC ChatService
+type ChatServiceServer struct {
+	pb.UnimplementedChatServiceServer
+	chatService  *service.ChatService
+	chatPermRepo repository.ChatPermissionRepository
+	redisClient  *redis.Client
+}
+
+// NewChatServiceServer creates a new chat service server
+func NewChatServiceServer(
+	chatService *service.ChatService,
+	chatPermRepo repository.ChatPermissionRepository,
+	redisClient *redis.Client,
+) *ChatServiceServer {
+	return &ChatServiceServer{
+		chatService:  chatService,
+		chatPermRepo: chatPermRepo,
+		redisClient:  redisClient,
+	}
+}
+
+// ListChats lists the chats the caller can read
+func (s *ChatServiceServer) ListChats(ctx context.Context, req *pb.ListChatsRequest) (*pb.ListChatsResponse, error) {
+	authCtx, err := authFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := int(req.Offset)
+	if offset < 0 {
+		offset = 0
+	}
+	limit := int(req.Limit)
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	filter := middleware.ReadableChatFilter(authCtx)
+	filter.Type = req.Type
+	filter.Search = req.Search
+	filter.IsActive = req.IsActive
+	if req.BotId != 0 {
+		botID := uint(req.BotId)
+		filter.BotID = &botID
+	}
+
+	chats, total, err := s.chatService.ListChatsFiltered(ctx, filter, offset, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidChatType) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to list chats: %v", err)
+	}
+
+	pbChats := make([]*pb.Chat, len(chats))
+	for i := range chats {
+		pbChats[i] = toPBChat(&chats[i])
+	}
+
+	return &pb.ListChatsResponse{
+		Chats: pbChats,
+		Total: int32(total),
+	}, nil
+}
+
+// GetChat retrieves a chat the caller can read
+func (s *ChatServiceServer) GetChat(ctx context.Context, req *pb.GetChatRequest) (*pb.Chat, error) {
+	authCtx, err := authFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := middleware.CheckChatPermission(ctx, authCtx, uint(req.ChatId), middleware.PermissionRead, s.chatPermRepo, s.redisClient)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to check permissions")
+	}
+	if !allowed {
+		return nil, status.Errorf(codes.PermissionDenied, "insufficient permissions for chat %d: can_read required", req.ChatId)
+	}
+
+	chat, err := s.chatService.GetChat(ctx, uint(req.ChatId))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "chat not found")
+	}
+
+	return toPBChat(chat), nil
+}
+
+// toPBChat converts a chat DTO to its protobuf representation
+func toPBChat(chat *service.ChatDTO) *pb.Chat {
+	return &pb.Chat{
+		Id:         uint64(chat.ID),
+		BotId:      uint64(chat.BotID),
+		TelegramId: chat.TelegramID,
+		Type:       chat.Type,
+		Title:      chat.Title,
+		Username:   chat.Username,
+		FirstName:  chat.FirstName,
+		LastName:   chat.LastName,
+		IsActive:   chat.IsActive,
+	}
+}
@@ -1,38 +1,66 @@
 package grpc
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	pb "github.com/kexi/telegram-bot-gateway/api/proto"
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
 	"github.com/kexi/telegram-bot-gateway/internal/pubsub"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
 	"github.com/kexi/telegram-bot-gateway/internal/service"
 )
 
 // MessageServiceServer implements the gRPC MessageService
 type MessageServiceServer struct {
 	pb.UnimplementedMessageServiceServer
-	messageService *service.MessageService
-	chatService    *service.ChatService
-	messageBroker  *pubsub.MessageBroker
+	messageService  *service.MessageService
+	chatService     *service.ChatService
+	botService      *service.BotService
+	outboundService *service.OutboundService
+	callbackService *service.CallbackQueryService
+	chatRepo        repository.ChatRepository
+	chatPermRepo    repository.ChatPermissionRepository
+	botPermRepo     repository.APIKeyBotPermissionRepository
+	messageBroker   *pubsub.MessageBroker
+	redisClient     *redis.Client
 }
 
 // NewMessageServiceServer creates a new message service server
 func NewMessageServiceServer(
 	messageService *service.MessageService,
 	chatService *service.ChatService,
+	botService *service.BotService,
+	outboundService *service.OutboundService,
+	callbackService *service.CallbackQueryService,
+	chatRepo repository.ChatRepository,
+	chatPermRepo repository.ChatPermissionRepository,
+	botPermRepo repository.APIKeyBotPermissionRepository,
 	messageBroker *pubsub.MessageBroker,
+	redisClient *redis.Client,
 ) *MessageServiceServer {
 	return &MessageServiceServer{
-		messageService: messageService,
-		chatService:    chatService,
-		messageBroker:  messageBroker,
+		messageService:  messageService,
+		chatService:     chatService,
+		botService:      botService,
+		outboundService: outboundService,
+		callbackService: callbackService,
+		chatRepo:        chatRepo,
+		chatPermRepo:    chatPermRepo,
+		botPermRepo:     botPermRepo,
+		messageBroker:   messageBroker,
+		redisClient:     redisClient,
 	}
 }
 
@@ -40,71 +68,59 @@ func NewMessageServiceServer(
 func (s *MessageServiceServer) StreamMessages(req *pb.StreamMessagesRequest, stream pb.MessageService_StreamMessagesServer) error {
 	ctx := stream.Context()
 
-	// Validate authentication from metadata
-	userID, err := getUserIDFromContext(ctx)
+	authCtx, err := authFromContext(ctx)
 	if err != nil {
-		return status.Error(codes.Unauthenticated, "authentication required")
+		return err
 	}
 
-	log.Printf("gRPC: User %d streaming messages for %d chats", userID, len(req.ChatIds))
+	if len(req.ChatIds) == 0 {
+		return status.Error(codes.InvalidArgument, "at least one chat_id is required")
+	}
+
+	format, err := newEventFormat(req.SchemaVersion, req.IncludeRaw)
+	if err != nil {
+		return err
+	}
 
-	// Convert chat IDs
+	// Convert chat IDs and check read access for each of them
 	chatIDs := make([]uint, len(req.ChatIds))
 	for i, id := range req.ChatIds {
 		chatIDs[i] = uint(id)
+		if err := s.requireChatPermission(ctx, authCtx, chatIDs[i], middleware.PermissionRead); err != nil {
+			return err
+		}
 	}
 
+	log.Printf("gRPC: %s streaming messages for %d chats", callerName(authCtx), len(chatIDs))
+
 	// Subscribe to message broker
 	eventChan, err := s.messageBroker.SubscribeToMultipleChats(ctx, chatIDs)
 	if err != nil {
 		return status.Errorf(codes.Internal, "failed to subscribe: %v", err)
 	}
 
-	// Stream events to client
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case event, ok := <-eventChan:
-			if !ok {
-				return nil
-			}
-
-			// Convert to protobuf message
-			pbEvent := &pb.MessageEvent{
-				Type:          event.Type,
-				ChatId:        uint64(event.ChatID),
-				MessageId:     uint64(event.MessageID),
-				TelegramId:    event.TelegramID,
-				BotId:         uint64(event.BotID),
-				Direction:     event.Direction,
-				Text:          event.Text,
-				FromUsername:  event.FromUsername,
-				MessageType:   event.Payload["message_type"].(string),
-				Timestamp:     event.Timestamp.Unix(),
-			}
-
-			// Send to client
-			if err := stream.Send(pbEvent); err != nil {
-				return err
-			}
-		}
-	}
+	return streamEvents(ctx, authCtx, eventChan, format, stream.Send)
 }
 
 // StreamChatMessages streams messages for a single chat
 func (s *MessageServiceServer) StreamChatMessages(req *pb.StreamChatMessagesRequest, stream pb.MessageService_StreamChatMessagesServer) error {
 	ctx := stream.Context()
 
-	// Validate authentication
-	userID, err := getUserIDFromContext(ctx)
+	authCtx, err := authFromContext(ctx)
 	if err != nil {
-		return status.Error(codes.Unauthenticated, "authentication required")
+		return err
 	}
 
-	// TODO: Check ACL permissions for this chat
+	if err := s.requireChatPermission(ctx, authCtx, uint(req.ChatId), middleware.PermissionRead); err != nil {
+		return err
+	}
 
-	log.Printf("gRPC: User %d streaming chat %d", userID, req.ChatId)
+	format, err := newEventFormat(req.SchemaVersion, req.IncludeRaw)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("gRPC: %s streaming chat %d", callerName(authCtx), req.ChatId)
 
 	// Subscribe to chat
 	eventChan, err := s.messageBroker.SubscribeToChat(ctx, uint(req.ChatId))
@@ -112,59 +128,47 @@ func (s *MessageServiceServer) StreamChatMessages(req *pb.StreamChatMessagesRequ
 		return status.Errorf(codes.Internal, "failed to subscribe: %v", err)
 	}
 
-	// Stream events
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case event, ok := <-eventChan:
-			if !ok {
-				return nil
-			}
-
-			pbEvent := &pb.MessageEvent{
-				Type:         event.Type,
-				ChatId:       uint64(event.ChatID),
-				MessageId:    uint64(event.MessageID),
-				TelegramId:   event.TelegramID,
-				BotId:        uint64(event.BotID),
-				Direction:    event.Direction,
-				Text:         event.Text,
-				FromUsername: event.FromUsername,
-				Timestamp:    event.Timestamp.Unix(),
-			}
-
-			if err := stream.Send(pbEvent); err != nil {
-				return err
-			}
-		}
-	}
+	return streamEvents(ctx, authCtx, eventChan, format, stream.Send)
 }
 
-// SendMessage sends a message to a chat
+// SendMessage sends a text message to a chat and records it as outgoing
 func (s *MessageServiceServer) SendMessage(ctx context.Context, req *pb.SendMessageRequest) (*pb.SendMessageResponse, error) {
-	// Validate authentication
-	_, err := getUserIDFromContext(ctx)
+	if req.Text == "" {
+		return nil, status.Error(codes.InvalidArgument, "text is required")
+	}
+
+	chat, opts, err := s.prepareSend(ctx, req.ChatId, req.GetOptions())
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "authentication required")
+		return nil, err
 	}
 
-	// TODO: Check ACL permissions
-	// TODO: Integrate with Telegram Bot API to actually send message
+	if req.ReplyToMessageId != 0 {
+		opts.ReplyToMessageID = &req.ReplyToMessageId
+	}
+
+	msg, err := s.outboundService.SendText(ctx, chat, req.Text, &opts)
+	if err != nil {
+		return nil, sendErrorStatus(err)
+	}
 
 	return &pb.SendMessageResponse{
-		Success: true,
-		Message: "Message queued for delivery",
-		QueuedAt: time.Now().Unix(),
+		Success:           true,
+		Message:           "Message sent",
+		MessageId:         uint64(msg.ID),
+		QueuedAt:          time.Now().Unix(),
+		TelegramMessageId: msg.TelegramID,
 	}, nil
 }
 
 // GetMessages retrieves historical messages
 func (s *MessageServiceServer) GetMessages(ctx context.Context, req *pb.GetMessagesRequest) (*pb.GetMessagesResponse, error) {
-	// Validate authentication
-	_, err := getUserIDFromContext(ctx)
+	authCtx, err := authFromContext(ctx)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "authentication required")
+		return nil, err
+	}
+
+	if err := s.requireChatPermission(ctx, authCtx, uint(req.ChatId), middleware.PermissionRead); err != nil {
+		return nil, err
 	}
 
 	// Convert cursor
@@ -187,26 +191,8 @@ func (s *MessageServiceServer) GetMessages(ctx context.Context, req *pb.GetMessa
 
 	// Convert to protobuf
 	pbMessages := make([]*pb.Message, len(messages))
-	for i, msg := range messages {
-		pbMessages[i] = &pb.Message{
-			Id:             uint64(msg.ID),
-			ChatId:         uint64(msg.ChatID),
-			TelegramId:     msg.TelegramID,
-			FromUsername:   msg.FromUsername,
-			FromFirstName:  msg.FromFirstName,
-			FromLastName:   msg.FromLastName,
-			Direction:      msg.Direction,
-			MessageType:    msg.MessageType,
-			Text:           msg.Text,
-			SentAt:         msg.SentAt.Unix(),
-			CreatedAt:      msg.CreatedAt.Unix(),
-		}
-		if msg.FromUserID != nil {
-			pbMessages[i].FromUserId = *msg.FromUserID
-		}
-		if msg.ReplyToMessageID != nil {
-			pbMessages[i].ReplyToMessageId = *msg.ReplyToMessageID
-		}
+	for i := range messages {
+		pbMessages[i] = toPBMessage(&messages[i])
 	}
 
 	// Determine if there are more messages
@@ -224,22 +210,367 @@ func (s *MessageServiceServer) GetMessages(ctx context.Context, req *pb.GetMessa
 	}, nil
 }
 
-// getUserIDFromContext extracts user ID from gRPC metadata
-func getUserIDFromContext(ctx context.Context) (uint, error) {
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return 0, fmt.Errorf("no metadata")
+// SendMedia sends a photo, document or voice message to a chat
+func (s *MessageServiceServer) SendMedia(ctx context.Context, req *pb.SendMediaRequest) (*pb.SendMediaResponse, error) {
+	chat, opts, err := s.prepareSend(ctx, req.ChatId, req.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	sendReq := &service.SendMediaRequest{
+		Type:        req.Type,
+		Media:       req.Media,
+		Caption:     req.Caption,
+		SendOptions: opts,
+	}
+	if len(req.FileData) > 0 {
+		sendReq.File = &service.InputFile{FileName: uploadFileName(req.FileName, req.Type), Reader: bytes.NewReader(req.FileData)}
+	}
+	if sendReq.Media == "" && sendReq.File == nil {
+		return nil, status.Error(codes.InvalidArgument, "media or file_data is required")
 	}
 
-	userIDs := md.Get("user-id")
-	if len(userIDs) == 0 {
-		return 0, fmt.Errorf("no user-id in metadata")
+	msg, err := s.outboundService.SendMedia(ctx, chat, sendReq)
+	if err != nil {
+		return nil, sendErrorStatus(err)
 	}
 
-	var userID uint
-	if _, err := fmt.Sscanf(userIDs[0], "%d", &userID); err != nil {
-		return 0, fmt.Errorf("invalid user-id: %w", err)
+	return &pb.SendMediaResponse{Success: true, Messages: []*pb.Message{toPBMessage(msg)}}, nil
+}
+
+// SendLocation sends a location to a chat
+func (s *MessageServiceServer) SendLocation(ctx context.Context, req *pb.SendLocationRequest) (*pb.SendMediaResponse, error) {
+	chat, opts, err := s.prepareSend(ctx, req.ChatId, req.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := s.outboundService.SendLocation(ctx, chat, &service.SendLocationRequest{
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+		SendOptions: opts,
+	})
+	if err != nil {
+		return nil, sendErrorStatus(err)
+	}
+
+	return &pb.SendMediaResponse{Success: true, Messages: []*pb.Message{toPBMessage(msg)}}, nil
+}
+
+// SendMediaGroup sends an album to a chat
+func (s *MessageServiceServer) SendMediaGroup(ctx context.Context, req *pb.SendMediaGroupRequest) (*pb.SendMediaResponse, error) {
+	chat, opts, err := s.prepareSend(ctx, req.ChatId, req.GetOptions())
+	if err != nil {
+		return nil, err
 	}
 
-	return userID, nil
+	items := make([]service.MediaGroupItem, len(req.Media))
+	var files []service.InputFile
+	for i, media := range req.Media {
+		items[i] = service.MediaGroupItem{
+			Type:      media.Type,
+			Media:     media.Media,
+			Caption:   media.Caption,
+			ParseMode: media.ParseMode,
+		}
+		if len(media.FileData) > 0 {
+			// Uploads are referenced from the InputMedia object by their multipart field name
+			field := fmt.Sprintf("file%d", i)
+			items[i].Media = "attach://" + field
+			files = append(files, service.InputFile{Field: field, FileName: uploadFileName(media.FileName, media.Type), Reader: bytes.NewReader(media.FileData)})
+		}
+	}
+
+	messages, err := s.outboundService.SendMediaGroup(ctx, chat, &service.SendMediaGroupRequest{
+		Media:       items,
+		Files:       files,
+		SendOptions: opts,
+	})
+	if err != nil {
+		return nil, sendErrorStatus(err)
+	}
+
+	pbMessages := make([]*pb.Message, len(messages))
+	for i := range messages {
+		pbMessages[i] = toPBMessage(&messages[i])
+	}
+
+	return &pb.SendMediaResponse{Success: true, Messages: pbMessages}, nil
+}
+
+// AnswerCallbackQuery answers an inline keyboard button press.
+// Routed queries can only be answered by the API key that sent the keyboard.
+func (s *MessageServiceServer) AnswerCallbackQuery(ctx context.Context, req *pb.AnswerCallbackQueryRequest) (*pb.AnswerCallbackQueryResponse, error) {
+	authCtx, err := authFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := s.callbackService.GetCallbackQuery(ctx, req.CallbackQueryId)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "callback query not found")
+	}
+
+	// Admins may answer any query; inline-mode queries without a chat are reserved for them
+	if requireAdmin(ctx) != nil {
+		switch {
+		case query.APIKeyID != nil:
+			if !authCtx.IsAPIKey || *authCtx.APIKeyID != *query.APIKeyID {
+				return nil, status.Error(codes.PermissionDenied, "callback query is routed to another API key")
+			}
+		case query.ChatID != nil:
+			if err := s.requireChatPermission(ctx, authCtx, *query.ChatID, middleware.PermissionSend); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, status.Error(codes.PermissionDenied, "admin role required")
+		}
+	}
+
+	if err := s.requireBotPermission(ctx, authCtx, query.BotID); err != nil {
+		return nil, err
+	}
+
+	answered, err := s.callbackService.AnswerCallbackQuery(ctx, query.QueryID, &service.AnswerCallbackQueryRequest{
+		Text:      req.Text,
+		ShowAlert: req.ShowAlert,
+		URL:       req.Url,
+		CacheTime: int(req.CacheTime),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrCallbackQueryAnswered) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		return nil, sendErrorStatus(err)
+	}
+
+	return &pb.AnswerCallbackQueryResponse{
+		Success:    true,
+		AnsweredAt: answered.AnsweredAt.Unix(),
+	}, nil
+}
+
+// prepareSend checks send permission on a chat and its bot and converts the protobuf send options
+func (s *MessageServiceServer) prepareSend(ctx context.Context, chatID uint64, pbOpts *pb.SendOptions) (*domain.Chat, service.SendOptions, error) {
+	var opts service.SendOptions
+
+	authCtx, err := authFromContext(ctx)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	if err := s.requireChatPermission(ctx, authCtx, uint(chatID), middleware.PermissionSend); err != nil {
+		return nil, opts, err
+	}
+
+	chat, err := s.chatRepo.GetByID(ctx, uint(chatID))
+	if err != nil {
+		return nil, opts, status.Error(codes.NotFound, "chat not found")
+	}
+
+	if err := s.requireBotPermission(ctx, authCtx, chat.BotID); err != nil {
+		return nil, opts, err
+	}
+
+	// Recorded so callback queries from inline keyboards are routed back to the key
+	if authCtx.IsAPIKey {
+		opts.SentByAPIKeyID = authCtx.APIKeyID
+	}
+
+	if pbOpts != nil {
+		opts.ParseMode = pbOpts.ParseMode
+		opts.DisableNotification = pbOpts.DisableNotification
+		if pbOpts.ReplyToMessageId != 0 {
+			opts.ReplyToMessageID = &pbOpts.ReplyToMessageId
+		}
+		if pbOpts.MessageThreadId != 0 {
+			opts.MessageThreadID = &pbOpts.MessageThreadId
+		}
+		if pbOpts.ReplyMarkupJson != "" {
+			if !json.Valid([]byte(pbOpts.ReplyMarkupJson)) {
+				return nil, opts, status.Error(codes.InvalidArgument, "reply_markup_json must be valid JSON")
+			}
+			opts.ReplyMarkup = json.RawMessage(pbOpts.ReplyMarkupJson)
+		}
+	}
+
+	return chat, opts, nil
+}
+
+// uploadFileName returns the upload's file name, falling back to the media type
+func uploadFileName(name, mediaType string) string {
+	if name != "" {
+		return name
+	}
+	return mediaType
+}
+
+// sendErrorStatus maps a send failure to a gRPC status. A bot token rejected by Telegram
+// is a gateway failure, like in the REST API.
+func sendErrorStatus(err error) error {
+	var invalidErr *service.InvalidSendError
+	if errors.As(err, &invalidErr) {
+		return status.Errorf(codes.InvalidArgument, "failed to send message: %v", err)
+	}
+
+	var apiErr *service.APIError
+	if errors.As(err, &apiErr) && apiErr.Code >= 400 && apiErr.Code < 500 && apiErr.Code != 401 {
+		if apiErr.Code == 429 {
+			return status.Errorf(codes.ResourceExhausted, "failed to send message: %v", err)
+		}
+		return status.Errorf(codes.FailedPrecondition, "failed to send message: %v", err)
+	}
+	return status.Errorf(codes.Unavailable, "failed to send message: %v", err)
+}
+
+// toPBMessage converts a stored message to its protobuf representation
+func toPBMessage(msg *service.MessageDTO) *pb.Message {
+	pbMessage := &pb.Message{
+		Id:            uint64(msg.ID),
+		ChatId:        uint64(msg.ChatID),
+		TelegramId:    msg.TelegramID,
+		FromUsername:  msg.FromUsername,
+		FromFirstName: msg.FromFirstName,
+		FromLastName:  msg.FromLastName,
+		Direction:     msg.Direction,
+		MessageType:   msg.MessageType,
+		Text:          msg.Text,
+		SentAt:        msg.SentAt.Unix(),
+		CreatedAt:     msg.CreatedAt.Unix(),
+	}
+	if msg.FromUserID != nil {
+		pbMessage.FromUserId = *msg.FromUserID
+	}
+	if msg.ReplyToMessageID != nil {
+		pbMessage.ReplyToMessageId = *msg.ReplyToMessageID
+	}
+	return pbMessage
+}
+
+// requireChatPermission returns a PermissionDenied status unless the caller holds the chat permission
+func (s *MessageServiceServer) requireChatPermission(ctx context.Context, authCtx *middleware.AuthContext, chatID uint, permission string) error {
+	allowed, err := middleware.CheckChatPermission(ctx, authCtx, chatID, permission, s.chatPermRepo, s.redisClient)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to check permissions")
+	}
+	if !allowed {
+		return status.Errorf(codes.PermissionDenied, "insufficient permissions for chat %d: can_%s required", chatID, permission)
+	}
+	return nil
+}
+
+// requireBotPermission returns a PermissionDenied status unless the caller may act through the bot
+func (s *MessageServiceServer) requireBotPermission(ctx context.Context, authCtx *middleware.AuthContext, botID uint) error {
+	allowed, err := middleware.CheckBotPermission(ctx, authCtx, botID, s.botPermRepo, s.redisClient)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to check bot permissions")
+	}
+	if !allowed {
+		return status.Error(codes.PermissionDenied, middleware.BotPermissionDeniedMessage(botID))
+	}
+	return nil
+}
+
+// eventFormat is the event schema a stream asked for
+type eventFormat struct {
+	envelope   bool // Add the event envelope
+	includeRaw bool // Keep the raw Telegram object in the envelope
+}
+
+// newEventFormat validates the event schema requested by a stream
+func newEventFormat(schemaVersion uint32, includeRaw bool) (eventFormat, error) {
+	switch schemaVersion {
+	case 0, domain.EventSchemaFlat:
+		return eventFormat{}, nil
+	case domain.EventSchemaEnvelope:
+		return eventFormat{envelope: true, includeRaw: includeRaw}, nil
+	}
+	return eventFormat{}, status.Error(codes.InvalidArgument, "schema_version must be 1 or 2")
+}
+
+// streamEvents forwards broker events to a gRPC stream until the client disconnects
+func streamEvents(ctx context.Context, authCtx *middleware.AuthContext, eventChan <-chan *pubsub.MessageEvent, format eventFormat, send func(*pb.MessageEvent) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-eventChan:
+			if !ok {
+				return nil
+			}
+
+			// Callback queries routed to an API key are only streamed to that key
+			if event.APIKeyID != nil && (!authCtx.IsAPIKey || *authCtx.APIKeyID != *event.APIKeyID) {
+				continue
+			}
+
+			pbEvent := toPBMessageEvent(event)
+			if format.envelope {
+				if event.Envelope == nil {
+					// Streams that asked for envelopes only get events that have one
+					continue
+				}
+				envelope := event.Envelope
+				if !format.includeRaw {
+					envelope = envelope.WithoutRaw()
+				}
+				data, err := json.Marshal(envelope)
+				if err != nil {
+					log.Printf("gRPC: failed to marshal event envelope: %v", err)
+					continue
+				}
+				pbEvent.Envelope = string(data)
+			}
+
+			if err := send(pbEvent); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toPBMessageEvent converts a broker event to its protobuf representation
+func toPBMessageEvent(event *pubsub.MessageEvent) *pb.MessageEvent {
+	pbEvent := &pb.MessageEvent{
+		Type:         event.Type,
+		ChatId:       uint64(event.ChatID),
+		MessageId:    uint64(event.MessageID),
+		TelegramId:   event.TelegramID,
+		BotId:        uint64(event.BotID),
+		Direction:    event.Direction,
+		Text:         event.Text,
+		FromUsername: event.FromUsername,
+		Timestamp:    event.Timestamp.Unix(),
+		Metadata:     make(map[string]string),
+	}
+
+	for key, value := range event.Payload {
+		if value == nil {
+			continue
+		}
+		if key == "message_type" {
+			pbEvent.MessageType = metadataValue(value)
+			continue
+		}
+		pbEvent.Metadata[key] = metadataValue(value)
+	}
+
+	return pbEvent
+}
+
+// metadataValue formats a JSON-decoded payload value as a metadata string
+func metadataValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		// JSON numbers decode as float64; keep integer IDs readable
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
 }
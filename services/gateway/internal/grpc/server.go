@@ -5,13 +5,20 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strings"
 
+	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/kexi/telegram-bot-gateway/api/proto"
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
+	"github.com/kexi/telegram-bot-gateway/internal/pkg/apikey"
 	"github.com/kexi/telegram-bot-gateway/internal/pkg/jwt"
 	"github.com/kexi/telegram-bot-gateway/internal/pubsub"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
 	"github.com/kexi/telegram-bot-gateway/internal/service"
 )
 
@@ -19,19 +26,30 @@ import (
 type Server struct {
 	grpcServer     *grpc.Server
 	messageService *MessageServiceServer
+	chatService    *ChatServiceServer
+	botService     *BotServiceServer
 	address        string
 }
 
-// NewServer creates a new gRPC server
+// NewServer creates a new gRPC server with the MessageService, ChatService and BotService registered
 func NewServer(
 	address string,
 	jwtService *jwt.Service,
+	apiKeyService *apikey.Service,
+	apiKeyRepo repository.APIKeyRepository,
 	messageService *service.MessageService,
 	chatService *service.ChatService,
+	botService *service.BotService,
+	outboundService *service.OutboundService,
+	callbackService *service.CallbackQueryService,
+	chatRepo repository.ChatRepository,
+	chatPermRepo repository.ChatPermissionRepository,
+	botPermRepo repository.APIKeyBotPermissionRepository,
 	messageBroker *pubsub.MessageBroker,
+	redisClient *redis.Client,
 ) *Server {
 	// Create interceptor for authentication
-	authInterceptor := NewAuthInterceptor(jwtService)
+	authInterceptor := NewAuthInterceptor(jwtService, apiKeyService, apiKeyRepo)
 
 	// Create gRPC server with interceptors
 	grpcServer := grpc.NewServer(
@@ -39,20 +57,26 @@ func NewServer(
 		grpc.StreamInterceptor(authInterceptor.Stream()),
 	)
 
-	// Create service server
-	msgServiceServer := NewMessageServiceServer(messageService, chatService, messageBroker)
+	// Create service servers
+	msgServiceServer := NewMessageServiceServer(messageService, chatService, botService, outboundService, callbackService, chatRepo, chatPermRepo, botPermRepo, messageBroker, redisClient)
+	chatServiceServer := NewChatServiceServer(chatService, chatPermRepo, redisClient)
+	botServiceServer := NewBotServiceServer(botService)
 
 	// Register services
 	pb.RegisterMessageServiceServer(grpcServer, msgServiceServer)
+	pb.RegisterChatServiceServer(grpcServer, chatServiceServer)
+	pb.RegisterBotServiceServer(grpcServer, botServiceServer)
 
 	return &Server{
 		grpcServer:     grpcServer,
 		messageService: msgServiceServer,
+		chatService:    chatServiceServer,
+		botService:     botServiceServer,
 		address:        address,
 	}
 }
 
-// Start starts the gRPC server
+// Start starts the gRPC server on its own listener
 func (s *Server) Start() error {
 	listener, err := net.Listen("tcp", s.address)
 	if err != nil {
@@ -61,6 +85,11 @@ func (s *Server) Start() error {
 
 	log.Printf("gRPC server listening on %s", s.address)
 
+	return s.Serve(listener)
+}
+
+// Serve serves gRPC on an existing listener (e.g. a cmux shared-port listener)
+func (s *Server) Serve(listener net.Listener) error {
 	if err := s.grpcServer.Serve(listener); err != nil {
 		return fmt.Errorf("failed to serve: %w", err)
 	}
@@ -76,13 +105,17 @@ func (s *Server) Stop() {
 
 // AuthInterceptor provides authentication for gRPC requests
 type AuthInterceptor struct {
-	jwtService *jwt.Service
+	jwtService    *jwt.Service
+	apiKeyService *apikey.Service
+	apiKeyRepo    repository.APIKeyRepository
 }
 
 // NewAuthInterceptor creates a new auth interceptor
-func NewAuthInterceptor(jwtService *jwt.Service) *AuthInterceptor {
+func NewAuthInterceptor(jwtService *jwt.Service, apiKeyService *apikey.Service, apiKeyRepo repository.APIKeyRepository) *AuthInterceptor {
 	return &AuthInterceptor{
-		jwtService: jwtService,
+		jwtService:    jwtService,
+		apiKeyService: apiKeyService,
+		apiKeyRepo:    apiKeyRepo,
 	}
 }
 
@@ -94,11 +127,14 @@ func (a *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		// Extract and validate token
+		// Extract and validate credentials
 		newCtx, err := a.authenticate(ctx)
 		if err != nil {
 			return nil, err
 		}
+		if err := authorizeScope(newCtx, info.FullMethod); err != nil {
+			return nil, err
+		}
 
 		return handler(newCtx, req)
 	}
@@ -112,11 +148,14 @@ func (a *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
 		info *grpc.StreamServerInfo,
 		handler grpc.StreamHandler,
 	) error {
-		// Extract and validate token
+		// Extract and validate credentials
 		newCtx, err := a.authenticate(stream.Context())
 		if err != nil {
 			return err
 		}
+		if err := authorizeScope(newCtx, info.FullMethod); err != nil {
+			return err
+		}
 
 		wrapped := &wrappedStream{
 			ServerStream: stream,
@@ -127,38 +166,94 @@ func (a *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
 	}
 }
 
-// authenticate validates the JWT token and adds user info to context
+// authenticate validates credentials from metadata the same way AuthMiddleware does:
+// a JWT in "authorization: Bearer <token>" first, then an API key in "x-api-key".
 func (a *AuthInterceptor) authenticate(ctx context.Context) (context.Context, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, fmt.Errorf("no metadata provided")
+		return nil, status.Error(codes.Unauthenticated, "no metadata provided")
 	}
 
-	// Get authorization header
-	authHeaders := md.Get("authorization")
-	if len(authHeaders) == 0 {
-		return nil, fmt.Errorf("no authorization header")
+	if authHeaders := md.Get("authorization"); len(authHeaders) > 0 && strings.HasPrefix(authHeaders[0], "Bearer ") {
+		token := strings.TrimPrefix(authHeaders[0], "Bearer ")
+		if authCtx, err := middleware.AuthenticateJWT(a.jwtService, token); err == nil {
+			return middleware.NewContextWithAuth(ctx, authCtx), nil
+		}
 	}
 
-	token := authHeaders[0]
-	if len(token) > 7 && token[:7] == "Bearer " {
-		token = token[7:]
+	if apiKeys := md.Get("x-api-key"); len(apiKeys) > 0 && apiKeys[0] != "" {
+		authCtx, err := middleware.AuthenticateAPIKey(ctx, a.apiKeyService, a.apiKeyRepo, apiKeys[0])
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return middleware.NewContextWithAuth(ctx, authCtx), nil
 	}
 
-	// Validate token
-	claims, err := a.jwtService.ValidateToken(token)
+	return nil, status.Error(codes.Unauthenticated, "authentication required")
+}
+
+// methodScopes lists the API key scope each method requires. API keys may only call
+// methods listed here or in adminMethods; users need no scope.
+var methodScopes = map[string]string{
+	"/gateway.MessageService/StreamMessages":      middleware.ScopeMessagesRead,
+	"/gateway.MessageService/StreamChatMessages":  middleware.ScopeMessagesRead,
+	"/gateway.MessageService/GetMessages":         middleware.ScopeMessagesRead,
+	"/gateway.MessageService/SendMessage":         middleware.ScopeMessagesSend,
+	"/gateway.MessageService/SendMedia":           middleware.ScopeMessagesSend,
+	"/gateway.MessageService/SendLocation":        middleware.ScopeMessagesSend,
+	"/gateway.MessageService/SendMediaGroup":      middleware.ScopeMessagesSend,
+	"/gateway.MessageService/AnswerCallbackQuery": middleware.ScopeMessagesSend,
+	"/gateway.ChatService/ListChats":              middleware.ScopeChatsRead,
+	"/gateway.ChatService/GetChat":                middleware.ScopeChatsRead,
+	"/gateway.BotService/ListBots":                middleware.ScopeBotsRead,
+	"/gateway.BotService/GetBot":                  middleware.ScopeBotsRead,
+}
+
+// adminMethods lists the methods reserved for admin users, which API keys cannot call
+var adminMethods = map[string]bool{
+	"/gateway.BotService/CreateBot": true,
+	"/gateway.BotService/DeleteBot": true,
+}
+
+// authorizeScope checks that the caller may call a method: API keys are denied admin
+// methods and methods without a scope, and need the scope of the others
+func authorizeScope(ctx context.Context, fullMethod string) error {
+	authCtx, err := authFromContext(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("invalid token: %w", err)
+		return err
+	}
+	if !authCtx.IsAPIKey {
+		return nil
 	}
 
-	// Add user info to metadata
-	newMD := metadata.Pairs(
-		"user-id", fmt.Sprintf("%d", claims.UserID),
-		"username", claims.Username,
-	)
-	newCtx := metadata.NewIncomingContext(ctx, metadata.Join(md, newMD))
+	if adminMethods[fullMethod] {
+		return status.Error(codes.PermissionDenied, "admin role required")
+	}
+	scope, ok := methodScopes[fullMethod]
+	if !ok {
+		return status.Error(codes.PermissionDenied, "method is not available to API keys")
+	}
+	if !authCtx.HasScope(scope) {
+		return status.Error(codes.PermissionDenied, middleware.ScopeDeniedMessage(scope))
+	}
+	return nil
+}
 
-	return newCtx, nil
+// authFromContext returns the caller's auth context set by the interceptor
+func authFromContext(ctx context.Context) (*middleware.AuthContext, error) {
+	authCtx, ok := middleware.AuthContextFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	return authCtx, nil
+}
+
+// callerName describes the caller for logging
+func callerName(authCtx *middleware.AuthContext) string {
+	if authCtx.IsAPIKey {
+		return fmt.Sprintf("API key %d", *authCtx.APIKeyID)
+	}
+	return fmt.Sprintf("user %d", authCtx.UserID)
 }
 
 // wrappedStream wraps a grpc.ServerStream with a custom context
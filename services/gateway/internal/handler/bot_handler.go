@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
 	"github.com/kexi/telegram-bot-gateway/internal/service"
 )
 
@@ -23,25 +24,47 @@ func NewBotHandler(botService *service.BotService) *BotHandler {
 
 // ListBots handles listing bots
 // @Summary List bots
-// @Description Get list of registered bots
+// @Description Get the bots visible to the caller, ordered by ID
 // @Tags bots
 // @Produce json
 // @Param offset query int false "Offset"
-// @Param limit query int false "Limit"
-// @Success 200 {array} service.BotDTO
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Param is_active query bool false "Only active or inactive bots"
+// @Param search query string false "Matches username or display name"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/bots [get]
 func (h *BotHandler) ListBots(c *gin.Context) {
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	authCtx, exists := middleware.GetAuthContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	offset, limit := listPage(c)
+
+	filter := middleware.VisibleBotFilter(authCtx)
+	filter.Search = c.Query("search")
+	isActive, err := queryBool(c, "is_active")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid is_active"})
+		return
+	}
+	filter.IsActive = isActive
 
-	bots, err := h.botService.ListBots(c.Request.Context(), offset, limit)
+	bots, total, err := h.botService.ListBotsFiltered(c.Request.Context(), filter, offset, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, bots)
+	c.JSON(http.StatusOK, gin.H{
+		"bots":   bots,
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	})
 }
 
 // GetBot handles getting a specific bot
@@ -54,13 +77,20 @@ func (h *BotHandler) ListBots(c *gin.Context) {
 // @Failure 404 {object} ErrorResponse
 // @Router /api/v1/bots/{id} [get]
 func (h *BotHandler) GetBot(c *gin.Context) {
+	authCtx, exists := middleware.GetAuthContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bot ID"})
 		return
 	}
 
-	bot, err := h.botService.GetBot(c.Request.Context(), uint(id))
+	// Bots the caller may not see are reported as missing, like in ListBots
+	bot, err := h.botService.GetVisibleBot(c.Request.Context(), uint(id), middleware.VisibleBotFilter(authCtx))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+// CallbackQueryHandler handles inline keyboard callback query endpoints
+type CallbackQueryHandler struct {
+	callbackService *service.CallbackQueryService
+	chatPermRepo    repository.ChatPermissionRepository
+	botPermRepo     repository.APIKeyBotPermissionRepository
+	redisClient     *redis.Client
+}
+
+// NewCallbackQueryHandler creates a new callback query handler
+func NewCallbackQueryHandler(
+	callbackService *service.CallbackQueryService,
+	chatPermRepo repository.ChatPermissionRepository,
+	botPermRepo repository.APIKeyBotPermissionRepository,
+	redisClient *redis.Client,
+) *CallbackQueryHandler {
+	return &CallbackQueryHandler{
+		callbackService: callbackService,
+		chatPermRepo:    chatPermRepo,
+		botPermRepo:     botPermRepo,
+		redisClient:     redisClient,
+	}
+}
+
+// AnswerCallbackQueryRequest represents an answerCallbackQuery request
+type AnswerCallbackQueryRequest struct {
+	Text      string `json:"text,omitempty" binding:"max=200"`
+	ShowAlert bool   `json:"show_alert,omitempty"`
+	URL       string `json:"url,omitempty"`
+	CacheTime int    `json:"cache_time,omitempty" binding:"min=0"`
+}
+
+// GetCallbackQuery handles getting a callback query
+// @Summary Get callback query
+// @Description Get a stored inline keyboard callback query by its Telegram ID
+// @Tags callback-queries
+// @Produce json
+// @Param query_id path string true "Telegram callback query ID"
+// @Success 200 {object} service.CallbackQueryDTO
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/callback-queries/{query_id} [get]
+func (h *CallbackQueryHandler) GetCallbackQuery(c *gin.Context) {
+	query, ok := h.lookupCallbackQuery(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, query)
+}
+
+// AnswerCallbackQuery handles answering a callback query
+// @Summary Answer callback query
+// @Description Answer an inline keyboard button press with answerCallbackQuery
+// @Tags callback-queries
+// @Accept json
+// @Produce json
+// @Param query_id path string true "Telegram callback query ID"
+// @Param request body AnswerCallbackQueryRequest false "Answer options"
+// @Success 200 {object} service.CallbackQueryDTO
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/callback-queries/{query_id}/answer [post]
+func (h *CallbackQueryHandler) AnswerCallbackQuery(c *gin.Context) {
+	query, ok := h.lookupCallbackQuery(c)
+	if !ok {
+		return
+	}
+
+	// Answering goes through the bot, so API keys need its grant
+	authCtx, _ := middleware.GetAuthContext(c)
+	botAllowed, err := middleware.CheckBotPermission(c.Request.Context(), authCtx, query.BotID, h.botPermRepo, h.redisClient)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check bot permissions"})
+		return
+	}
+	if !botAllowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": middleware.BotPermissionDeniedMessage(query.BotID)})
+		return
+	}
+
+	var req AnswerCallbackQueryRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	answered, err := h.callbackService.AnswerCallbackQuery(c.Request.Context(), query.QueryID, &service.AnswerCallbackQueryRequest{
+		Text:      req.Text,
+		ShowAlert: req.ShowAlert,
+		URL:       req.URL,
+		CacheTime: req.CacheTime,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrCallbackQueryAnswered) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": answered})
+}
+
+// lookupCallbackQuery loads the callback query in the URL and checks the caller may handle it.
+// Routed queries belong to the API key that sent the keyboard; others need can_send on the chat,
+// and inline-mode queries without a chat are reserved for admins.
+func (h *CallbackQueryHandler) lookupCallbackQuery(c *gin.Context) (*service.CallbackQueryDTO, bool) {
+	authCtx, exists := middleware.GetAuthContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return nil, false
+	}
+
+	query, err := h.callbackService.GetCallbackQuery(c.Request.Context(), c.Param("query_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Callback query not found"})
+		return nil, false
+	}
+
+	var allowed bool
+	switch {
+	case isAdmin(authCtx):
+		allowed = true
+	case query.APIKeyID != nil:
+		allowed = authCtx.IsAPIKey && *authCtx.APIKeyID == *query.APIKeyID
+	case query.ChatID != nil:
+		allowed, err = middleware.CheckChatPermission(c.Request.Context(), authCtx, *query.ChatID, middleware.PermissionSend, h.chatPermRepo, h.redisClient)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			return nil, false
+		}
+	}
+
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for this callback query: " + callbackQueryGrant(query)})
+		return nil, false
+	}
+
+	return query, true
+}
+
+// callbackQueryGrant describes what is needed to handle a callback query
+func callbackQueryGrant(query *service.CallbackQueryDTO) string {
+	switch {
+	case query.APIKeyID != nil:
+		return "routed to the API key that sent the keyboard"
+	case query.ChatID != nil:
+		return "can_send on the chat required"
+	default:
+		return "admin role required"
+	}
+}
+
+// isAdmin reports whether the caller is a JWT-authenticated user with the admin role
+func isAdmin(authCtx *middleware.AuthContext) bool {
+	if authCtx.IsAPIKey {
+		return false
+	}
+	for _, role := range authCtx.Roles {
+		if role == "admin" {
+			return true
+		}
+	}
+	return false
+}
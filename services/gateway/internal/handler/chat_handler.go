@@ -1,56 +1,97 @@
 package handler
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
 	"github.com/kexi/telegram-bot-gateway/internal/repository"
 	"github.com/kexi/telegram-bot-gateway/internal/service"
 )
 
 // ChatHandler handles chat endpoints
 type ChatHandler struct {
-	chatService    *service.ChatService
-	messageService *service.MessageService
-	chatRepo       repository.ChatRepository
-	botService     *service.BotService
+	chatService        *service.ChatService
+	messageService     *service.MessageService
+	chatRepo           repository.ChatRepository
+	outboundService    *service.OutboundService
+	updateEventService *service.UpdateEventService
 }
 
 // NewChatHandler creates a new chat handler
-func NewChatHandler(chatService *service.ChatService, messageService *service.MessageService, chatRepo repository.ChatRepository, botService *service.BotService) *ChatHandler {
+func NewChatHandler(chatService *service.ChatService, messageService *service.MessageService, chatRepo repository.ChatRepository, outboundService *service.OutboundService, updateEventService *service.UpdateEventService) *ChatHandler {
 	return &ChatHandler{
-		chatService:    chatService,
-		messageService: messageService,
-		chatRepo:       chatRepo,
-		botService:     botService,
+		chatService:        chatService,
+		messageService:     messageService,
+		chatRepo:           chatRepo,
+		outboundService:    outboundService,
+		updateEventService: updateEventService,
 	}
 }
 
 // ListChats handles listing chats
 // @Summary List chats
-// @Description Get list of accessible chats
+// @Description Get the chats the caller can read, ordered by ID
 // @Tags chats
 // @Produce json
 // @Param offset query int false "Offset"
-// @Param limit query int false "Limit"
-// @Success 200 {array} service.ChatDTO
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Param bot_id query int false "Only chats of this bot"
+// @Param type query string false "private, group, supergroup or channel"
+// @Param is_active query bool false "Only active or inactive chats"
+// @Param search query string false "Matches title, username, first or last name"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/chats [get]
 func (h *ChatHandler) ListChats(c *gin.Context) {
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	authCtx, exists := middleware.GetAuthContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
 
-	chats, err := h.chatService.ListChats(c.Request.Context(), offset, limit)
+	offset, limit := listPage(c)
+
+	filter := middleware.ReadableChatFilter(authCtx)
+	filter.Type = c.Query("type")
+	filter.Search = c.Query("search")
+	if raw := c.Query("bot_id"); raw != "" {
+		botID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bot_id"})
+			return
+		}
+		id := uint(botID)
+		filter.BotID = &id
+	}
+	isActive, err := queryBool(c, "is_active")
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid is_active"})
+		return
+	}
+	filter.IsActive = isActive
+
+	chats, total, err := h.chatService.ListChatsFiltered(c.Request.Context(), filter, offset, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidChatType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, chats)
+	c.JSON(http.StatusOK, gin.H{
+		"chats":  chats,
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	})
 }
 
 // GetChat handles getting a specific chat
@@ -119,15 +160,64 @@ func (h *ChatHandler) GetMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, messages)
 }
 
+// GetEvents handles getting the non-message updates of a chat
+// @Summary Get chat events
+// @Description Get the stored non-message updates of a chat (member changes, join requests, reactions, ...) with cursor-based pagination
+// @Tags chats
+// @Produce json
+// @Param id path int true "Chat ID"
+// @Param type query string false "Event type, e.g. chat_member"
+// @Param cursor query string false "Cursor (RFC3339 timestamp)"
+// @Param limit query int false "Limit"
+// @Success 200 {array} service.UpdateEventDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/events [get]
+func (h *ChatHandler) GetEvents(c *gin.Context) {
+	telegramChatID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	var cursor *time.Time
+	cursorStr := c.Query("cursor")
+	if cursorStr != "" {
+		t, err := time.Parse(time.RFC3339, cursorStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor format"})
+			return
+		}
+		cursor = &t
+	}
+
+	chat, err := h.chatRepo.GetByTelegramID(c.Request.Context(), telegramChatID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+		return
+	}
+
+	events, err := h.updateEventService.ListChatEvents(c.Request.Context(), chat.ID, c.Query("type"), cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
 // SendMessage handles sending a message to a chat
 // @Summary Send message
-// @Description Send a message to a chat (requires can_send permission)
+// @Description Send a text message to a chat (requires can_send permission). The message is stored and published as outgoing.
 // @Tags chats
 // @Accept json
 // @Produce json
 // @Param id path int true "Chat ID"
 // @Param request body SendMessageRequest true "Message content"
-// @Success 200 {object} SuccessResponse
+// @Success 200 {object} service.MessageDTO
+// @Success 202 {object} service.OutboundMessageDTO
 // @Failure 400 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
 // @Router /api/v1/chats/{id}/messages [post]
@@ -139,7 +229,13 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 	}
 
 	var req SendMessageRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts, err := bindSendOptions(c, &req.SendOptionsRequest)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -151,28 +247,62 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	// Send message via BotService (handles token decryption internally)
-	err = h.botService.SendTelegramMessage(c.Request.Context(), chat.BotID, telegramChatID, req.Text, req.ReplyToMessageID, req.ParseMode)
+	if req.Async || req.SendAt != nil {
+		call, err := service.NewTextCall(chat, req.Text, &opts)
+		queueSend(c, h.outboundService, call, err)
+		return
+	}
+
+	// Send, store and publish the message (token decryption is handled by BotService)
+	msg, err := h.outboundService.SendText(c.Request.Context(), chat, req.Text, &opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"ok":          false,
-			"description": fmt.Sprintf("Failed to send message: %v", err),
-		})
+		respondSendError(c, err)
 		return
 	}
 
-	// Return Telegram Bot API-compatible response
+	// Return Telegram Bot API-compatible response with the stored message
 	c.JSON(http.StatusOK, gin.H{
-		"ok":      true,
-		"chat_id": telegramChatID,
-		"text":    req.Text,
-		"sent_at": time.Now(),
+		"ok":         true,
+		"chat_id":    telegramChatID,
+		"message_id": msg.TelegramID,
+		"text":       msg.Text,
+		"sent_at":    msg.SentAt,
+		"result":     msg,
 	})
 }
 
 // SendMessageRequest represents a message send request
 type SendMessageRequest struct {
-	Text             string `json:"text" binding:"required"`
-	ParseMode        string `json:"parse_mode,omitempty"`
-	ReplyToMessageID *int64 `json:"reply_to_message_id,omitempty"`
+	Text string `json:"text" form:"text" binding:"required"`
+	SendOptionsRequest
+}
+
+// listPage reads the offset and limit query parameters of a list endpoint.
+// The limit defaults to 20 and is capped at 100.
+func listPage(c *gin.Context) (offset, limit int) {
+	offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return offset, limit
+}
+
+// queryBool reads an optional boolean query parameter; nil when it is absent
+func queryBool(c *gin.Context, name string) (*bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
 }
@@ -0,0 +1,855 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+// MessageHandler handles outbound message endpoints beyond plain text:
+// rich media, edits, deletions, pins and forwards
+type MessageHandler struct {
+	outboundService *service.OutboundService
+	messageService  *service.MessageService
+	mediaService    *service.MediaService
+	chatRepo        repository.ChatRepository
+	chatPermRepo    repository.ChatPermissionRepository
+	redisClient     *redis.Client
+}
+
+// NewMessageHandler creates a new message handler
+func NewMessageHandler(
+	outboundService *service.OutboundService,
+	messageService *service.MessageService,
+	mediaService *service.MediaService,
+	chatRepo repository.ChatRepository,
+	chatPermRepo repository.ChatPermissionRepository,
+	redisClient *redis.Client,
+) *MessageHandler {
+	return &MessageHandler{
+		outboundService: outboundService,
+		messageService:  messageService,
+		mediaService:    mediaService,
+		chatRepo:        chatRepo,
+		chatPermRepo:    chatPermRepo,
+		redisClient:     redisClient,
+	}
+}
+
+// SendOptionsRequest holds the options shared by every send endpoint.
+// In multipart requests reply_markup is a JSON-encoded string.
+type SendOptionsRequest struct {
+	ParseMode           string          `json:"parse_mode,omitempty" form:"parse_mode"`
+	ReplyToMessageID    *int64          `json:"reply_to_message_id,omitempty" form:"reply_to_message_id"`
+	DisableNotification bool            `json:"disable_notification,omitempty" form:"disable_notification"`
+	MessageThreadID     *int64          `json:"message_thread_id,omitempty" form:"message_thread_id"`
+	ReplyMarkup         json.RawMessage `json:"reply_markup,omitempty" form:"-"`
+	Async               bool            `json:"async,omitempty" form:"async"`     // Queue the send and return 202 with its delivery status
+	SendAt              *time.Time      `json:"send_at,omitempty" form:"send_at"` // Schedule the send for later (RFC 3339); implies async
+}
+
+// SendMediaRequest represents a photo, document or voice send request.
+// The media field named after the type holds a URL or file_id; in multipart requests it may be a file upload.
+type SendMediaRequest struct {
+	Photo    string `json:"photo,omitempty" form:"photo"`
+	Document string `json:"document,omitempty" form:"document"`
+	Voice    string `json:"voice,omitempty" form:"voice"`
+	Caption  string `json:"caption,omitempty" form:"caption"`
+	SendOptionsRequest
+}
+
+// SendLocationRequest represents a location send request
+type SendLocationRequest struct {
+	Latitude  *float64 `json:"latitude" form:"latitude" binding:"required"`
+	Longitude *float64 `json:"longitude" form:"longitude" binding:"required"`
+	SendOptionsRequest
+}
+
+// SendMediaGroupRequest represents a media group send request.
+// In multipart requests media is a JSON-encoded string and uploads are referenced as attach://<field>.
+type SendMediaGroupRequest struct {
+	Media []service.MediaGroupItem `json:"media" form:"-"`
+	SendOptionsRequest
+}
+
+// EditMessageRequest represents a message text edit request
+type EditMessageRequest struct {
+	Text        string          `json:"text" form:"text" binding:"required"`
+	ParseMode   string          `json:"parse_mode,omitempty" form:"parse_mode"`
+	ReplyMarkup json.RawMessage `json:"reply_markup,omitempty" form:"-"`
+}
+
+// PinMessageRequest represents a pin request
+type PinMessageRequest struct {
+	DisableNotification bool `json:"disable_notification,omitempty" form:"disable_notification"`
+}
+
+// ForwardMessageRequest represents a forward or copy request.
+// FromChatID is the Telegram chat ID of the source chat.
+type ForwardMessageRequest struct {
+	FromChatID *int64 `json:"from_chat_id" form:"from_chat_id" binding:"required"`
+	MessageID  *int64 `json:"message_id" form:"message_id" binding:"required"`
+	Caption    string `json:"caption,omitempty" form:"caption"` // Copy only; replaces the original caption
+	SendOptionsRequest
+}
+
+// SendPhoto handles sending a photo
+// @Summary Send photo
+// @Description Send a photo by URL, file_id or multipart upload (requires can_send permission)
+// @Tags messages
+// @Accept json,mpfd
+// @Produce json
+// @Param id path int true "Telegram chat ID"
+// @Param request body SendMediaRequest true "Photo and options"
+// @Success 200 {object} service.MessageDTO
+// @Success 202 {object} service.OutboundMessageDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/photo [post]
+func (h *MessageHandler) SendPhoto(c *gin.Context) {
+	h.sendMedia(c, service.MediaTypePhoto)
+}
+
+// SendDocument handles sending a document
+// @Summary Send document
+// @Description Send a document by URL, file_id or multipart upload (requires can_send permission)
+// @Tags messages
+// @Accept json,mpfd
+// @Produce json
+// @Param id path int true "Telegram chat ID"
+// @Param request body SendMediaRequest true "Document and options"
+// @Success 200 {object} service.MessageDTO
+// @Success 202 {object} service.OutboundMessageDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/document [post]
+func (h *MessageHandler) SendDocument(c *gin.Context) {
+	h.sendMedia(c, service.MediaTypeDocument)
+}
+
+// SendVoice handles sending a voice message
+// @Summary Send voice
+// @Description Send an OGG/Opus voice message by URL, file_id or multipart upload (requires can_send permission)
+// @Tags messages
+// @Accept json,mpfd
+// @Produce json
+// @Param id path int true "Telegram chat ID"
+// @Param request body SendMediaRequest true "Voice and options"
+// @Success 200 {object} service.MessageDTO
+// @Success 202 {object} service.OutboundMessageDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/voice [post]
+func (h *MessageHandler) SendVoice(c *gin.Context) {
+	h.sendMedia(c, service.MediaTypeVoice)
+}
+
+// SendLocation handles sending a location
+// @Summary Send location
+// @Description Send a point on the map (requires can_send permission)
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param id path int true "Telegram chat ID"
+// @Param request body SendLocationRequest true "Location and options"
+// @Success 200 {object} service.MessageDTO
+// @Success 202 {object} service.OutboundMessageDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/location [post]
+func (h *MessageHandler) SendLocation(c *gin.Context) {
+	chat, ok := h.lookupChat(c)
+	if !ok {
+		return
+	}
+
+	var req SendLocationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts, err := bindSendOptions(c, &req.SendOptionsRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sendReq := &service.SendLocationRequest{
+		Latitude:    *req.Latitude,
+		Longitude:   *req.Longitude,
+		SendOptions: opts,
+	}
+	if req.Async || req.SendAt != nil {
+		call, err := service.NewLocationCall(chat, sendReq)
+		queueSend(c, h.outboundService, call, err)
+		return
+	}
+
+	msg, err := h.outboundService.SendLocation(c.Request.Context(), chat, sendReq)
+	if err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": msg})
+}
+
+// SendMediaGroup handles sending an album
+// @Summary Send media group
+// @Description Send 2-10 photos, videos, documents or audios as an album (requires can_send permission)
+// @Tags messages
+// @Accept json,mpfd
+// @Produce json
+// @Param id path int true "Telegram chat ID"
+// @Param request body SendMediaGroupRequest true "Media items and options"
+// @Success 200 {array} service.MessageDTO
+// @Success 202 {object} service.OutboundMessageDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/media-group [post]
+func (h *MessageHandler) SendMediaGroup(c *gin.Context) {
+	chat, ok := h.lookupChat(c)
+	if !ok {
+		return
+	}
+
+	var req SendMediaGroupRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var files []service.InputFile
+	if form, err := c.MultipartForm(); err == nil {
+		if err := json.Unmarshal([]byte(c.PostForm("media")), &req.Media); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "media must be a JSON array of InputMedia objects"})
+			return
+		}
+
+		// Attach every uploaded file under its field name so attach://<field> references resolve
+		for field, headers := range form.File {
+			if len(headers) == 0 {
+				continue
+			}
+			file, err := headers[0].Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read %s", field)})
+				return
+			}
+			defer file.Close()
+			files = append(files, service.InputFile{Field: field, FileName: headers[0].Filename, Reader: file})
+		}
+	}
+
+	opts, err := bindSendOptions(c, &req.SendOptionsRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sendReq := &service.SendMediaGroupRequest{
+		Media:       req.Media,
+		Files:       files,
+		SendOptions: opts,
+	}
+	if req.Async || req.SendAt != nil {
+		call, err := service.NewMediaGroupCall(chat, sendReq)
+		queueSend(c, h.outboundService, call, err)
+		return
+	}
+
+	messages, err := h.outboundService.SendMediaGroup(c.Request.Context(), chat, sendReq)
+	if err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": messages})
+}
+
+// EditMessage handles editing the text of a message
+// @Summary Edit message text
+// @Description Edit a message's text and record the previous text (requires can_send permission)
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param id path int true "Telegram chat ID"
+// @Param message_id path int true "Telegram message ID"
+// @Param request body EditMessageRequest true "New text"
+// @Success 200 {object} service.MessageDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/messages/{message_id} [put]
+func (h *MessageHandler) EditMessage(c *gin.Context) {
+	chat, messageID, ok := h.lookupMessage(c)
+	if !ok {
+		return
+	}
+
+	var req EditMessageRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts, err := bindSendOptions(c, &SendOptionsRequest{ReplyMarkup: req.ReplyMarkup})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	msg, err := h.outboundService.EditText(c.Request.Context(), chat, messageID, req.Text, &service.EditOptions{
+		ParseMode:   req.ParseMode,
+		ReplyMarkup: opts.ReplyMarkup,
+	})
+	if err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": msg})
+}
+
+// DeleteMessage handles deleting a message
+// @Summary Delete message
+// @Description Delete a message from the chat and flag the stored copy as deleted (requires can_manage permission)
+// @Tags messages
+// @Produce json
+// @Param id path int true "Telegram chat ID"
+// @Param message_id path int true "Telegram message ID"
+// @Success 200 {object} service.MessageDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/messages/{message_id} [delete]
+func (h *MessageHandler) DeleteMessage(c *gin.Context) {
+	chat, messageID, ok := h.lookupMessage(c)
+	if !ok {
+		return
+	}
+
+	msg, err := h.outboundService.DeleteMessage(c.Request.Context(), chat, messageID)
+	if err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": msg})
+}
+
+// GetMessageEdits handles listing the edit history of a message
+// @Summary Get message edit history
+// @Description List previous texts of an edited message, oldest first (requires can_read permission)
+// @Tags messages
+// @Produce json
+// @Param id path int true "Telegram chat ID"
+// @Param message_id path int true "Telegram message ID"
+// @Success 200 {array} service.MessageEditDTO
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/messages/{message_id}/edits [get]
+func (h *MessageHandler) GetMessageEdits(c *gin.Context) {
+	chat, messageID, ok := h.lookupMessage(c)
+	if !ok {
+		return
+	}
+
+	msg, err := h.messageService.GetMessageByTelegramID(c.Request.Context(), chat.ID, messageID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	edits, err := h.messageService.ListEdits(c.Request.Context(), msg.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": msg, "edits": edits})
+}
+
+// GetMessageMedia handles downloading the file attached to a received message
+// @Summary Get message media
+// @Description Download the photo, video, document, voice note or other file attached to a received message (requires can_read permission)
+// @Tags messages
+// @Produce octet-stream
+// @Param id path int true "Telegram chat ID"
+// @Param message_id path int true "Telegram message ID"
+// @Success 200 {file} binary
+// @Failure 404 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/messages/{message_id}/media [get]
+func (h *MessageHandler) GetMessageMedia(c *gin.Context) {
+	chat, messageID, ok := h.lookupMessage(c)
+	if !ok {
+		return
+	}
+
+	msg, err := h.messageService.GetMessageByTelegramID(c.Request.Context(), chat.ID, messageID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	body, size, file, err := h.mediaService.OpenMedia(c.Request.Context(), msg.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMediaNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrMediaTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error(), "media": file})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	defer body.Close()
+
+	// Files come from chat members, so they are always downloaded, never rendered by the browser
+	params := map[string]string{}
+	if file.FileName != "" {
+		params["filename"] = file.FileName
+	}
+	headers := map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", params),
+		"X-Content-Type-Options": "nosniff",
+	}
+
+	c.DataFromReader(http.StatusOK, size, mediaContentType(file.MimeType), body, headers)
+}
+
+// servedMediaTypes are the MIME types media is served with; others, such as HTML or SVG
+// documents that a browser would run scripts in, are served as application/octet-stream
+var servedMediaTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"video/mp4":       true,
+	"video/mpeg":      true,
+	"video/quicktime": true,
+	"video/webm":      true,
+	"audio/mpeg":      true,
+	"audio/mp4":       true,
+	"audio/ogg":       true,
+	"audio/webm":      true,
+	"application/pdf": true,
+	"application/zip": true,
+	"text/plain":      true,
+}
+
+// mediaContentType returns the Content-Type a file is served with, based on the MIME type
+// reported by its sender
+func mediaContentType(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil || !servedMediaTypes[mediaType] {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// PinMessage handles pinning a message
+// @Summary Pin message
+// @Description Pin a message in the chat (requires can_manage permission)
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param id path int true "Telegram chat ID"
+// @Param message_id path int true "Telegram message ID"
+// @Param request body PinMessageRequest false "Pin options"
+// @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/messages/{message_id}/pin [post]
+func (h *MessageHandler) PinMessage(c *gin.Context) {
+	chat, messageID, ok := h.lookupMessage(c)
+	if !ok {
+		return
+	}
+
+	var req PinMessageRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.outboundService.PinMessage(c.Request.Context(), chat, messageID, req.DisableNotification); err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// UnpinMessage handles unpinning a message
+// @Summary Unpin message
+// @Description Unpin a message in the chat (requires can_manage permission)
+// @Tags messages
+// @Produce json
+// @Param id path int true "Telegram chat ID"
+// @Param message_id path int true "Telegram message ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/messages/{message_id}/pin [delete]
+func (h *MessageHandler) UnpinMessage(c *gin.Context) {
+	chat, messageID, ok := h.lookupMessage(c)
+	if !ok {
+		return
+	}
+
+	if err := h.outboundService.UnpinMessage(c.Request.Context(), chat, messageID); err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// ForwardMessage handles forwarding a message into the chat
+// @Summary Forward message
+// @Description Forward a message from another chat of the same bot (requires can_send on this chat and can_read on the source chat)
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param id path int true "Telegram chat ID of the target chat"
+// @Param request body ForwardMessageRequest true "Source message"
+// @Success 200 {object} service.MessageDTO
+// @Success 202 {object} service.OutboundMessageDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/forward [post]
+func (h *MessageHandler) ForwardMessage(c *gin.Context) {
+	h.forward(c, false)
+}
+
+// CopyMessage handles copying a message into the chat
+// @Summary Copy message
+// @Description Copy a message from another chat of the same bot without the forward header (requires can_send on this chat and can_read on the source chat)
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param id path int true "Telegram chat ID of the target chat"
+// @Param request body ForwardMessageRequest true "Source message"
+// @Success 200 {object} service.MessageDTO
+// @Success 202 {object} service.OutboundMessageDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/chats/{id}/copy [post]
+func (h *MessageHandler) CopyMessage(c *gin.Context) {
+	h.forward(c, true)
+}
+
+// forward forwards or copies a message after checking read access to the source chat
+func (h *MessageHandler) forward(c *gin.Context, copyMessage bool) {
+	chat, ok := h.lookupChat(c)
+	if !ok {
+		return
+	}
+
+	var req ForwardMessageRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts, err := bindSendOptions(c, &req.SendOptionsRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fromChat, err := h.chatRepo.GetByTelegramID(c.Request.Context(), *req.FromChatID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Source chat not found"})
+		return
+	}
+
+	// ChatACLMiddleware only covers the target chat
+	authCtx, _ := middleware.GetAuthContext(c)
+	allowed, err := middleware.CheckChatPermission(c.Request.Context(), authCtx, fromChat.ID, middleware.PermissionRead, h.chatPermRepo, h.redisClient)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for the source chat: can_read required"})
+		return
+	}
+
+	if req.Async || req.SendAt != nil {
+		var call *service.OutboundCall
+		if copyMessage {
+			call, err = service.NewCopyCall(chat, fromChat, *req.MessageID, req.Caption, &opts)
+		} else {
+			call, err = service.NewForwardCall(chat, fromChat, *req.MessageID, &opts)
+		}
+		queueSend(c, h.outboundService, call, err)
+		return
+	}
+
+	var msg *service.MessageDTO
+	if copyMessage {
+		msg, err = h.outboundService.CopyMessage(c.Request.Context(), chat, fromChat, *req.MessageID, req.Caption, &opts)
+	} else {
+		msg, err = h.outboundService.ForwardMessage(c.Request.Context(), chat, fromChat, *req.MessageID, &opts)
+	}
+	if err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": msg})
+}
+
+// sendMedia sends a photo, document or voice message from a JSON or multipart request
+func (h *MessageHandler) sendMedia(c *gin.Context, mediaType string) {
+	chat, ok := h.lookupChat(c)
+	if !ok {
+		return
+	}
+
+	var req SendMediaRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts, err := bindSendOptions(c, &req.SendOptionsRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sendReq := &service.SendMediaRequest{
+		Type:        mediaType,
+		Caption:     req.Caption,
+		SendOptions: opts,
+	}
+
+	switch mediaType {
+	case service.MediaTypePhoto:
+		sendReq.Media = req.Photo
+	case service.MediaTypeDocument:
+		sendReq.Media = req.Document
+	case service.MediaTypeVoice:
+		sendReq.Media = req.Voice
+	}
+
+	// A multipart upload takes precedence over a URL or file_id
+	if header, err := c.FormFile(mediaType); err == nil {
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read %s", mediaType)})
+			return
+		}
+		defer file.Close()
+		sendReq.File = &service.InputFile{FileName: header.Filename, Reader: file}
+	}
+
+	if sendReq.Media == "" && sendReq.File == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is required (URL, file_id or upload)", mediaType)})
+		return
+	}
+
+	if req.Async || req.SendAt != nil {
+		call, err := service.NewMediaCall(chat, sendReq)
+		queueSend(c, h.outboundService, call, err)
+		return
+	}
+
+	msg, err := h.outboundService.SendMedia(c.Request.Context(), chat, sendReq)
+	if err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": msg})
+}
+
+// lookupChat resolves the Telegram chat ID in the URL to a chat, writing a response on failure
+func (h *MessageHandler) lookupChat(c *gin.Context) (*domain.Chat, bool) {
+	telegramChatID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return nil, false
+	}
+
+	chat, err := h.chatRepo.GetByTelegramID(c.Request.Context(), telegramChatID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+		return nil, false
+	}
+
+	return chat, true
+}
+
+// lookupMessage resolves the chat and Telegram message ID in the URL, writing a response on failure
+func (h *MessageHandler) lookupMessage(c *gin.Context) (*domain.Chat, int64, bool) {
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return nil, 0, false
+	}
+
+	chat, ok := h.lookupChat(c)
+	if !ok {
+		return nil, 0, false
+	}
+
+	return chat, messageID, true
+}
+
+// bindSendOptions converts bound send options, reading reply_markup from the form in multipart requests.
+// The calling API key is recorded so callback queries can be routed back to it; the calling
+// user is recorded so the permissions of scheduled sends can be checked again at send time.
+func bindSendOptions(c *gin.Context, req *SendOptionsRequest) (service.SendOptions, error) {
+	opts := service.SendOptions{
+		ParseMode:           req.ParseMode,
+		ReplyToMessageID:    req.ReplyToMessageID,
+		DisableNotification: req.DisableNotification,
+		MessageThreadID:     req.MessageThreadID,
+		ReplyMarkup:         req.ReplyMarkup,
+	}
+
+	if authCtx, ok := middleware.GetAuthContext(c); ok {
+		if authCtx.IsAPIKey {
+			opts.SentByAPIKeyID = authCtx.APIKeyID
+		} else {
+			userID := authCtx.UserID
+			opts.SentByUserID = &userID
+		}
+	}
+	opts.SendAt = req.SendAt
+
+	if !strings.HasPrefix(c.ContentType(), "application/json") {
+		if markup := c.PostForm("reply_markup"); markup != "" {
+			if !json.Valid([]byte(markup)) {
+				return opts, fmt.Errorf("reply_markup must be a JSON object")
+			}
+			opts.ReplyMarkup = json.RawMessage(markup)
+		}
+	}
+
+	return opts, nil
+}
+
+// GetOutboundMessage handles reporting the delivery status of a queued send
+// @Summary Get outbound message status
+// @Description Get the delivery status of a message sent with "async": true or "send_at". Visible to the API key or user that queued it and to callers with can_send on its chat.
+// @Tags messages
+// @Produce json
+// @Param id path int true "Outbound message ID"
+// @Success 200 {object} service.OutboundMessageDTO
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/outbound/{id} [get]
+func (h *MessageHandler) GetOutboundMessage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid outbound message ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	outbound, err := h.outboundService.GetQueued(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Outbound message not found"})
+		return
+	}
+
+	authCtx, _ := middleware.GetAuthContext(c)
+	queuedByCaller := authCtx.IsAPIKey && authCtx.APIKeyID != nil && outbound.SentByAPIKeyID != nil &&
+		*authCtx.APIKeyID == *outbound.SentByAPIKeyID
+	if !authCtx.IsAPIKey && outbound.SentByUserID != nil {
+		queuedByCaller = *outbound.SentByUserID == authCtx.UserID
+	}
+	if !queuedByCaller {
+		allowed, err := middleware.CheckChatPermission(ctx, authCtx, outbound.ChatID, middleware.PermissionSend, h.chatPermRepo, h.redisClient)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions: can_send required"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": outbound})
+}
+
+// queueSend queues or schedules a prepared send for the outbound workers and responds 202
+// with its delivery status; err is the error from preparing the call
+func queueSend(c *gin.Context, outboundService *service.OutboundService, call *service.OutboundCall, err error) {
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	outbound, err := outboundService.Queue(c.Request.Context(), call)
+	if err != nil {
+		if errors.Is(err, service.ErrAsyncUpload) || errors.Is(err, service.ErrSendAtPast) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/v1/outbound/%d", outbound.ID))
+	c.JSON(http.StatusAccepted, gin.H{"ok": true, "result": outbound})
+}
+
+// respondSendError writes a Bot API-style error response for a failed send. Telegram's
+// 400 and 429 are passed through; its other errors must not look like the gateway's own
+// authentication and permission failures, so a rejected bot token is reported as 502 and a
+// bot that was blocked or removed from the chat as 409, with Telegram's code and description.
+func respondSendError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	response := gin.H{
+		"ok":          false,
+		"description": fmt.Sprintf("Failed to send message: %v", err),
+	}
+
+	var invalidErr *service.InvalidSendError
+	var apiErr *service.APIError
+	var netErr *service.NetworkError
+	switch {
+	case errors.As(err, &invalidErr):
+		status = http.StatusBadRequest
+	case errors.As(err, &apiErr):
+		response["error_code"] = apiErr.Code
+		switch apiErr.Code {
+		case http.StatusBadRequest, http.StatusTooManyRequests:
+			status = apiErr.Code
+		case http.StatusForbidden:
+			status = http.StatusConflict
+		default:
+			status = http.StatusBadGateway
+		}
+
+		if apiErr.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(apiErr.RetryAfter))
+			response["parameters"] = gin.H{"retry_after": apiErr.RetryAfter}
+		}
+	case errors.As(err, &netErr):
+		status = http.StatusBadGateway
+	}
+
+	c.JSON(status, response)
+}
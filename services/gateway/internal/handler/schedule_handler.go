@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+// ScheduleHandler manages messages scheduled with send_at
+type ScheduleHandler struct {
+	scheduleService *service.ScheduleService
+	chatRepo        repository.ChatRepository
+}
+
+// NewScheduleHandler creates a new schedule handler
+func NewScheduleHandler(scheduleService *service.ScheduleService, chatRepo repository.ChatRepository) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService: scheduleService,
+		chatRepo:        chatRepo,
+	}
+}
+
+// RescheduleRequest represents a new send time for a scheduled message
+type RescheduleRequest struct {
+	SendAt *time.Time `json:"send_at" form:"send_at" binding:"required"`
+}
+
+// ListScheduledMessages handles listing the messages scheduled by the caller
+// @Summary List scheduled messages
+// @Description List the messages scheduled by the calling API key or user, earliest send time first. Without status only messages still waiting for their send time are listed.
+// @Tags scheduled-messages
+// @Produce json
+// @Param chat_id query int false "Telegram chat ID"
+// @Param status query string false "scheduled (default), queued, sending, sent, failed or canceled"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (max 100)"
+// @Success 200 {array} service.OutboundMessageDTO
+// @Router /api/v1/scheduled-messages [get]
+func (h *ScheduleHandler) ListScheduledMessages(c *gin.Context) {
+	owner, ok := scheduleOwner(c)
+	if !ok {
+		return
+	}
+
+	offset, limit := listPage(c)
+
+	var chatID *uint
+	if raw := c.Query("chat_id"); raw != "" {
+		telegramChatID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat_id"})
+			return
+		}
+		chat, err := h.chatRepo.GetByTelegramID(c.Request.Context(), telegramChatID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+			return
+		}
+		chatID = &chat.ID
+	}
+
+	messages, total, err := h.scheduleService.ListScheduled(c.Request.Context(), owner, chatID, c.Query("status"), offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scheduled_messages": messages,
+		"total":              total,
+		"offset":             offset,
+		"limit":              limit,
+	})
+}
+
+// GetScheduledMessage handles getting a message scheduled by the caller
+// @Summary Get scheduled message
+// @Description Get a message scheduled by the calling API key or user
+// @Tags scheduled-messages
+// @Produce json
+// @Param id path int true "Scheduled message ID"
+// @Success 200 {object} service.OutboundMessageDTO
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/scheduled-messages/{id} [get]
+func (h *ScheduleHandler) GetScheduledMessage(c *gin.Context) {
+	owner, id, ok := scheduledMessageID(c)
+	if !ok {
+		return
+	}
+
+	msg, err := h.scheduleService.GetScheduled(c.Request.Context(), owner, id)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": msg})
+}
+
+// RescheduleMessage handles moving the send time of a scheduled message
+// @Summary Reschedule message
+// @Description Move the send time of a message that is still waiting for it
+// @Tags scheduled-messages
+// @Accept json
+// @Produce json
+// @Param id path int true "Scheduled message ID"
+// @Param request body RescheduleRequest true "New send time"
+// @Success 200 {object} service.OutboundMessageDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/scheduled-messages/{id} [patch]
+func (h *ScheduleHandler) RescheduleMessage(c *gin.Context) {
+	owner, id, ok := scheduledMessageID(c)
+	if !ok {
+		return
+	}
+
+	var req RescheduleRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	msg, err := h.scheduleService.Reschedule(c.Request.Context(), owner, id, *req.SendAt)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": msg})
+}
+
+// CancelScheduledMessage handles canceling a scheduled message
+// @Summary Cancel scheduled message
+// @Description Cancel a message that is still waiting for its send time. It is kept with status canceled.
+// @Tags scheduled-messages
+// @Produce json
+// @Param id path int true "Scheduled message ID"
+// @Success 200 {object} service.OutboundMessageDTO
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/scheduled-messages/{id} [delete]
+func (h *ScheduleHandler) CancelScheduledMessage(c *gin.Context) {
+	owner, id, ok := scheduledMessageID(c)
+	if !ok {
+		return
+	}
+
+	msg, err := h.scheduleService.Cancel(c.Request.Context(), owner, id)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "result": msg})
+}
+
+// scheduleOwner builds the owner of scheduled messages from the caller, writing a response on failure
+func scheduleOwner(c *gin.Context) (service.ScheduleOwner, bool) {
+	authCtx, exists := middleware.GetAuthContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return service.ScheduleOwner{}, false
+	}
+
+	if authCtx.IsAPIKey {
+		return service.ScheduleOwner{APIKeyID: authCtx.APIKeyID}, true
+	}
+	userID := authCtx.UserID
+	return service.ScheduleOwner{UserID: &userID}, true
+}
+
+// scheduledMessageID resolves the caller and the scheduled message ID in the URL, writing a response on failure
+func scheduledMessageID(c *gin.Context) (service.ScheduleOwner, uint, bool) {
+	owner, ok := scheduleOwner(c)
+	if !ok {
+		return owner, 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled message ID"})
+		return owner, 0, false
+	}
+
+	return owner, uint(id), true
+}
+
+// respondScheduleError writes the response for a failed scheduled message operation
+func respondScheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrScheduledNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNotScheduled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSendAtPast):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -10,16 +11,25 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
 	"github.com/kexi/telegram-bot-gateway/internal/pubsub"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
 	"github.com/kexi/telegram-bot-gateway/internal/service"
 )
 
 // TelegramHandler handles Telegram webhook endpoints
 type TelegramHandler struct {
-	botService     *service.BotService
-	chatService    *service.ChatService
-	messageService *service.MessageService
-	messageBroker  *pubsub.MessageBroker
+	botService         *service.BotService
+	chatService        *service.ChatService
+	messageService     *service.MessageService
+	callbackService    *service.CallbackQueryService
+	updateEventService *service.UpdateEventService
+	mediaService       *service.MediaService
+	outboundService    *service.OutboundService
+	chatRepo           repository.ChatRepository
+	messageBroker      *pubsub.MessageBroker
+	dispatcher         *service.WebhookDispatcher
+	eventBuilder       *service.EventBuilder
 }
 
 // NewTelegramHandler creates a new Telegram handler
@@ -27,23 +37,116 @@ func NewTelegramHandler(
 	botService *service.BotService,
 	chatService *service.ChatService,
 	messageService *service.MessageService,
+	callbackService *service.CallbackQueryService,
+	updateEventService *service.UpdateEventService,
+	mediaService *service.MediaService,
+	outboundService *service.OutboundService,
+	chatRepo repository.ChatRepository,
 	messageBroker *pubsub.MessageBroker,
+	dispatcher *service.WebhookDispatcher,
+	eventBuilder *service.EventBuilder,
 ) *TelegramHandler {
 	return &TelegramHandler{
-		botService:     botService,
-		chatService:    chatService,
-		messageService: messageService,
-		messageBroker:  messageBroker,
+		botService:         botService,
+		chatService:        chatService,
+		messageService:     messageService,
+		callbackService:    callbackService,
+		updateEventService: updateEventService,
+		mediaService:       mediaService,
+		outboundService:    outboundService,
+		chatRepo:           chatRepo,
+		messageBroker:      messageBroker,
+		dispatcher:         dispatcher,
+		eventBuilder:       eventBuilder,
 	}
 }
 
-// TelegramUpdate represents a Telegram update
+// TelegramUpdate represents a Telegram update. At most one of the optional fields is set.
 type TelegramUpdate struct {
-	UpdateID      int64            `json:"update_id"`
-	Message       *TelegramMessage `json:"message,omitempty"`
-	EditedMessage *TelegramMessage `json:"edited_message,omitempty"`
-	ChannelPost   *TelegramMessage `json:"channel_post,omitempty"`
-	CallbackQuery *CallbackQuery   `json:"callback_query,omitempty"`
+	UpdateID          int64            `json:"update_id"`
+	Message           *TelegramMessage `json:"message,omitempty"`
+	EditedMessage     *TelegramMessage `json:"edited_message,omitempty"`
+	ChannelPost       *TelegramMessage `json:"channel_post,omitempty"`
+	EditedChannelPost *TelegramMessage `json:"edited_channel_post,omitempty"`
+	CallbackQuery     *CallbackQuery   `json:"callback_query,omitempty"`
+
+	// Updates stored as update events
+	MessageReaction      *MessageReactionUpdated      `json:"message_reaction,omitempty"`
+	MessageReactionCount *MessageReactionCountUpdated `json:"message_reaction_count,omitempty"`
+	InlineQuery          *InlineQuery                 `json:"inline_query,omitempty"`
+	ChosenInlineResult   *ChosenInlineResult          `json:"chosen_inline_result,omitempty"`
+	ShippingQuery        *ShippingQuery               `json:"shipping_query,omitempty"`
+	PreCheckoutQuery     *PreCheckoutQuery            `json:"pre_checkout_query,omitempty"`
+	Poll                 *Poll                        `json:"poll,omitempty"`
+	PollAnswer           *PollAnswer                  `json:"poll_answer,omitempty"`
+	MyChatMember         *ChatMemberUpdated           `json:"my_chat_member,omitempty"`
+	ChatMember           *ChatMemberUpdated           `json:"chat_member,omitempty"`
+	ChatJoinRequest      *ChatJoinRequest             `json:"chat_join_request,omitempty"`
+	ChatBoost            *ChatBoostUpdated            `json:"chat_boost,omitempty"`
+	RemovedChatBoost     *ChatBoostRemoved            `json:"removed_chat_boost,omitempty"`
+
+	// fields keeps each update field as received, so stored events keep
+	// attributes that are not modelled here
+	fields map[string]json.RawMessage
+}
+
+// UnmarshalJSON decodes an update and keeps the raw JSON of its fields
+func (u *TelegramUpdate) UnmarshalJSON(data []byte) error {
+	type plain TelegramUpdate
+	if err := json.Unmarshal(data, (*plain)(u)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &u.fields)
+}
+
+// updateEvent describes a non-message update: its type (the update field name),
+// the chat and user it concerns, the message it refers to and when it happened.
+// An empty eventType means the update carries no event we know.
+type updateEvent struct {
+	eventType string
+	chat      *TelegramChat
+	from      *TelegramUser
+	messageID int64
+	date      int64
+}
+
+// event returns the non-message event carried by the update
+func (u *TelegramUpdate) event() updateEvent {
+	switch {
+	case u.MessageReaction != nil:
+		r := u.MessageReaction
+		return updateEvent{"message_reaction", r.Chat, r.User, r.MessageID, r.Date}
+	case u.MessageReactionCount != nil:
+		r := u.MessageReactionCount
+		return updateEvent{"message_reaction_count", r.Chat, nil, r.MessageID, r.Date}
+	case u.InlineQuery != nil:
+		return updateEvent{"inline_query", nil, u.InlineQuery.From, 0, 0}
+	case u.ChosenInlineResult != nil:
+		return updateEvent{"chosen_inline_result", nil, u.ChosenInlineResult.From, 0, 0}
+	case u.ShippingQuery != nil:
+		return updateEvent{"shipping_query", nil, u.ShippingQuery.From, 0, 0}
+	case u.PreCheckoutQuery != nil:
+		return updateEvent{"pre_checkout_query", nil, u.PreCheckoutQuery.From, 0, 0}
+	case u.Poll != nil:
+		return updateEvent{"poll", nil, nil, 0, 0}
+	case u.PollAnswer != nil:
+		return updateEvent{"poll_answer", u.PollAnswer.VoterChat, u.PollAnswer.User, 0, 0}
+	case u.MyChatMember != nil:
+		m := u.MyChatMember
+		return updateEvent{"my_chat_member", m.Chat, m.From, 0, m.Date}
+	case u.ChatMember != nil:
+		m := u.ChatMember
+		return updateEvent{"chat_member", m.Chat, m.From, 0, m.Date}
+	case u.ChatJoinRequest != nil:
+		r := u.ChatJoinRequest
+		return updateEvent{"chat_join_request", r.Chat, r.From, 0, r.Date}
+	case u.ChatBoost != nil:
+		return updateEvent{"chat_boost", u.ChatBoost.Chat, nil, 0, 0}
+	case u.RemovedChatBoost != nil:
+		return updateEvent{"removed_chat_boost", u.RemovedChatBoost.Chat, nil, 0, u.RemovedChatBoost.RemoveDate}
+	default:
+		return updateEvent{}
+	}
 }
 
 // TelegramMessage represents a Telegram message
@@ -52,6 +155,7 @@ type TelegramMessage struct {
 	From      *TelegramUser `json:"from,omitempty"`
 	Chat      *TelegramChat `json:"chat"`
 	Date      int64        `json:"date"`
+	EditDate  int64        `json:"edit_date,omitempty"`
 	Text      string       `json:"text,omitempty"`
 	ReplyToMessage *TelegramMessage `json:"reply_to_message,omitempty"`
 
@@ -103,60 +207,229 @@ type TelegramChat struct {
 
 // CallbackQuery represents a callback query
 type CallbackQuery struct {
-	ID      string           `json:"id"`
-	From    *TelegramUser    `json:"from"`
-	Message *TelegramMessage `json:"message,omitempty"`
-	Data    string           `json:"data,omitempty"`
+	ID              string           `json:"id"`
+	From            *TelegramUser    `json:"from"`
+	Message         *TelegramMessage `json:"message,omitempty"`
+	InlineMessageID string           `json:"inline_message_id,omitempty"`
+	ChatInstance    string           `json:"chat_instance,omitempty"`
+	Data            string           `json:"data,omitempty"`
+	GameShortName   string           `json:"game_short_name,omitempty"`
+}
+
+// ChatMemberUpdated represents a change of a chat member's status
+type ChatMemberUpdated struct {
+	Chat          *TelegramChat `json:"chat"`
+	From          *TelegramUser `json:"from"`
+	Date          int64         `json:"date"`
+	OldChatMember *ChatMember   `json:"old_chat_member"`
+	NewChatMember *ChatMember   `json:"new_chat_member"`
+}
+
+// ChatMember represents a member of a chat
+type ChatMember struct {
+	Status string        `json:"status"` // "creator", "administrator", "member", "restricted", "left", "kicked"
+	User   *TelegramUser `json:"user"`
+}
+
+// IsPresent reports whether the member is in the chat
+func (m *ChatMember) IsPresent() bool {
+	return m != nil && m.Status != "left" && m.Status != "kicked"
+}
+
+// ChatJoinRequest represents a request to join a chat
+type ChatJoinRequest struct {
+	Chat       *TelegramChat `json:"chat"`
+	From       *TelegramUser `json:"from"`
+	UserChatID int64         `json:"user_chat_id"`
+	Date       int64         `json:"date"`
+	Bio        string        `json:"bio,omitempty"`
+}
+
+// ReactionType represents an emoji, custom emoji or paid reaction
+type ReactionType struct {
+	Type          string `json:"type"`
+	Emoji         string `json:"emoji,omitempty"`
+	CustomEmojiID string `json:"custom_emoji_id,omitempty"`
+}
+
+// MessageReactionUpdated represents a change of a user's reactions to a message
+type MessageReactionUpdated struct {
+	Chat        *TelegramChat  `json:"chat"`
+	MessageID   int64          `json:"message_id"`
+	User        *TelegramUser  `json:"user,omitempty"`
+	ActorChat   *TelegramChat  `json:"actor_chat,omitempty"`
+	Date        int64          `json:"date"`
+	OldReaction []ReactionType `json:"old_reaction"`
+	NewReaction []ReactionType `json:"new_reaction"`
+}
+
+// MessageReactionCountUpdated represents changed anonymous reaction counts of a message
+type MessageReactionCountUpdated struct {
+	Chat      *TelegramChat `json:"chat"`
+	MessageID int64         `json:"message_id"`
+	Date      int64         `json:"date"`
+	Reactions []struct {
+		Type       ReactionType `json:"type"`
+		TotalCount int          `json:"total_count"`
+	} `json:"reactions"`
+}
+
+// InlineQuery represents an incoming inline query
+type InlineQuery struct {
+	ID       string        `json:"id"`
+	From     *TelegramUser `json:"from"`
+	Query    string        `json:"query"`
+	Offset   string        `json:"offset"`
+	ChatType string        `json:"chat_type,omitempty"`
+}
+
+// ChosenInlineResult represents an inline query result chosen by a user
+type ChosenInlineResult struct {
+	ResultID        string        `json:"result_id"`
+	From            *TelegramUser `json:"from"`
+	InlineMessageID string        `json:"inline_message_id,omitempty"`
+	Query           string        `json:"query"`
+}
+
+// ShippingQuery represents an incoming shipping query
+type ShippingQuery struct {
+	ID             string        `json:"id"`
+	From           *TelegramUser `json:"from"`
+	InvoicePayload string        `json:"invoice_payload"`
+}
+
+// PreCheckoutQuery represents an incoming pre-checkout query
+type PreCheckoutQuery struct {
+	ID             string        `json:"id"`
+	From           *TelegramUser `json:"from"`
+	Currency       string        `json:"currency"`
+	TotalAmount    int           `json:"total_amount"`
+	InvoicePayload string        `json:"invoice_payload"`
+}
+
+// PollAnswer represents a user's answer in a non-anonymous poll
+type PollAnswer struct {
+	PollID    string        `json:"poll_id"`
+	VoterChat *TelegramChat `json:"voter_chat,omitempty"`
+	User      *TelegramUser `json:"user,omitempty"`
+	OptionIDs []int         `json:"option_ids"`
+}
+
+// ChatBoostUpdated represents a boost added to a chat or changed
+type ChatBoostUpdated struct {
+	Chat *TelegramChat `json:"chat"`
+}
+
+// ChatBoostRemoved represents a boost removed from a chat
+type ChatBoostRemoved struct {
+	Chat       *TelegramChat `json:"chat"`
+	BoostID    string        `json:"boost_id"`
+	RemoveDate int64         `json:"remove_date"`
 }
 
 // Media types
 type PhotoSize struct {
-	FileID   string `json:"file_id"`
-	FileSize int    `json:"file_size,omitempty"`
-	Width    int    `json:"width"`
-	Height   int    `json:"height"`
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
 }
 
 type Video struct {
-	FileID   string `json:"file_id"`
-	Width    int    `json:"width"`
-	Height   int    `json:"height"`
-	Duration int    `json:"duration"`
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Duration     int    `json:"duration"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
 }
 
 type Document struct {
-	FileID   string `json:"file_id"`
-	FileName string `json:"file_name,omitempty"`
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
 }
 
 type Audio struct {
-	FileID   string `json:"file_id"`
-	Duration int    `json:"duration"`
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Duration     int    `json:"duration"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
 }
 
 type Voice struct {
-	FileID   string `json:"file_id"`
-	Duration int    `json:"duration"`
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Duration     int    `json:"duration"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
 }
 
 type Sticker struct {
-	FileID string `json:"file_id"`
-	Width  int    `json:"width"`
-	Height int    `json:"height"`
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	FileSize     int64  `json:"file_size,omitempty"`
 }
 
 type Animation struct {
-	FileID   string `json:"file_id"`
-	Width    int    `json:"width"`
-	Height   int    `json:"height"`
-	Duration int    `json:"duration"`
-	FileName string `json:"file_name,omitempty"`
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Duration     int    `json:"duration"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
 }
 
 type VideoNote struct {
-	FileID   string `json:"file_id"`
-	Length   int    `json:"length"`
-	Duration int    `json:"duration"`
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Length       int    `json:"length"`
+	Duration     int    `json:"duration"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+// mediaFile returns the file attached to the message, or nil for messages without one.
+// For photos the largest size is used.
+func (msg *TelegramMessage) mediaFile() *service.MediaFileRef {
+	switch {
+	case len(msg.Photo) > 0:
+		p := msg.Photo[len(msg.Photo)-1]
+		return &service.MediaFileRef{FileID: p.FileID, FileUniqueID: p.FileUniqueID, MimeType: "image/jpeg", FileSize: p.FileSize}
+	case msg.Video != nil:
+		v := msg.Video
+		return &service.MediaFileRef{FileID: v.FileID, FileUniqueID: v.FileUniqueID, FileName: v.FileName, MimeType: v.MimeType, FileSize: v.FileSize}
+	case msg.Document != nil:
+		d := msg.Document
+		return &service.MediaFileRef{FileID: d.FileID, FileUniqueID: d.FileUniqueID, FileName: d.FileName, MimeType: d.MimeType, FileSize: d.FileSize}
+	case msg.Audio != nil:
+		a := msg.Audio
+		return &service.MediaFileRef{FileID: a.FileID, FileUniqueID: a.FileUniqueID, FileName: a.FileName, MimeType: a.MimeType, FileSize: a.FileSize}
+	case msg.Voice != nil:
+		v := msg.Voice
+		return &service.MediaFileRef{FileID: v.FileID, FileUniqueID: v.FileUniqueID, MimeType: v.MimeType, FileSize: v.FileSize}
+	case msg.Sticker != nil:
+		st := msg.Sticker
+		return &service.MediaFileRef{FileID: st.FileID, FileUniqueID: st.FileUniqueID, FileSize: st.FileSize}
+	case msg.Animation != nil:
+		a := msg.Animation
+		return &service.MediaFileRef{FileID: a.FileID, FileUniqueID: a.FileUniqueID, FileName: a.FileName, MimeType: a.MimeType, FileSize: a.FileSize}
+	case msg.VideoNote != nil:
+		v := msg.VideoNote
+		return &service.MediaFileRef{FileID: v.FileID, FileUniqueID: v.FileUniqueID, MimeType: "video/mp4", FileSize: v.FileSize}
+	default:
+		return nil
+	}
 }
 
 // Location represents a point on the map
@@ -260,6 +533,17 @@ func (h *TelegramHandler) ReceiveUpdate(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// ProcessRawUpdate processes a raw Telegram update received outside the webhook endpoint,
+// e.g. by the getUpdates poller
+func (h *TelegramHandler) ProcessRawUpdate(ctx context.Context, botID uint, data []byte) error {
+	var update TelegramUpdate
+	if err := json.Unmarshal(data, &update); err != nil {
+		return fmt.Errorf("%w: %v", service.ErrInvalidUpdate, err)
+	}
+
+	return h.processUpdate(ctx, botID, &update)
+}
+
 // processUpdate processes a Telegram update
 func (h *TelegramHandler) processUpdate(ctx context.Context, botID uint, update *TelegramUpdate) error {
 	// Determine which message to process
@@ -276,14 +560,23 @@ func (h *TelegramHandler) processUpdate(ctx context.Context, botID uint, update
 	case update.ChannelPost != nil:
 		msg = update.ChannelPost
 		messageType = "channel_post"
+	case update.EditedChannelPost != nil:
+		msg = update.EditedChannelPost
+		messageType = "edited_channel_post"
+	case update.CallbackQuery != nil:
+		return h.processCallbackQuery(ctx, botID, update.CallbackQuery)
 	default:
-		// No message to process (could be callback query, etc.)
-		return nil
+		return h.processUpdateEvent(ctx, botID, update)
+	}
+
+	chat, err := h.upsertChat(ctx, botID, msg.Chat)
+	if err != nil {
+		return err
 	}
 
 	// Check for gateway commands before processing message
-	if msg != nil && msg.Text != "" {
-		handled, err := h.handleGatewayCommand(ctx, botID, update, msg)
+	if msg.Text != "" {
+		handled, err := h.handleGatewayCommand(ctx, chat.ID, update, msg)
 		if err != nil {
 			fmt.Printf("Warning: gateway command error: %v\n", err)
 		}
@@ -292,25 +585,291 @@ func (h *TelegramHandler) processUpdate(ctx context.Context, botID uint, update
 		}
 	}
 
-	// Create or update chat
+	var storedMsg *service.MessageDTO
+	if msg.EditDate != 0 {
+		// Edits replace the stored text and keep the previous one in the edit history
+		text := msg.Text
+		if text == "" {
+			text = msg.Caption
+		}
+		storedMsg, err = h.messageService.RecordEdit(ctx, chat.ID, msg.MessageID, text, time.Unix(msg.EditDate, 0))
+		if err != nil && !errors.Is(err, service.ErrMessageNotFound) {
+			return err
+		}
+	}
+	if storedMsg == nil {
+		// New message, or an edit of a message received before the gateway
+		storedMsg, err = h.storeMessage(ctx, chat.ID, msg, "incoming")
+		if err != nil {
+			return err
+		}
+
+		// Attached files are downloaded into blob storage by the media worker
+		if ref := msg.mediaFile(); ref != nil {
+			if _, err := h.mediaService.RecordMedia(ctx, botID, storedMsg.ID, ref); err != nil {
+				// Log error but don't fail the request
+				fmt.Printf("Failed to record media: %v\n", err)
+			}
+		}
+	}
+
+	// Publish to message broker for real-time distribution
+	event := &pubsub.MessageEvent{
+		Type:         messageType,
+		ChatID:       chat.ID,
+		MessageID:    storedMsg.ID,
+		TelegramID:   msg.MessageID,
+		BotID:        botID,
+		Direction:    "incoming",
+		Text:         storedMsg.Text,
+		FromUsername: storedMsg.FromUsername,
+		Timestamp:    time.Unix(msg.Date, 0),
+		Payload: map[string]interface{}{
+			"message_type": storedMsg.MessageType,
+			"from_user_id": storedMsg.FromUserID,
+		},
+	}
+	event.Envelope = h.envelope(h.eventBuilder.MessageEvent(ctx, messageType, storedMsg.ID))
+
+	if err := h.messageBroker.PublishMessage(ctx, event); err != nil {
+		// Log error but don't fail the request
+		fmt.Printf("Failed to publish message event: %v\n", err)
+	}
+
+	// Queue webhook deliveries for every matching webhook
+	if _, err := h.dispatcher.Dispatch(ctx, messageType, storedMsg); err != nil {
+		// Log error but don't fail the request
+		fmt.Printf("Failed to dispatch webhooks: %v\n", err)
+	}
+
+	return nil
+}
+
+// envelope returns a built event envelope; subscribers that opted into envelopes miss
+// the event when it could not be built
+func (h *TelegramHandler) envelope(envelope *domain.EventEnvelope, err error) *domain.EventEnvelope {
+	if err != nil {
+		// Log error but don't fail the request
+		fmt.Printf("Failed to build event envelope: %v\n", err)
+		return nil
+	}
+	return envelope
+}
+
+// processUpdateEvent stores a non-message update as an update event and publishes it
+// to real-time subscribers and webhooks. my_chat_member updates also track whether
+// the bot is still a member of the chat.
+func (h *TelegramHandler) processUpdateEvent(ctx context.Context, botID uint, update *TelegramUpdate) error {
+	ev := update.event()
+	if ev.eventType == "" {
+		// Update type we do not handle
+		return nil
+	}
+
+	req := &service.RecordUpdateEventRequest{
+		BotID:      botID,
+		EventType:  ev.eventType,
+		RawData:    string(update.fields[ev.eventType]),
+		OccurredAt: time.Now(),
+	}
+	if ev.date != 0 {
+		req.OccurredAt = time.Unix(ev.date, 0)
+	}
+	if ev.from != nil {
+		req.FromUserID = &ev.from.ID
+		req.FromUsername = ev.from.Username
+	}
+
+	var chat *service.ChatDTO
+	if ev.chat != nil {
+		var err error
+		chat, err = h.upsertChat(ctx, botID, ev.chat)
+		if err != nil {
+			return err
+		}
+		req.ChatID = &chat.ID
+
+		if update.MyChatMember != nil {
+			// The chat is active while the bot is a member, and inactive once it left or was kicked
+			isActive := update.MyChatMember.NewChatMember.IsPresent()
+			if err := h.chatService.UpdateChat(ctx, chat.ID, isActive); err != nil {
+				return fmt.Errorf("failed to update chat membership: %w", err)
+			}
+			chat.IsActive = isActive
+		}
+
+		if ev.messageID != 0 {
+			if msg, err := h.messageService.GetMessageByTelegramID(ctx, chat.ID, ev.messageID); err == nil {
+				req.MessageID = &msg.ID
+			}
+		}
+	}
+
+	event, err := h.updateEventService.RecordEvent(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	brokerEvent := &pubsub.MessageEvent{
+		Type:         ev.eventType,
+		TelegramID:   ev.messageID,
+		BotID:        botID,
+		Direction:    "incoming",
+		FromUsername: event.FromUsername,
+		Timestamp:    event.OccurredAt,
+		Payload: map[string]interface{}{
+			"event_id":     event.ID,
+			"from_user_id": event.FromUserID,
+			ev.eventType:   event.Data,
+		},
+	}
+	if chat != nil {
+		brokerEvent.ChatID = chat.ID
+	}
+	if event.MessageID != nil {
+		brokerEvent.MessageID = *event.MessageID
+	}
+	brokerEvent.Envelope = h.envelope(h.eventBuilder.UpdateEvent(ctx, event.ID))
+
+	if err := h.messageBroker.PublishMessage(ctx, brokerEvent); err != nil {
+		// Log error but don't fail the request
+		fmt.Printf("Failed to publish %s event: %v\n", ev.eventType, err)
+	}
+
+	if _, err := h.dispatcher.DispatchUpdateEvent(ctx, event); err != nil {
+		// Log error but don't fail the request
+		fmt.Printf("Failed to dispatch webhooks: %v\n", err)
+	}
+
+	return nil
+}
+
+// processCallbackQuery stores an inline keyboard button press, routes it to the API key that
+// sent the keyboard and publishes it to WebSocket, gRPC and webhook consumers
+func (h *TelegramHandler) processCallbackQuery(ctx context.Context, botID uint, query *CallbackQuery) error {
+	if query.From == nil {
+		return fmt.Errorf("callback query %s has no sender", query.ID)
+	}
+
+	// Telegram may redeliver an update; the query is already stored and published
+	if _, err := h.callbackService.GetCallbackQuery(ctx, query.ID); err == nil {
+		return nil
+	}
+
+	req := &service.RecordCallbackQueryRequest{
+		QueryID:         query.ID,
+		BotID:           botID,
+		InlineMessageID: query.InlineMessageID,
+		FromUserID:      query.From.ID,
+		FromUsername:    query.From.Username,
+		FromFirstName:   query.From.FirstName,
+		ChatInstance:    query.ChatInstance,
+		Data:            query.Data,
+		GameShortName:   query.GameShortName,
+	}
+	rawData, _ := json.Marshal(query)
+	req.RawData = string(rawData)
+
+	// Buttons on inline-mode messages have no chat message to attach to
+	var chat *service.ChatDTO
+	var keyboardMsg *service.MessageDTO
+	if query.Message != nil && query.Message.Chat != nil {
+		var err error
+		chat, err = h.upsertChat(ctx, botID, query.Message.Chat)
+		if err != nil {
+			return err
+		}
+
+		keyboardMsg, err = h.messageService.GetMessageByTelegramID(ctx, chat.ID, query.Message.MessageID)
+		if err != nil {
+			// Keyboard sent outside the gateway; keep the message so the query has context
+			keyboardMsg, err = h.storeMessage(ctx, chat.ID, query.Message, "outgoing")
+			if err != nil {
+				return err
+			}
+		}
+
+		req.ChatID = &chat.ID
+		req.MessageID = &keyboardMsg.ID
+		req.APIKeyID = keyboardMsg.SentByAPIKeyID
+	}
+
+	callback, err := h.callbackService.RecordCallbackQuery(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	event := &pubsub.MessageEvent{
+		Type:         "callback_query",
+		BotID:        botID,
+		Direction:    "incoming",
+		Text:         query.Data,
+		FromUsername: query.From.Username,
+		APIKeyID:     callback.APIKeyID,
+		Timestamp:    callback.CreatedAt,
+		Payload: map[string]interface{}{
+			"callback_query_id": query.ID,
+			"data":              query.Data,
+			"from_user_id":      query.From.ID,
+			"chat_instance":     query.ChatInstance,
+		},
+	}
+	if keyboardMsg != nil {
+		event.ChatID = chat.ID
+		event.MessageID = keyboardMsg.ID
+		event.TelegramID = keyboardMsg.TelegramID
+		event.Payload["message_type"] = keyboardMsg.MessageType
+	} else {
+		event.Payload["inline_message_id"] = query.InlineMessageID
+	}
+	event.Envelope = h.envelope(h.eventBuilder.CallbackQueryEvent(ctx, callback.ID))
+
+	if err := h.messageBroker.PublishMessage(ctx, event); err != nil {
+		// Log error but don't fail the request
+		fmt.Printf("Failed to publish callback query event: %v\n", err)
+	}
+
+	// Like other events without a chat, presses on inline-mode messages go to global webhooks
+	if keyboardMsg == nil {
+		if _, err := h.dispatcher.DispatchInlineCallbackQuery(ctx, callback.ID); err != nil {
+			// Log error but don't fail the request
+			fmt.Printf("Failed to dispatch webhooks: %v\n", err)
+		}
+		return nil
+	}
+
+	if _, err := h.dispatcher.DispatchCallbackQuery(ctx, keyboardMsg, callback.ID); err != nil {
+		// Log error but don't fail the request
+		fmt.Printf("Failed to dispatch webhooks: %v\n", err)
+	}
+
+	return nil
+}
+
+// upsertChat creates or updates the chat a Telegram message belongs to
+func (h *TelegramHandler) upsertChat(ctx context.Context, botID uint, tgChat *TelegramChat) (*service.ChatDTO, error) {
 	chatReq := &service.CreateChatRequest{
 		BotID:      botID,
-		TelegramID: msg.Chat.ID,
-		Type:       msg.Chat.Type,
-		Title:      msg.Chat.Title,
-		Username:   msg.Chat.Username,
-		FirstName:  msg.Chat.FirstName,
-		LastName:   msg.Chat.LastName,
+		TelegramID: tgChat.ID,
+		Type:       tgChat.Type,
+		Title:      tgChat.Title,
+		Username:   tgChat.Username,
+		FirstName:  tgChat.FirstName,
+		LastName:   tgChat.LastName,
 	}
 
 	chat, err := h.chatService.CreateOrUpdateChat(ctx, chatReq)
 	if err != nil {
-		return fmt.Errorf("failed to create/update chat: %w", err)
+		return nil, fmt.Errorf("failed to create/update chat: %w", err)
 	}
 
+	return chat, nil
+}
+
+// storeMessage stores a Telegram message received in an update
+func (h *TelegramHandler) storeMessage(ctx context.Context, chatID uint, msg *TelegramMessage, direction string) (*service.MessageDTO, error) {
 	// Determine message content type
 	msgType := "text"
-	text := msg.Text
 	if len(msg.Photo) > 0 {
 		msgType = "photo"
 	} else if msg.Video != nil {
@@ -344,17 +903,16 @@ func (h *TelegramHandler) processUpdate(ctx context.Context, botID uint, update
 	// Serialize full message to JSON
 	rawData, _ := json.Marshal(msg)
 
-	// Store message
 	messageReq := &service.CreateMessageRequest{
-		ChatID:           chat.ID,
+		ChatID:           chatID,
 		TelegramID:       msg.MessageID,
 		FromUserID:       fromUserID,
 		FromUsername:     fromUsername,
 		FromFirstName:    fromFirstName,
 		FromLastName:     fromLastName,
-		Direction:        "incoming",
+		Direction:        direction,
 		MessageType:      msgType,
-		Text:             text,
+		Text:             msg.Text,
 		RawData:          string(rawData),
 		ReplyToMessageID: replyToMessageID,
 		SentAt:           time.Unix(msg.Date, 0),
@@ -362,39 +920,14 @@ func (h *TelegramHandler) processUpdate(ctx context.Context, botID uint, update
 
 	storedMsg, err := h.messageService.StoreMessage(ctx, messageReq)
 	if err != nil {
-		return fmt.Errorf("failed to store message: %w", err)
-	}
-
-	// Publish to message broker for real-time distribution
-	event := &pubsub.MessageEvent{
-		Type:         messageType,
-		ChatID:       chat.ID,
-		MessageID:    storedMsg.ID,
-		TelegramID:   msg.MessageID,
-		BotID:        botID,
-		Direction:    "incoming",
-		Text:         text,
-		FromUsername: fromUsername,
-		Timestamp:    time.Unix(msg.Date, 0),
-		Payload: map[string]interface{}{
-			"message_type": msgType,
-			"from_user_id": fromUserID,
-		},
-	}
-
-	if err := h.messageBroker.PublishMessage(ctx, event); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Failed to publish message event: %v\n", err)
+		return nil, fmt.Errorf("failed to store message: %w", err)
 	}
 
-	// TODO: Queue webhook deliveries for this message
-	// For each active webhook matching this chat/scope, create a delivery job
-
-	return nil
+	return storedMsg, nil
 }
 
 // handleGatewayCommand checks if a message is a gateway command and handles it
-func (h *TelegramHandler) handleGatewayCommand(ctx context.Context, botID uint, update *TelegramUpdate, msg *TelegramMessage) (bool, error) {
+func (h *TelegramHandler) handleGatewayCommand(ctx context.Context, chatID uint, update *TelegramUpdate, msg *TelegramMessage) (bool, error) {
 	text := strings.TrimSpace(msg.Text)
 	if !strings.HasPrefix(text, "/") {
 		return false, nil
@@ -415,15 +948,16 @@ func (h *TelegramHandler) handleGatewayCommand(ctx context.Context, botID uint,
 	// Dispatch to command handlers
 	switch cmd {
 	case "/debuggateway":
-		return true, h.handleDebugGateway(ctx, botID, update, msg)
+		return true, h.handleDebugGateway(ctx, chatID, update, msg)
 	default:
 		return false, nil
 	}
 }
 
 // handleDebugGateway dumps the raw Telegram Update as JSON reply
-// If the command is a reply to a message, it dumps the replied-to message instead
-func (h *TelegramHandler) handleDebugGateway(ctx context.Context, botID uint, update *TelegramUpdate, msg *TelegramMessage) error {
+// If the command is a reply to a message, it dumps the replied-to message instead.
+// Replies are sent like any outgoing message, so they are stored and published.
+func (h *TelegramHandler) handleDebugGateway(ctx context.Context, chatID uint, update *TelegramUpdate, msg *TelegramMessage) error {
 	// If this is a reply to another message, dump that message instead
 	var dataToDump interface{}
 	if msg.ReplyToMessage != nil {
@@ -444,10 +978,16 @@ func (h *TelegramHandler) handleDebugGateway(ctx context.Context, botID uint, up
 	// Split if necessary (Telegram has 4096 char limit)
 	chunks := splitMessage(text, 4096)
 
+	chat, err := h.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("chat not found: %w", err)
+	}
+
 	// Send each chunk as a reply
 	replyTo := &msg.MessageID
 	for _, chunk := range chunks {
-		if err := h.botService.SendTelegramMessage(ctx, botID, msg.Chat.ID, chunk, replyTo, "HTML"); err != nil {
+		opts := &service.SendOptions{ParseMode: "HTML", ReplyToMessageID: replyTo}
+		if _, err := h.outboundService.SendText(ctx, chat, chunk, opts); err != nil {
 			return fmt.Errorf("failed to send debug message: %w", err)
 		}
 		// Only reply to the original message for the first chunk
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
+)
+
+// UsageHandler reports the quota consumption of the caller
+type UsageHandler struct {
+	quotas *middleware.QuotaLimiter
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(quotas *middleware.QuotaLimiter) *UsageHandler {
+	return &UsageHandler{
+		quotas: quotas,
+	}
+}
+
+// UsageResponse describes the hourly budgets of the caller
+type UsageResponse struct {
+	APIKeyID *uint                  `json:"api_key_id,omitempty"`
+	UserID   uint                   `json:"user_id,omitempty"`
+	Window   string                 `json:"window"`
+	Read     *middleware.QuotaUsage `json:"read"`
+	Send     *middleware.QuotaUsage `json:"send"`
+}
+
+// GetUsage handles reporting the read and send quotas of the caller
+// @Summary Get quota usage
+// @Description Get the hourly read and send budgets of the calling API key or user and their consumption
+// @Tags usage
+// @Produce json
+// @Success 200 {object} UsageResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/usage [get]
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	authCtx, exists := middleware.GetAuthContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	quota, err := h.quotas.Resolve(ctx, authCtx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load quota"})
+		return
+	}
+
+	read, err := h.quotas.Usage(ctx, authCtx, middleware.QuotaRead, quota.Read)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load usage"})
+		return
+	}
+	send, err := h.quotas.Usage(ctx, authCtx, middleware.QuotaSend, quota.Send)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load usage"})
+		return
+	}
+
+	response := UsageResponse{
+		Window: middleware.QuotaWindow.String(),
+		Read:   read,
+		Send:   send,
+	}
+	if authCtx.IsAPIKey {
+		response.APIKeyID = authCtx.APIKeyID
+	} else {
+		response.UserID = authCtx.UserID
+	}
+
+	c.JSON(http.StatusOK, response)
+}
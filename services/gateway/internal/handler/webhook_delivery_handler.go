@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+// WebhookDeliveryHandler handles webhook delivery endpoints
+type WebhookDeliveryHandler struct {
+	deliveryService *service.WebhookDeliveryService
+}
+
+// NewWebhookDeliveryHandler creates a new webhook delivery handler
+func NewWebhookDeliveryHandler(deliveryService *service.WebhookDeliveryService) *WebhookDeliveryHandler {
+	return &WebhookDeliveryHandler{
+		deliveryService: deliveryService,
+	}
+}
+
+// ReplayDeadLettersRequest selects the dead letters of a webhook to replay
+type ReplayDeadLettersRequest struct {
+	WebhookID uint       `json:"webhook_id" binding:"required"`
+	From      *time.Time `json:"from"` // Dead letters that failed at or after this time
+	To        *time.Time `json:"to"`   // Dead letters that failed before this time
+}
+
+// ListDeliveries handles listing the delivery log of a webhook
+// @Summary List webhook deliveries
+// @Description List the deliveries of a webhook with the outcome of their last attempt, newest first
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param status query string false "pending, delivered or failed"
+// @Param event_type query string false "Event type, e.g. new_message"
+// @Param from query string false "Created at or after (RFC 3339)"
+// @Param to query string false "Created before (RFC 3339)"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (max 100)"
+// @Success 200 {array} service.WebhookDeliveryDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func (h *WebhookDeliveryHandler) ListDeliveries(c *gin.Context) {
+	webhookID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	filter := service.DeliveryLogFilter{
+		Status:    c.Query("status"),
+		EventType: c.Query("event_type"),
+	}
+	if filter.From, ok = queryTime(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = queryTime(c, "to"); !ok {
+		return
+	}
+
+	offset, limit := listPage(c)
+
+	deliveries, total, err := h.deliveryService.ListDeliveries(c.Request.Context(), webhookID, filter, offset, limit)
+	if err != nil {
+		respondDeliveryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"total":      total,
+		"offset":     offset,
+		"limit":      limit,
+	})
+}
+
+// GetWebhookHealth handles summarizing the health of a webhook
+// @Summary Get webhook health
+// @Description Summarize the deliveries of a webhook over the last 24 hours: success rate, p50/p95 latency, circuit breaker state and last failure
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} service.WebhookHealthDTO
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/health [get]
+func (h *WebhookDeliveryHandler) GetWebhookHealth(c *gin.Context) {
+	webhookID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	health, err := h.deliveryService.GetHealth(c.Request.Context(), webhookID)
+	if err != nil {
+		respondDeliveryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+}
+
+// TestWebhook handles sending a test ping to a webhook
+// @Summary Test webhook
+// @Description Send a signed "ping" event to the webhook URL and report how the endpoint answered. The ping is not stored.
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} service.WebhookPingResult
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/test [post]
+func (h *WebhookDeliveryHandler) TestWebhook(c *gin.Context) {
+	webhookID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.deliveryService.Ping(c.Request.Context(), webhookID)
+	if err != nil {
+		respondDeliveryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListDeadLetters handles listing failed webhook deliveries
+// @Summary List dead letters
+// @Description List webhook deliveries whose retries were used up, newest first
+// @Tags webhooks
+// @Produce json
+// @Param webhook_id query int false "Webhook ID"
+// @Param from query string false "Failed at or after (RFC 3339)"
+// @Param to query string false "Failed before (RFC 3339)"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (max 100)"
+// @Success 200 {array} service.WebhookDeliveryDTO
+// @Failure 400 {object} ErrorResponse
+// @Router /api/v1/webhooks/dead-letters [get]
+func (h *WebhookDeliveryHandler) ListDeadLetters(c *gin.Context) {
+	var filter service.DeadLetterFilter
+	if raw := c.Query("webhook_id"); raw != "" {
+		webhookID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+			return
+		}
+		id := uint(webhookID)
+		filter.WebhookID = &id
+	}
+
+	var ok bool
+	if filter.From, ok = queryTime(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = queryTime(c, "to"); !ok {
+		return
+	}
+
+	offset, limit := listPage(c)
+
+	deadLetters, total, err := h.deliveryService.ListDeadLetters(c.Request.Context(), filter, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dead_letters": deadLetters,
+		"total":        total,
+		"offset":       offset,
+		"limit":        limit,
+	})
+}
+
+// GetDeadLetter handles inspecting a failed webhook delivery
+// @Summary Get dead letter
+// @Description Get a failed webhook delivery with the error and response of its last attempt
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Success 200 {object} service.WebhookDeliveryDTO
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/dead-letters/{id} [get]
+func (h *WebhookDeliveryHandler) GetDeadLetter(c *gin.Context) {
+	id, ok := deliveryID(c)
+	if !ok {
+		return
+	}
+
+	deadLetter, err := h.deliveryService.GetDeadLetter(c.Request.Context(), id)
+	if err != nil {
+		respondDeliveryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deadLetter)
+}
+
+// ReplayDeadLetter handles replaying a failed webhook delivery
+// @Summary Replay dead letter
+// @Description Queue a failed webhook delivery again with a fresh set of retries
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Success 200 {object} service.WebhookDeliveryDTO
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/webhooks/dead-letters/{id}/replay [post]
+func (h *WebhookDeliveryHandler) ReplayDeadLetter(c *gin.Context) {
+	id, ok := deliveryID(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.deliveryService.ReplayDeadLetter(c.Request.Context(), id)
+	if err != nil {
+		respondDeliveryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// ReplayDeadLetters handles replaying the failed deliveries of a webhook
+// @Summary Replay dead letters
+// @Description Queue again every failed delivery of a webhook, optionally only those that failed in a time range
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body ReplayDeadLettersRequest true "Dead letters to replay"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} ErrorResponse
+// @Router /api/v1/webhooks/dead-letters/replay [post]
+func (h *WebhookDeliveryHandler) ReplayDeadLetters(c *gin.Context) {
+	var req ReplayDeadLettersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := service.DeadLetterFilter{
+		WebhookID: &req.WebhookID,
+		From:      req.From,
+		To:        req.To,
+	}
+
+	replayed, err := h.deliveryService.ReplayDeadLetters(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "replayed": replayed})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"replayed": replayed})
+}
+
+// webhookIDParam parses the webhook ID in the URL, writing a response on failure
+func webhookIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// deliveryID parses the delivery ID in the URL, writing a response on failure
+func deliveryID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// queryTime reads an optional RFC 3339 query parameter, writing a response when it is invalid
+func queryTime(c *gin.Context, name string) (*time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " (expected RFC 3339)"})
+		return nil, false
+	}
+	return &t, true
+}
+
+// respondDeliveryError writes the response for a failed webhook delivery operation
+func respondDeliveryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound), errors.Is(err, service.ErrDeadLetterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNotDeadLetter):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,23 +1,36 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
 	"github.com/kexi/telegram-bot-gateway/internal/service"
 )
 
 // WebhookHandler handles webhook endpoints
 type WebhookHandler struct {
 	webhookService *service.WebhookService
+	chatPermRepo   repository.ChatPermissionRepository
+	redisClient    *redis.Client
 }
 
 // NewWebhookHandler creates a new webhook handler
-func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+func NewWebhookHandler(
+	webhookService *service.WebhookService,
+	chatPermRepo repository.ChatPermissionRepository,
+	redisClient *redis.Client,
+) *WebhookHandler {
 	return &WebhookHandler{
 		webhookService: webhookService,
+		chatPermRepo:   chatPermRepo,
+		redisClient:    redisClient,
 	}
 }
 
@@ -110,6 +123,7 @@ func (h *WebhookHandler) GetWebhook(c *gin.Context) {
 // @Param id path int true "Webhook ID"
 // @Success 200 {object} SuccessResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
 // @Router /api/v1/webhooks/{id} [delete]
 func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
@@ -119,7 +133,7 @@ func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
 	}
 
 	if err := h.webhookService.DeleteWebhook(c.Request.Context(), uint(id)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondWebhookError(c, err, http.StatusBadRequest)
 		return
 	}
 
@@ -136,6 +150,7 @@ func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
 // @Param request body UpdateWebhookRequest true "Updated settings"
 // @Success 200 {object} SuccessResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
 // @Router /api/v1/webhooks/{id} [put]
 func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
@@ -150,16 +165,248 @@ func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
 		return
 	}
 
-	if err := h.webhookService.UpdateWebhook(c.Request.Context(), uint(id), req.URL, req.IsActive); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.webhookService.UpdateWebhook(c.Request.Context(), uint(id), req.URL, req.IsActive, req.SchemaVersion, req.IncludeRaw); err != nil {
+		respondWebhookError(c, err, http.StatusBadRequest)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully"})
 }
 
+// SetFeedbackWebhook handles registering the feedback webhook of the calling API key
+// @Summary Set feedback webhook
+// @Description Register the URL that receives incoming messages from chats the API key may receive feedback from
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body service.SetFeedbackWebhookRequest true "Feedback webhook"
+// @Success 200 {object} service.WebhookDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/feedback/webhook [put]
+func (h *WebhookHandler) SetFeedbackWebhook(c *gin.Context) {
+	apiKeyID, ok := feedbackAPIKeyID(c)
+	if !ok {
+		return
+	}
+
+	var req service.SetFeedbackWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook, err := h.webhookService.SetFeedbackWebhook(c.Request.Context(), apiKeyID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// GetFeedbackWebhook handles getting the feedback webhook of the calling API key
+// @Summary Get feedback webhook
+// @Description Get the feedback webhook of the calling API key
+// @Tags webhooks
+// @Produce json
+// @Success 200 {object} service.WebhookDTO
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/feedback/webhook [get]
+func (h *WebhookHandler) GetFeedbackWebhook(c *gin.Context) {
+	apiKeyID, ok := feedbackAPIKeyID(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhookService.GetFeedbackWebhook(c.Request.Context(), apiKeyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteFeedbackWebhook handles removing the feedback webhook of the calling API key
+// @Summary Delete feedback webhook
+// @Description Stop pushing incoming messages to the calling API key
+// @Tags webhooks
+// @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/feedback/webhook [delete]
+func (h *WebhookHandler) DeleteFeedbackWebhook(c *gin.Context) {
+	apiKeyID, ok := feedbackAPIKeyID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeleteFeedbackWebhook(c.Request.Context(), apiKeyID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Feedback webhook deleted successfully"})
+}
+
+// RotateWebhookSecret handles replacing the secret of a webhook
+// @Summary Rotate webhook secret
+// @Description Generate a new webhook secret. Deliveries are signed with both the old and the new secret until the overlap ends. The new secret is only returned here.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param request body RotateSecretRequest false "Overlap"
+// @Success 200 {object} service.WebhookDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/rotate-secret [post]
+func (h *WebhookHandler) RotateWebhookSecret(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	if !h.authorizeWebhook(c, uint(id)) {
+		return
+	}
+
+	overlap, ok := secretOverlap(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhookService.RotateSecret(c.Request.Context(), uint(id), overlap)
+	if err != nil {
+		respondRotateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// RotateFeedbackWebhookSecret handles replacing the secret of the feedback webhook of the calling API key
+// @Summary Rotate feedback webhook secret
+// @Description Generate a new secret for the feedback webhook, signing with both secrets until the overlap ends
+// @Tags feedback
+// @Accept json
+// @Produce json
+// @Param request body RotateSecretRequest false "Overlap"
+// @Success 200 {object} service.WebhookDTO
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/feedback/webhook/rotate-secret [post]
+func (h *WebhookHandler) RotateFeedbackWebhookSecret(c *gin.Context) {
+	apiKeyID, ok := feedbackAPIKeyID(c)
+	if !ok {
+		return
+	}
+
+	overlap, ok := secretOverlap(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhookService.RotateFeedbackSecret(c.Request.Context(), apiKeyID, overlap)
+	if err != nil {
+		respondRotateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// authorizeWebhook checks the caller may manage a webhook, responding 404 otherwise so webhook IDs
+// cannot be probed. Chat webhooks need can_manage on their chat, global webhooks the admin role.
+func (h *WebhookHandler) authorizeWebhook(c *gin.Context, id uint) bool {
+	authCtx, exists := middleware.GetAuthContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return false
+	}
+
+	webhook, err := h.webhookService.GetWebhook(c.Request.Context(), id)
+	if err != nil || webhook.Scope == "feedback" {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrWebhookNotFound.Error()})
+		return false
+	}
+
+	allowed := isAdmin(authCtx)
+	if !allowed && webhook.ChatID != nil {
+		allowed, err = middleware.CheckChatPermission(c.Request.Context(), authCtx, *webhook.ChatID, middleware.PermissionManage, h.chatPermRepo, h.redisClient)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			return false
+		}
+	}
+
+	if !allowed {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrWebhookNotFound.Error()})
+		return false
+	}
+	return true
+}
+
+// feedbackAPIKeyID returns the calling API key, responding 403 to other callers
+func feedbackAPIKeyID(c *gin.Context) (uint, bool) {
+	authCtx, exists := middleware.GetAuthContext(c)
+	if !exists || !authCtx.IsAPIKey {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Feedback webhooks require API key authentication"})
+		return 0, false
+	}
+	return *authCtx.APIKeyID, true
+}
+
 // UpdateWebhookRequest represents a webhook update request
 type UpdateWebhookRequest struct {
-	URL      string `json:"url"`
-	IsActive bool   `json:"is_active"`
+	URL           string `json:"url"`
+	IsActive      bool   `json:"is_active"`
+	SchemaVersion int    `json:"schema_version" binding:"omitempty,oneof=1 2"` // Unchanged when omitted
+	IncludeRaw    *bool  `json:"include_raw"`                                  // Unchanged when omitted
+}
+
+// RotateSecretRequest represents a webhook secret rotation request
+type RotateSecretRequest struct {
+	// Seconds the old secret stays valid; defaults to a day, 0 revokes it right away
+	OverlapSeconds *int `json:"overlap_seconds" binding:"omitempty,min=0"`
+}
+
+// secretOverlap reads the rotation overlap from the optional request body, writing a response on failure
+func secretOverlap(c *gin.Context) (time.Duration, bool) {
+	var req RotateSecretRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return 0, false
+		}
+	}
+
+	if req.OverlapSeconds == nil {
+		return service.DefaultSecretOverlap, true
+	}
+
+	overlap := time.Duration(*req.OverlapSeconds) * time.Second
+	if overlap > service.MaxSecretOverlap {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "overlap_seconds must be at most " + strconv.Itoa(int(service.MaxSecretOverlap.Seconds()))})
+		return 0, false
+	}
+	return overlap, true
+}
+
+// respondRotateError writes the response for a failed secret rotation
+func respondRotateError(c *gin.Context, err error) {
+	respondWebhookError(c, err, http.StatusInternalServerError)
+}
+
+// respondWebhookError writes the response for a failed webhook operation: 404 for unknown
+// webhooks, the given status otherwise
+func respondWebhookError(c *gin.Context, err error, status int) {
+	if errors.Is(err, service.ErrWebhookNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
 }
@@ -3,11 +3,13 @@ package handler
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
 	"github.com/kexi/telegram-bot-gateway/internal/middleware"
 	ws "github.com/kexi/telegram-bot-gateway/internal/websocket"
 )
@@ -38,6 +40,8 @@ func NewWebSocketHandler(hub *ws.Hub) *WebSocketHandler {
 // @Description Upgrade to WebSocket for real-time message streaming
 // @Tags websocket
 // @Param token query string false "JWT token (alternative to Authorization header)"
+// @Param schema_version query int false "Event schema: 1 (flat, default) or 2 (event envelope)"
+// @Param include_raw query bool false "Add the raw Telegram object to envelopes"
 // @Success 101 {string} string "Switching Protocols"
 // @Router /api/v1/ws [get]
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
@@ -53,6 +57,18 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		// TODO: Validate token and set auth context
 	}
 
+	// Events are sent flat unless the client opts into envelopes
+	format := ws.EventFormat{SchemaVersion: domain.EventSchemaFlat}
+	if raw := c.Query("schema_version"); raw != "" {
+		version, err := strconv.Atoi(raw)
+		if err != nil || (version != domain.EventSchemaFlat && version != domain.EventSchemaEnvelope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "schema_version must be 1 or 2"})
+			return
+		}
+		format.SchemaVersion = version
+	}
+	format.IncludeRaw = c.Query("include_raw") == "true"
+
 	// Upgrade connection
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -63,17 +79,8 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	// Create client ID
 	clientID := fmt.Sprintf("client_%d", time.Now().UnixNano())
 
-	// Create client
-	var userID, apiKeyID *uint
-	if authCtx != nil {
-		if authCtx.IsAPIKey {
-			apiKeyID = authCtx.APIKeyID
-		} else {
-			userID = &authCtx.UserID
-		}
-	}
-
-	client := ws.NewClient(clientID, h.hub, conn, userID, apiKeyID)
+	// Create client; subscriptions are checked against the caller's chat permissions
+	client := ws.NewClient(clientID, h.hub, conn, authCtx, format)
 
 	// Register client with hub
 	h.hub.RegisterClient(client)
@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,6 +23,125 @@ type AuthContext struct {
 	Roles     []string
 	APIKeyID  *uint
 	IsAPIKey  bool
+	Scopes    []string // Scopes of an API key; empty when unrestricted
+	AllBots   bool     // API key may use every bot without bot grants
+
+	// Hourly quotas of an API key; users' quotas are loaded by PerUserRateLimitMiddleware
+	RateLimit     int
+	SendRateLimit int
+}
+
+// API key authentication errors
+var (
+	ErrInvalidAPIKeyFormat = errors.New("Invalid API key format")
+	ErrInvalidAPIKey       = errors.New("Invalid API key")
+	ErrAPIKeyInactive      = errors.New("API key is inactive")
+	ErrAPIKeyExpired       = errors.New("API key has expired")
+	ErrAPIKeyInvalidScopes = errors.New("API key has invalid scopes")
+)
+
+type authContextKey struct{}
+
+// NewContextWithAuth returns a copy of ctx carrying the auth context
+func NewContextWithAuth(ctx context.Context, authCtx *AuthContext) context.Context {
+	return context.WithValue(ctx, authContextKey{}, authCtx)
+}
+
+// AuthContextFromContext retrieves the auth context stored by NewContextWithAuth
+func AuthContextFromContext(ctx context.Context) (*AuthContext, bool) {
+	authCtx, ok := ctx.Value(authContextKey{}).(*AuthContext)
+	return authCtx, ok && authCtx != nil
+}
+
+// AuthenticateJWT validates a JWT access token and builds the auth context
+func AuthenticateJWT(jwtService *jwt.Service, token string) (*AuthContext, error) {
+	claims, err := jwtService.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthContext{
+		UserID:   claims.UserID,
+		Username: claims.Username,
+		Roles:    claims.Roles,
+		IsAPIKey: false,
+	}, nil
+}
+
+// AuthenticateAPIKey validates a plaintext API key against the database and builds the auth context
+func AuthenticateAPIKey(ctx context.Context, apiKeyService *apikey.Service, apiKeyRepo repository.APIKeyRepository, key string) (*AuthContext, error) {
+	if !apiKeyService.IsValid(key) {
+		return nil, ErrInvalidAPIKeyFormat
+	}
+
+	// Look up API key in database by its visible prefix.
+	// Keys created before per-key salts are stored in full.
+	apiKey, err := apiKeyRepo.GetByKey(ctx, apiKeyService.VisiblePrefix(key))
+	if err != nil {
+		apiKey, err = apiKeyRepo.GetByKey(ctx, key)
+		if err != nil {
+			return nil, ErrInvalidAPIKey
+		}
+	}
+
+	// Verify key hash
+	if !apiKeyService.Verify(key, apiKey.HashedKey) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Check if active
+	if !apiKey.IsActive {
+		return nil, ErrAPIKeyInactive
+	}
+
+	// Check expiration
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	// Re-hash legacy keys with their own salt and drop the stored plaintext
+	if apiKeyService.NeedsRehash(apiKey.HashedKey) {
+		if _, running := rehashing.LoadOrStore(apiKey.ID, struct{}{}); !running {
+			go rehashAPIKey(apiKeyService, apiKeyRepo, apiKey.ID, key)
+		}
+	}
+
+	// Update last used timestamp (async, don't block request)
+	go apiKeyRepo.UpdateLastUsed(context.Background(), apiKey.ID)
+
+	// Unreadable scopes must not grant everything
+	scopes, err := ParseScopes(apiKey.Scopes)
+	if err != nil {
+		log.Printf("API key %d has invalid scopes: %v", apiKey.ID, err)
+		return nil, ErrAPIKeyInvalidScopes
+	}
+
+	return &AuthContext{
+		APIKeyID:      &apiKey.ID,
+		IsAPIKey:      true,
+		Scopes:        scopes,
+		AllBots:       apiKey.AllBots,
+		RateLimit:     apiKey.RateLimit,
+		SendRateLimit: apiKey.SendRateLimit,
+	}, nil
+}
+
+// rehashing holds the IDs of the API keys being re-hashed, so that a burst of requests
+// with a legacy key runs a single argon2 hash
+var rehashing sync.Map
+
+// rehashAPIKey stores a fresh per-key salted hash and the visible prefix of a verified key
+func rehashAPIKey(apiKeyService *apikey.Service, apiKeyRepo repository.APIKeyRepository, id uint, key string) {
+	defer rehashing.Delete(id)
+
+	hashedKey, err := apiKeyService.Hash(key)
+	if err != nil {
+		log.Printf("Failed to re-hash API key %d: %v", id, err)
+		return
+	}
+	if err := apiKeyRepo.UpdateHash(context.Background(), id, apiKeyService.VisiblePrefix(key), hashedKey); err != nil {
+		log.Printf("Failed to re-hash API key %d: %v", id, err)
+	}
 }
 
 // AuthMiddleware creates a middleware for JWT and API key authentication
@@ -29,15 +151,9 @@ func AuthMiddleware(jwtService *jwt.Service, apiKeyService *apikey.Service, apiK
 		authHeader := c.GetHeader("Authorization")
 		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
 			token := strings.TrimPrefix(authHeader, "Bearer ")
-			claims, err := jwtService.ValidateToken(token)
+			authCtx, err := AuthenticateJWT(jwtService, token)
 			if err == nil {
 				// Valid JWT token
-				authCtx := &AuthContext{
-					UserID:   claims.UserID,
-					Username: claims.Username,
-					Roles:    claims.Roles,
-					IsAPIKey: false,
-				}
 				c.Set("auth", authCtx)
 				c.Next()
 				return
@@ -66,50 +182,14 @@ func AuthMiddleware(jwtService *jwt.Service, apiKeyService *apikey.Service, apiK
 		}
 
 		if apiKeyValue != "" {
-			if !apiKeyService.IsValid(apiKeyValue) {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key format"})
-				c.Abort()
-				return
-			}
-
-			// Look up API key in database
-			ctx := context.Background()
-			apiKey, err := apiKeyRepo.GetByKey(ctx, apiKeyValue)
+			authCtx, err := AuthenticateAPIKey(context.Background(), apiKeyService, apiKeyRepo, apiKeyValue)
 			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
-				c.Abort()
-				return
-			}
-
-			// Verify key hash
-			if !apiKeyService.Verify(apiKeyValue, apiKey.HashedKey) {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
-				c.Abort()
-				return
-			}
-
-			// Check if active
-			if !apiKey.IsActive {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "API key is inactive"})
-				c.Abort()
-				return
-			}
-
-			// Check expiration
-			if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "API key has expired"})
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 				c.Abort()
 				return
 			}
 
-			// Update last used timestamp (async, don't block request)
-			go apiKeyRepo.UpdateLastUsed(context.Background(), apiKey.ID)
-
 			// Set auth context
-			authCtx := &AuthContext{
-				APIKeyID: &apiKey.ID,
-				IsAPIKey: true,
-			}
 			c.Set("auth", authCtx)
 			c.Next()
 			return
@@ -155,18 +235,9 @@ func OptionalAuthMiddleware(jwtService *jwt.Service, apiKeyService *apikey.Servi
 			}
 		}
 
-		if apiKeyValue != "" && apiKeyService.IsValid(apiKeyValue) {
-			ctx := context.Background()
-			apiKey, err := apiKeyRepo.GetByKey(ctx, apiKeyValue)
-			if err == nil && apiKey.IsActive {
-				if apiKeyService.Verify(apiKeyValue, apiKey.HashedKey) {
-					authCtx := &AuthContext{
-						APIKeyID: &apiKey.ID,
-						IsAPIKey: true,
-					}
-					c.Set("auth", authCtx)
-					go apiKeyRepo.UpdateLastUsed(context.Background(), apiKey.ID)
-				}
+		if apiKeyValue != "" {
+			if authCtx, err := AuthenticateAPIKey(context.Background(), apiKeyService, apiKeyRepo, apiKeyValue); err == nil {
+				c.Set("auth", authCtx)
 			}
 		}
 
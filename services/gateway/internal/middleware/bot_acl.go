@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// CheckBotPermission reports whether the caller may act through a bot.
+// Bot grants only restrict API keys: a key may only use the bots granted with
+// can_send, or every bot once granted all bots. Users are not restricted.
+// The allowed bots of a key are cached in Redis for 5 minutes.
+func CheckBotPermission(ctx context.Context, authCtx *AuthContext, botID uint, botPermRepo repository.APIKeyBotPermissionRepository, redisClient *redis.Client) (bool, error) {
+	if !authCtx.IsAPIKey || authCtx.AllBots {
+		return true, nil
+	}
+
+	allowed, err := allowedBots(ctx, *authCtx.APIKeyID, botPermRepo, redisClient)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range strings.Split(allowed, ",") {
+		if id == strconv.FormatUint(uint64(botID), 10) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// VisibleBotFilter limits a bot listing for API keys to the bots of chats they have
+// permissions on, within their bot grants. Users, admins included, see the bots of
+// the chats they hold can_read on, as ReadableChatFilter does for chats.
+func VisibleBotFilter(authCtx *AuthContext) repository.BotFilter {
+	if authCtx.IsAPIKey {
+		return repository.BotFilter{VisibleToAPIKey: authCtx.APIKeyID, AllBots: authCtx.AllBots}
+	}
+	userID := authCtx.UserID
+	return repository.BotFilter{VisibleToUser: &userID}
+}
+
+// InvalidateBotPermissionCache removes the cached bot grants of an API key.
+// Call it after granting or revoking bot permissions.
+func InvalidateBotPermissionCache(ctx context.Context, redisClient *redis.Client, apiKeyID uint) error {
+	if redisClient == nil {
+		return nil
+	}
+	return redisClient.Del(ctx, botPermissionCacheKey(apiKeyID)).Err()
+}
+
+// BotPermissionDeniedMessage describes the missing bot grant in 403 responses
+func BotPermissionDeniedMessage(botID uint) string {
+	return fmt.Sprintf("API key has no grant for bot %d (apikey grant-bot)", botID)
+}
+
+// botPermissionCacheKey builds the Redis cache key for an API key's bot grants
+func botPermissionCacheKey(apiKeyID uint) string {
+	return fmt.Sprintf("bot_perm:apikey:%d", apiKeyID)
+}
+
+// allowedBots returns the comma-separated IDs of the bots an API key is granted
+func allowedBots(ctx context.Context, apiKeyID uint, botPermRepo repository.APIKeyBotPermissionRepository, redisClient *redis.Client) (string, error) {
+	cacheKey := botPermissionCacheKey(apiKeyID)
+
+	// Try cache first
+	if redisClient != nil {
+		cached, err := redisClient.Get(ctx, cacheKey).Result()
+		if err == nil {
+			return cached, nil
+		}
+	}
+
+	perms, err := botPermRepo.ListByAPIKey(ctx, apiKeyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load bot permissions: %w", err)
+	}
+
+	ids := make([]string, 0, len(perms))
+	for _, perm := range perms {
+		if perm.CanSend {
+			ids = append(ids, strconv.FormatUint(uint64(perm.BotID), 10))
+		}
+	}
+	allowed := strings.Join(ids, ",")
+
+	if redisClient != nil {
+		redisClient.Set(ctx, cacheKey, allowed, 5*time.Minute)
+	}
+
+	return allowed, nil
+}
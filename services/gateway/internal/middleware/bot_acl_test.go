@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// fakeChatRepository serves a single chat by its Telegram ID
+type fakeChatRepository struct {
+	repository.ChatRepository
+	chat *domain.Chat
+}
+
+func (r *fakeChatRepository) GetByTelegramID(ctx context.Context, telegramID int64) (*domain.Chat, error) {
+	if r.chat.TelegramID != telegramID {
+		return nil, assert.AnError
+	}
+	return r.chat, nil
+}
+
+// fakeChatPermissionRepository gives every API key can_send on every chat
+type fakeChatPermissionRepository struct {
+	repository.ChatPermissionRepository
+}
+
+func (r *fakeChatPermissionRepository) GetByAPIKeyAndChat(ctx context.Context, apiKeyID, chatID uint) (*domain.ChatPermission, error) {
+	return &domain.ChatPermission{ChatID: chatID, APIKeyID: &apiKeyID, CanRead: true, CanSend: true}, nil
+}
+
+// fakeBotPermissionRepository serves bot grants from memory, keyed by API key ID
+type fakeBotPermissionRepository struct {
+	repository.APIKeyBotPermissionRepository
+	perms map[uint][]domain.APIKeyBotPermission
+}
+
+func (r *fakeBotPermissionRepository) ListByAPIKey(ctx context.Context, apiKeyID uint) ([]domain.APIKeyBotPermission, error) {
+	return r.perms[apiKeyID], nil
+}
+
+// sendThroughChat runs a request to a send route of chat -100500, owned by bot 2
+func sendThroughChat(t *testing.T, authCtx *AuthContext, botPermRepo repository.APIKeyBotPermissionRepository) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	chatRepo := &fakeChatRepository{chat: &domain.Chat{ID: 9, BotID: 2, TelegramID: -100500}}
+
+	router := gin.New()
+	router.POST("/chats/:id/messages",
+		func(c *gin.Context) { c.Set("auth", authCtx) },
+		ChatACLMiddlewareWithBotCheck(PermissionSend, &fakeChatPermissionRepository{}, chatRepo, botPermRepo, nil),
+		func(c *gin.Context) { c.Status(http.StatusOK) },
+	)
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "/chats/-100500/messages", nil)
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyWithoutBotGrantsIsRefused(t *testing.T) {
+	apiKeyID := uint(4)
+	w := sendThroughChat(t, &AuthContext{IsAPIKey: true, APIKeyID: &apiKeyID}, &fakeBotPermissionRepository{})
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, BotPermissionDeniedMessage(2), body["error"])
+}
+
+func TestAPIKeyBotGrants(t *testing.T) {
+	apiKeyID := uint(4)
+	tests := []struct {
+		name    string
+		allBots bool
+		perms   []domain.APIKeyBotPermission
+		want    int
+	}{
+		{"granted bot", false, []domain.APIKeyBotPermission{{APIKeyID: 4, BotID: 2, CanSend: true}}, http.StatusOK},
+		{"other bot", false, []domain.APIKeyBotPermission{{APIKeyID: 4, BotID: 3, CanSend: true}}, http.StatusForbidden},
+		{"grant without can_send", false, []domain.APIKeyBotPermission{{APIKeyID: 4, BotID: 2}}, http.StatusForbidden},
+		{"all bots", true, nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			botPermRepo := &fakeBotPermissionRepository{perms: map[uint][]domain.APIKeyBotPermission{apiKeyID: tt.perms}}
+			w := sendThroughChat(t, &AuthContext{IsAPIKey: true, APIKeyID: &apiKeyID, AllBots: tt.allBots}, botPermRepo)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestVisibleBotFilter(t *testing.T) {
+	apiKeyID := uint(4)
+	filter := VisibleBotFilter(&AuthContext{IsAPIKey: true, APIKeyID: &apiKeyID})
+	assert.Equal(t, &apiKeyID, filter.VisibleToAPIKey)
+	assert.Nil(t, filter.VisibleToUser)
+
+	filter = VisibleBotFilter(&AuthContext{UserID: 7, Roles: []string{"admin"}})
+	require.NotNil(t, filter.VisibleToUser, "users, admins included, only see the bots of chats they can read")
+	assert.Equal(t, uint(7), *filter.VisibleToUser)
+	assert.Nil(t, filter.VisibleToAPIKey)
+}
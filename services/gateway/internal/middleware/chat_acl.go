@@ -23,6 +23,23 @@ const (
 
 // ChatACLMiddleware checks granular chat-level permissions
 func ChatACLMiddleware(permission string, chatPermRepo repository.ChatPermissionRepository, chatRepo repository.ChatRepository, redisClient *redis.Client) gin.HandlerFunc {
+	return chatACL(permission, chatPermRepo, chatRepo, nil, redisClient)
+}
+
+// ChatACLMiddlewareWithBotCheck checks chat permissions and, for API keys, the grant for the chat's bot.
+// Use it on every route that acts through the bot (sending, editing, deleting, pinning).
+func ChatACLMiddlewareWithBotCheck(
+	permission string,
+	chatPermRepo repository.ChatPermissionRepository,
+	chatRepo repository.ChatRepository,
+	botPermRepo repository.APIKeyBotPermissionRepository,
+	redisClient *redis.Client,
+) gin.HandlerFunc {
+	return chatACL(permission, chatPermRepo, chatRepo, botPermRepo, redisClient)
+}
+
+// chatACL builds the chat ACL middleware; bot grants are checked when botPermRepo is set
+func chatACL(permission string, chatPermRepo repository.ChatPermissionRepository, chatRepo repository.ChatRepository, botPermRepo repository.APIKeyBotPermissionRepository, redisClient *redis.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get auth context
 		authCtx, exists := GetAuthContext(c)
@@ -67,25 +84,87 @@ func ChatACLMiddleware(permission string, chatPermRepo repository.ChatPermission
 		}
 
 		if !allowed {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for this chat"})
+			c.JSON(http.StatusForbidden, gin.H{"error": ChatPermissionDeniedMessage(permission)})
 			c.Abort()
 			return
 		}
 
+		// API keys restricted to some bots may only act through those
+		if botPermRepo != nil {
+			botAllowed, err := CheckBotPermission(c.Request.Context(), authCtx, chat.BotID, botPermRepo, redisClient)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check bot permissions"})
+				c.Abort()
+				return
+			}
+
+			if !botAllowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": BotPermissionDeniedMessage(chat.BotID)})
+				c.Abort()
+				return
+			}
+		}
+
 		c.Next()
 	}
 }
 
+// ChatPermissionDeniedMessage describes the missing chat grant in 403 responses
+func ChatPermissionDeniedMessage(permission string) string {
+	return fmt.Sprintf("Insufficient permissions for this chat: can_%s required", permission)
+}
+
+// CheckChatPermission reports whether the caller holds the permission on a chat (internal chat ID).
+// It is shared by the REST middleware, WebSocket subscriptions and gRPC services.
+func CheckChatPermission(ctx context.Context, authCtx *AuthContext, chatID uint, permission string, chatPermRepo repository.ChatPermissionRepository, redisClient *redis.Client) (bool, error) {
+	return checkChatPermission(ctx, authCtx, chatID, permission, chatPermRepo, redisClient)
+}
+
+// ReadableChatFilter limits a chat listing to the chats the caller holds can_read on
+func ReadableChatFilter(authCtx *AuthContext) repository.ChatFilter {
+	if authCtx.IsAPIKey {
+		return repository.ChatFilter{ReadableByAPIKey: authCtx.APIKeyID}
+	}
+	userID := authCtx.UserID
+	return repository.ChatFilter{ReadableByUser: &userID}
+}
+
+// InvalidateChatPermissionCache removes cached permission results for an API key or user on a chat.
+// Call it after granting, updating or revoking chat permissions.
+func InvalidateChatPermissionCache(ctx context.Context, redisClient *redis.Client, apiKeyID, userID *uint, chatID uint) error {
+	if redisClient == nil {
+		return nil
+	}
+
+	var keys []string
+	for _, permission := range []string{PermissionRead, PermissionSend, PermissionManage} {
+		if apiKeyID != nil {
+			keys = append(keys, chatPermissionCacheKey(&AuthContext{IsAPIKey: true, APIKeyID: apiKeyID}, chatID, permission))
+		}
+		if userID != nil {
+			keys = append(keys, chatPermissionCacheKey(&AuthContext{UserID: *userID}, chatID, permission))
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return redisClient.Del(ctx, keys...).Err()
+}
+
+// chatPermissionCacheKey builds the Redis cache key for a permission check
+func chatPermissionCacheKey(authCtx *AuthContext, chatID uint, permission string) string {
+	if authCtx.IsAPIKey {
+		return fmt.Sprintf("chat_perm:apikey:%d:chat:%d:%s", *authCtx.APIKeyID, chatID, permission)
+	}
+	return fmt.Sprintf("chat_perm:user:%d:chat:%d:%s", authCtx.UserID, chatID, permission)
+}
+
 // checkChatPermission checks if the authenticated user/API key has the required permission
 // Uses Redis cache with 5-minute TTL
 func checkChatPermission(ctx context.Context, authCtx *AuthContext, chatID uint, permission string, chatPermRepo repository.ChatPermissionRepository, redisClient *redis.Client) (bool, error) {
 	// Build cache key
-	var cacheKey string
-	if authCtx.IsAPIKey {
-		cacheKey = fmt.Sprintf("chat_perm:apikey:%d:chat:%d:%s", *authCtx.APIKeyID, chatID, permission)
-	} else {
-		cacheKey = fmt.Sprintf("chat_perm:user:%d:chat:%d:%s", authCtx.UserID, chatID, permission)
-	}
+	cacheKey := chatPermissionCacheKey(authCtx, chatID, permission)
 
 	// Try cache first
 	if redisClient != nil {
@@ -140,80 +219,3 @@ func checkChatPermission(ctx context.Context, authCtx *AuthContext, chatID uint,
 
 	return allowed, nil
 }
-
-// ChatACLMiddlewareWithBotCheck checks both chat permissions and bot restrictions
-func ChatACLMiddlewareWithBotCheck(
-	permission string,
-	chatPermRepo repository.ChatPermissionRepository,
-	chatRepo repository.ChatRepository,
-	botPermRepo repository.APIKeyBotPermissionRepository,
-	redisClient *redis.Client,
-) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Get auth context
-		authCtx, exists := GetAuthContext(c)
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
-			c.Abort()
-			return
-		}
-
-		// Get chat ID from URL parameter
-		chatIDStr := c.Param("id")
-		if chatIDStr == "" {
-			chatIDStr = c.Param("chat_id")
-		}
-		if chatIDStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID required"})
-			c.Abort()
-			return
-		}
-
-		chatID, err := strconv.ParseUint(chatIDStr, 10, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
-			c.Abort()
-			return
-		}
-
-		// Check chat permission first
-		allowed, err := checkChatPermission(c.Request.Context(), authCtx, uint(chatID), permission, chatPermRepo, redisClient)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
-			c.Abort()
-			return
-		}
-
-		if !allowed {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for this chat"})
-			c.Abort()
-			return
-		}
-
-		// For API keys sending messages, verify bot permission
-		if authCtx.IsAPIKey && permission == PermissionSend {
-			chat, err := chatRepo.GetByID(c.Request.Context(), uint(chatID))
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve chat"})
-				c.Abort()
-				return
-			}
-
-			// Check if API key has permission to use this chat's bot
-			botAllowed, err := botPermRepo.HasBotAccess(c.Request.Context(), *authCtx.APIKeyID, chat.BotID)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check bot permissions"})
-				c.Abort()
-				return
-			}
-
-			if !botAllowed {
-				c.JSON(http.StatusForbidden, gin.H{"error": "API key not authorized for this bot"})
-				c.Abort()
-				return
-			}
-		}
-
-		c.Next()
-	}
-}
@@ -0,0 +1,235 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// Quota buckets: reads are GET requests, every other request counts as a send
+const (
+	QuotaRead = "read"
+	QuotaSend = "send"
+)
+
+// QuotaWindow is the window hourly quotas are counted in
+const QuotaWindow = time.Hour
+
+// Quota holds the hourly budgets of a caller
+type Quota struct {
+	Read int
+	Send int
+}
+
+// Limit returns the budget of a bucket
+func (q Quota) Limit(bucket string) int {
+	if bucket == QuotaSend {
+		return q.Send
+	}
+	return q.Read
+}
+
+// QuotaUsage describes the consumption of one budget in the current window
+type QuotaUsage struct {
+	Limit     int        `json:"limit"`
+	Used      int        `json:"used"`
+	Remaining int        `json:"remaining"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"` // Unset until the first request of a window
+}
+
+// QuotaLimiter enforces the hourly quotas stored on API keys and users
+type QuotaLimiter struct {
+	limiter     *RateLimiter
+	defaults    Quota
+	userRepo    repository.UserRepository
+	redisClient *redis.Client
+}
+
+// NewQuotaLimiter creates a quota limiter. defaults apply to users without their
+// own quotas and to API keys without rate limits.
+func NewQuotaLimiter(limiter *RateLimiter, defaults Quota, userRepo repository.UserRepository, redisClient *redis.Client) *QuotaLimiter {
+	return &QuotaLimiter{
+		limiter:     limiter,
+		defaults:    defaults,
+		userRepo:    userRepo,
+		redisClient: redisClient,
+	}
+}
+
+// QuotaBucket returns the budget a request method counts against
+func QuotaBucket(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return QuotaRead
+	default:
+		return QuotaSend
+	}
+}
+
+// Resolve returns the hourly budgets of the caller. API keys carry theirs in the
+// auth context and use their read budget for sends unless a send budget is set;
+// keys with neither get the default budgets. The quotas of a user are cached in
+// Redis for 5 minutes.
+func (q *QuotaLimiter) Resolve(ctx context.Context, authCtx *AuthContext) (Quota, error) {
+	if authCtx.IsAPIKey {
+		quota := Quota{Read: authCtx.RateLimit, Send: authCtx.SendRateLimit}
+		if quota.Send <= 0 {
+			quota.Send = quota.Read
+		}
+		if quota.Read <= 0 {
+			quota.Read = q.defaults.Read
+		}
+		if quota.Send <= 0 {
+			quota.Send = q.defaults.Send
+		}
+		return quota, nil
+	}
+
+	quota, err := q.userQuota(ctx, authCtx.UserID)
+	if err != nil {
+		return Quota{}, err
+	}
+	if quota.Read <= 0 {
+		quota.Read = q.defaults.Read
+	}
+	if quota.Send <= 0 {
+		quota.Send = q.defaults.Send
+	}
+	return quota, nil
+}
+
+// Allow counts a request against a budget of the caller
+func (q *QuotaLimiter) Allow(ctx context.Context, authCtx *AuthContext, bucket string, limit int) (allowed bool, remaining int, resetAt time.Time, err error) {
+	return q.limiter.allow(ctx, quotaKey(authCtx, bucket), limit, QuotaWindow)
+}
+
+// Usage reports the consumption of a budget of the caller without counting a request
+func (q *QuotaLimiter) Usage(ctx context.Context, authCtx *AuthContext, bucket string, limit int) (*QuotaUsage, error) {
+	key := quotaKey(authCtx, bucket)
+	usage := &QuotaUsage{Limit: limit, Remaining: limit}
+
+	used, err := q.redisClient.Get(ctx, key).Int()
+	if err == redis.Nil {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := q.redisClient.TTL(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	usage.Used = used
+	usage.Remaining = max(limit-used, 0)
+	if ttl > 0 {
+		resetAt := time.Now().Add(ttl).Truncate(time.Second)
+		usage.ResetAt = &resetAt
+	}
+	return usage, nil
+}
+
+// PerUserRateLimitMiddleware enforces the hourly read and send quotas of authenticated
+// callers and reports the budget the request counted against in X-RateLimit-* headers
+func PerUserRateLimitMiddleware(quotas *QuotaLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authCtx, exists := GetAuthContext(c)
+		if !exists {
+			// No auth context, skip rate limiting
+			c.Next()
+			return
+		}
+
+		quota, err := quotas.Resolve(c.Request.Context(), authCtx)
+		if err != nil {
+			log.Printf("Failed to load quota of %s: %v", authIdentifier(authCtx), err)
+			c.Next()
+			return
+		}
+
+		bucket := QuotaBucket(c.Request.Method)
+		limit := quota.Limit(bucket)
+
+		allowed, remaining, resetAt, err := quotas.Allow(c.Request.Context(), authCtx, bucket, limit)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Used", strconv.Itoa(limit-remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+		c.Header("X-RateLimit-Resource", bucket)
+
+		if !allowed {
+			c.Header("Retry-After", strconv.FormatInt(int64(time.Until(resetAt).Seconds()), 10))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Rate limit exceeded",
+				"message": fmt.Sprintf("Hourly %s quota of %d requests exhausted. Try again after %s",
+					bucket, limit, resetAt.Format(time.RFC3339)),
+				"retry_after": resetAt.Unix(),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// InvalidateUserQuotaCache removes the cached quotas of a user.
+// Call it after changing the user's rate limits.
+func InvalidateUserQuotaCache(ctx context.Context, redisClient *redis.Client, userID uint) error {
+	if redisClient == nil {
+		return nil
+	}
+	return redisClient.Del(ctx, userQuotaCacheKey(userID)).Err()
+}
+
+// quotaKey builds the Redis counter key of a budget of the caller
+func quotaKey(authCtx *AuthContext, bucket string) string {
+	return fmt.Sprintf("ratelimit:quota:%s:%s", authIdentifier(authCtx), bucket)
+}
+
+// userQuotaCacheKey builds the Redis cache key for a user's quotas
+func userQuotaCacheKey(userID uint) string {
+	return fmt.Sprintf("quota:user:%d", userID)
+}
+
+// userQuota returns the quotas stored on a user, 0 where unset
+func (q *QuotaLimiter) userQuota(ctx context.Context, userID uint) (Quota, error) {
+	cacheKey := userQuotaCacheKey(userID)
+
+	// Try cache first
+	if q.redisClient != nil {
+		cached, err := q.redisClient.Get(ctx, cacheKey).Result()
+		if err == nil {
+			var quota Quota
+			if _, err := fmt.Sscanf(cached, "%d,%d", &quota.Read, &quota.Send); err == nil {
+				return quota, nil
+			}
+		}
+	}
+
+	user, err := q.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return Quota{}, fmt.Errorf("failed to load user: %w", err)
+	}
+	quota := Quota{Read: user.RateLimit, Send: user.SendRateLimit}
+
+	if q.redisClient != nil {
+		q.redisClient.Set(ctx, cacheKey, fmt.Sprintf("%d,%d", quota.Read, quota.Send), 5*time.Minute)
+	}
+
+	return quota, nil
+}
@@ -66,43 +66,48 @@ func RateLimitMiddleware(limiter *RateLimiter) gin.HandlerFunc {
 
 // Allow checks if a request should be allowed based on rate limit
 func (rl *RateLimiter) Allow(ctx context.Context, identifier string) (allowed bool, remaining int, resetAt time.Time, err error) {
+	return rl.allow(ctx, fmt.Sprintf("ratelimit:%s", identifier), rl.requestsPerSecond, time.Second)
+}
+
+// fixedWindowScript counts requests in a window that starts with the first request
+var fixedWindowScript = redis.NewScript(`
+	local key = KEYS[1]
+	local limit = tonumber(ARGV[1])
+	local window = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+
+	-- Get current count and timestamp
+	local current = redis.call('GET', key)
+
+	if current == false then
+		-- First request in this window
+		redis.call('SET', key, 1, 'EX', window)
+		return {1, limit - 1, now + window}
+	end
+
+	local count = tonumber(current)
+
+	if count < limit then
+		-- Increment count
+		redis.call('INCR', key)
+		local ttl = redis.call('TTL', key)
+		return {1, limit - count - 1, now + ttl}
+	else
+		-- Rate limit exceeded
+		local ttl = redis.call('TTL', key)
+		return {0, 0, now + ttl}
+	end
+`)
+
+// allow counts a request against limit requests per window under a Redis key
+func (rl *RateLimiter) allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, remaining int, resetAt time.Time, err error) {
 	now := time.Now()
-	key := fmt.Sprintf("ratelimit:%s", identifier)
 
 	// Use Lua script for atomic rate limiting check
-	script := redis.NewScript(`
-		local key = KEYS[1]
-		local limit = tonumber(ARGV[1])
-		local window = tonumber(ARGV[2])
-		local now = tonumber(ARGV[3])
-
-		-- Get current count and timestamp
-		local current = redis.call('GET', key)
-
-		if current == false then
-			-- First request in this window
-			redis.call('SET', key, 1, 'EX', window)
-			return {1, limit - 1, now + window}
-		end
-
-		local count = tonumber(current)
-
-		if count < limit then
-			-- Increment count
-			redis.call('INCR', key)
-			local ttl = redis.call('TTL', key)
-			return {1, limit - count - 1, now + ttl}
-		else
-			-- Rate limit exceeded
-			local ttl = redis.call('TTL', key)
-			return {0, 0, now + ttl}
-		end
-	`)
-
-	result, err := script.Run(ctx, rl.client,
+	result, err := fixedWindowScript.Run(ctx, rl.client,
 		[]string{key},
-		rl.requestsPerSecond,
-		1, // 1 second window
+		limit,
+		int(window.Seconds()),
 		now.Unix(),
 	).Result()
 
@@ -127,55 +132,19 @@ func getIdentifier(c *gin.Context) string {
 	// Try to get from auth context first
 	authCtx, exists := GetAuthContext(c)
 	if exists {
-		if authCtx.IsAPIKey {
-			return fmt.Sprintf("apikey:%d", *authCtx.APIKeyID)
-		}
-		return fmt.Sprintf("user:%d", authCtx.UserID)
+		return authIdentifier(authCtx)
 	}
 
 	// Fall back to IP address
 	return fmt.Sprintf("ip:%s", c.ClientIP())
 }
 
-// PerUserRateLimitMiddleware creates a rate limiter specific to authenticated users
-func PerUserRateLimitMiddleware(limiter *RateLimiter) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authCtx, exists := GetAuthContext(c)
-		if !exists {
-			// No auth context, skip rate limiting
-			c.Next()
-			return
-		}
-
-		var identifier string
-		if authCtx.IsAPIKey {
-			identifier = fmt.Sprintf("apikey:%d", *authCtx.APIKeyID)
-		} else {
-			identifier = fmt.Sprintf("user:%d", authCtx.UserID)
-		}
-
-		allowed, remaining, resetAt, err := limiter.Allow(c.Request.Context(), identifier)
-		if err != nil {
-			c.Next()
-			return
-		}
-
-		c.Header("X-RateLimit-Limit", strconv.Itoa(limiter.requestsPerSecond))
-		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
-		c.Header("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
-
-		if !allowed {
-			c.Header("Retry-After", strconv.FormatInt(int64(resetAt.Sub(time.Now()).Seconds()), 10))
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":   "Rate limit exceeded",
-				"message": "You have exceeded your request quota",
-			})
-			c.Abort()
-			return
-		}
-
-		c.Next()
+// authIdentifier identifies an authenticated caller for rate limiting
+func authIdentifier(authCtx *AuthContext) string {
+	if authCtx.IsAPIKey {
+		return fmt.Sprintf("apikey:%d", *authCtx.APIKeyID)
 	}
+	return fmt.Sprintf("user:%d", authCtx.UserID)
 }
 
 // GlobalRateLimitMiddleware creates a global rate limiter for all requests
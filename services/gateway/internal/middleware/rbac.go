@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// RequirePermission only lets users whose roles grant the permission through,
+// e.g. RequirePermission("messages:send", ...). API keys are authorized by their
+// chat and bot grants instead and are not checked.
+func RequirePermission(permission string, roleRepo repository.RoleRepository, redisClient *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authCtx, exists := GetAuthContext(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+
+		allowed, err := HasPermission(c.Request.Context(), authCtx, permission, roleRepo, redisClient)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": RolePermissionDeniedMessage(permission)})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasPermission reports whether the caller's roles grant a permission.
+// The permissions of a user are cached in Redis for 5 minutes.
+func HasPermission(ctx context.Context, authCtx *AuthContext, permission string, roleRepo repository.RoleRepository, redisClient *redis.Client) (bool, error) {
+	if authCtx.IsAPIKey {
+		return true, nil
+	}
+
+	permissions, err := userPermissions(ctx, authCtx.UserID, roleRepo, redisClient)
+	if err != nil {
+		return false, err
+	}
+
+	for _, granted := range permissions {
+		if granted == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// InvalidateUserPermissionCache removes the cached permissions of users.
+// Call it after changing their roles or the permissions of one of their roles.
+func InvalidateUserPermissionCache(ctx context.Context, redisClient *redis.Client, userIDs ...uint) error {
+	if redisClient == nil || len(userIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = userPermissionCacheKey(userID)
+	}
+	return redisClient.Del(ctx, keys...).Err()
+}
+
+// RolePermissionDeniedMessage describes the missing role permission in 403 responses
+func RolePermissionDeniedMessage(permission string) string {
+	return fmt.Sprintf("Insufficient permissions: %s required", permission)
+}
+
+// userPermissionCacheKey builds the Redis cache key for a user's permissions
+func userPermissionCacheKey(userID uint) string {
+	return fmt.Sprintf("rbac:user:%d", userID)
+}
+
+// userPermissions returns the names of the permissions granted by a user's roles
+func userPermissions(ctx context.Context, userID uint, roleRepo repository.RoleRepository, redisClient *redis.Client) ([]string, error) {
+	cacheKey := userPermissionCacheKey(userID)
+
+	// Try cache first
+	if redisClient != nil {
+		cached, err := redisClient.Get(ctx, cacheKey).Result()
+		if err == nil {
+			if cached == "" {
+				return nil, nil
+			}
+			return strings.Split(cached, ","), nil
+		}
+	}
+
+	permissions, err := roleRepo.ListUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user permissions: %w", err)
+	}
+
+	if redisClient != nil {
+		redisClient.Set(ctx, cacheKey, strings.Join(permissions, ","), 5*time.Minute)
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API key scopes
+const (
+	ScopeBotsRead       = "bots:read"       // List and get bots
+	ScopeChatsRead      = "chats:read"      // List and get chats and their update events
+	ScopeMessagesRead   = "messages:read"   // Read messages, media, edits and callback queries; gRPC streams
+	ScopeMessagesSend   = "messages:send"   // Send, edit, forward and copy messages; answer callback queries
+	ScopeMessagesManage = "messages:manage" // Delete, pin and unpin messages
+	ScopeWebhooksManage = "webhooks:manage" // Manage webhooks and the feedback webhook
+	ScopeWSSubscribe    = "ws:subscribe"    // Open WebSocket connections and subscribe to chats
+)
+
+// AllScopes lists every scope an API key can be given
+var AllScopes = []string{
+	ScopeBotsRead,
+	ScopeChatsRead,
+	ScopeMessagesRead,
+	ScopeMessagesSend,
+	ScopeMessagesManage,
+	ScopeWebhooksManage,
+	ScopeWSSubscribe,
+}
+
+// ParseScopes decodes the JSON array stored in domain.APIKey.Scopes
+func ParseScopes(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var scopes []string
+	if err := json.Unmarshal([]byte(raw), &scopes); err != nil {
+		return nil, fmt.Errorf("scopes must be a JSON array of strings: %w", err)
+	}
+	return scopes, nil
+}
+
+// ValidateScopes checks that every scope is known
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		known := false
+		for _, s := range AllScopes {
+			if scope == s {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown scope %q (valid: %s)", scope, strings.Join(AllScopes, ", "))
+		}
+	}
+	return nil
+}
+
+// HasScope reports whether the caller may use a scope.
+// Only API keys are scoped, and a key without scopes may use all of them.
+func (a *AuthContext) HasScope(scope string) bool {
+	if !a.IsAPIKey || len(a.Scopes) == 0 {
+		return true
+	}
+	for _, s := range a.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope only lets API keys with the scope through. Users are authorized
+// by their role permissions instead and are not checked.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authCtx, exists := GetAuthContext(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+
+		if !authCtx.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": ScopeDeniedMessage(scope)})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ScopeDeniedMessage describes the missing API key scope in 403 responses
+func ScopeDeniedMessage(scope string) string {
+	return fmt.Sprintf("API key is missing scope %s", scope)
+}
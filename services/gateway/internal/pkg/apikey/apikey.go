@@ -2,6 +2,7 @@ package apikey
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"strings"
@@ -23,6 +24,21 @@ func NewService(prefix string, length int) *Service {
 	}
 }
 
+// Argon2id parameters (OWASP recommendations)
+const (
+	argonTime    = 1
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	argonKeyLen  = 32
+	saltLength   = 16
+)
+
+// visibleLength is the number of random characters kept in the visible key prefix
+const visibleLength = 12
+
+// legacySalt was shared by all keys hashed before per-key salts were introduced
+const legacySalt = "telegram-bot-gateway-salt-change-me"
+
 // Generate creates a new API key
 // Returns the plaintext key (to show to user) and the hashed key (to store in DB)
 func (s *Service) Generate() (key, hashedKey string, err error) {
@@ -37,23 +53,78 @@ func (s *Service) Generate() (key, hashedKey string, err error) {
 	key = s.prefix + randomPart
 
 	// Hash the key for storage
-	hashedKey = s.Hash(key)
+	hashedKey, err = s.Hash(key)
+	if err != nil {
+		return "", "", err
+	}
 
 	return key, hashedKey, nil
 }
 
-// Hash creates an argon2id hash of the API key
-func (s *Service) Hash(key string) string {
-	// Argon2id parameters (OWASP recommendations)
-	salt := []byte("telegram-bot-gateway-salt-change-me") // In production, use unique salt per key
-	hash := argon2.IDKey([]byte(key), salt, 1, 64*1024, 4, 32)
-	return base64.RawStdEncoding.EncodeToString(hash)
+// Hash creates an argon2id hash of the API key with a random salt,
+// encoded as $argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
+func (s *Service) Hash(key string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	hash := argon2.IDKey([]byte(key), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash)), nil
 }
 
-// Verify checks if a plaintext key matches a hashed key
+// Verify checks in constant time if a plaintext key matches a hashed key.
+// Hashes created with the former shared salt are still accepted.
 func (s *Service) Verify(key, hashedKey string) bool {
-	computedHash := s.Hash(key)
-	return computedHash == hashedKey
+	if !strings.HasPrefix(hashedKey, "$argon2id$") {
+		hash := argon2.IDKey([]byte(key), []byte(legacySalt), argonTime, argonMemory, argonThreads, argonKeyLen)
+		computed := base64.RawStdEncoding.EncodeToString(hash)
+		return subtle.ConstantTimeCompare([]byte(computed), []byte(hashedKey)) == 1
+	}
+
+	var version int
+	var memory, iterations uint32
+	var threads uint8
+	parts := strings.Split(hashedKey, "$")
+	if len(parts) != 6 {
+		return false
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	hash := argon2.IDKey([]byte(key), salt, iterations, memory, threads, uint32(len(expected)))
+	return subtle.ConstantTimeCompare(hash, expected) == 1
+}
+
+// NeedsRehash reports whether a hashed key uses the former shared salt or
+// other parameters than new hashes, and should be replaced after a successful Verify
+func (s *Service) NeedsRehash(hashedKey string) bool {
+	return !strings.HasPrefix(hashedKey, fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$",
+		argon2.Version, argonMemory, argonTime, argonThreads))
+}
+
+// VisiblePrefix returns the part of a key that is stored in plaintext to look it up
+func (s *Service) VisiblePrefix(key string) string {
+	n := len(s.prefix) + visibleLength
+	if len(key) <= n {
+		return key
+	}
+	return key[:n]
 }
 
 // IsValid checks if a key has the correct format
@@ -0,0 +1,70 @@
+package apikey
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	svc := NewService("tgw_", 32)
+
+	key, hashedKey, err := svc.Generate()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hashedKey, "$argon2id$"))
+	assert.True(t, svc.Verify(key, hashedKey))
+	assert.False(t, svc.Verify(key+"x", hashedKey))
+	assert.False(t, svc.NeedsRehash(hashedKey))
+
+	other, err := svc.Hash(key)
+	require.NoError(t, err)
+	assert.NotEqual(t, hashedKey, other, "every hash has its own salt")
+	assert.True(t, svc.Verify(key, other))
+}
+
+func TestVerifyLegacySharedSaltHash(t *testing.T) {
+	svc := NewService("tgw_", 32)
+	key := "tgw_legacy-key"
+	hash := argon2.IDKey([]byte(key), []byte(legacySalt), argonTime, argonMemory, argonThreads, argonKeyLen)
+	legacy := base64.RawStdEncoding.EncodeToString(hash)
+
+	assert.True(t, svc.Verify(key, legacy))
+	assert.False(t, svc.Verify("tgw_other-key", legacy))
+	assert.True(t, svc.NeedsRehash(legacy))
+}
+
+func TestNeedsRehashOnOtherParameters(t *testing.T) {
+	svc := NewService("tgw_", 32)
+	key := "tgw_key"
+	salt := []byte("0123456789abcdef")
+	hash := argon2.IDKey([]byte(key), salt, 2, 32*1024, 2, argonKeyLen)
+	hashedKey := "$argon2id$v=19$m=32768,t=2,p=2$" +
+		base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(hash)
+
+	assert.True(t, svc.Verify(key, hashedKey))
+	assert.True(t, svc.NeedsRehash(hashedKey))
+}
+
+func TestVerifyRejectsMalformedHash(t *testing.T) {
+	svc := NewService("tgw_", 32)
+	key, hashedKey, err := svc.Generate()
+	require.NoError(t, err)
+	parts := strings.Split(hashedKey, "$")
+
+	for name, malformed := range map[string]string{
+		"missing hash":  strings.Join(parts[:5], "$"),
+		"extra part":    hashedKey + "$extra",
+		"wrong version": strings.Replace(hashedKey, "v=19", "v=16", 1),
+		"bad params":    strings.Replace(hashedKey, parts[3], "m=x,t=1,p=4", 1),
+		"bad salt":      strings.Replace(hashedKey, parts[4], "!!!", 1),
+		"bad hash":      strings.Replace(hashedKey, parts[5], "!!!", 1),
+		"empty hash":    strings.TrimSuffix(hashedKey, parts[5]),
+	} {
+		assert.False(t, svc.Verify(key, malformed), name)
+	}
+}
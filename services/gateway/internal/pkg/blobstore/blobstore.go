@@ -0,0 +1,23 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// Store is a key/value store for file contents
+type Store interface {
+	// Put stores the contents of r under key. size is -1 when unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Get opens the blob stored under key and returns its size, -1 when unknown.
+	// The caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
+
+	// Delete removes the blob stored under key. Missing blobs are not an error.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,159 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrUnknownKey is returned when decrypting a value encrypted under a key that is not configured
+var ErrUnknownKey = errors.New("encryption key is not configured")
+
+// Keyring encrypts values with AES-256-GCM under versioned keys.
+// Ciphertexts are "<key id>:<base64 nonce and sealed data>", so older keys can
+// still decrypt their values after a new primary key is introduced.
+type Keyring struct {
+	primaryID string
+	keys      map[string][]byte
+	legacyKey []byte
+}
+
+// NewKeyring creates a keyring from base64-encoded 32-byte keys indexed by key ID.
+// New values are encrypted under primaryID. legacySecret decrypts values written
+// before key IDs were introduced, which used the JWT secret zero-padded or
+// truncated to 32 bytes. When it is empty such values fail with ErrUnknownKey;
+// the configuration leaves it empty once encryption.disable_legacy is set.
+func NewKeyring(primaryID string, keys map[string]string, legacySecret string) (*Keyring, error) {
+	k := &Keyring{
+		primaryID: primaryID,
+		keys:      make(map[string][]byte, len(keys)),
+	}
+
+	for id, encoded := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid encryption key ID %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q is not valid base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %q must be 32 bytes, got %d", id, len(key))
+		}
+		k.keys[id] = key
+	}
+
+	if _, ok := k.keys[primaryID]; !ok {
+		return nil, fmt.Errorf("primary encryption key %q is not configured", primaryID)
+	}
+
+	if legacySecret != "" {
+		k.legacyKey = make([]byte, 32)
+		copy(k.legacyKey, legacySecret)
+	}
+
+	return k, nil
+}
+
+// PrimaryKeyID returns the ID of the key new values are encrypted under
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primaryID
+}
+
+// Encrypt encrypts a value under the primary key
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	sealed, err := seal(k.keys[k.primaryID], plaintext)
+	if err != nil {
+		return "", err
+	}
+	return k.primaryID + ":" + sealed, nil
+}
+
+// Decrypt decrypts a value encrypted under any configured key
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	id, sealed, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		// Written before key IDs were introduced
+		if k.legacyKey == nil {
+			return "", fmt.Errorf("%w: value has no key ID", ErrUnknownKey)
+		}
+		return open(k.legacyKey, ciphertext)
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return open(key, sealed)
+}
+
+// KeyID returns the ID of the key a value is encrypted under, or "" for values
+// written before key IDs were introduced
+func (k *Keyring) KeyID(ciphertext string) string {
+	id, _, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// NeedsRotation reports whether a value is not encrypted under the primary key
+func (k *Keyring) NeedsRotation(ciphertext string) bool {
+	return k.KeyID(ciphertext) != k.primaryID
+}
+
+// seal encrypts plaintext and returns the base64-encoded nonce and sealed data
+func seal(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open reverses seal
+func open(key []byte, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, data := sealed[:nonceSize], sealed[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// newGCM creates an AES-GCM cipher for a 32-byte key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
 )
 
 // MessageBroker handles pub/sub operations for real-time message distribution
@@ -24,7 +26,7 @@ func NewMessageBroker(client *redis.Client) *MessageBroker {
 
 // MessageEvent represents a message event to be published
 type MessageEvent struct {
-	Type           string                 `json:"type"` // "new_message", "edited_message", "deleted_message"
+	Type           string                 `json:"type"` // "new_message", "edited_message", "deleted_message", "callback_query", or an update event type such as "my_chat_member"
 	ChatID         uint                   `json:"chat_id"`
 	MessageID      uint                   `json:"message_id"`
 	TelegramID     int64                  `json:"telegram_id"`
@@ -32,8 +34,12 @@ type MessageEvent struct {
 	Direction      string                 `json:"direction"` // "incoming", "outgoing"
 	Text           string                 `json:"text,omitempty"`
 	FromUsername   string                 `json:"from_username,omitempty"`
+	APIKeyID       *uint                  `json:"api_key_id,omitempty"` // Routes the event to this API key only
 	Timestamp      time.Time              `json:"timestamp"`
 	Payload        map[string]interface{} `json:"payload,omitempty"` // Full message data
+
+	// Envelope is the versioned shape of the event, sent to subscribers that opt into it
+	Envelope *domain.EventEnvelope `json:"envelope,omitempty"`
 }
 
 // PublishMessage publishes a message event to the appropriate channels
@@ -160,6 +166,92 @@ func (b *MessageBroker) DequeueWebhookDelivery(ctx context.Context, timeout time
 	return deliveryID, nil
 }
 
+// webhookRetriesKey is the sorted set of deliveries waiting for a retry, scored by the
+// Unix milliseconds of their next attempt
+const webhookRetriesKey = "webhook_delivery_retries"
+
+// ScheduleWebhookRetry holds a delivery back until at, when ReleaseDueWebhookRetries
+// moves it back onto the delivery queue
+func (b *MessageBroker) ScheduleWebhookRetry(ctx context.Context, deliveryID uint, at time.Time) error {
+	return b.client.ZAdd(ctx, webhookRetriesKey, redis.Z{Score: float64(at.UnixMilli()), Member: deliveryID}).Err()
+}
+
+// releaseRetriesScript moves up to ARGV[2] deliveries whose retry time has passed from
+// the retry set KEYS[1] to the delivery queue KEYS[2]. Returns the number moved.
+var releaseRetriesScript = redis.NewScript(`
+	local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+	for _, id in ipairs(due) do
+		redis.call('ZREM', KEYS[1], id)
+		redis.call('RPUSH', KEYS[2], id)
+	end
+	return #due
+`)
+
+// ReleaseDueWebhookRetries queues up to limit deliveries whose retry time has passed
+// and returns how many were queued
+func (b *MessageBroker) ReleaseDueWebhookRetries(ctx context.Context, now time.Time, limit int) (int, error) {
+	released, err := releaseRetriesScript.Run(ctx, b.client, []string{webhookRetriesKey, "webhook_deliveries"}, now.UnixMilli(), limit).Int()
+	if err != nil {
+		return 0, err
+	}
+	return released, nil
+}
+
+// recoverDeliveryScript schedules delivery ARGV[1] at ARGV[2] unless it is already
+// waiting in the retry set KEYS[1] or the delivery queue KEYS[2]. Returns 1 when scheduled.
+var recoverDeliveryScript = redis.NewScript(`
+	if redis.call('ZSCORE', KEYS[1], ARGV[1]) then
+		return 0
+	end
+	if redis.call('LPOS', KEYS[2], ARGV[1]) then
+		return 0
+	end
+	redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+	return 1
+`)
+
+// RecoverWebhookDelivery schedules a pending delivery at at unless it is already queued
+// or waiting for a retry. It reports whether the delivery had been lost.
+func (b *MessageBroker) RecoverWebhookDelivery(ctx context.Context, deliveryID uint, at time.Time) (bool, error) {
+	recovered, err := recoverDeliveryScript.Run(ctx, b.client, []string{webhookRetriesKey, "webhook_deliveries"}, deliveryID, at.UnixMilli()).Int()
+	if err != nil {
+		return false, err
+	}
+	return recovered == 1, nil
+}
+
+// webhookCircuitKey builds the Redis key holding when the circuit breaker of a webhook URL
+// lets a delivery through again
+func webhookCircuitKey(url string) string {
+	return "webhook_circuit:" + url
+}
+
+// SetWebhookCircuitOpen shares that the circuit breaker of a webhook URL opened until a time,
+// so the state can be reported outside the webhook workers
+func (b *MessageBroker) SetWebhookCircuitOpen(ctx context.Context, url string, until time.Time) error {
+	return b.client.Set(ctx, webhookCircuitKey(url), until.UnixMilli(), 24*time.Hour).Err()
+}
+
+// ClearWebhookCircuit shares that the circuit breaker of a webhook URL closed
+func (b *MessageBroker) ClearWebhookCircuit(ctx context.Context, url string) error {
+	return b.client.Del(ctx, webhookCircuitKey(url)).Err()
+}
+
+// GetWebhookCircuit returns until when the circuit breaker of a webhook URL was opened,
+// or nil when it is closed
+func (b *MessageBroker) GetWebhookCircuit(ctx context.Context, url string) (*time.Time, error) {
+	until, err := b.client.Get(ctx, webhookCircuitKey(url)).Int64()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := time.UnixMilli(until)
+	return &t, nil
+}
+
 // GetPendingWebhookDeliveryCount returns the number of pending webhook deliveries
 func (b *MessageBroker) GetPendingWebhookDeliveryCount(ctx context.Context) (int64, error) {
 	return b.client.LLen(ctx, "webhook_deliveries").Result()
@@ -181,3 +273,64 @@ func (b *MessageBroker) PublishWebhookDeliveryResult(ctx context.Context, delive
 
 	return b.client.Publish(ctx, "webhook_delivery_results", data).Err()
 }
+
+// PermissionChangeEvent announces that a chat permission was changed or revoked
+type PermissionChangeEvent struct {
+	ChatID    uint      `json:"chat_id"`
+	APIKeyID  *uint     `json:"api_key_id,omitempty"`
+	UserID    *uint     `json:"user_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PublishPermissionChange notifies subscribers that a chat permission changed
+func (b *MessageBroker) PublishPermissionChange(ctx context.Context, event *PermissionChangeEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	return b.client.Publish(ctx, "chat_permissions:changed", data).Err()
+}
+
+// SubscribeToPermissionChanges subscribes to chat permission change notifications
+func (b *MessageBroker) SubscribeToPermissionChanges(ctx context.Context) (<-chan *PermissionChangeEvent, error) {
+	pubsub := b.client.Subscribe(ctx, "chat_permissions:changed")
+
+	// Wait for confirmation that subscription is created
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	eventChan := make(chan *PermissionChangeEvent, 100)
+
+	go func() {
+		defer close(eventChan)
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				var event PermissionChangeEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					log.Printf("Failed to unmarshal permission change: %v", err)
+					continue
+				}
+
+				select {
+				case eventChan <- &event:
+				default:
+					log.Printf("Permission change channel full, dropping event for chat %d", event.ChatID)
+				}
+			}
+		}
+	}()
+
+	return eventChan, nil
+}
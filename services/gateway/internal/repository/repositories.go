@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -19,6 +20,7 @@ type UserRepository interface {
 	Update(ctx context.Context, user *domain.User) error
 	Delete(ctx context.Context, id uint) error
 	WithRoles(ctx context.Context, userID uint) (*domain.User, error)
+	UpdateQuota(ctx context.Context, id uint, rateLimit, sendRateLimit int) error
 }
 
 type userRepository struct {
@@ -71,6 +73,12 @@ func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
 	return r.db.WithContext(ctx).Save(user).Error
 }
 
+// UpdateQuota sets the hourly read and send quotas of a user; 0 uses the configured default
+func (r *userRepository) UpdateQuota(ctx context.Context, id uint, rateLimit, sendRateLimit int) error {
+	return r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"rate_limit": rateLimit, "send_rate_limit": sendRateLimit}).Error
+}
+
 func (r *userRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(&domain.User{}, id).Error
 }
@@ -84,6 +92,118 @@ func (r *userRepository) WithRoles(ctx context.Context, userID uint) (*domain.Us
 	return &user, nil
 }
 
+// RoleRepository defines operations for roles, their permissions and user assignments
+type RoleRepository interface {
+	Create(ctx context.Context, role *domain.Role) error
+	GetByName(ctx context.Context, name string) (*domain.Role, error)
+	List(ctx context.Context) ([]domain.Role, error)
+	Delete(ctx context.Context, id uint) error
+	ListPermissions(ctx context.Context) ([]domain.Permission, error)
+	GetPermissionByName(ctx context.Context, name string) (*domain.Permission, error)
+	AddPermission(ctx context.Context, role *domain.Role, permission *domain.Permission) error
+	RemovePermission(ctx context.Context, role *domain.Role, permission *domain.Permission) error
+	AssignToUser(ctx context.Context, userID uint, role *domain.Role) error
+	RemoveFromUser(ctx context.Context, userID uint, role *domain.Role) error
+	ListUserIDs(ctx context.Context, roleID uint) ([]uint, error)
+	ListUserPermissions(ctx context.Context, userID uint) ([]string, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new role repository
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) Create(ctx context.Context, role *domain.Role) error {
+	return r.db.WithContext(ctx).Create(role).Error
+}
+
+func (r *roleRepository) GetByName(ctx context.Context, name string) (*domain.Role, error) {
+	var role domain.Role
+	err := r.db.WithContext(ctx).Preload("Permissions").Where("name = ?", name).First(&role).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) List(ctx context.Context) ([]domain.Role, error) {
+	var roles []domain.Role
+	err := r.db.WithContext(ctx).Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&domain.Role{}, id).Error
+}
+
+func (r *roleRepository) ListPermissions(ctx context.Context) ([]domain.Permission, error) {
+	var permissions []domain.Permission
+	err := r.db.WithContext(ctx).Order("resource, action").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *roleRepository) GetPermissionByName(ctx context.Context, name string) (*domain.Permission, error) {
+	var permission domain.Permission
+	err := r.db.WithContext(ctx).Where("name = ?", name).First(&permission).Error
+	if err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func (r *roleRepository) AddPermission(ctx context.Context, role *domain.Role, permission *domain.Permission) error {
+	return r.db.WithContext(ctx).Model(role).Association("Permissions").Append(permission)
+}
+
+func (r *roleRepository) RemovePermission(ctx context.Context, role *domain.Role, permission *domain.Permission) error {
+	return r.db.WithContext(ctx).Model(role).Association("Permissions").Delete(permission)
+}
+
+func (r *roleRepository) AssignToUser(ctx context.Context, userID uint, role *domain.Role) error {
+	return r.db.WithContext(ctx).Model(&domain.User{ID: userID}).Association("Roles").Append(role)
+}
+
+func (r *roleRepository) RemoveFromUser(ctx context.Context, userID uint, role *domain.Role) error {
+	return r.db.WithContext(ctx).Model(&domain.User{ID: userID}).Association("Roles").Delete(role)
+}
+
+// ListUserIDs returns the IDs of the users holding a role
+func (r *roleRepository) ListUserIDs(ctx context.Context, roleID uint) ([]uint, error) {
+	var userIDs []uint
+	err := r.db.WithContext(ctx).
+		Table("user_roles").
+		Where("role_id = ?", roleID).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// ListUserPermissions returns the names of all permissions granted to a user through their roles
+func (r *roleRepository) ListUserPermissions(ctx context.Context, userID uint) ([]string, error) {
+	var names []string
+	err := r.db.WithContext(ctx).
+		Table("permissions").
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Pluck("permissions.name", &names).Error
+	return names, err
+}
+
+// BotFilter selects bots in BotRepository.ListFiltered. Zero values do not filter.
+type BotFilter struct {
+	ID              *uint // Only this bot
+	VisibleToAPIKey *uint // Only bots of chats the API key has permissions on, limited to its bot grants
+	AllBots         bool  // With VisibleToAPIKey: the key may use every bot, so its bot grants do not apply
+	VisibleToUser   *uint // Only bots of chats the user holds can_read on
+	IsActive        *bool
+	Search          string // Matches username or display name
+}
+
 // BotRepository defines operations for bot management
 type BotRepository interface {
 	Create(ctx context.Context, bot *domain.Bot) error
@@ -92,7 +212,12 @@ type BotRepository interface {
 	GetByToken(ctx context.Context, token string) (*domain.Bot, error)
 	GetByWebhookSecret(ctx context.Context, secret string) (*domain.Bot, error)
 	List(ctx context.Context, offset, limit int) ([]domain.Bot, error)
+	ListFiltered(ctx context.Context, filter BotFilter, offset, limit int) ([]domain.Bot, int64, error)
+	ListActiveByIngestionMode(ctx context.Context, mode string) ([]domain.Bot, error)
+	Count(ctx context.Context) (int64, error)
 	Update(ctx context.Context, bot *domain.Bot) error
+	UpdateLastUpdateID(ctx context.Context, id uint, updateID int64) error
+	UpdateToken(ctx context.Context, id uint, token string) error
 	Delete(ctx context.Context, id uint) error
 }
 
@@ -151,14 +276,91 @@ func (r *botRepository) List(ctx context.Context, offset, limit int) ([]domain.B
 	return bots, err
 }
 
+// ListFiltered returns a page of matching bots ordered by ID, with the total number of matches
+func (r *botRepository) ListFiltered(ctx context.Context, filter BotFilter, offset, limit int) ([]domain.Bot, int64, error) {
+	query := r.db.WithContext(ctx).Model(&domain.Bot{})
+
+	if filter.ID != nil {
+		query = query.Where("bots.id = ?", *filter.ID)
+	}
+	if filter.VisibleToAPIKey != nil {
+		apiKeyID := *filter.VisibleToAPIKey
+		query = query.
+			Where("bots.id IN (?)", r.db.Table("chats").
+				Select("chats.bot_id").
+				Joins("JOIN chat_permissions ON chat_permissions.chat_id = chats.id").
+				Where("chat_permissions.api_key_id = ?", apiKeyID).
+				Where("(chat_permissions.can_read OR chat_permissions.can_send OR chat_permissions.can_manage)"))
+		if !filter.AllBots {
+			query = query.Where("bots.id IN (?)",
+				r.db.Table("api_key_bot_permissions").Select("bot_id").Where("api_key_id = ? AND can_send = ?", apiKeyID, true))
+		}
+	}
+	if filter.VisibleToUser != nil {
+		query = query.Where("bots.id IN (?)", r.db.Table("chats").
+			Select("chats.bot_id").
+			Joins("JOIN chat_permissions ON chat_permissions.chat_id = chats.id").
+			Where("chat_permissions.user_id = ? AND chat_permissions.can_read = ?", *filter.VisibleToUser, true))
+	}
+	if filter.IsActive != nil {
+		query = query.Where("bots.is_active = ?", *filter.IsActive)
+	}
+	if filter.Search != "" {
+		pattern := likePattern(filter.Search)
+		query = query.Where("(bots.username LIKE ? OR bots.display_name LIKE ?)", pattern, pattern)
+	}
+
+	// Count and page from the same conditions
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var bots []domain.Bot
+	err := query.Order("bots.id").Offset(offset).Limit(limit).Find(&bots).Error
+	return bots, total, err
+}
+
+func (r *botRepository) ListActiveByIngestionMode(ctx context.Context, mode string) ([]domain.Bot, error) {
+	var bots []domain.Bot
+	err := r.db.WithContext(ctx).Where("ingestion_mode = ? AND is_active = ?", mode, true).Find(&bots).Error
+	return bots, err
+}
+
+func (r *botRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.Bot{}).Count(&count).Error
+	return count, err
+}
+
 func (r *botRepository) Update(ctx context.Context, bot *domain.Bot) error {
 	return r.db.WithContext(ctx).Save(bot).Error
 }
 
+func (r *botRepository) UpdateLastUpdateID(ctx context.Context, id uint, updateID int64) error {
+	return r.db.WithContext(ctx).Model(&domain.Bot{}).Where("id = ?", id).Update("last_update_id", updateID).Error
+}
+
+func (r *botRepository) UpdateToken(ctx context.Context, id uint, token string) error {
+	return r.db.WithContext(ctx).Model(&domain.Bot{}).Where("id = ?", id).Update("token", token).Error
+}
+
 func (r *botRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(&domain.Bot{}, id).Error
 }
 
+// ChatFilter selects chats in ChatRepository.ListFiltered. Zero values do not filter.
+type ChatFilter struct {
+	ReadableByAPIKey *uint // Only chats the API key holds can_read on
+	ReadableByUser   *uint // Only chats the user holds can_read on
+	BotID            *uint
+	Type             string // "private", "group", "supergroup" or "channel"
+	IsActive         *bool
+	Search           string // Matches title, username, first or last name
+}
+
 // ChatRepository defines operations for chat management
 type ChatRepository interface {
 	Create(ctx context.Context, chat *domain.Chat) error
@@ -167,6 +369,8 @@ type ChatRepository interface {
 	GetByTelegramID(ctx context.Context, telegramID int64) (*domain.Chat, error)
 	List(ctx context.Context, offset, limit int) ([]domain.Chat, error)
 	ListByBot(ctx context.Context, botID uint, offset, limit int) ([]domain.Chat, error)
+	ListFiltered(ctx context.Context, filter ChatFilter, offset, limit int) ([]domain.Chat, int64, error)
+	Count(ctx context.Context) (int64, error)
 	Update(ctx context.Context, chat *domain.Chat) error
 	Delete(ctx context.Context, id uint) error
 }
@@ -230,6 +434,54 @@ func (r *chatRepository) ListByBot(ctx context.Context, botID uint, offset, limi
 	return chats, err
 }
 
+// ListFiltered returns a page of matching chats ordered by ID, with the total number of matches
+func (r *chatRepository) ListFiltered(ctx context.Context, filter ChatFilter, offset, limit int) ([]domain.Chat, int64, error) {
+	query := r.db.WithContext(ctx).Model(&domain.Chat{})
+
+	if filter.ReadableByAPIKey != nil {
+		query = query.Where("chats.id IN (?)", r.db.Table("chat_permissions").
+			Select("chat_id").
+			Where("api_key_id = ? AND can_read = ?", *filter.ReadableByAPIKey, true))
+	}
+	if filter.ReadableByUser != nil {
+		query = query.Where("chats.id IN (?)", r.db.Table("chat_permissions").
+			Select("chat_id").
+			Where("user_id = ? AND can_read = ?", *filter.ReadableByUser, true))
+	}
+	if filter.BotID != nil {
+		query = query.Where("chats.bot_id = ?", *filter.BotID)
+	}
+	if filter.Type != "" {
+		query = query.Where("chats.type = ?", filter.Type)
+	}
+	if filter.IsActive != nil {
+		query = query.Where("chats.is_active = ?", *filter.IsActive)
+	}
+	if filter.Search != "" {
+		pattern := likePattern(filter.Search)
+		query = query.Where("(chats.title LIKE ? OR chats.username LIKE ? OR chats.first_name LIKE ? OR chats.last_name LIKE ?)",
+			pattern, pattern, pattern, pattern)
+	}
+
+	// Count and page from the same conditions
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var chats []domain.Chat
+	err := query.Order("chats.id").Offset(offset).Limit(limit).Find(&chats).Error
+	return chats, total, err
+}
+
+func (r *chatRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.Chat{}).Count(&count).Error
+	return count, err
+}
+
 func (r *chatRepository) Update(ctx context.Context, chat *domain.Chat) error {
 	return r.db.WithContext(ctx).Save(chat).Error
 }
@@ -242,8 +494,12 @@ func (r *chatRepository) Delete(ctx context.Context, id uint) error {
 type MessageRepository interface {
 	Create(ctx context.Context, message *domain.Message) error
 	GetByID(ctx context.Context, id uint) (*domain.Message, error)
+	GetByChatAndTelegramID(ctx context.Context, chatID uint, telegramID int64) (*domain.Message, error)
 	ListByChat(ctx context.Context, chatID uint, cursor *time.Time, limit int) ([]domain.Message, error)
 	ListByReplyTo(ctx context.Context, replyToMessageID int64, offset, limit int) ([]domain.Message, error)
+	Update(ctx context.Context, message *domain.Message) error
+	RecordEdit(ctx context.Context, message *domain.Message, edit *domain.MessageEdit) error
+	ListEdits(ctx context.Context, messageID uint) ([]domain.MessageEdit, error)
 	Delete(ctx context.Context, id uint) error
 	DeleteOlderThan(ctx context.Context, cutoff time.Time) error
 }
@@ -270,6 +526,19 @@ func (r *messageRepository) GetByID(ctx context.Context, id uint) (*domain.Messa
 	return &message, nil
 }
 
+// GetByChatAndTelegramID returns the latest stored message with a Telegram message ID in a chat
+func (r *messageRepository) GetByChatAndTelegramID(ctx context.Context, chatID uint, telegramID int64) (*domain.Message, error) {
+	var message domain.Message
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND telegram_id = ?", chatID, telegramID).
+		Order("id DESC").
+		First(&message).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
 // ListByChat returns messages with cursor-based pagination
 func (r *messageRepository) ListByChat(ctx context.Context, chatID uint, cursor *time.Time, limit int) ([]domain.Message, error) {
 	var messages []domain.Message
@@ -293,6 +562,26 @@ func (r *messageRepository) ListByReplyTo(ctx context.Context, replyToMessageID
 	return messages, err
 }
 
+func (r *messageRepository) Update(ctx context.Context, message *domain.Message) error {
+	return r.db.WithContext(ctx).Omit("Chat").Save(message).Error
+}
+
+// RecordEdit saves the previous text and the updated message in one transaction
+func (r *messageRepository) RecordEdit(ctx context.Context, message *domain.Message, edit *domain.MessageEdit) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(edit).Error; err != nil {
+			return err
+		}
+		return tx.Omit("Chat").Save(message).Error
+	})
+}
+
+func (r *messageRepository) ListEdits(ctx context.Context, messageID uint) ([]domain.MessageEdit, error) {
+	var edits []domain.MessageEdit
+	err := r.db.WithContext(ctx).Where("message_id = ?", messageID).Order("edited_at ASC").Find(&edits).Error
+	return edits, err
+}
+
 func (r *messageRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(&domain.Message{}, id).Error
 }
@@ -301,6 +590,284 @@ func (r *messageRepository) DeleteOlderThan(ctx context.Context, cutoff time.Tim
 	return r.db.WithContext(ctx).Where("created_at < ?", cutoff).Delete(&domain.Message{}).Error
 }
 
+// CallbackQueryRepository defines operations for inline keyboard callback queries
+type CallbackQueryRepository interface {
+	Create(ctx context.Context, query *domain.CallbackQuery) error
+	GetByID(ctx context.Context, id uint) (*domain.CallbackQuery, error)
+	GetByQueryID(ctx context.Context, queryID string) (*domain.CallbackQuery, error)
+	Update(ctx context.Context, query *domain.CallbackQuery) error
+}
+
+type callbackQueryRepository struct {
+	db *gorm.DB
+}
+
+// NewCallbackQueryRepository creates a new callback query repository
+func NewCallbackQueryRepository(db *gorm.DB) CallbackQueryRepository {
+	return &callbackQueryRepository{db: db}
+}
+
+func (r *callbackQueryRepository) Create(ctx context.Context, query *domain.CallbackQuery) error {
+	return r.db.WithContext(ctx).Create(query).Error
+}
+
+func (r *callbackQueryRepository) GetByID(ctx context.Context, id uint) (*domain.CallbackQuery, error) {
+	var query domain.CallbackQuery
+	err := r.db.WithContext(ctx).First(&query, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &query, nil
+}
+
+func (r *callbackQueryRepository) GetByQueryID(ctx context.Context, queryID string) (*domain.CallbackQuery, error) {
+	var query domain.CallbackQuery
+	err := r.db.WithContext(ctx).Where("query_id = ?", queryID).First(&query).Error
+	if err != nil {
+		return nil, err
+	}
+	return &query, nil
+}
+
+func (r *callbackQueryRepository) Update(ctx context.Context, query *domain.CallbackQuery) error {
+	return r.db.WithContext(ctx).Omit("Bot", "Chat", "Message").Save(query).Error
+}
+
+// UpdateEventRepository defines operations for non-message update events
+type UpdateEventRepository interface {
+	Create(ctx context.Context, event *domain.UpdateEvent) error
+	GetByID(ctx context.Context, id uint) (*domain.UpdateEvent, error)
+	ListByChat(ctx context.Context, chatID uint, eventType string, cursor *time.Time, limit int) ([]domain.UpdateEvent, error)
+}
+
+type updateEventRepository struct {
+	db *gorm.DB
+}
+
+// NewUpdateEventRepository creates a new update event repository
+func NewUpdateEventRepository(db *gorm.DB) UpdateEventRepository {
+	return &updateEventRepository{db: db}
+}
+
+func (r *updateEventRepository) Create(ctx context.Context, event *domain.UpdateEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r *updateEventRepository) GetByID(ctx context.Context, id uint) (*domain.UpdateEvent, error) {
+	var event domain.UpdateEvent
+	err := r.db.WithContext(ctx).First(&event, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// ListByChat returns a chat's update events with cursor-based pagination, optionally of one type
+func (r *updateEventRepository) ListByChat(ctx context.Context, chatID uint, eventType string, cursor *time.Time, limit int) ([]domain.UpdateEvent, error) {
+	var events []domain.UpdateEvent
+	query := r.db.WithContext(ctx).Where("chat_id = ?", chatID).Order("occurred_at DESC")
+
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+	if cursor != nil {
+		query = query.Where("occurred_at < ?", cursor)
+	}
+
+	err := query.Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// MediaFileRepository defines operations for files attached to messages
+type MediaFileRepository interface {
+	Create(ctx context.Context, file *domain.MediaFile) error
+	GetByMessageID(ctx context.Context, messageID uint) (*domain.MediaFile, error)
+	ListPending(ctx context.Context, limit int) ([]domain.MediaFile, error)
+	Update(ctx context.Context, file *domain.MediaFile) error
+}
+
+type mediaFileRepository struct {
+	db *gorm.DB
+}
+
+// NewMediaFileRepository creates a new media file repository
+func NewMediaFileRepository(db *gorm.DB) MediaFileRepository {
+	return &mediaFileRepository{db: db}
+}
+
+func (r *mediaFileRepository) Create(ctx context.Context, file *domain.MediaFile) error {
+	return r.db.WithContext(ctx).Create(file).Error
+}
+
+func (r *mediaFileRepository) GetByMessageID(ctx context.Context, messageID uint) (*domain.MediaFile, error) {
+	var file domain.MediaFile
+	err := r.db.WithContext(ctx).Where("message_id = ?", messageID).First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// ListPending returns files that have not been downloaded yet, oldest first
+func (r *mediaFileRepository) ListPending(ctx context.Context, limit int) ([]domain.MediaFile, error) {
+	var files []domain.MediaFile
+	err := r.db.WithContext(ctx).Where("status = ?", domain.MediaStatusPending).Order("id ASC").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+func (r *mediaFileRepository) Update(ctx context.Context, file *domain.MediaFile) error {
+	return r.db.WithContext(ctx).Omit("Message", "Bot").Save(file).Error
+}
+
+// OutboundFilter selects sends in OutboundMessageRepository.ListFiltered. Zero values do not filter.
+type OutboundFilter struct {
+	SentByAPIKeyID *uint
+	SentByUserID   *uint
+	ChatID         *uint
+	Status         string
+	Scheduled      bool // Only scheduled sends, whatever their status
+}
+
+// OutboundMessageRepository defines operations for queued outbound sends
+type OutboundMessageRepository interface {
+	Create(ctx context.Context, msg *domain.OutboundMessage) error
+	GetByID(ctx context.Context, id uint) (*domain.OutboundMessage, error)
+	ListFiltered(ctx context.Context, filter OutboundFilter, offset, limit int) ([]domain.OutboundMessage, int64, error)
+	ClaimDue(ctx context.Context, now time.Time, limit int) ([]domain.OutboundMessage, error)
+	RequeueStale(ctx context.Context, claimedBefore time.Time) (int64, error)
+	ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]domain.OutboundMessage, error)
+	Transition(ctx context.Context, id uint, from, to, lastError string) (bool, error)
+	Reschedule(ctx context.Context, id uint, sendAt time.Time) (bool, error)
+	Update(ctx context.Context, msg *domain.OutboundMessage) error
+}
+
+type outboundMessageRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboundMessageRepository creates a new outbound message repository
+func NewOutboundMessageRepository(db *gorm.DB) OutboundMessageRepository {
+	return &outboundMessageRepository{db: db}
+}
+
+func (r *outboundMessageRepository) Create(ctx context.Context, msg *domain.OutboundMessage) error {
+	return r.db.WithContext(ctx).Omit("Chat", "SourceChat").Create(msg).Error
+}
+
+func (r *outboundMessageRepository) GetByID(ctx context.Context, id uint) (*domain.OutboundMessage, error) {
+	var msg domain.OutboundMessage
+	err := r.db.WithContext(ctx).First(&msg, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (r *outboundMessageRepository) ListFiltered(ctx context.Context, filter OutboundFilter, offset, limit int) ([]domain.OutboundMessage, int64, error) {
+	query := r.db.WithContext(ctx).Model(&domain.OutboundMessage{})
+
+	if filter.SentByAPIKeyID != nil {
+		query = query.Where("sent_by_api_key_id = ?", *filter.SentByAPIKeyID)
+	}
+	if filter.SentByUserID != nil {
+		query = query.Where("sent_by_user_id = ?", *filter.SentByUserID)
+	}
+	if filter.ChatID != nil {
+		query = query.Where("chat_id = ?", *filter.ChatID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Scheduled {
+		query = query.Where("scheduled_at IS NOT NULL")
+	}
+
+	// Count and page from the same conditions
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var msgs []domain.OutboundMessage
+	err := query.Order("next_attempt_at, id").Offset(offset).Limit(limit).Find(&msgs).Error
+	return msgs, total, err
+}
+
+// ClaimDue marks due sends as sending and returns them with their chats, oldest first.
+// Only the oldest unsent message of each chat is due, so a chat receives its messages in order.
+// A send claimed by another worker in the meantime is skipped.
+func (r *outboundMessageRepository) ClaimDue(ctx context.Context, now time.Time, limit int) ([]domain.OutboundMessage, error) {
+	var candidates []domain.OutboundMessage
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", domain.OutboundStatusQueued, now).
+		Where("NOT EXISTS (SELECT 1 FROM outbound_messages earlier WHERE earlier.chat_id = outbound_messages.chat_id AND earlier.id < outbound_messages.id AND earlier.status IN ?)",
+			[]string{domain.OutboundStatusQueued, domain.OutboundStatusSending}).
+		Order("id ASC").Limit(limit).
+		Preload("Chat").Preload("SourceChat").
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := candidates[:0]
+	for _, msg := range candidates {
+		result := r.db.WithContext(ctx).Model(&domain.OutboundMessage{}).
+			Where("id = ? AND status = ?", msg.ID, domain.OutboundStatusQueued).
+			Update("status", domain.OutboundStatusSending)
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 1 {
+			msg.Status = domain.OutboundStatusSending
+			claimed = append(claimed, msg)
+		}
+	}
+	return claimed, nil
+}
+
+// RequeueStale puts sends back in the queue that were claimed before a time but never
+// finished, e.g. because the gateway stopped while sending them
+func (r *outboundMessageRepository) RequeueStale(ctx context.Context, claimedBefore time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&domain.OutboundMessage{}).
+		Where("status = ? AND updated_at < ?", domain.OutboundStatusSending, claimedBefore).
+		Update("status", domain.OutboundStatusQueued)
+	return result.RowsAffected, result.Error
+}
+
+// ListDueScheduled returns scheduled sends whose send time has come, earliest first
+func (r *outboundMessageRepository) ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]domain.OutboundMessage, error) {
+	var msgs []domain.OutboundMessage
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", domain.OutboundStatusScheduled, now).
+		Order("next_attempt_at, id").Limit(limit).
+		Preload("Chat").
+		Find(&msgs).Error
+	return msgs, err
+}
+
+// Transition moves a send from one status to another and reports whether it was still
+// in the from status, so concurrent cancels and releases cannot both win
+func (r *outboundMessageRepository) Transition(ctx context.Context, id uint, from, to, lastError string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.OutboundMessage{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{"status": to, "last_error": lastError})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Reschedule moves the send time of a send that is still scheduled
+func (r *outboundMessageRepository) Reschedule(ctx context.Context, id uint, sendAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.OutboundMessage{}).
+		Where("id = ? AND status = ?", id, domain.OutboundStatusScheduled).
+		Updates(map[string]interface{}{"scheduled_at": sendAt, "next_attempt_at": sendAt})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *outboundMessageRepository) Update(ctx context.Context, msg *domain.OutboundMessage) error {
+	return r.db.WithContext(ctx).Omit("Chat", "SourceChat").Save(msg).Error
+}
+
 // ChatPermissionRepository defines operations for chat permissions
 type ChatPermissionRepository interface {
 	Create(ctx context.Context, permission *domain.ChatPermission) error
@@ -403,6 +970,7 @@ type APIKeyRepository interface {
 	Update(ctx context.Context, apiKey *domain.APIKey) error
 	Delete(ctx context.Context, id uint) error
 	UpdateLastUsed(ctx context.Context, id uint) error
+	UpdateHash(ctx context.Context, id uint, key, hashedKey string) error
 }
 
 type apiKeyRepository struct {
@@ -455,13 +1023,24 @@ func (r *apiKeyRepository) UpdateLastUsed(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Model(&domain.APIKey{}).Where("id = ?", id).Update("last_used_at", now).Error
 }
 
+// UpdateHash replaces the stored lookup key and hash of an API key
+func (r *apiKeyRepository) UpdateHash(ctx context.Context, id uint, key, hashedKey string) error {
+	return r.db.WithContext(ctx).Model(&domain.APIKey{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"key": key, "hashed_key": hashedKey}).Error
+}
+
 // WebhookRepository defines operations for webhook management
 type WebhookRepository interface {
 	Create(ctx context.Context, webhook *domain.Webhook) error
 	GetByID(ctx context.Context, id uint) (*domain.Webhook, error)
 	ListByChat(ctx context.Context, chatID uint) ([]domain.Webhook, error)
 	ListActive(ctx context.Context) ([]domain.Webhook, error)
+	ListActiveForChat(ctx context.Context, chatID uint) ([]domain.Webhook, error)
+	ListActiveGlobal(ctx context.Context) ([]domain.Webhook, error)
+	ListActiveFeedback(ctx context.Context) ([]domain.Webhook, error)
+	GetFeedbackByAPIKey(ctx context.Context, apiKeyID uint) (*domain.Webhook, error)
 	Update(ctx context.Context, webhook *domain.Webhook) error
+	RecordFailure(ctx context.Context, id uint, at time.Time, message string) error
 	Delete(ctx context.Context, id uint) error
 }
 
@@ -501,19 +1080,88 @@ func (r *webhookRepository) ListActive(ctx context.Context) ([]domain.Webhook, e
 	return webhooks, err
 }
 
+// ListActiveForChat returns active webhooks bound to the chat plus global webhooks (NULL chat_id)
+func (r *webhookRepository) ListActiveForChat(ctx context.Context, chatID uint) ([]domain.Webhook, error) {
+	var webhooks []domain.Webhook
+	err := r.db.WithContext(ctx).
+		Where("is_active = ? AND (chat_id = ? OR chat_id IS NULL)", true, chatID).
+		Find(&webhooks).Error
+	return webhooks, err
+}
+
+// ListActiveGlobal returns active webhooks that are not bound to a chat
+func (r *webhookRepository) ListActiveGlobal(ctx context.Context) ([]domain.Webhook, error) {
+	var webhooks []domain.Webhook
+	err := r.db.WithContext(ctx).
+		Where("is_active = ? AND chat_id IS NULL", true).
+		Find(&webhooks).Error
+	return webhooks, err
+}
+
+// ListActiveFeedback returns the active feedback webhooks of all API keys
+func (r *webhookRepository) ListActiveFeedback(ctx context.Context) ([]domain.Webhook, error) {
+	var webhooks []domain.Webhook
+	err := r.db.WithContext(ctx).
+		Where("is_active = ? AND scope = ? AND api_key_id IS NOT NULL", true, "feedback").
+		Find(&webhooks).Error
+	return webhooks, err
+}
+
+// GetFeedbackByAPIKey returns the feedback webhook registered by an API key
+func (r *webhookRepository) GetFeedbackByAPIKey(ctx context.Context, apiKeyID uint) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	err := r.db.WithContext(ctx).
+		Where("api_key_id = ? AND scope = ?", apiKeyID, "feedback").
+		First(&webhook).Error
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
 func (r *webhookRepository) Update(ctx context.Context, webhook *domain.Webhook) error {
 	return r.db.WithContext(ctx).Save(webhook).Error
 }
 
+// RecordFailure stores the last failed delivery attempt of a webhook without touching its settings
+func (r *webhookRepository) RecordFailure(ctx context.Context, id uint, at time.Time, message string) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.Webhook{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"last_failure_at": at,
+			"last_failure":    message,
+		}).Error
+}
+
 func (r *webhookRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(&domain.Webhook{}, id).Error
 }
 
+// WebhookDeliveryFilter selects deliveries in WebhookDeliveryRepository.ListFiltered. Zero values do not filter.
+type WebhookDeliveryFilter struct {
+	WebhookID   *uint
+	Status      string
+	EventType   string
+	CreatedFrom *time.Time // Deliveries created at or after this time
+	CreatedTo   *time.Time // Deliveries created before this time
+	FailedFrom  *time.Time // Deliveries that failed at or after this time
+	FailedTo    *time.Time // Deliveries that failed before this time
+
+	ExcludeFeedback bool // Leave out the deliveries of feedback webhooks
+}
+
 // WebhookDeliveryRepository defines operations for webhook delivery tracking
 type WebhookDeliveryRepository interface {
 	Create(ctx context.Context, delivery *domain.WebhookDelivery) error
 	GetByID(ctx context.Context, id uint) (*domain.WebhookDelivery, error)
 	GetPendingRetries(ctx context.Context, limit int) ([]domain.WebhookDelivery, error)
+	ListPending(ctx context.Context, updatedBefore time.Time, afterID uint, limit int) ([]domain.WebhookDelivery, error)
+	Touch(ctx context.Context, id uint) error
+	ListFiltered(ctx context.Context, filter WebhookDeliveryFilter, offset, limit int) ([]domain.WebhookDelivery, int64, error)
+	Replay(ctx context.Context, id uint) (bool, error)
+	CountByStatus(ctx context.Context, webhookID uint, since time.Time) (map[string]int64, error)
+	ListLatencies(ctx context.Context, webhookID uint, since time.Time, limit int) ([]int64, error)
 	Update(ctx context.Context, delivery *domain.WebhookDelivery) error
 	DeleteOlderThan(ctx context.Context, cutoff time.Time) error
 }
@@ -533,7 +1181,7 @@ func (r *webhookDeliveryRepository) Create(ctx context.Context, delivery *domain
 
 func (r *webhookDeliveryRepository) GetByID(ctx context.Context, id uint) (*domain.WebhookDelivery, error) {
 	var delivery domain.WebhookDelivery
-	err := r.db.WithContext(ctx).Preload("Webhook").Preload("Message").First(&delivery, id).Error
+	err := r.db.WithContext(ctx).Preload("Webhook").Preload("Message").Preload("CallbackQuery").Preload("UpdateEvent").First(&delivery, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -553,10 +1201,123 @@ func (r *webhookDeliveryRepository) GetPendingRetries(ctx context.Context, limit
 	return deliveries, err
 }
 
+// ListPending lists pending deliveries last updated before updatedBefore with an ID above
+// afterID in ID order, for paging through all of them
+func (r *webhookDeliveryRepository) ListPending(ctx context.Context, updatedBefore time.Time, afterID uint, limit int) ([]domain.WebhookDelivery, error) {
+	var deliveries []domain.WebhookDelivery
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND updated_at < ? AND id > ?", "pending", updatedBefore, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ListFiltered lists deliveries with their webhooks, newest first
+func (r *webhookDeliveryRepository) ListFiltered(ctx context.Context, filter WebhookDeliveryFilter, offset, limit int) ([]domain.WebhookDelivery, int64, error) {
+	query := r.db.WithContext(ctx).Model(&domain.WebhookDelivery{})
+
+	if filter.WebhookID != nil {
+		query = query.Where("webhook_id = ?", *filter.WebhookID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	if filter.FailedFrom != nil {
+		query = query.Where("failed_at >= ?", *filter.FailedFrom)
+	}
+	if filter.FailedTo != nil {
+		query = query.Where("failed_at < ?", *filter.FailedTo)
+	}
+	if filter.ExcludeFeedback {
+		query = query.Where("webhook_id NOT IN (?)", r.db.Model(&domain.Webhook{}).Select("id").Where("scope = ?", "feedback"))
+	}
+
+	// Count and page from the same conditions
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var deliveries []domain.WebhookDelivery
+	err := query.Preload("Webhook").Order("id DESC").Offset(offset).Limit(limit).Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+// Replay makes a failed delivery pending again with a fresh set of retries.
+// It reports false when the delivery is not failed, such as when it was replayed already.
+func (r *webhookDeliveryRepository) Replay(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.WebhookDelivery{}).
+		Where("id = ? AND status = ?", id, "failed").
+		Updates(map[string]interface{}{
+			"status":        "pending",
+			"attempt_count": 0,
+			"next_retry_at": nil,
+			"failed_at":     nil,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountByStatus counts the deliveries of a webhook created since a time by status
+func (r *webhookDeliveryRepository) CountByStatus(ctx context.Context, webhookID uint, since time.Time) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).
+		Model(&domain.WebhookDelivery{}).
+		Select("status, COUNT(*) AS count").
+		Where("webhook_id = ? AND created_at >= ?", webhookID, since).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// ListLatencies lists the last attempt latencies of the most recent deliveries of a webhook
+// created since a time
+func (r *webhookDeliveryRepository) ListLatencies(ctx context.Context, webhookID uint, since time.Time, limit int) ([]int64, error) {
+	var latencies []int64
+	err := r.db.WithContext(ctx).
+		Model(&domain.WebhookDelivery{}).
+		Where("webhook_id = ? AND created_at >= ? AND latency_ms IS NOT NULL", webhookID, since).
+		Order("id DESC").
+		Limit(limit).
+		Pluck("latency_ms", &latencies).Error
+	return latencies, err
+}
+
 func (r *webhookDeliveryRepository) Update(ctx context.Context, delivery *domain.WebhookDelivery) error {
 	return r.db.WithContext(ctx).Save(delivery).Error
 }
 
+// Touch sets the update time of a delivery to now, marking it as being worked on
+func (r *webhookDeliveryRepository) Touch(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.WebhookDelivery{}).
+		Where("id = ?", id).
+		Update("updated_at", time.Now()).Error
+}
+
 func (r *webhookDeliveryRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time) error {
 	return r.db.WithContext(ctx).Where("created_at < ?", cutoff).Delete(&domain.WebhookDelivery{}).Error
 }
@@ -662,22 +1423,9 @@ func (r *apiKeyBotPermissionRepository) Delete(ctx context.Context, apiKeyID, bo
 		Delete(&domain.APIKeyBotPermission{}).Error
 }
 
+// HasBotAccess reports whether an API key is granted a bot with can_send.
+// Keys without grants may not use any bot; APIKey.AllBots is checked by callers.
 func (r *apiKeyBotPermissionRepository) HasBotAccess(ctx context.Context, apiKeyID, botID uint) (bool, error) {
-	// First check if there are ANY bot permissions for this API key
-	var count int64
-	if err := r.db.WithContext(ctx).
-		Model(&domain.APIKeyBotPermission{}).
-		Where("api_key_id = ?", apiKeyID).
-		Count(&count).Error; err != nil {
-		return false, err
-	}
-
-	// If no bot permissions exist, all bots are allowed (default behavior)
-	if count == 0 {
-		return true, nil
-	}
-
-	// If bot permissions exist, check if this specific bot is allowed
 	var perm domain.APIKeyBotPermission
 	err := r.db.WithContext(ctx).
 		Where("api_key_id = ? AND bot_id = ? AND can_send = ?", apiKeyID, botID, true).
@@ -759,3 +1507,9 @@ func (r *apiKeyFeedbackPermissionRepository) CanReceiveFeedback(ctx context.Cont
 
 	return true, nil
 }
+
+// likePattern builds a LIKE pattern matching values that contain s
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
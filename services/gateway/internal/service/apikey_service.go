@@ -59,7 +59,7 @@ func (s *APIKeyService) CreateAPIKey(ctx context.Context, req *CreateAPIKeyReque
 	}
 
 	apiKey := &domain.APIKey{
-		Key:         key,
+		Key:         s.apiKeyPkg.VisiblePrefix(key),
 		HashedKey:   hashedKey,
 		Name:        req.Name,
 		Description: req.Description,
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// InputFile is a file uploaded to the Bot API as part of a multipart request
+type InputFile struct {
+	Field    string    // Multipart field name, e.g. "photo" or an attach:// name
+	FileName string    // Original file name
+	Reader   io.Reader // File contents
+}
+
+// APIError is an error response returned by the Bot API
+type APIError struct {
+	Code        int
+	Description string
+	RetryAfter  int // Seconds to wait before retrying, set on 429 responses
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Telegram API error %d: %s", e.Code, e.Description)
+}
+
+// NetworkError is a Bot API request that got no response. NotSent is set when the request
+// cannot have reached Telegram, such as when the connection was refused; otherwise Telegram
+// may have carried out the method, for example when the response timed out.
+type NetworkError struct {
+	Method  string
+	NotSent bool
+	Err     error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("failed to call Telegram API: %v", e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// requestNotSent reports whether a failed HTTP request never reached the server, which is
+// the case when no connection could be made
+func requestNotSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// CallBotAPI calls a Bot API method on behalf of a bot and returns the raw "result" field.
+// Params are sent as JSON, or as multipart/form-data when files are attached.
+func (s *BotService) CallBotAPI(ctx context.Context, botID uint, method string, params map[string]interface{}, files []InputFile) (json.RawMessage, error) {
+	token, err := s.GetBotToken(ctx, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot token: %w", err)
+	}
+
+	var body io.Reader
+	var contentType string
+	if len(files) > 0 {
+		buf, ct, err := encodeMultipart(params, files)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType = buf, ct
+	} else {
+		jsonData, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		body, contentType = bytes.NewBuffer(jsonData), "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.apiURL(token, method), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, &NetworkError{Method: method, NotSent: requestNotSent(err), Err: err}
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Ok          bool            `json:"ok"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("Telegram API returned status %d: failed to decode response: %w", resp.StatusCode, err)
+	}
+
+	if !result.Ok {
+		code := result.ErrorCode
+		if code == 0 {
+			code = resp.StatusCode
+		}
+		return nil, &APIError{Code: code, Description: result.Description, RetryAfter: result.Parameters.RetryAfter}
+	}
+
+	return result.Result, nil
+}
+
+// encodeMultipart builds a multipart/form-data body from Bot API params and files
+func encodeMultipart(params map[string]interface{}, files []InputFile) (*bytes.Buffer, string, error) {
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+
+	for key, value := range params {
+		field, err := formValue(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode %s: %w", key, err)
+		}
+		if err := writer.WriteField(key, field); err != nil {
+			return nil, "", fmt.Errorf("failed to write %s: %w", key, err)
+		}
+	}
+
+	for _, file := range files {
+		part, err := writer.CreateFormFile(file.Field, file.FileName)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create file part: %w", err)
+		}
+		if _, err := io.Copy(part, file.Reader); err != nil {
+			return nil, "", fmt.Errorf("failed to copy file %s: %w", file.FileName, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to finish multipart body: %w", err)
+	}
+
+	return buf, writer.FormDataContentType(), nil
+}
+
+// formValue encodes a Bot API parameter as a form field; objects and arrays are sent as JSON
+func formValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.RawMessage:
+		return string(v), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+// TelegramFile describes a file prepared for download by getFile
+type TelegramFile struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	FilePath     string `json:"file_path,omitempty"`
+}
+
+// GetFile resolves a file_id to a downloadable file path
+func (s *BotService) GetFile(ctx context.Context, botID uint, fileID string) (*TelegramFile, error) {
+	result, err := s.CallBotAPI(ctx, botID, "getFile", map[string]interface{}{"file_id": fileID}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var file TelegramFile
+	if err := json.Unmarshal(result, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode file: %w", err)
+	}
+	if file.FilePath == "" {
+		return nil, fmt.Errorf("Telegram returned no file path for %s", fileID)
+	}
+
+	return &file, nil
+}
+
+// DownloadFile opens a file returned by GetFile. The caller closes the body.
+func (s *BotService) DownloadFile(ctx context.Context, botID uint, filePath string) (io.ReadCloser, error) {
+	token, err := s.GetBotToken(ctx, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/file/bot%s/%s", s.apiBaseURL, token, filePath), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Telegram file download returned status %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
@@ -3,54 +3,59 @@ package service
 import (
 	"bytes"
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
-	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 
 	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/pkg/encryption"
 	"github.com/kexi/telegram-bot-gateway/internal/repository"
 )
 
+// AllowedUpdates lists the update types requested from Telegram. chat_member,
+// message_reaction and message_reaction_count are only sent when asked for explicitly.
+var AllowedUpdates = []string{
+	"message", "edited_message", "channel_post", "edited_channel_post",
+	"callback_query", "inline_query", "chosen_inline_result",
+	"shipping_query", "pre_checkout_query", "poll", "poll_answer",
+	"my_chat_member", "chat_member", "chat_join_request",
+	"message_reaction", "message_reaction_count", "chat_boost", "removed_chat_boost",
+}
+
 // BotService handles bot management operations
 type BotService struct {
 	botRepo        repository.BotRepository
-	encryptionKey  []byte
+	keyring        *encryption.Keyring
 	webhookBaseURL string
+	apiBaseURL     string
 	httpClient     *http.Client
+	pollingClient  *http.Client
 	redisClient    *redis.Client
 }
 
-// NewBotService creates a new bot service
-func NewBotService(botRepo repository.BotRepository, encryptionKey, webhookBaseURL string, redisClient *redis.Client) *BotService {
-	// Ensure key is 32 bytes for AES-256
-	key := []byte(encryptionKey)
-	if len(key) < 32 {
-		// Pad with zeros if too short (in production, use proper key derivation)
-		padded := make([]byte, 32)
-		copy(padded, key)
-		key = padded
-	} else if len(key) > 32 {
-		key = key[:32]
-	}
-
+// NewBotService creates a new bot service. Bot tokens are encrypted with the keyring.
+// apiBaseURL is the Telegram Bot API endpoint, e.g. "https://api.telegram.org".
+func NewBotService(botRepo repository.BotRepository, keyring *encryption.Keyring, webhookBaseURL, apiBaseURL string, redisClient *redis.Client) *BotService {
 	return &BotService{
 		botRepo:        botRepo,
-		encryptionKey:  key,
+		keyring:        keyring,
 		webhookBaseURL: webhookBaseURL,
+		apiBaseURL:     strings.TrimRight(apiBaseURL, "/"),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		redisClient: redisClient,
+		// Long polling requests are bounded by their context instead of a fixed timeout
+		pollingClient: &http.Client{},
+		redisClient:   redisClient,
 	}
 }
 
@@ -60,20 +65,33 @@ type CreateBotRequest struct {
 	Token       string `json:"token" binding:"required"`
 	DisplayName string `json:"display_name"`
 	Description string `json:"description"`
+	Mode        string `json:"mode"` // "webhook" (default) or "polling"
 }
 
 // BotDTO represents bot data transfer object
 type BotDTO struct {
-	ID          uint   `json:"id"`
-	Username    string `json:"username"`
-	DisplayName string `json:"display_name,omitempty"`
-	Description string `json:"description,omitempty"`
-	IsActive    bool   `json:"is_active"`
-	WebhookURL  string `json:"webhook_url,omitempty"`
+	ID            uint   `json:"id"`
+	Username      string `json:"username"`
+	DisplayName   string `json:"display_name,omitempty"`
+	Description   string `json:"description,omitempty"`
+	IsActive      bool   `json:"is_active"`
+	WebhookURL    string `json:"webhook_url,omitempty"`
+	IngestionMode string `json:"ingestion_mode"`
 }
 
 // CreateBot registers a new Telegram bot
 func (s *BotService) CreateBot(ctx context.Context, req *CreateBotRequest) (*BotDTO, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = domain.BotModeWebhook
+	}
+	if err := validateIngestionMode(mode); err != nil {
+		return nil, err
+	}
+	if mode == domain.BotModeWebhook && s.webhookBaseURL == "" {
+		return nil, fmt.Errorf("telegram webhook base URL is not configured; use polling mode instead")
+	}
+
 	// Encrypt the bot token
 	encryptedToken, err := s.encryptToken(req.Token)
 	if err != nil {
@@ -87,8 +105,11 @@ func (s *BotService) CreateBot(ctx context.Context, req *CreateBotRequest) (*Bot
 	}
 	webhookSecret := hex.EncodeToString(secretBytes)
 
-	// Compute webhook URL
-	webhookURL := fmt.Sprintf("%s/api/v1/telegram/webhook/%s", s.webhookBaseURL, webhookSecret)
+	// Compute webhook URL (polling bots are never registered with Telegram)
+	var webhookURL string
+	if mode == domain.BotModeWebhook {
+		webhookURL = s.buildWebhookURL(webhookSecret)
+	}
 
 	bot := &domain.Bot{
 		Username:      req.Username,
@@ -98,6 +119,7 @@ func (s *BotService) CreateBot(ctx context.Context, req *CreateBotRequest) (*Bot
 		IsActive:      true,
 		WebhookURL:    webhookURL,
 		WebhookSecret: webhookSecret,
+		IngestionMode: mode,
 	}
 
 	// Create bot in database first
@@ -105,21 +127,21 @@ func (s *BotService) CreateBot(ctx context.Context, req *CreateBotRequest) (*Bot
 		return nil, fmt.Errorf("failed to create bot: %w", err)
 	}
 
-	// Register webhook with Telegram
-	if err := s.setTelegramWebhook(ctx, req.Token, webhookURL); err != nil {
-		// Rollback: delete the bot record
-		_ = s.botRepo.Delete(ctx, bot.ID)
-		return nil, fmt.Errorf("failed to set Telegram webhook: %w", err)
+	if mode == domain.BotModeWebhook {
+		// Register webhook with Telegram
+		if err := s.setTelegramWebhook(ctx, req.Token, webhookURL); err != nil {
+			// Rollback: delete the bot record
+			_ = s.botRepo.Delete(ctx, bot.ID)
+			return nil, fmt.Errorf("failed to set Telegram webhook: %w", err)
+		}
+	} else {
+		// getUpdates is rejected while a webhook is set
+		if err := s.deleteTelegramWebhook(ctx, req.Token); err != nil {
+			fmt.Printf("Warning: failed to delete Telegram webhook: %v\n", err)
+		}
 	}
 
-	return &BotDTO{
-		ID:          bot.ID,
-		Username:    bot.Username,
-		DisplayName: bot.DisplayName,
-		Description: bot.Description,
-		IsActive:    bot.IsActive,
-		WebhookURL:  bot.WebhookURL,
-	}, nil
+	return toBotDTO(bot), nil
 }
 
 // GetBot retrieves a bot by ID
@@ -129,14 +151,22 @@ func (s *BotService) GetBot(ctx context.Context, id uint) (*BotDTO, error) {
 		return nil, fmt.Errorf("bot not found: %w", err)
 	}
 
-	return &BotDTO{
-		ID:          bot.ID,
-		Username:    bot.Username,
-		DisplayName: bot.DisplayName,
-		Description: bot.Description,
-		IsActive:    bot.IsActive,
-		WebhookURL:  bot.WebhookURL,
-	}, nil
+	return toBotDTO(bot), nil
+}
+
+// GetVisibleBot retrieves a bot by ID if it matches the filter, such as the bots visible to
+// an API key, and reports it as not found otherwise
+func (s *BotService) GetVisibleBot(ctx context.Context, id uint, filter repository.BotFilter) (*BotDTO, error) {
+	filter.ID = &id
+	bots, _, err := s.botRepo.ListFiltered(ctx, filter, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot: %w", err)
+	}
+	if len(bots) == 0 {
+		return nil, fmt.Errorf("bot not found")
+	}
+
+	return toBotDTO(&bots[0]), nil
 }
 
 // GetBotToken retrieves and decrypts a bot's token
@@ -190,20 +220,70 @@ func (s *BotService) ListBots(ctx context.Context, offset, limit int) ([]BotDTO,
 	}
 
 	result := make([]BotDTO, len(bots))
-	for i, bot := range bots {
-		result[i] = BotDTO{
-			ID:          bot.ID,
-			Username:    bot.Username,
-			DisplayName: bot.DisplayName,
-			Description: bot.Description,
-			IsActive:    bot.IsActive,
-			WebhookURL:  bot.WebhookURL,
-		}
+	for i := range bots {
+		result[i] = *toBotDTO(&bots[i])
 	}
 
 	return result, nil
 }
 
+// ListBotsFiltered retrieves a page of bots matching the filter, with the total number of matches
+func (s *BotService) ListBotsFiltered(ctx context.Context, filter repository.BotFilter, offset, limit int) ([]BotDTO, int64, error) {
+	bots, total, err := s.botRepo.ListFiltered(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list bots: %w", err)
+	}
+
+	result := make([]BotDTO, len(bots))
+	for i := range bots {
+		result[i] = *toBotDTO(&bots[i])
+	}
+
+	return result, total, nil
+}
+
+// CountBots returns the total number of bots
+func (s *BotService) CountBots(ctx context.Context) (int64, error) {
+	return s.botRepo.Count(ctx)
+}
+
+// RotateEncryption re-encrypts every bot token that is not encrypted under the
+// primary key and returns how many tokens were re-encrypted
+func (s *BotService) RotateEncryption(ctx context.Context) (int, error) {
+	const batchSize = 100
+	rotated := 0
+
+	for offset := 0; ; offset += batchSize {
+		bots, _, err := s.botRepo.ListFiltered(ctx, repository.BotFilter{}, offset, batchSize)
+		if err != nil {
+			return rotated, fmt.Errorf("failed to list bots: %w", err)
+		}
+
+		for _, bot := range bots {
+			if !s.keyring.NeedsRotation(bot.Token) {
+				continue
+			}
+
+			token, err := s.decryptToken(bot.Token)
+			if err != nil {
+				return rotated, fmt.Errorf("failed to decrypt token of bot %d: %w", bot.ID, err)
+			}
+			encrypted, err := s.encryptToken(token)
+			if err != nil {
+				return rotated, fmt.Errorf("failed to encrypt token of bot %d: %w", bot.ID, err)
+			}
+			if err := s.botRepo.UpdateToken(ctx, bot.ID, encrypted); err != nil {
+				return rotated, fmt.Errorf("failed to update token of bot %d: %w", bot.ID, err)
+			}
+			rotated++
+		}
+
+		if len(bots) < batchSize {
+			return rotated, nil
+		}
+	}
+}
+
 // UpdateBot updates bot information
 func (s *BotService) UpdateBot(ctx context.Context, id uint, displayName, description string, isActive bool) error {
 	bot, err := s.botRepo.GetByID(ctx, id)
@@ -286,14 +366,7 @@ func (s *BotService) GetBotByWebhookSecret(ctx context.Context, secret string) (
 		s.redisClient.Set(ctx, cacheKey, fmt.Sprintf("%d", bot.ID), 10*time.Minute)
 	}
 
-	return &BotDTO{
-		ID:          bot.ID,
-		Username:    bot.Username,
-		DisplayName: bot.DisplayName,
-		Description: bot.Description,
-		IsActive:    bot.IsActive,
-		WebhookURL:  bot.WebhookURL,
-	}, nil
+	return toBotDTO(bot), nil
 }
 
 // SetWebhook re-registers the webhook with Telegram
@@ -311,70 +384,158 @@ func (s *BotService) SetWebhook(ctx context.Context, botID uint) error {
 	return s.setTelegramWebhook(ctx, token, bot.WebhookURL)
 }
 
-// SendTelegramMessage sends a message via Telegram Bot API
-func (s *BotService) SendTelegramMessage(ctx context.Context, botID uint, chatID int64, text string, replyToMessageID *int64, parseMode string) error {
-	// Get decrypted token
-	token, err := s.GetBotToken(ctx, botID)
+// SetIngestionMode switches a bot between webhook and polling mode
+// and updates the webhook registration with Telegram accordingly
+func (s *BotService) SetIngestionMode(ctx context.Context, botID uint, mode string) error {
+	if err := validateIngestionMode(mode); err != nil {
+		return err
+	}
+
+	bot, err := s.botRepo.GetByID(ctx, botID)
 	if err != nil {
-		return fmt.Errorf("failed to get bot token: %w", err)
+		return fmt.Errorf("bot not found: %w", err)
 	}
 
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", token)
+	token, err := s.decryptToken(bot.Token)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt token: %w", err)
+	}
 
-	payload := map[string]interface{}{
-		"chat_id": chatID,
-		"text":    text,
+	switch mode {
+	case domain.BotModeWebhook:
+		if s.webhookBaseURL == "" {
+			return fmt.Errorf("telegram webhook base URL is not configured")
+		}
+		bot.WebhookURL = s.buildWebhookURL(bot.WebhookSecret)
+		if err := s.setTelegramWebhook(ctx, token, bot.WebhookURL); err != nil {
+			return fmt.Errorf("failed to set Telegram webhook: %w", err)
+		}
+	case domain.BotModePolling:
+		if err := s.deleteTelegramWebhook(ctx, token); err != nil {
+			return fmt.Errorf("failed to delete Telegram webhook: %w", err)
+		}
+		bot.WebhookURL = ""
 	}
-	if replyToMessageID != nil {
-		payload["reply_to_message_id"] = *replyToMessageID
+
+	bot.IngestionMode = mode
+	return s.botRepo.Update(ctx, bot)
+}
+
+// ListPollingBots returns the active bots that ingest updates with getUpdates
+func (s *BotService) ListPollingBots(ctx context.Context) ([]BotDTO, error) {
+	bots, err := s.botRepo.ListActiveByIngestionMode(ctx, domain.BotModePolling)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list polling bots: %w", err)
 	}
-	if parseMode != "" {
-		payload["parse_mode"] = parseMode
+
+	result := make([]BotDTO, len(bots))
+	for i := range bots {
+		result[i] = *toBotDTO(&bots[i])
+	}
+
+	return result, nil
+}
+
+// GetPollingOffset returns the ID of the last update processed for a polling bot
+func (s *BotService) GetPollingOffset(ctx context.Context, botID uint) (int64, error) {
+	bot, err := s.botRepo.GetByID(ctx, botID)
+	if err != nil {
+		return 0, fmt.Errorf("bot not found: %w", err)
+	}
+
+	return bot.LastUpdateID, nil
+}
+
+// SavePollingOffset records the ID of the last update processed for a polling bot
+func (s *BotService) SavePollingOffset(ctx context.Context, botID uint, updateID int64) error {
+	return s.botRepo.UpdateLastUpdateID(ctx, botID, updateID)
+}
+
+// ErrInvalidUpdate is returned for an update that cannot be decoded, so processing it again cannot succeed
+var ErrInvalidUpdate = errors.New("invalid update format")
+
+// PolledUpdate is a raw update returned by getUpdates
+type PolledUpdate struct {
+	UpdateID int64
+	Raw      json.RawMessage
+}
+
+// GetUpdates long-polls Telegram for updates newer than lastUpdateID
+func (s *BotService) GetUpdates(ctx context.Context, botID uint, lastUpdateID int64, timeout time.Duration) ([]PolledUpdate, error) {
+	token, err := s.GetBotToken(ctx, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot token: %w", err)
+	}
+
+	payload := map[string]interface{}{
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": AllowedUpdates,
+	}
+	if lastUpdateID > 0 {
+		// Confirms every update up to lastUpdateID
+		payload["offset"] = lastUpdateID + 1
 	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	// Give Telegram time to answer after the long-poll timeout expires
+	reqCtx, cancel := context.WithTimeout(ctx, timeout+10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, "POST", s.apiURL(token, "getUpdates"), bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.pollingClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to call Telegram API: %w", err)
+		return nil, fmt.Errorf("failed to call Telegram API: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("Telegram API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("Telegram API returned status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
-		Ok          bool   `json:"ok"`
-		Description string `json:"description"`
+		Ok          bool              `json:"ok"`
+		Description string            `json:"description"`
+		Result      []json.RawMessage `json:"result"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if !result.Ok {
-		return fmt.Errorf("Telegram API error: %s", result.Description)
+		return nil, fmt.Errorf("Telegram API error: %s", result.Description)
 	}
 
-	return nil
+	updates := make([]PolledUpdate, 0, len(result.Result))
+	for _, raw := range result.Result {
+		var header struct {
+			UpdateID int64 `json:"update_id"`
+		}
+		if err := json.Unmarshal(raw, &header); err != nil {
+			return nil, fmt.Errorf("failed to decode update: %w", err)
+		}
+		updates = append(updates, PolledUpdate{UpdateID: header.UpdateID, Raw: raw})
+	}
+
+	return updates, nil
 }
 
 // setTelegramWebhook calls Telegram API to set webhook
 func (s *BotService) setTelegramWebhook(ctx context.Context, token, webhookURL string) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/setWebhook", token)
+	url := s.apiURL(token, "setWebhook")
 
-	payload := map[string]string{
-		"url": webhookURL,
+	payload := map[string]interface{}{
+		"url":             webhookURL,
+		"allowed_updates": AllowedUpdates,
 	}
 
 	jsonData, err := json.Marshal(payload)
@@ -416,7 +577,7 @@ func (s *BotService) setTelegramWebhook(ctx context.Context, token, webhookURL s
 
 // deleteTelegramWebhook calls Telegram API to delete webhook
 func (s *BotService) deleteTelegramWebhook(ctx context.Context, token string) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/deleteWebhook", token)
+	url := s.apiURL(token, "deleteWebhook")
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
@@ -437,54 +598,45 @@ func (s *BotService) deleteTelegramWebhook(ctx context.Context, token string) er
 	return nil
 }
 
-// encryptToken encrypts a bot token using AES-256-GCM
-func (s *BotService) encryptToken(plaintext string) (string, error) {
-	block, err := aes.NewCipher(s.encryptionKey)
-	if err != nil {
-		return "", err
-	}
+// apiURL builds a Bot API method URL
+func (s *BotService) apiURL(token, method string) string {
+	return fmt.Sprintf("%s/bot%s/%s", s.apiBaseURL, token, method)
+}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
+// buildWebhookURL builds the gateway webhook URL for a webhook secret
+func (s *BotService) buildWebhookURL(webhookSecret string) string {
+	return fmt.Sprintf("%s/api/v1/telegram/webhook/%s", s.webhookBaseURL, webhookSecret)
+}
+
+// validateIngestionMode checks that a bot ingestion mode is supported
+func validateIngestionMode(mode string) error {
+	switch mode {
+	case domain.BotModeWebhook, domain.BotModePolling:
+		return nil
+	default:
+		return fmt.Errorf("invalid ingestion mode %q (use %s or %s)", mode, domain.BotModeWebhook, domain.BotModePolling)
 	}
+}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
+// toBotDTO converts a bot model to its DTO
+func toBotDTO(bot *domain.Bot) *BotDTO {
+	return &BotDTO{
+		ID:            bot.ID,
+		Username:      bot.Username,
+		DisplayName:   bot.DisplayName,
+		Description:   bot.Description,
+		IsActive:      bot.IsActive,
+		WebhookURL:    bot.WebhookURL,
+		IngestionMode: bot.IngestionMode,
 	}
+}
 
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+// encryptToken encrypts a bot token under the primary encryption key
+func (s *BotService) encryptToken(plaintext string) (string, error) {
+	return s.keyring.Encrypt(plaintext)
 }
 
 // decryptToken decrypts a bot token
 func (s *BotService) decryptToken(encrypted string) (string, error) {
-	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
-	if err != nil {
-		return "", err
-	}
-
-	block, err := aes.NewCipher(s.encryptionKey)
-	if err != nil {
-		return "", err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	nonceSize := gcm.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
-	}
-
-	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", err
-	}
-
-	return string(plaintext), nil
+	return s.keyring.Decrypt(encrypted)
 }
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/pkg/encryption"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// fakeBotRepository serves bots from memory
+type fakeBotRepository struct {
+	repository.BotRepository
+	bots map[uint]*domain.Bot
+}
+
+func (r *fakeBotRepository) GetByID(ctx context.Context, id uint) (*domain.Bot, error) {
+	bot, ok := r.bots[id]
+	if !ok {
+		return nil, assert.AnError
+	}
+	return bot, nil
+}
+
+func (r *fakeBotRepository) ListFiltered(ctx context.Context, filter repository.BotFilter, offset, limit int) ([]domain.Bot, int64, error) {
+	var bots []domain.Bot
+	for id := uint(1); id <= uint(len(r.bots)); id++ {
+		if filter.ID != nil && *filter.ID != id {
+			continue
+		}
+		bots = append(bots, *r.bots[id])
+	}
+	if offset >= len(bots) {
+		return nil, int64(len(bots)), nil
+	}
+	return bots[offset:min(offset+limit, len(bots))], int64(len(bots)), nil
+}
+
+func (r *fakeBotRepository) UpdateToken(ctx context.Context, id uint, token string) error {
+	r.bots[id].Token = token
+	return nil
+}
+
+// testKeyring returns a keyring with a single test key
+func testKeyring(t *testing.T) *encryption.Keyring {
+	keyring, err := encryption.NewKeyring("test", map[string]string{"test": testEncryptionKey}, "")
+	require.NoError(t, err)
+	return keyring
+}
+
+const testEncryptionKey = "dGVzdC1lbmNyeXB0aW9uLWtleS1vZi0zMi1ieXRlcyE=" // "test-encryption-key-of-32-bytes!"
+
+func TestRotateEncryption(t *testing.T) {
+	const legacySecret = "legacy-jwt-secret-at-least-32-characters"
+	before, err := encryption.NewKeyring("v1", map[string]string{"v1": testEncryptionKey}, legacySecret)
+	require.NoError(t, err)
+	after, err := encryption.NewKeyring("v2", map[string]string{
+		"v1": testEncryptionKey,
+		"v2": "c2Vjb25kLWVuY3J5cHRpb24ta2V5LTMyLWJ5dGVzISE=",
+	}, legacySecret)
+	require.NoError(t, err)
+
+	repo := &fakeBotRepository{bots: map[uint]*domain.Bot{}}
+	v1Token, err := before.Encrypt("111:aaa")
+	require.NoError(t, err)
+	repo.bots[1] = &domain.Bot{ID: 1, Token: v1Token}
+	repo.bots[2] = &domain.Bot{ID: 2, Token: legacyEncrypt(t, legacySecret, "222:bbb")}
+
+	svc := NewBotService(repo, after, "", "http://127.0.0.1:0", nil)
+	rotated, err := svc.RotateEncryption(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, rotated)
+
+	for id, want := range map[uint]string{1: "111:aaa", 2: "222:bbb"} {
+		assert.True(t, strings.HasPrefix(repo.bots[id].Token, "v2:"))
+		token, err := svc.GetBotToken(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, want, token)
+	}
+
+	rotated, err = svc.RotateEncryption(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, rotated)
+}
+
+// legacyEncrypt encrypts a token the way it was stored before key IDs were introduced
+func legacyEncrypt(t *testing.T, secret, plaintext string) string {
+	key := make([]byte, 32)
+	copy(key, secret)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil))
+}
+
+func TestGetVisibleBot(t *testing.T) {
+	repo := &fakeBotRepository{bots: map[uint]*domain.Bot{
+		1: {ID: 1, Username: "first_bot"},
+		2: {ID: 2, Username: "second_bot"},
+	}}
+	svc := NewBotService(repo, testKeyring(t), "", "http://127.0.0.1:0", nil)
+
+	bot, err := svc.GetVisibleBot(context.Background(), 2, repository.BotFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, "second_bot", bot.Username)
+
+	_, err = svc.GetVisibleBot(context.Background(), 3, repository.BotFilter{})
+	assert.Error(t, err)
+}
+
+func TestGetUpdatesAgainstFakeBotAPI(t *testing.T) {
+	var gotPath string
+	var gotPayload map[string]interface{}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&gotPayload)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"result":[
+			{"update_id":42,"message":{"message_id":1,"date":0,"chat":{"id":5,"type":"private"},"text":"hi"}},
+			{"update_id":43,"edited_message":{"message_id":1,"date":0,"chat":{"id":5,"type":"private"},"text":"hi!"}}
+		]}`))
+	}))
+	defer api.Close()
+
+	repo := &fakeBotRepository{bots: map[uint]*domain.Bot{}}
+	svc := NewBotService(repo, testKeyring(t), "", api.URL+"/", nil)
+
+	encrypted, err := svc.encryptToken("123:abc")
+	require.NoError(t, err)
+	repo.bots[1] = &domain.Bot{ID: 1, Token: encrypted, IngestionMode: domain.BotModePolling}
+
+	updates, err := svc.GetUpdates(context.Background(), 1, 41, time.Second)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/bot123:abc/getUpdates", gotPath)
+	assert.Equal(t, float64(42), gotPayload["offset"])
+	assert.Equal(t, float64(1), gotPayload["timeout"])
+	require.Len(t, updates, 2)
+	assert.Equal(t, int64(42), updates[0].UpdateID)
+	assert.Equal(t, int64(43), updates[1].UpdateID)
+	assert.Contains(t, string(updates[1].Raw), "edited_message")
+}
+
+func TestCreateBotRequiresWebhookBaseURLOnlyInWebhookMode(t *testing.T) {
+	svc := NewBotService(&fakeBotRepository{}, testKeyring(t), "", "http://127.0.0.1:0", nil)
+
+	_, err := svc.CreateBot(context.Background(), &CreateBotRequest{Username: "dev_bot", Token: "123:abc"})
+	assert.Error(t, err)
+
+	_, err = svc.CreateBot(context.Background(), &CreateBotRequest{Username: "dev_bot", Token: "123:abc", Mode: "push"})
+	assert.Error(t, err)
+}
+
+func TestCallBotAPIMultipartUpload(t *testing.T) {
+	var gotCaption, gotMarkup, gotFileName, gotFileBody string
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		gotCaption = r.FormValue("caption")
+		gotMarkup = r.FormValue("reply_markup")
+		file, header, err := r.FormFile("photo")
+		require.NoError(t, err)
+		defer file.Close()
+		body, _ := io.ReadAll(file)
+		gotFileName, gotFileBody = header.Filename, string(body)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":7,"date":0,"photo":[]}}`))
+	}))
+	defer api.Close()
+
+	repo := &fakeBotRepository{bots: map[uint]*domain.Bot{}}
+	svc := NewBotService(repo, testKeyring(t), "", api.URL, nil)
+	encrypted, err := svc.encryptToken("123:abc")
+	require.NoError(t, err)
+	repo.bots[1] = &domain.Bot{ID: 1, Token: encrypted}
+
+	params := map[string]interface{}{
+		"chat_id":      int64(5),
+		"caption":      "cat",
+		"reply_markup": json.RawMessage(`{"inline_keyboard":[[{"text":"ok","callback_data":"ok"}]]}`),
+	}
+	files := []InputFile{{Field: "photo", FileName: "cat.jpg", Reader: strings.NewReader("jpeg")}}
+
+	result, err := svc.CallBotAPI(context.Background(), 1, "sendPhoto", params, files)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"message_id":7,"date":0,"photo":[]}`, string(result))
+	assert.Equal(t, "cat", gotCaption)
+	assert.JSONEq(t, `{"inline_keyboard":[[{"text":"ok","callback_data":"ok"}]]}`, gotMarkup)
+	assert.Equal(t, "cat.jpg", gotFileName)
+	assert.Equal(t, "jpeg", gotFileBody)
+}
+
+func TestCallBotAPIReturnsAPIError(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 3","parameters":{"retry_after":3}}`))
+	}))
+	defer api.Close()
+
+	repo := &fakeBotRepository{bots: map[uint]*domain.Bot{}}
+	svc := NewBotService(repo, testKeyring(t), "", api.URL, nil)
+	encrypted, err := svc.encryptToken("123:abc")
+	require.NoError(t, err)
+	repo.bots[1] = &domain.Bot{ID: 1, Token: encrypted}
+
+	_, err = svc.CallBotAPI(context.Background(), 1, "sendMessage", map[string]interface{}{"chat_id": 5, "text": "hi"}, nil)
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 429, apiErr.Code)
+	assert.Equal(t, 3, apiErr.RetryAfter)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// ErrCallbackQueryAnswered is returned when a callback query has already been answered
+var ErrCallbackQueryAnswered = errors.New("callback query already answered")
+
+// CallbackQueryService stores inline keyboard callback queries and answers them through the Bot API
+type CallbackQueryService struct {
+	callbackRepo repository.CallbackQueryRepository
+	botService   *BotService
+}
+
+// NewCallbackQueryService creates a new callback query service
+func NewCallbackQueryService(callbackRepo repository.CallbackQueryRepository, botService *BotService) *CallbackQueryService {
+	return &CallbackQueryService{
+		callbackRepo: callbackRepo,
+		botService:   botService,
+	}
+}
+
+// CallbackQueryDTO represents a callback query data transfer object
+type CallbackQueryDTO struct {
+	ID              uint       `json:"id"`
+	QueryID         string     `json:"query_id"`
+	BotID           uint       `json:"bot_id"`
+	ChatID          *uint      `json:"chat_id,omitempty"`
+	MessageID       *uint      `json:"message_id,omitempty"`
+	InlineMessageID string     `json:"inline_message_id,omitempty"`
+	APIKeyID        *uint      `json:"api_key_id,omitempty"`
+	FromUserID      int64      `json:"from_user_id"`
+	FromUsername    string     `json:"from_username,omitempty"`
+	FromFirstName   string     `json:"from_first_name,omitempty"`
+	ChatInstance    string     `json:"chat_instance,omitempty"`
+	Data            string     `json:"data,omitempty"`
+	GameShortName   string     `json:"game_short_name,omitempty"`
+	AnsweredAt      *time.Time `json:"answered_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// RecordCallbackQueryRequest represents a received callback query
+type RecordCallbackQueryRequest struct {
+	QueryID         string
+	BotID           uint
+	ChatID          *uint
+	MessageID       *uint
+	InlineMessageID string
+	APIKeyID        *uint // API key that sent the keyboard, if known
+	FromUserID      int64
+	FromUsername    string
+	FromFirstName   string
+	ChatInstance    string
+	Data            string
+	GameShortName   string
+	RawData         string
+}
+
+// AnswerCallbackQueryRequest holds the answerCallbackQuery options
+type AnswerCallbackQueryRequest struct {
+	Text      string `json:"text,omitempty"`       // Notification text, 0-200 characters
+	ShowAlert bool   `json:"show_alert,omitempty"` // Show an alert instead of a notification
+	URL       string `json:"url,omitempty"`        // URL to open (games and t.me links)
+	CacheTime int    `json:"cache_time,omitempty"` // Seconds the client may cache the answer
+}
+
+// RecordCallbackQuery stores a received callback query
+func (s *CallbackQueryService) RecordCallbackQuery(ctx context.Context, req *RecordCallbackQueryRequest) (*CallbackQueryDTO, error) {
+	query := &domain.CallbackQuery{
+		QueryID:         req.QueryID,
+		BotID:           req.BotID,
+		ChatID:          req.ChatID,
+		MessageID:       req.MessageID,
+		InlineMessageID: req.InlineMessageID,
+		APIKeyID:        req.APIKeyID,
+		FromUserID:      req.FromUserID,
+		FromUsername:    req.FromUsername,
+		FromFirstName:   req.FromFirstName,
+		ChatInstance:    req.ChatInstance,
+		Data:            req.Data,
+		GameShortName:   req.GameShortName,
+		RawData:         req.RawData,
+	}
+
+	if err := s.callbackRepo.Create(ctx, query); err != nil {
+		return nil, fmt.Errorf("failed to store callback query: %w", err)
+	}
+
+	return toCallbackQueryDTO(query), nil
+}
+
+// GetCallbackQuery retrieves a callback query by its Telegram ID
+func (s *CallbackQueryService) GetCallbackQuery(ctx context.Context, queryID string) (*CallbackQueryDTO, error) {
+	query, err := s.callbackRepo.GetByQueryID(ctx, queryID)
+	if err != nil {
+		return nil, fmt.Errorf("callback query not found: %w", err)
+	}
+
+	return toCallbackQueryDTO(query), nil
+}
+
+// AnswerCallbackQuery answers a callback query through the Bot API and marks it answered.
+// Telegram accepts a single answer per query.
+func (s *CallbackQueryService) AnswerCallbackQuery(ctx context.Context, queryID string, req *AnswerCallbackQueryRequest) (*CallbackQueryDTO, error) {
+	query, err := s.callbackRepo.GetByQueryID(ctx, queryID)
+	if err != nil {
+		return nil, fmt.Errorf("callback query not found: %w", err)
+	}
+	if query.AnsweredAt != nil {
+		return nil, ErrCallbackQueryAnswered
+	}
+
+	params := map[string]interface{}{
+		"callback_query_id": query.QueryID,
+	}
+	if req.Text != "" {
+		params["text"] = req.Text
+	}
+	if req.ShowAlert {
+		params["show_alert"] = true
+	}
+	if req.URL != "" {
+		params["url"] = req.URL
+	}
+	if req.CacheTime > 0 {
+		params["cache_time"] = req.CacheTime
+	}
+
+	if _, err := s.botService.CallBotAPI(ctx, query.BotID, "answerCallbackQuery", params, nil); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	query.AnsweredAt = &now
+	if err := s.callbackRepo.Update(ctx, query); err != nil {
+		return nil, fmt.Errorf("callback query answered but not updated: %w", err)
+	}
+
+	return toCallbackQueryDTO(query), nil
+}
+
+// toCallbackQueryDTO converts a stored callback query to its DTO
+func toCallbackQueryDTO(query *domain.CallbackQuery) *CallbackQueryDTO {
+	return &CallbackQueryDTO{
+		ID:              query.ID,
+		QueryID:         query.QueryID,
+		BotID:           query.BotID,
+		ChatID:          query.ChatID,
+		MessageID:       query.MessageID,
+		InlineMessageID: query.InlineMessageID,
+		APIKeyID:        query.APIKeyID,
+		FromUserID:      query.FromUserID,
+		FromUsername:    query.FromUsername,
+		FromFirstName:   query.FromFirstName,
+		ChatInstance:    query.ChatInstance,
+		Data:            query.Data,
+		GameShortName:   query.GameShortName,
+		AnsweredAt:      query.AnsweredAt,
+		CreatedAt:       query.CreatedAt,
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// fakeCallbackQueryRepository keeps callback queries in memory
+type fakeCallbackQueryRepository struct {
+	repository.CallbackQueryRepository
+	queries map[string]*domain.CallbackQuery
+}
+
+func (r *fakeCallbackQueryRepository) GetByQueryID(ctx context.Context, queryID string) (*domain.CallbackQuery, error) {
+	query, ok := r.queries[queryID]
+	if !ok {
+		return nil, assert.AnError
+	}
+	return query, nil
+}
+
+func (r *fakeCallbackQueryRepository) GetByID(ctx context.Context, id uint) (*domain.CallbackQuery, error) {
+	for _, query := range r.queries {
+		if query.ID == id {
+			return query, nil
+		}
+	}
+	return nil, assert.AnError
+}
+
+func (r *fakeCallbackQueryRepository) Update(ctx context.Context, query *domain.CallbackQuery) error {
+	r.queries[query.QueryID] = query
+	return nil
+}
+
+func TestAnswerCallbackQueryOnlyOnce(t *testing.T) {
+	var gotPayload map[string]interface{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.URL.Path, "/answerCallbackQuery")
+		_ = json.NewDecoder(r.Body).Decode(&gotPayload)
+		_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer api.Close()
+
+	botRepo := &fakeBotRepository{bots: map[uint]*domain.Bot{}}
+	botService := NewBotService(botRepo, testKeyring(t), "", api.URL, nil)
+	encrypted, err := botService.encryptToken("123:abc")
+	require.NoError(t, err)
+	botRepo.bots[1] = &domain.Bot{ID: 1, Token: encrypted}
+
+	callbackRepo := &fakeCallbackQueryRepository{queries: map[string]*domain.CallbackQuery{
+		"4711": {ID: 1, QueryID: "4711", BotID: 1, Data: "like"},
+	}}
+	svc := NewCallbackQueryService(callbackRepo, botService)
+
+	answered, err := svc.AnswerCallbackQuery(context.Background(), "4711", &AnswerCallbackQueryRequest{Text: "Thanks!", ShowAlert: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, "4711", gotPayload["callback_query_id"])
+	assert.Equal(t, "Thanks!", gotPayload["text"])
+	assert.Equal(t, true, gotPayload["show_alert"])
+	assert.NotNil(t, answered.AnsweredAt)
+
+	_, err = svc.AnswerCallbackQuery(context.Background(), "4711", &AnswerCallbackQueryRequest{})
+	assert.ErrorIs(t, err, ErrCallbackQueryAnswered)
+}
@@ -2,12 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/kexi/telegram-bot-gateway/internal/domain"
 	"github.com/kexi/telegram-bot-gateway/internal/repository"
 )
 
+// ErrInvalidChatType is returned when filtering chats by an unknown type
+var ErrInvalidChatType = errors.New("type must be private, group, supergroup or channel")
+
 // ChatService handles chat operations
 type ChatService struct {
 	chatRepo repository.ChatRepository
@@ -49,7 +53,8 @@ type CreateChatRequest struct {
 	LastName   string `json:"last_name,omitempty"`
 }
 
-// CreateOrUpdateChat creates a new chat or updates if it exists
+// CreateOrUpdateChat creates a new chat or updates if it exists. New chats are active;
+// updating a chat keeps its active flag.
 func (s *ChatService) CreateOrUpdateChat(ctx context.Context, req *CreateChatRequest) (*ChatDTO, error) {
 	// Verify bot exists
 	_, err := s.botRepo.GetByID(ctx, req.BotID)
@@ -66,7 +71,7 @@ func (s *ChatService) CreateOrUpdateChat(ctx context.Context, req *CreateChatReq
 		existingChat.Username = req.Username
 		existingChat.FirstName = req.FirstName
 		existingChat.LastName = req.LastName
-		existingChat.IsActive = true
+		// IsActive is left as is; only my_chat_member updates tell whether the bot is still in the chat
 
 		if err := s.chatRepo.Update(ctx, existingChat); err != nil {
 			return nil, fmt.Errorf("failed to update chat: %w", err)
@@ -134,11 +139,17 @@ func (s *ChatService) GetChat(ctx context.Context, id uint) (*ChatDTO, error) {
 	}, nil
 }
 
-// ListChats retrieves all chats
-func (s *ChatService) ListChats(ctx context.Context, offset, limit int) ([]ChatDTO, error) {
-	chats, err := s.chatRepo.List(ctx, offset, limit)
+// ListChatsFiltered retrieves a page of chats matching the filter, with the total number of matches
+func (s *ChatService) ListChatsFiltered(ctx context.Context, filter repository.ChatFilter, offset, limit int) ([]ChatDTO, int64, error) {
+	switch filter.Type {
+	case "", "private", "group", "supergroup", "channel":
+	default:
+		return nil, 0, ErrInvalidChatType
+	}
+
+	chats, total, err := s.chatRepo.ListFiltered(ctx, filter, offset, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list chats: %w", err)
+		return nil, 0, fmt.Errorf("failed to list chats: %w", err)
 	}
 
 	result := make([]ChatDTO, len(chats))
@@ -156,7 +167,12 @@ func (s *ChatService) ListChats(ctx context.Context, offset, limit int) ([]ChatD
 		}
 	}
 
-	return result, nil
+	return result, total, nil
+}
+
+// CountChats returns the total number of chats
+func (s *ChatService) CountChats(ctx context.Context) (int64, error) {
+	return s.chatRepo.Count(ctx)
 }
 
 // ListChatsByBot retrieves chats for a specific bot
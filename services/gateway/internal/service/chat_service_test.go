@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+)
+
+func (r *fakeChatRepository) GetByBotAndTelegramID(ctx context.Context, botID uint, telegramID int64) (*domain.Chat, error) {
+	for _, chat := range r.chats {
+		if chat.BotID == botID && chat.TelegramID == telegramID {
+			return chat, nil
+		}
+	}
+	return nil, assert.AnError
+}
+
+func (r *fakeChatRepository) Create(ctx context.Context, chat *domain.Chat) error {
+	chat.ID = uint(len(r.chats) + 1)
+	r.chats[chat.ID] = chat
+	return nil
+}
+
+func (r *fakeChatRepository) Update(ctx context.Context, chat *domain.Chat) error {
+	r.chats[chat.ID] = chat
+	return nil
+}
+
+func TestChatStaysInactiveAfterBotWasKicked(t *testing.T) {
+	botRepo := &fakeBotRepository{bots: map[uint]*domain.Bot{1: {ID: 1}}}
+	chatRepo := &fakeChatRepository{chats: map[uint]*domain.Chat{}}
+	svc := NewChatService(chatRepo, botRepo)
+	ctx := context.Background()
+	req := &CreateChatRequest{BotID: 1, TelegramID: -100500, Type: "group", Title: "Sales"}
+
+	chat, err := svc.CreateOrUpdateChat(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, chat.IsActive, "new chats are active")
+
+	// my_chat_member: the bot was kicked
+	require.NoError(t, svc.UpdateChat(ctx, chat.ID, false))
+
+	// A later message from the chat, e.g. delivered out of order
+	req.Title = "Sales team"
+	chat, err = svc.CreateOrUpdateChat(ctx, req)
+	require.NoError(t, err)
+	assert.False(t, chat.IsActive, "a message does not bring the bot back into the chat")
+	assert.Equal(t, "Sales team", chat.Title)
+	assert.False(t, chatRepo.chats[chat.ID].IsActive)
+
+	// my_chat_member: the bot was added back
+	require.NoError(t, svc.UpdateChat(ctx, chat.ID, true))
+	chat, err = svc.CreateOrUpdateChat(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, chat.IsActive)
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// EventBuilder builds the event envelopes published to webhooks, WebSocket clients and
+// gRPC streams from stored messages, callback queries and update events
+type EventBuilder struct {
+	botRepo         repository.BotRepository
+	chatRepo        repository.ChatRepository
+	messageRepo     repository.MessageRepository
+	mediaRepo       repository.MediaFileRepository
+	callbackRepo    repository.CallbackQueryRepository
+	updateEventRepo repository.UpdateEventRepository
+}
+
+// NewEventBuilder creates a new event builder
+func NewEventBuilder(
+	botRepo repository.BotRepository,
+	chatRepo repository.ChatRepository,
+	messageRepo repository.MessageRepository,
+	mediaRepo repository.MediaFileRepository,
+	callbackRepo repository.CallbackQueryRepository,
+	updateEventRepo repository.UpdateEventRepository,
+) *EventBuilder {
+	return &EventBuilder{
+		botRepo:         botRepo,
+		chatRepo:        chatRepo,
+		messageRepo:     messageRepo,
+		mediaRepo:       mediaRepo,
+		callbackRepo:    callbackRepo,
+		updateEventRepo: updateEventRepo,
+	}
+}
+
+// MessageEvent builds the envelope of a message event such as "new_message" or "edited_message"
+func (b *EventBuilder) MessageEvent(ctx context.Context, event string, messageID uint) (*domain.EventEnvelope, error) {
+	message, err := b.messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	createdAt := message.SentAt
+	switch {
+	case event == "deleted_message":
+		createdAt = time.Now()
+	case message.EditedAt != nil:
+		createdAt = *message.EditedAt
+	}
+
+	envelope := b.newEnvelope(event, "message", message.ID, createdAt)
+	if err := b.setChat(ctx, envelope, message.ChatID); err != nil {
+		return nil, err
+	}
+	envelope.Message = b.eventMessage(ctx, message)
+	envelope.Raw = rawJSON(message.RawData)
+
+	return envelope, nil
+}
+
+// CallbackQueryEvent builds the envelope of a button press, with the message holding the keyboard
+func (b *EventBuilder) CallbackQueryEvent(ctx context.Context, callbackQueryID uint) (*domain.EventEnvelope, error) {
+	query, err := b.callbackRepo.GetByID(ctx, callbackQueryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get callback query: %w", err)
+	}
+
+	envelope := b.newEnvelope("callback_query", "callback_query", query.ID, query.CreatedAt)
+	if err := b.setBot(ctx, envelope, query.BotID); err != nil {
+		return nil, err
+	}
+	if query.ChatID != nil {
+		if err := b.setChat(ctx, envelope, *query.ChatID); err != nil {
+			return nil, err
+		}
+	}
+	if query.MessageID != nil {
+		if message, err := b.messageRepo.GetByID(ctx, *query.MessageID); err == nil {
+			envelope.Message = b.eventMessage(ctx, message)
+		}
+	}
+
+	envelope.CallbackQuery = &domain.EventCallbackQuery{
+		ID:   query.QueryID,
+		Data: query.Data,
+		From: domain.EventUser{
+			ID:        query.FromUserID,
+			Username:  query.FromUsername,
+			FirstName: query.FromFirstName,
+		},
+		ChatInstance:    query.ChatInstance,
+		InlineMessageID: query.InlineMessageID,
+		APIKeyID:        query.APIKeyID,
+	}
+	envelope.Raw = rawJSON(query.RawData)
+
+	return envelope, nil
+}
+
+// UpdateEvent builds the envelope of a stored update event. The Telegram object of the
+// update is its content, so it is always included as update.data.
+func (b *EventBuilder) UpdateEvent(ctx context.Context, updateEventID uint) (*domain.EventEnvelope, error) {
+	ue, err := b.updateEventRepo.GetByID(ctx, updateEventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get update event: %w", err)
+	}
+
+	envelope := b.newEnvelope(ue.EventType, "update_event", ue.ID, ue.OccurredAt)
+	if err := b.setBot(ctx, envelope, ue.BotID); err != nil {
+		return nil, err
+	}
+	if ue.ChatID != nil {
+		if err := b.setChat(ctx, envelope, *ue.ChatID); err != nil {
+			return nil, err
+		}
+	}
+	if ue.MessageID != nil {
+		if message, err := b.messageRepo.GetByID(ctx, *ue.MessageID); err == nil {
+			envelope.Message = b.eventMessage(ctx, message)
+		}
+	}
+
+	envelope.Update = &domain.EventUpdate{Data: rawJSON(ue.RawData)}
+	if ue.FromUserID != nil {
+		envelope.Update.From = &domain.EventUser{ID: *ue.FromUserID, Username: ue.FromUsername}
+	}
+
+	return envelope, nil
+}
+
+// newEnvelope starts an envelope whose ID is derived from the event's source, so webhooks,
+// WebSocket clients and gRPC streams see the same ID for the same event
+func (b *EventBuilder) newEnvelope(event, source string, sourceID uint, createdAt time.Time) *domain.EventEnvelope {
+	return &domain.EventEnvelope{
+		Version:   domain.EventSchemaEnvelope,
+		Event:     event,
+		ID:        eventID(event, source, sourceID, createdAt),
+		CreatedAt: createdAt,
+	}
+}
+
+// setBot adds the bot of an event
+func (b *EventBuilder) setBot(ctx context.Context, envelope *domain.EventEnvelope, botID uint) error {
+	bot, err := b.botRepo.GetByID(ctx, botID)
+	if err != nil {
+		return fmt.Errorf("failed to get bot: %w", err)
+	}
+	envelope.Bot = toEventBot(bot)
+	return nil
+}
+
+// setChat adds the chat of an event and its bot
+func (b *EventBuilder) setChat(ctx context.Context, envelope *domain.EventEnvelope, chatID uint) error {
+	chat, err := b.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("failed to get chat: %w", err)
+	}
+
+	envelope.Chat = &domain.EventChat{
+		ID:         chat.ID,
+		TelegramID: chat.TelegramID,
+		Type:       chat.Type,
+		Title:      chat.Title,
+		Username:   chat.Username,
+		FirstName:  chat.FirstName,
+		LastName:   chat.LastName,
+	}
+	if envelope.Bot == nil {
+		envelope.Bot = toEventBot(&chat.Bot)
+	}
+	return nil
+}
+
+// eventMessage converts a stored message, adding its media and the message it replies to
+func (b *EventBuilder) eventMessage(ctx context.Context, message *domain.Message) *domain.EventMessage {
+	result := &domain.EventMessage{
+		ID:         message.ID,
+		TelegramID: message.TelegramID,
+		Direction:  message.Direction,
+		Type:       message.MessageType,
+		Text:       message.Text,
+		From:       eventSender(message),
+		SentAt:     message.SentAt,
+		EditedAt:   message.EditedAt,
+		IsDeleted:  message.IsDeleted,
+	}
+
+	if file, err := b.mediaRepo.GetByMessageID(ctx, message.ID); err == nil {
+		result.Media = &domain.EventMedia{
+			FileID:       file.FileID,
+			FileUniqueID: file.FileUniqueID,
+			FileName:     file.FileName,
+			MimeType:     file.MimeType,
+			FileSize:     file.FileSize,
+			Status:       file.Status,
+		}
+	}
+
+	if message.ReplyToMessageID != nil {
+		result.ReplyTo = &domain.EventReply{TelegramID: *message.ReplyToMessageID}
+		if replied, err := b.messageRepo.GetByChatAndTelegramID(ctx, message.ChatID, *message.ReplyToMessageID); err == nil {
+			result.ReplyTo.MessageID = &replied.ID
+			result.ReplyTo.Text = replied.Text
+			result.ReplyTo.From = eventSender(replied)
+		}
+	}
+
+	return result
+}
+
+// eventSender returns the sender of a message, nil when unknown such as for outgoing messages
+func eventSender(message *domain.Message) *domain.EventUser {
+	if message.FromUserID == nil {
+		return nil
+	}
+	return &domain.EventUser{
+		ID:        *message.FromUserID,
+		Username:  message.FromUsername,
+		FirstName: message.FromFirstName,
+		LastName:  message.FromLastName,
+	}
+}
+
+// toEventBot converts a bot
+func toEventBot(bot *domain.Bot) *domain.EventBot {
+	return &domain.EventBot{
+		ID:          bot.ID,
+		Username:    bot.Username,
+		DisplayName: bot.DisplayName,
+	}
+}
+
+// eventID derives a stable, opaque event ID
+func eventID(event, source string, sourceID uint, createdAt time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%d:%d", event, source, sourceID, createdAt.Unix())))
+	return "evt_" + hex.EncodeToString(sum[:12])
+}
+
+// rawJSON returns stored Telegram JSON, nil when there is none
+func rawJSON(data string) json.RawMessage {
+	if data == "" || !json.Valid([]byte(data)) {
+		return nil
+	}
+	return json.RawMessage(data)
+}
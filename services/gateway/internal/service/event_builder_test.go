@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// fakeUpdateEventRepository serves update events from memory
+type fakeUpdateEventRepository struct {
+	repository.UpdateEventRepository
+	events map[uint]*domain.UpdateEvent
+}
+
+func (r *fakeUpdateEventRepository) GetByID(ctx context.Context, id uint) (*domain.UpdateEvent, error) {
+	event, ok := r.events[id]
+	if !ok {
+		return nil, assert.AnError
+	}
+	return event, nil
+}
+
+func newEventTestBuilder() (*EventBuilder, *fakeMessageRepository, *fakeCallbackQueryRepository, *fakeUpdateEventRepository) {
+	bot := domain.Bot{ID: 1, Username: "weather_bot", DisplayName: "Weather"}
+	userID := int64(42)
+	sentAt := time.Unix(1770638400, 0)
+
+	messages := &fakeMessageRepository{messages: []*domain.Message{
+		{ID: 10, ChatID: 3, TelegramID: 500, Direction: "outgoing", MessageType: "text", Text: "Rain tomorrow", SentAt: sentAt},
+		{ID: 11, ChatID: 3, TelegramID: 501, Direction: "incoming", MessageType: "photo", Text: "Here?",
+			FromUserID: &userID, FromUsername: "john_doe", FromFirstName: "John",
+			ReplyToMessageID: int64Ptr(500), RawData: `{"message_id":501}`, SentAt: sentAt.Add(time.Minute)},
+	}}
+	callbacks := &fakeCallbackQueryRepository{queries: map[string]*domain.CallbackQuery{
+		"q1": {ID: 5, QueryID: "q1", BotID: 1, ChatID: uintPtr(3), MessageID: uintPtr(10), FromUserID: 42, FromUsername: "john_doe",
+			Data: "refresh", RawData: `{"id":"q1"}`, CreatedAt: sentAt.Add(2 * time.Minute)},
+	}}
+	updates := &fakeUpdateEventRepository{events: map[uint]*domain.UpdateEvent{
+		8: {ID: 8, BotID: 1, EventType: "inline_query", FromUserID: &userID, FromUsername: "john_doe",
+			RawData: `{"id":"iq1","query":"rain"}`, OccurredAt: sentAt},
+	}}
+
+	builder := NewEventBuilder(
+		&fakeBotRepository{bots: map[uint]*domain.Bot{1: &bot}},
+		&fakeChatRepository{chats: map[uint]*domain.Chat{
+			3: {ID: 3, BotID: 1, TelegramID: -100123, Type: "supergroup", Title: "Forecasts", Bot: bot},
+		}},
+		messages,
+		&fakeMediaFileRepository{files: map[uint]*domain.MediaFile{
+			11: {MessageID: 11, FileID: "AgAD", FileUniqueID: "uniq", MimeType: "image/jpeg", Status: domain.MediaStatusPending},
+		}},
+		callbacks,
+		updates,
+	)
+	return builder, messages, callbacks, updates
+}
+
+func TestMessageEventEnvelope(t *testing.T) {
+	builder, _, _, _ := newEventTestBuilder()
+
+	envelope, err := builder.MessageEvent(context.Background(), "new_message", 11)
+	require.NoError(t, err)
+
+	assert.Equal(t, domain.EventSchemaEnvelope, envelope.Version)
+	assert.Equal(t, "new_message", envelope.Event)
+	assert.Equal(t, time.Unix(1770638460, 0), envelope.CreatedAt)
+	require.NotNil(t, envelope.Bot)
+	assert.Equal(t, "weather_bot", envelope.Bot.Username)
+	require.NotNil(t, envelope.Chat)
+	assert.Equal(t, int64(-100123), envelope.Chat.TelegramID)
+
+	message := envelope.Message
+	require.NotNil(t, message)
+	assert.Equal(t, "photo", message.Type)
+	require.NotNil(t, message.From)
+	assert.Equal(t, int64(42), message.From.ID)
+	require.NotNil(t, message.Media)
+	assert.Equal(t, "AgAD", message.Media.FileID)
+	require.NotNil(t, message.ReplyTo)
+	assert.Equal(t, int64(500), message.ReplyTo.TelegramID)
+	require.NotNil(t, message.ReplyTo.MessageID)
+	assert.Equal(t, uint(10), *message.ReplyTo.MessageID)
+	assert.Equal(t, "Rain tomorrow", message.ReplyTo.Text)
+	assert.JSONEq(t, `{"message_id":501}`, string(envelope.Raw))
+
+	// Every consumer of the event sees the same ID
+	again, err := builder.MessageEvent(context.Background(), "new_message", 11)
+	require.NoError(t, err)
+	assert.Equal(t, envelope.ID, again.ID)
+	edited, err := builder.MessageEvent(context.Background(), "edited_message", 11)
+	require.NoError(t, err)
+	assert.NotEqual(t, envelope.ID, edited.ID)
+
+	stripped := envelope.WithoutRaw()
+	assert.Nil(t, stripped.Raw)
+	assert.NotNil(t, envelope.Raw, "the built envelope is left alone")
+
+	data, err := json.Marshal(stripped)
+	require.NoError(t, err)
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &fields))
+	for _, key := range []string{"version", "event", "id", "created_at", "bot", "chat", "message"} {
+		assert.Contains(t, fields, key)
+	}
+	assert.NotContains(t, fields, "raw")
+}
+
+func TestCallbackQueryEventEnvelope(t *testing.T) {
+	builder, _, _, _ := newEventTestBuilder()
+
+	envelope, err := builder.CallbackQueryEvent(context.Background(), 5)
+	require.NoError(t, err)
+
+	assert.Equal(t, "callback_query", envelope.Event)
+	require.NotNil(t, envelope.CallbackQuery)
+	assert.Equal(t, "q1", envelope.CallbackQuery.ID)
+	assert.Equal(t, "refresh", envelope.CallbackQuery.Data)
+	require.NotNil(t, envelope.Message, "the message holding the keyboard")
+	assert.Equal(t, uint(10), envelope.Message.ID)
+	assert.Nil(t, envelope.Message.From, "outgoing messages have no sender")
+	assert.JSONEq(t, `{"id":"q1"}`, string(envelope.Raw))
+}
+
+func TestUpdateEventEnvelopeWithoutChat(t *testing.T) {
+	builder, _, _, _ := newEventTestBuilder()
+
+	envelope, err := builder.UpdateEvent(context.Background(), 8)
+	require.NoError(t, err)
+
+	assert.Equal(t, "inline_query", envelope.Event)
+	require.NotNil(t, envelope.Bot)
+	assert.Equal(t, uint(1), envelope.Bot.ID)
+	assert.Nil(t, envelope.Chat)
+	assert.Nil(t, envelope.Message)
+	require.NotNil(t, envelope.Update)
+	require.NotNil(t, envelope.Update.From)
+	assert.Equal(t, "john_doe", envelope.Update.From.Username)
+	assert.JSONEq(t, `{"id":"iq1","query":"rain"}`, string(envelope.Update.Data))
+	assert.Nil(t, envelope.Raw)
+}
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func uintPtr(v uint) *uint { return &v }
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+)
+
+// FloodLimits are the Bot API send limits enforced by FloodControl.
+// Telegram allows about 30 messages per second per bot, one per second per chat
+// and 20 per minute per group.
+type FloodLimits struct {
+	BotMessagesPerSecond   int
+	ChatInterval           time.Duration
+	GroupMessagesPerMinute int
+}
+
+// FloodControl spaces the Bot API calls of every bot so they stay under Telegram's
+// flood limits. Slots are reserved in Redis, so the limits hold across gateway
+// instances. A nil FloodControl or one without Redis does not limit.
+type FloodControl struct {
+	client *redis.Client
+	limits FloodLimits
+}
+
+// NewFloodControl creates a flood controller
+func NewFloodControl(client *redis.Client, limits FloodLimits) *FloodControl {
+	return &FloodControl{
+		client: client,
+		limits: limits,
+	}
+}
+
+// reserveScript reserves the earliest time slot that every limit allows.
+// KEYS[1] holds the time a 429 response blocked the bot until; the other keys hold
+// the earliest time of the next call under each limit, spaced by the intervals in ARGV.
+// Returns {reserved, wait in ms}. Nothing is reserved when the wait exceeds the maximum.
+var reserveScript = redis.NewScript(`
+	local now = tonumber(ARGV[1])
+	local max_wait = tonumber(ARGV[2])
+
+	local at = now
+	for i = 1, #KEYS do
+		local next_at = tonumber(redis.call('GET', KEYS[i]) or '0')
+		if next_at > at then
+			at = next_at
+		end
+	end
+
+	if at - now > max_wait then
+		return {0, at - now}
+	end
+
+	for i = 2, #KEYS do
+		local interval = tonumber(ARGV[i + 1])
+		redis.call('SET', KEYS[i], at + interval, 'PX', at + interval - now + 1000)
+	end
+	return {1, at - now}
+`)
+
+// Reserve reserves the next slot for a call to a chat and returns how long to wait for it.
+// When the slot is more than maxWait away, nothing is reserved and ok is false.
+func (f *FloodControl) Reserve(ctx context.Context, chat *domain.Chat, maxWait time.Duration) (wait time.Duration, ok bool, err error) {
+	if f == nil || f.client == nil {
+		return 0, true, nil
+	}
+
+	keys := []string{floodBlockKey(chat), fmt.Sprintf("flood:bot:%d", chat.BotID), fmt.Sprintf("flood:chat:%d:%d", chat.BotID, chat.TelegramID)}
+	args := []interface{}{time.Now().UnixMilli(), maxWait.Milliseconds(), callSpacing(f.limits.BotMessagesPerSecond, time.Second), f.limits.ChatInterval.Milliseconds()}
+	if chat.Type == "group" || chat.Type == "supergroup" {
+		keys = append(keys, fmt.Sprintf("flood:group:%d:%d", chat.BotID, chat.TelegramID))
+		args = append(args, callSpacing(f.limits.GroupMessagesPerMinute, time.Minute))
+	}
+
+	result, err := reserveScript.Run(ctx, f.client, keys, args...).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(result) != 2 {
+		return 0, false, fmt.Errorf("unexpected result format")
+	}
+
+	return time.Duration(result[1]) * time.Millisecond, result[0] == 1, nil
+}
+
+// Block stops calls to a chat until Telegram's retry_after has passed. A 429 response
+// does not say whether the chat or the bot hit its limit, so every chat of the bot waits.
+func (f *FloodControl) Block(ctx context.Context, chat *domain.Chat, retryAfter time.Duration) error {
+	if f == nil || f.client == nil {
+		return nil
+	}
+
+	until := time.Now().Add(retryAfter).UnixMilli()
+	return f.client.Set(ctx, floodBlockKey(chat), until, retryAfter+time.Second).Err()
+}
+
+// floodBlockKey builds the Redis key holding the time calls through a chat's bot are blocked until
+func floodBlockKey(chat *domain.Chat) string {
+	return fmt.Sprintf("flood:block:%d", chat.BotID)
+}
+
+// callSpacing returns the milliseconds between calls allowed n times per period, 0 for no limit
+func callSpacing(n int, period time.Duration) int64 {
+	if n <= 0 {
+		return 0
+	}
+	return (period / time.Duration(n)).Milliseconds()
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+)
+
+func TestFloodBlockHoldsEveryChatOfTheBot(t *testing.T) {
+	flooded := &domain.Chat{ID: 9, BotID: 1, TelegramID: -100500, Type: "group"}
+	other := &domain.Chat{ID: 10, BotID: 1, TelegramID: 42, Type: "private"}
+	otherBot := &domain.Chat{ID: 11, BotID: 2, TelegramID: -100500, Type: "group"}
+
+	// Block writes the key that Reserve checks first for every chat
+	assert.Equal(t, floodBlockKey(flooded), floodBlockKey(other), "a 429 in one chat holds the other chats of the bot")
+	assert.NotEqual(t, floodBlockKey(flooded), floodBlockKey(otherBot), "other bots keep sending")
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/pkg/blobstore"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// Media errors
+var (
+	ErrMediaNotFound = errors.New("message has no media")
+	ErrMediaTooLarge = errors.New("media file is too large to download")
+)
+
+// MediaService copies files attached to received messages into blob storage and serves them.
+// Files are recorded as pending when the message arrives and downloaded by the media worker,
+// or on first request.
+type MediaService struct {
+	mediaRepo   repository.MediaFileRepository
+	botService  *BotService
+	store       blobstore.Store
+	maxFileSize int64
+}
+
+// NewMediaService creates a new media service. Files larger than maxFileSize bytes are not downloaded.
+func NewMediaService(mediaRepo repository.MediaFileRepository, botService *BotService, store blobstore.Store, maxFileSize int64) *MediaService {
+	return &MediaService{
+		mediaRepo:   mediaRepo,
+		botService:  botService,
+		store:       store,
+		maxFileSize: maxFileSize,
+	}
+}
+
+// MediaFileRef identifies the file attached to a Telegram message
+type MediaFileRef struct {
+	FileID       string
+	FileUniqueID string
+	FileName     string
+	MimeType     string
+	FileSize     int64 // 0 when Telegram did not report it
+}
+
+// MediaFileDTO represents a media file data transfer object
+type MediaFileDTO struct {
+	ID           uint       `json:"id"`
+	MessageID    uint       `json:"message_id"`
+	FileID       string     `json:"file_id"`
+	FileUniqueID string     `json:"file_unique_id"`
+	FileName     string     `json:"file_name,omitempty"`
+	MimeType     string     `json:"mime_type,omitempty"`
+	FileSize     int64      `json:"file_size,omitempty"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	StoredAt     *time.Time `json:"stored_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// RecordMedia records the file attached to a stored message for download
+func (s *MediaService) RecordMedia(ctx context.Context, botID, messageID uint, ref *MediaFileRef) (*MediaFileDTO, error) {
+	file := &domain.MediaFile{
+		MessageID:    messageID,
+		BotID:        botID,
+		FileID:       ref.FileID,
+		FileUniqueID: ref.FileUniqueID,
+		FileName:     ref.FileName,
+		MimeType:     ref.MimeType,
+		FileSize:     ref.FileSize,
+		Status:       domain.MediaStatusPending,
+	}
+	if s.exceedsLimit(ref.FileSize) {
+		file.Status = domain.MediaStatusTooLarge
+	}
+
+	if err := s.mediaRepo.Create(ctx, file); err != nil {
+		return nil, fmt.Errorf("failed to store media file: %w", err)
+	}
+
+	return toMediaFileDTO(file), nil
+}
+
+// GetMedia retrieves the media file of a message
+func (s *MediaService) GetMedia(ctx context.Context, messageID uint) (*MediaFileDTO, error) {
+	file, err := s.mediaRepo.GetByMessageID(ctx, messageID)
+	if err != nil {
+		return nil, ErrMediaNotFound
+	}
+
+	return toMediaFileDTO(file), nil
+}
+
+// OpenMedia opens the stored copy of a message's file, downloading it first if needed,
+// and returns its stored size, -1 when the store does not report it. The caller closes the reader.
+func (s *MediaService) OpenMedia(ctx context.Context, messageID uint) (io.ReadCloser, int64, *MediaFileDTO, error) {
+	file, err := s.mediaRepo.GetByMessageID(ctx, messageID)
+	if err != nil {
+		return nil, 0, nil, ErrMediaNotFound
+	}
+
+	if file.Status == domain.MediaStatusTooLarge {
+		return nil, 0, toMediaFileDTO(file), ErrMediaTooLarge
+	}
+
+	if file.Status == domain.MediaStatusStored {
+		body, size, err := s.store.Get(ctx, file.StorageKey)
+		if err == nil {
+			return body, size, toMediaFileDTO(file), nil
+		}
+		if !errors.Is(err, blobstore.ErrNotFound) {
+			return nil, 0, nil, fmt.Errorf("failed to read media file: %w", err)
+		}
+		// The blob was removed from storage; download it again
+	}
+
+	if err := s.fetch(ctx, file); err != nil {
+		return nil, 0, toMediaFileDTO(file), err
+	}
+
+	body, size, err := s.store.Get(ctx, file.StorageKey)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("failed to read media file: %w", err)
+	}
+	return body, size, toMediaFileDTO(file), nil
+}
+
+// FetchPending downloads up to limit pending files and returns how many were processed
+func (s *MediaService) FetchPending(ctx context.Context, limit int) (int, error) {
+	files, err := s.mediaRepo.ListPending(ctx, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pending media: %w", err)
+	}
+
+	for i := range files {
+		if ctx.Err() != nil {
+			return i, ctx.Err()
+		}
+		// Failures are recorded on the file and retried when it is requested
+		_ = s.fetch(ctx, &files[i])
+	}
+
+	return len(files), nil
+}
+
+// fetch downloads a file from Telegram into blob storage and records the outcome
+func (s *MediaService) fetch(ctx context.Context, file *domain.MediaFile) error {
+	fetchErr := s.download(ctx, file)
+	switch {
+	case fetchErr == nil:
+		now := time.Now()
+		file.Status = domain.MediaStatusStored
+		file.Error = ""
+		file.StoredAt = &now
+	case errors.Is(fetchErr, ErrMediaTooLarge):
+		file.Status = domain.MediaStatusTooLarge
+		file.Error = ""
+	default:
+		file.Status = domain.MediaStatusFailed
+		file.Error = fetchErr.Error()
+	}
+
+	if err := s.mediaRepo.Update(ctx, file); err != nil {
+		return fmt.Errorf("failed to update media file: %w", err)
+	}
+	return fetchErr
+}
+
+// download resolves the file with getFile and copies it to the blob store
+func (s *MediaService) download(ctx context.Context, file *domain.MediaFile) error {
+	tgFile, err := s.botService.GetFile(ctx, file.BotID, file.FileID)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Code == 400 && apiErr.Description == "Bad Request: file is too big" {
+			return ErrMediaTooLarge
+		}
+		return fmt.Errorf("getFile failed: %w", err)
+	}
+	if tgFile.FileSize > 0 {
+		file.FileSize = tgFile.FileSize
+	}
+	if s.exceedsLimit(file.FileSize) {
+		return ErrMediaTooLarge
+	}
+
+	body, err := s.botService.DownloadFile(ctx, file.BotID, tgFile.FilePath)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	ext := path.Ext(tgFile.FilePath)
+	if file.MimeType == "" {
+		file.MimeType = mime.TypeByExtension(ext)
+	}
+
+	uniqueID := file.FileUniqueID
+	if uniqueID == "" {
+		uniqueID = tgFile.FileUniqueID
+	}
+	key := fmt.Sprintf("%d/%s%s", file.BotID, uniqueID, ext)
+
+	size := file.FileSize
+	if size == 0 {
+		size = -1
+	}
+	if err := s.store.Put(ctx, key, body, size, file.MimeType); err != nil {
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+
+	file.StorageKey = key
+	return nil
+}
+
+// exceedsLimit reports whether a file of the given size may not be downloaded
+func (s *MediaService) exceedsLimit(size int64) bool {
+	return s.maxFileSize > 0 && size > s.maxFileSize
+}
+
+// toMediaFileDTO converts a stored media file to its DTO
+func toMediaFileDTO(file *domain.MediaFile) *MediaFileDTO {
+	return &MediaFileDTO{
+		ID:           file.ID,
+		MessageID:    file.MessageID,
+		FileID:       file.FileID,
+		FileUniqueID: file.FileUniqueID,
+		FileName:     file.FileName,
+		MimeType:     file.MimeType,
+		FileSize:     file.FileSize,
+		Status:       file.Status,
+		Error:        file.Error,
+		StoredAt:     file.StoredAt,
+		CreatedAt:    file.CreatedAt,
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/pkg/blobstore"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// fakeMediaFileRepository keeps media files in memory, keyed by message ID
+type fakeMediaFileRepository struct {
+	repository.MediaFileRepository
+	files map[uint]*domain.MediaFile
+}
+
+func (r *fakeMediaFileRepository) Create(ctx context.Context, file *domain.MediaFile) error {
+	file.ID = uint(len(r.files) + 1)
+	r.files[file.MessageID] = file
+	return nil
+}
+
+func (r *fakeMediaFileRepository) GetByMessageID(ctx context.Context, messageID uint) (*domain.MediaFile, error) {
+	file, ok := r.files[messageID]
+	if !ok {
+		return nil, assert.AnError
+	}
+	copied := *file
+	return &copied, nil
+}
+
+func (r *fakeMediaFileRepository) Update(ctx context.Context, file *domain.MediaFile) error {
+	r.files[file.MessageID] = file
+	return nil
+}
+
+func TestOpenMediaDownloadsOnce(t *testing.T) {
+	var getFileCalls, downloads int
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bot123:abc/getFile":
+			getFileCalls++
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"file_id":"AgAD","file_unique_id":"uniq","file_size":4,"file_path":"photos/file_1.jpg"}}`))
+		case "/file/bot123:abc/photos/file_1.jpg":
+			downloads++
+			_, _ = w.Write([]byte("jpeg"))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer api.Close()
+
+	botRepo := &fakeBotRepository{bots: map[uint]*domain.Bot{}}
+	botService := NewBotService(botRepo, testKeyring(t), "", api.URL, nil)
+	encrypted, err := botService.encryptToken("123:abc")
+	require.NoError(t, err)
+	botRepo.bots[1] = &domain.Bot{ID: 1, Token: encrypted}
+
+	store, err := blobstore.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	repo := &fakeMediaFileRepository{files: map[uint]*domain.MediaFile{}}
+	svc := NewMediaService(repo, botService, store, 1024)
+
+	recorded, err := svc.RecordMedia(context.Background(), 1, 10, &MediaFileRef{FileID: "AgAD", FileUniqueID: "uniq", MimeType: "image/jpeg"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.MediaStatusPending, recorded.Status)
+
+	for i := 0; i < 2; i++ {
+		body, size, file, err := svc.OpenMedia(context.Background(), 10)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(body)
+		body.Close()
+
+		assert.Equal(t, "jpeg", string(data))
+		assert.Equal(t, int64(4), size)
+		assert.Equal(t, domain.MediaStatusStored, file.Status)
+		assert.Equal(t, int64(4), file.FileSize)
+	}
+
+	assert.Equal(t, 1, getFileCalls)
+	assert.Equal(t, 1, downloads)
+	assert.Equal(t, "1/uniq.jpg", repo.files[10].StorageKey)
+}
+
+func TestRecordMediaOverSizeLimit(t *testing.T) {
+	repo := &fakeMediaFileRepository{files: map[uint]*domain.MediaFile{}}
+	svc := NewMediaService(repo, nil, nil, 1024)
+
+	recorded, err := svc.RecordMedia(context.Background(), 1, 10, &MediaFileRef{FileID: "BQAD", FileSize: 4096})
+	require.NoError(t, err)
+	assert.Equal(t, domain.MediaStatusTooLarge, recorded.Status)
+
+	_, _, _, err = svc.OpenMedia(context.Background(), 10)
+	assert.ErrorIs(t, err, ErrMediaTooLarge)
+
+	_, _, _, err = svc.OpenMedia(context.Background(), 11)
+	assert.ErrorIs(t, err, ErrMediaNotFound)
+}
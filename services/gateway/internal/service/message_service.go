@@ -2,13 +2,19 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"gorm.io/gorm"
+
 	"github.com/kexi/telegram-bot-gateway/internal/domain"
 	"github.com/kexi/telegram-bot-gateway/internal/repository"
 )
 
+// ErrMessageNotFound is returned when a Telegram message was never stored by the gateway
+var ErrMessageNotFound = errors.New("message not found")
+
 // MessageService handles message operations
 type MessageService struct {
 	messageRepo repository.MessageRepository
@@ -36,13 +42,22 @@ type MessageDTO struct {
 	FromFirstName  string    `json:"from_first_name,omitempty"`
 	FromLastName   string    `json:"from_last_name,omitempty"`
 	Direction      string    `json:"direction"`
+	SentByAPIKeyID *uint     `json:"sent_by_api_key_id,omitempty"`
 	MessageType    string    `json:"message_type"`
 	Text           string    `json:"text,omitempty"`
 	ReplyToMessageID *int64  `json:"reply_to_message_id,omitempty"`
 	SentAt         time.Time `json:"sent_at"`
+	EditedAt       *time.Time `json:"edited_at,omitempty"`
+	IsDeleted      bool      `json:"is_deleted,omitempty"`
 	CreatedAt      time.Time `json:"created_at"`
 }
 
+// MessageEditDTO represents a previous version of an edited message
+type MessageEditDTO struct {
+	Text     string    `json:"text"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
 // CreateMessageRequest represents a message creation request
 type CreateMessageRequest struct {
 	ChatID           uint      `json:"chat_id"`
@@ -52,6 +67,7 @@ type CreateMessageRequest struct {
 	FromFirstName    string    `json:"from_first_name,omitempty"`
 	FromLastName     string    `json:"from_last_name,omitempty"`
 	Direction        string    `json:"direction"`
+	SentByAPIKeyID   *uint     `json:"sent_by_api_key_id,omitempty"`
 	MessageType      string    `json:"message_type"`
 	Text             string    `json:"text,omitempty"`
 	RawData          string    `json:"raw_data,omitempty"`
@@ -75,6 +91,7 @@ func (s *MessageService) StoreMessage(ctx context.Context, req *CreateMessageReq
 		FromFirstName:    req.FromFirstName,
 		FromLastName:     req.FromLastName,
 		Direction:        req.Direction,
+		SentByAPIKeyID:   req.SentByAPIKeyID,
 		MessageType:      req.MessageType,
 		Text:             req.Text,
 		RawData:          req.RawData,
@@ -86,21 +103,7 @@ func (s *MessageService) StoreMessage(ctx context.Context, req *CreateMessageReq
 		return nil, fmt.Errorf("failed to store message: %w", err)
 	}
 
-	return &MessageDTO{
-		ID:               message.ID,
-		ChatID:           message.ChatID,
-		TelegramID:       message.TelegramID,
-		FromUserID:       message.FromUserID,
-		FromUsername:     message.FromUsername,
-		FromFirstName:    message.FromFirstName,
-		FromLastName:     message.FromLastName,
-		Direction:        message.Direction,
-		MessageType:      message.MessageType,
-		Text:             message.Text,
-		ReplyToMessageID: message.ReplyToMessageID,
-		SentAt:           message.SentAt,
-		CreatedAt:        message.CreatedAt,
-	}, nil
+	return toMessageDTO(message), nil
 }
 
 // GetMessage retrieves a message by ID
@@ -110,21 +113,7 @@ func (s *MessageService) GetMessage(ctx context.Context, id uint) (*MessageDTO,
 		return nil, fmt.Errorf("message not found: %w", err)
 	}
 
-	return &MessageDTO{
-		ID:               message.ID,
-		ChatID:           message.ChatID,
-		TelegramID:       message.TelegramID,
-		FromUserID:       message.FromUserID,
-		FromUsername:     message.FromUsername,
-		FromFirstName:    message.FromFirstName,
-		FromLastName:     message.FromLastName,
-		Direction:        message.Direction,
-		MessageType:      message.MessageType,
-		Text:             message.Text,
-		ReplyToMessageID: message.ReplyToMessageID,
-		SentAt:           message.SentAt,
-		CreatedAt:        message.CreatedAt,
-	}, nil
+	return toMessageDTO(message), nil
 }
 
 // ListMessages retrieves messages for a chat with cursor-based pagination
@@ -139,22 +128,8 @@ func (s *MessageService) ListMessages(ctx context.Context, chatID uint, cursor *
 	}
 
 	result := make([]MessageDTO, len(messages))
-	for i, msg := range messages {
-		result[i] = MessageDTO{
-			ID:               msg.ID,
-			ChatID:           msg.ChatID,
-			TelegramID:       msg.TelegramID,
-			FromUserID:       msg.FromUserID,
-			FromUsername:     msg.FromUsername,
-			FromFirstName:    msg.FromFirstName,
-			FromLastName:     msg.FromLastName,
-			Direction:        msg.Direction,
-			MessageType:      msg.MessageType,
-			Text:             msg.Text,
-			ReplyToMessageID: msg.ReplyToMessageID,
-			SentAt:           msg.SentAt,
-			CreatedAt:        msg.CreatedAt,
-		}
+	for i := range messages {
+		result[i] = *toMessageDTO(&messages[i])
 	}
 
 	return result, nil
@@ -168,22 +143,73 @@ func (s *MessageService) ListMessagesByReply(ctx context.Context, replyToMessage
 	}
 
 	result := make([]MessageDTO, len(messages))
-	for i, msg := range messages {
-		result[i] = MessageDTO{
-			ID:               msg.ID,
-			ChatID:           msg.ChatID,
-			TelegramID:       msg.TelegramID,
-			FromUserID:       msg.FromUserID,
-			FromUsername:     msg.FromUsername,
-			FromFirstName:    msg.FromFirstName,
-			FromLastName:     msg.FromLastName,
-			Direction:        msg.Direction,
-			MessageType:      msg.MessageType,
-			Text:             msg.Text,
-			ReplyToMessageID: msg.ReplyToMessageID,
-			SentAt:           msg.SentAt,
-			CreatedAt:        msg.CreatedAt,
+	for i := range messages {
+		result[i] = *toMessageDTO(&messages[i])
+	}
+
+	return result, nil
+}
+
+// GetMessageByTelegramID retrieves a stored message by its Telegram message ID in a chat
+func (s *MessageService) GetMessageByTelegramID(ctx context.Context, chatID uint, telegramID int64) (*MessageDTO, error) {
+	message, err := s.messageRepo.GetByChatAndTelegramID(ctx, chatID, telegramID)
+	if err != nil {
+		return nil, fmt.Errorf("message not found: %w", err)
+	}
+
+	return toMessageDTO(message), nil
+}
+
+// RecordEdit replaces a stored message's text, keeping the previous text in its edit history
+func (s *MessageService) RecordEdit(ctx context.Context, chatID uint, telegramID int64, text string, editedAt time.Time) (*MessageDTO, error) {
+	message, err := s.messageRepo.GetByChatAndTelegramID(ctx, chatID, telegramID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
 		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	edit := &domain.MessageEdit{
+		MessageID: message.ID,
+		Text:      message.Text,
+		EditedAt:  editedAt,
+	}
+	message.Text = text
+	message.EditedAt = &editedAt
+
+	if err := s.messageRepo.RecordEdit(ctx, message, edit); err != nil {
+		return nil, fmt.Errorf("failed to record edit: %w", err)
+	}
+
+	return toMessageDTO(message), nil
+}
+
+// MarkDeleted flags a stored message as deleted from its Telegram chat
+func (s *MessageService) MarkDeleted(ctx context.Context, chatID uint, telegramID int64) (*MessageDTO, error) {
+	message, err := s.messageRepo.GetByChatAndTelegramID(ctx, chatID, telegramID)
+	if err != nil {
+		return nil, fmt.Errorf("message not found: %w", err)
+	}
+
+	message.IsDeleted = true
+	if err := s.messageRepo.Update(ctx, message); err != nil {
+		return nil, fmt.Errorf("failed to mark message deleted: %w", err)
+	}
+
+	return toMessageDTO(message), nil
+}
+
+// ListEdits retrieves the edit history of a message, oldest first
+func (s *MessageService) ListEdits(ctx context.Context, messageID uint) ([]MessageEditDTO, error) {
+	edits, err := s.messageRepo.ListEdits(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list edits: %w", err)
+	}
+
+	result := make([]MessageEditDTO, len(edits))
+	for i, edit := range edits {
+		result[i] = MessageEditDTO{Text: edit.Text, EditedAt: edit.EditedAt}
 	}
 
 	return result, nil
@@ -193,3 +219,25 @@ func (s *MessageService) ListMessagesByReply(ctx context.Context, replyToMessage
 func (s *MessageService) CleanupOldMessages(ctx context.Context, cutoff time.Time) error {
 	return s.messageRepo.DeleteOlderThan(ctx, cutoff)
 }
+
+// toMessageDTO converts a message model to its DTO
+func toMessageDTO(message *domain.Message) *MessageDTO {
+	return &MessageDTO{
+		ID:               message.ID,
+		ChatID:           message.ChatID,
+		TelegramID:       message.TelegramID,
+		FromUserID:       message.FromUserID,
+		FromUsername:     message.FromUsername,
+		FromFirstName:    message.FromFirstName,
+		FromLastName:     message.FromLastName,
+		Direction:        message.Direction,
+		SentByAPIKeyID:   message.SentByAPIKeyID,
+		MessageType:      message.MessageType,
+		Text:             message.Text,
+		ReplyToMessageID: message.ReplyToMessageID,
+		SentAt:           message.SentAt,
+		EditedAt:         message.EditedAt,
+		IsDeleted:        message.IsDeleted,
+		CreatedAt:        message.CreatedAt,
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+)
+
+// Outbound queue errors
+var (
+	ErrAsyncUpload      = errors.New("async sending does not support file uploads")
+	ErrOutboundNotFound = errors.New("outbound message not found")
+	ErrSendAtPast       = errors.New("send_at must be in the future")
+)
+
+// outboundStaleAfter is how long a claimed send may stay in "sending" before it is
+// assumed abandoned by a stopped worker and queued again
+const outboundStaleAfter = 5 * time.Minute
+
+// outboundMaxSlotWait is how long a worker waits for a flood control slot before
+// putting a send back in the queue until the slot comes up
+const outboundMaxSlotWait = time.Second
+
+// OutboundMessageDTO reports the delivery status of a queued send
+type OutboundMessageDTO struct {
+	ID             uint         `json:"id"`
+	ChatID         uint         `json:"chat_id"`
+	BotID          uint         `json:"bot_id"`
+	Method         string       `json:"method"`
+	SentByAPIKeyID *uint        `json:"sent_by_api_key_id,omitempty"`
+	SentByUserID   *uint        `json:"sent_by_user_id,omitempty"`
+	Status         string       `json:"status"`
+	Attempts       int          `json:"attempts"`
+	LastError      string       `json:"last_error,omitempty"`
+	ScheduledAt    *time.Time   `json:"scheduled_at,omitempty"`    // Requested send time of a scheduled send
+	NextAttemptAt  *time.Time   `json:"next_attempt_at,omitempty"` // Set while queued
+	SentAt         *time.Time   `json:"sent_at,omitempty"`
+	Messages       []MessageDTO `json:"messages,omitempty"` // Recorded messages, once sent
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// Queue stores a prepared call for delivery by the outbound workers and returns its status.
+// A call with SendAt set is held back as scheduled until then.
+// Uploads cannot be queued; send them synchronously or by URL or file_id.
+func (s *OutboundService) Queue(ctx context.Context, call *OutboundCall) (*OutboundMessageDTO, error) {
+	if len(call.files) > 0 {
+		return nil, ErrAsyncUpload
+	}
+	if call.opts.SendAt != nil && !call.opts.SendAt.After(time.Now()) {
+		return nil, ErrSendAtPast
+	}
+
+	params, err := json.Marshal(call.params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode params: %w", err)
+	}
+
+	msg := &domain.OutboundMessage{
+		ChatID:         call.chat.ID,
+		BotID:          call.chat.BotID,
+		Method:         call.method,
+		Params:         string(params),
+		SentByAPIKeyID: call.opts.SentByAPIKeyID,
+		SentByUserID:   call.opts.SentByUserID,
+		Status:         domain.OutboundStatusQueued,
+		NextAttemptAt:  time.Now(),
+	}
+	if call.opts.SendAt != nil {
+		sendAt := call.opts.SendAt.UTC()
+		msg.Status = domain.OutboundStatusScheduled
+		msg.ScheduledAt = &sendAt
+		msg.NextAttemptAt = sendAt
+	}
+	if call.sourceChat != nil {
+		msg.SourceChatID = &call.sourceChat.ID
+	}
+
+	if err := s.outboundRepo.Create(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to queue message: %w", err)
+	}
+
+	return s.toOutboundDTO(ctx, msg), nil
+}
+
+// GetQueued returns the delivery status of a queued send
+func (s *OutboundService) GetQueued(ctx context.Context, id uint) (*OutboundMessageDTO, error) {
+	msg, err := s.outboundRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrOutboundNotFound
+	}
+
+	return s.toOutboundDTO(ctx, msg), nil
+}
+
+// DeliverQueued sends up to limit due messages and returns how many were handled.
+// Messages to the same chat are delivered one at a time in the order they were queued.
+func (s *OutboundService) DeliverQueued(ctx context.Context, limit int) (int, error) {
+	now := time.Now()
+	if requeued, err := s.outboundRepo.RequeueStale(ctx, now.Add(-outboundStaleAfter)); err != nil {
+		log.Printf("Failed to requeue stale outbound messages: %v", err)
+	} else if requeued > 0 {
+		log.Printf("Requeued %d stale outbound messages", requeued)
+	}
+
+	due, err := s.outboundRepo.ClaimDue(ctx, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbound messages: %w", err)
+	}
+
+	for i := range due {
+		s.deliver(ctx, &due[i])
+	}
+
+	return len(due), nil
+}
+
+// deliver makes one delivery attempt for a claimed message. Flood waits and 429 responses
+// put it back in the queue without using up an attempt; other transient failures are
+// retried with backoff until the policy's attempts are used up.
+func (s *OutboundService) deliver(ctx context.Context, msg *domain.OutboundMessage) {
+	call, err := s.callFromQueued(msg)
+	if err != nil {
+		s.finishQueued(ctx, msg, domain.OutboundStatusFailed, err.Error())
+		return
+	}
+
+	wait, ok, err := s.floodControl.Reserve(ctx, call.chat, outboundMaxSlotWait)
+	if err != nil {
+		log.Printf("Flood control unavailable for chat %d: %v", call.chat.ID, err)
+	} else if !ok {
+		s.requeue(ctx, msg, wait, "")
+		return
+	} else if wait > 0 {
+		select {
+		case <-ctx.Done():
+			s.requeue(ctx, msg, 0, "")
+			return
+		case <-time.After(wait):
+		}
+	}
+
+	msg.Attempts++
+	result, err := s.botService.CallBotAPI(ctx, call.chat.BotID, call.method, call.params, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Shutting down; try again on the next start
+			s.requeue(ctx, msg, 0, err.Error())
+			return
+		}
+
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests {
+			delay, _ := s.retryDelay(ctx, call.chat, err, msg.Attempts)
+			msg.Attempts--
+			s.requeue(ctx, msg, delay, err.Error())
+			return
+		}
+
+		delay, retry := s.retryDelay(ctx, call.chat, err, msg.Attempts)
+		if retry && msg.Attempts < max(s.policy.MaxAttempts, 1) {
+			s.requeue(ctx, msg, delay, err.Error())
+			return
+		}
+
+		s.finishQueued(ctx, msg, domain.OutboundStatusFailed, err.Error())
+		return
+	}
+
+	now := time.Now()
+	msg.SentAt = &now
+	lastError := ""
+	messages, err := s.record(ctx, call, result)
+	if err != nil {
+		// Telegram accepted the message, so it must not be sent again
+		lastError = err.Error()
+	}
+
+	ids := make([]uint, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+	}
+	encoded, _ := json.Marshal(ids)
+	msg.MessageIDs = string(encoded)
+
+	s.finishQueued(ctx, msg, domain.OutboundStatusSent, lastError)
+}
+
+// requeue puts a claimed message back in the queue until after the delay
+func (s *OutboundService) requeue(ctx context.Context, msg *domain.OutboundMessage, delay time.Duration, lastError string) {
+	msg.NextAttemptAt = time.Now().Add(delay)
+	if lastError != "" {
+		msg.LastError = lastError
+	}
+	s.finishQueued(ctx, msg, domain.OutboundStatusQueued, msg.LastError)
+}
+
+// finishQueued saves the outcome of a delivery attempt. It is saved even when ctx is
+// cancelled so a stopping worker does not leave the message claimed.
+func (s *OutboundService) finishQueued(ctx context.Context, msg *domain.OutboundMessage, status, lastError string) {
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+
+	msg.Status = status
+	msg.LastError = lastError
+	if err := s.outboundRepo.Update(ctx, msg); err != nil {
+		log.Printf("Failed to update outbound message %d: %v", msg.ID, err)
+	}
+}
+
+// callFromQueued rebuilds the prepared call of a queued message
+func (s *OutboundService) callFromQueued(msg *domain.OutboundMessage) (*OutboundCall, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(msg.Params)))
+	decoder.UseNumber()
+
+	var params map[string]interface{}
+	if err := decoder.Decode(&params); err != nil {
+		return nil, fmt.Errorf("failed to decode params: %w", err)
+	}
+
+	opts := &SendOptions{SentByAPIKeyID: msg.SentByAPIKeyID}
+	if replyTo, ok := paramInt64(params, "reply_to_message_id"); ok {
+		opts.ReplyToMessageID = &replyTo
+	}
+
+	return &OutboundCall{
+		chat:       &msg.Chat,
+		sourceChat: msg.SourceChat,
+		method:     msg.Method,
+		params:     params,
+		opts:       opts,
+	}, nil
+}
+
+// toOutboundDTO converts a queued message to its status report
+func (s *OutboundService) toOutboundDTO(ctx context.Context, msg *domain.OutboundMessage) *OutboundMessageDTO {
+	dto := &OutboundMessageDTO{
+		ID:             msg.ID,
+		ChatID:         msg.ChatID,
+		BotID:          msg.BotID,
+		Method:         msg.Method,
+		SentByAPIKeyID: msg.SentByAPIKeyID,
+		SentByUserID:   msg.SentByUserID,
+		ScheduledAt:    msg.ScheduledAt,
+		Status:         msg.Status,
+		Attempts:       msg.Attempts,
+		LastError:      msg.LastError,
+		SentAt:         msg.SentAt,
+		CreatedAt:      msg.CreatedAt,
+	}
+	if msg.Status == domain.OutboundStatusQueued {
+		nextAttemptAt := msg.NextAttemptAt
+		dto.NextAttemptAt = &nextAttemptAt
+	}
+
+	var ids []uint
+	if msg.MessageIDs != "" && json.Unmarshal([]byte(msg.MessageIDs), &ids) == nil {
+		for _, id := range ids {
+			if stored, err := s.messageService.GetMessage(ctx, id); err == nil {
+				dto.Messages = append(dto.Messages, *stored)
+			}
+		}
+	}
+
+	return dto
+}
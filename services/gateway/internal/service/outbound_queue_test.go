@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// fakeOutboundMessageRepository keeps queued sends in memory
+type fakeOutboundMessageRepository struct {
+	repository.OutboundMessageRepository
+	chat     *domain.Chat
+	messages map[uint]*domain.OutboundMessage
+}
+
+func (r *fakeOutboundMessageRepository) Create(ctx context.Context, msg *domain.OutboundMessage) error {
+	msg.ID = uint(len(r.messages) + 1)
+	msg.CreatedAt = time.Now()
+	r.messages[msg.ID] = msg
+	return nil
+}
+
+func (r *fakeOutboundMessageRepository) GetByID(ctx context.Context, id uint) (*domain.OutboundMessage, error) {
+	msg, ok := r.messages[id]
+	if !ok {
+		return nil, assert.AnError
+	}
+	copied := *msg
+	return &copied, nil
+}
+
+func (r *fakeOutboundMessageRepository) ClaimDue(ctx context.Context, now time.Time, limit int) ([]domain.OutboundMessage, error) {
+	var due []domain.OutboundMessage
+	for _, msg := range r.messages {
+		if msg.Status == domain.OutboundStatusQueued && !msg.NextAttemptAt.After(now) && len(due) < limit {
+			msg.Status = domain.OutboundStatusSending
+			claimed := *msg
+			claimed.Chat = *r.chat
+			due = append(due, claimed)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeOutboundMessageRepository) RequeueStale(ctx context.Context, claimedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeOutboundMessageRepository) ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]domain.OutboundMessage, error) {
+	var due []domain.OutboundMessage
+	for _, msg := range r.messages {
+		if msg.Status == domain.OutboundStatusScheduled && !msg.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, *msg)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeOutboundMessageRepository) Transition(ctx context.Context, id uint, from, to, lastError string) (bool, error) {
+	msg, ok := r.messages[id]
+	if !ok || msg.Status != from {
+		return false, nil
+	}
+	msg.Status = to
+	msg.LastError = lastError
+	return true, nil
+}
+
+func (r *fakeOutboundMessageRepository) Reschedule(ctx context.Context, id uint, sendAt time.Time) (bool, error) {
+	msg, ok := r.messages[id]
+	if !ok || msg.Status != domain.OutboundStatusScheduled {
+		return false, nil
+	}
+	msg.ScheduledAt = &sendAt
+	msg.NextAttemptAt = sendAt
+	return true, nil
+}
+
+func (r *fakeOutboundMessageRepository) Update(ctx context.Context, msg *domain.OutboundMessage) error {
+	saved := *msg
+	saved.Chat = domain.Chat{}
+	r.messages[msg.ID] = &saved
+	return nil
+}
+
+func TestQueuedSendWaitsOutFloodControl(t *testing.T) {
+	flooded := true
+	svc, messageRepo, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if flooded {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 7","parameters":{"retry_after":7}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":40,"date":0,"text":"later","reply_to_message":{"message_id":39}}}`))
+	})
+	repo := &fakeOutboundMessageRepository{chat: chat, messages: map[uint]*domain.OutboundMessage{}}
+	svc.outboundRepo = repo
+	svc.policy = SendPolicy{MaxAttempts: 3}
+
+	ctx := context.Background()
+	replyTo := int64(39)
+	apiKeyID := uint(4)
+	call, err := NewTextCall(chat, "later", &SendOptions{ReplyToMessageID: &replyTo, SentByAPIKeyID: &apiKeyID})
+	require.NoError(t, err)
+
+	queued, err := svc.Queue(ctx, call)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OutboundStatusQueued, queued.Status)
+	require.NotNil(t, queued.NextAttemptAt)
+
+	// A 429 puts the send back without using up an attempt
+	delivered, err := svc.DeliverQueued(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	status, err := svc.GetQueued(ctx, queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OutboundStatusQueued, status.Status)
+	assert.Equal(t, 0, status.Attempts)
+	assert.Contains(t, status.LastError, "retry after 7")
+	require.NotNil(t, status.NextAttemptAt)
+	assert.WithinDuration(t, time.Now().Add(7*time.Second), *status.NextAttemptAt, 2*time.Second)
+
+	delivered, err = svc.DeliverQueued(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered, "not due before retry_after")
+
+	flooded = false
+	repo.messages[queued.ID].NextAttemptAt = time.Now()
+	_, err = svc.DeliverQueued(ctx, 10)
+	require.NoError(t, err)
+
+	status, err = svc.GetQueued(ctx, queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OutboundStatusSent, status.Status)
+	assert.Equal(t, 1, status.Attempts)
+	assert.Empty(t, status.LastError)
+	assert.Nil(t, status.NextAttemptAt)
+	require.Len(t, status.Messages, 1)
+	assert.Equal(t, int64(40), status.Messages[0].TelegramID)
+
+	require.Len(t, messageRepo.messages, 1)
+	require.NotNil(t, messageRepo.messages[0].SentByAPIKeyID)
+	assert.Equal(t, apiKeyID, *messageRepo.messages[0].SentByAPIKeyID)
+}
+
+func TestQueuedSendFailsOnClientError(t *testing.T) {
+	svc, _, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`))
+	})
+	repo := &fakeOutboundMessageRepository{chat: chat, messages: map[uint]*domain.OutboundMessage{}}
+	svc.outboundRepo = repo
+	svc.policy = SendPolicy{MaxAttempts: 3}
+
+	ctx := context.Background()
+	call, err := NewTextCall(chat, "hello", &SendOptions{})
+	require.NoError(t, err)
+	queued, err := svc.Queue(ctx, call)
+	require.NoError(t, err)
+
+	_, err = svc.DeliverQueued(ctx, 10)
+	require.NoError(t, err)
+
+	status, err := svc.GetQueued(ctx, queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OutboundStatusFailed, status.Status)
+	assert.Equal(t, 1, status.Attempts)
+	assert.Contains(t, status.LastError, "blocked by the user")
+}
+
+func TestQueueRejectsUploads(t *testing.T) {
+	chat := &domain.Chat{ID: 9, BotID: 1, TelegramID: -100500}
+	call, err := NewMediaCall(chat, &SendMediaRequest{Type: MediaTypePhoto, File: &InputFile{FileName: "a.jpg"}})
+	require.NoError(t, err)
+
+	svc := NewOutboundService(nil, nil, nil, nil, SendPolicy{}, nil, nil)
+	_, err = svc.Queue(context.Background(), call)
+	assert.ErrorIs(t, err, ErrAsyncUpload)
+}
@@ -0,0 +1,763 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/pubsub"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// InvalidSendError is a send rejected before calling Telegram because the request is invalid
+type InvalidSendError struct {
+	Message string
+}
+
+func (e *InvalidSendError) Error() string {
+	return e.Message
+}
+
+// invalidSend returns an InvalidSendError
+func invalidSend(format string, args ...interface{}) error {
+	return &InvalidSendError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Media types accepted by SendMedia
+const (
+	MediaTypePhoto    = "photo"
+	MediaTypeDocument = "document"
+	MediaTypeVoice    = "voice"
+)
+
+// OutboundService sends messages through the Bot API, records them as outgoing messages
+// and publishes them to real-time subscribers. Calls are spaced by FloodControl and
+// transient failures are retried; sends can also be queued for the outbound workers.
+type OutboundService struct {
+	botService     *BotService
+	messageService *MessageService
+	outboundRepo   repository.OutboundMessageRepository
+	floodControl   *FloodControl
+	policy         SendPolicy
+	messageBroker  *pubsub.MessageBroker
+	eventBuilder   *EventBuilder
+}
+
+// SendPolicy controls how outbound Bot API calls are retried
+type SendPolicy struct {
+	MaxAttempts int           // Bot API calls per send before giving up on transient failures
+	MaxSyncWait time.Duration // Longest a synchronous send waits for flood control or a retry
+}
+
+// NewOutboundService creates a new outbound message service
+func NewOutboundService(
+	botService *BotService,
+	messageService *MessageService,
+	outboundRepo repository.OutboundMessageRepository,
+	floodControl *FloodControl,
+	policy SendPolicy,
+	messageBroker *pubsub.MessageBroker,
+	eventBuilder *EventBuilder,
+) *OutboundService {
+	return &OutboundService{
+		botService:     botService,
+		messageService: messageService,
+		outboundRepo:   outboundRepo,
+		floodControl:   floodControl,
+		policy:         policy,
+		messageBroker:  messageBroker,
+		eventBuilder:   eventBuilder,
+	}
+}
+
+// SendOptions holds the options shared by Bot API send methods
+type SendOptions struct {
+	ParseMode           string          `json:"parse_mode,omitempty"`
+	ReplyToMessageID    *int64          `json:"reply_to_message_id,omitempty"`
+	DisableNotification bool            `json:"disable_notification,omitempty"`
+	MessageThreadID     *int64          `json:"message_thread_id,omitempty"` // Forum topic ID
+	ReplyMarkup         json.RawMessage `json:"reply_markup,omitempty"`      // Inline or reply keyboard object
+
+	// SentByAPIKeyID is the API key sending the message. It is recorded, not sent to Telegram,
+	// so callback queries from the message's inline keyboard can be routed back to the key.
+	SentByAPIKeyID *uint `json:"-"`
+	// SentByUserID is the user sending the message, recorded on queued and scheduled sends
+	SentByUserID *uint `json:"-"`
+	// SendAt schedules a queued send for a later time
+	SendAt *time.Time `json:"-"`
+}
+
+// SendMediaRequest sends a photo, document or voice message.
+// Media is a URL or file_id; File is used instead when set.
+type SendMediaRequest struct {
+	Type    string
+	Media   string
+	File    *InputFile
+	Caption string
+	SendOptions
+}
+
+// SendLocationRequest sends a location
+type SendLocationRequest struct {
+	Latitude  float64
+	Longitude float64
+	SendOptions
+}
+
+// MediaGroupItem is an InputMedia object in a media group
+type MediaGroupItem struct {
+	Type      string `json:"type"`  // "photo", "video", "document" or "audio"
+	Media     string `json:"media"` // URL, file_id, or "attach://<name>" for an uploaded file
+	Caption   string `json:"caption,omitempty"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+// SendMediaGroupRequest sends an album of 2-10 items.
+// Files holds the uploads referenced by attach:// names.
+type SendMediaGroupRequest struct {
+	Media []MediaGroupItem
+	Files []InputFile
+	SendOptions
+}
+
+// OutboundCall is a prepared send, built by the New*Call functions, that is either
+// sent right away with Send or queued for the outbound workers with Queue
+type OutboundCall struct {
+	chat       *domain.Chat
+	sourceChat *domain.Chat // Chat a forwarded or copied message comes from
+	method     string
+	params     map[string]interface{}
+	files      []InputFile
+	opts       *SendOptions
+}
+
+// NewTextCall prepares sending a text message to a chat
+func NewTextCall(chat *domain.Chat, text string, opts *SendOptions) (*OutboundCall, error) {
+	if text == "" {
+		return nil, invalidSend("text is required")
+	}
+
+	params := sendParams(chat, opts)
+	params["text"] = text
+
+	return &OutboundCall{chat: chat, method: "sendMessage", params: params, opts: opts}, nil
+}
+
+// NewMediaCall prepares sending a photo, document or voice message to a chat
+func NewMediaCall(chat *domain.Chat, req *SendMediaRequest) (*OutboundCall, error) {
+	switch req.Type {
+	case MediaTypePhoto, MediaTypeDocument, MediaTypeVoice:
+	default:
+		return nil, invalidSend("unsupported media type %q", req.Type)
+	}
+
+	params := sendParams(chat, &req.SendOptions)
+	if req.Caption != "" {
+		params["caption"] = req.Caption
+	}
+
+	var files []InputFile
+	if req.File != nil {
+		file := *req.File
+		file.Field = req.Type
+		files = append(files, file)
+	} else if req.Media != "" {
+		params[req.Type] = req.Media
+	} else {
+		return nil, invalidSend("%s is required", req.Type)
+	}
+
+	method := map[string]string{
+		MediaTypePhoto:    "sendPhoto",
+		MediaTypeDocument: "sendDocument",
+		MediaTypeVoice:    "sendVoice",
+	}[req.Type]
+
+	return &OutboundCall{chat: chat, method: method, params: params, files: files, opts: &req.SendOptions}, nil
+}
+
+// NewLocationCall prepares sending a location to a chat
+func NewLocationCall(chat *domain.Chat, req *SendLocationRequest) (*OutboundCall, error) {
+	params := sendParams(chat, &req.SendOptions)
+	params["latitude"] = req.Latitude
+	params["longitude"] = req.Longitude
+
+	return &OutboundCall{chat: chat, method: "sendLocation", params: params, opts: &req.SendOptions}, nil
+}
+
+// NewMediaGroupCall prepares sending an album to a chat
+func NewMediaGroupCall(chat *domain.Chat, req *SendMediaGroupRequest) (*OutboundCall, error) {
+	if len(req.Media) < 2 || len(req.Media) > 10 {
+		return nil, invalidSend("a media group must contain 2-10 items")
+	}
+
+	// Telegram does not accept reply_markup for media groups
+	opts := req.SendOptions
+	opts.ReplyMarkup = nil
+
+	params := sendParams(chat, &opts)
+	params["media"] = req.Media
+
+	return &OutboundCall{chat: chat, method: "sendMediaGroup", params: params, files: req.Files, opts: &opts}, nil
+}
+
+// NewForwardCall prepares forwarding a message from one chat to another with the forward header
+func NewForwardCall(chat, fromChat *domain.Chat, messageID int64, opts *SendOptions) (*OutboundCall, error) {
+	if chat.BotID != fromChat.BotID {
+		return nil, invalidSend("both chats must belong to the same bot")
+	}
+
+	params := sendParams(chat, opts)
+	params["from_chat_id"] = fromChat.TelegramID
+	params["message_id"] = messageID
+
+	return &OutboundCall{chat: chat, sourceChat: fromChat, method: "forwardMessage", params: params, opts: opts}, nil
+}
+
+// NewCopyCall prepares copying a message from one chat to another without the forward header.
+// An empty caption keeps the original caption.
+func NewCopyCall(chat, fromChat *domain.Chat, messageID int64, caption string, opts *SendOptions) (*OutboundCall, error) {
+	if chat.BotID != fromChat.BotID {
+		return nil, invalidSend("both chats must belong to the same bot")
+	}
+
+	params := sendParams(chat, opts)
+	params["from_chat_id"] = fromChat.TelegramID
+	params["message_id"] = messageID
+	if caption != "" {
+		params["caption"] = caption
+	}
+
+	return &OutboundCall{chat: chat, sourceChat: fromChat, method: "copyMessage", params: params, opts: opts}, nil
+}
+
+// Send sends a prepared call and returns the recorded messages
+func (s *OutboundService) Send(ctx context.Context, call *OutboundCall) ([]MessageDTO, error) {
+	result, err := s.call(ctx, call.chat, call.method, call.params, call.files)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.record(ctx, call, result)
+}
+
+// SendText sends a text message to a chat
+func (s *OutboundService) SendText(ctx context.Context, chat *domain.Chat, text string, opts *SendOptions) (*MessageDTO, error) {
+	call, err := NewTextCall(chat, text, opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.sendOne(ctx, call)
+}
+
+// SendMedia sends a photo, document or voice message to a chat
+func (s *OutboundService) SendMedia(ctx context.Context, chat *domain.Chat, req *SendMediaRequest) (*MessageDTO, error) {
+	call, err := NewMediaCall(chat, req)
+	if err != nil {
+		return nil, err
+	}
+	return s.sendOne(ctx, call)
+}
+
+// SendLocation sends a location to a chat
+func (s *OutboundService) SendLocation(ctx context.Context, chat *domain.Chat, req *SendLocationRequest) (*MessageDTO, error) {
+	call, err := NewLocationCall(chat, req)
+	if err != nil {
+		return nil, err
+	}
+	return s.sendOne(ctx, call)
+}
+
+// SendMediaGroup sends an album to a chat and records every message in it
+func (s *OutboundService) SendMediaGroup(ctx context.Context, chat *domain.Chat, req *SendMediaGroupRequest) ([]MessageDTO, error) {
+	call, err := NewMediaGroupCall(chat, req)
+	if err != nil {
+		return nil, err
+	}
+	return s.Send(ctx, call)
+}
+
+// EditOptions holds the options accepted by editMessageText
+type EditOptions struct {
+	ParseMode   string          `json:"parse_mode,omitempty"`
+	ReplyMarkup json.RawMessage `json:"reply_markup,omitempty"`
+}
+
+// EditText edits the text of a message in a chat. The stored message keeps the previous
+// text in its edit history; nil is returned when the message was never stored by the gateway.
+func (s *OutboundService) EditText(ctx context.Context, chat *domain.Chat, messageID int64, text string, opts *EditOptions) (*MessageDTO, error) {
+	if text == "" {
+		return nil, invalidSend("text is required")
+	}
+
+	params := map[string]interface{}{
+		"chat_id":    chat.TelegramID,
+		"message_id": messageID,
+		"text":       text,
+	}
+	if opts.ParseMode != "" {
+		params["parse_mode"] = opts.ParseMode
+	}
+	if len(opts.ReplyMarkup) > 0 {
+		params["reply_markup"] = opts.ReplyMarkup
+	}
+
+	result, err := s.call(ctx, chat, "editMessageText", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	editedAt := time.Now()
+	var edited struct {
+		EditDate int64 `json:"edit_date"`
+	}
+	if json.Unmarshal(result, &edited) == nil && edited.EditDate > 0 {
+		editedAt = time.Unix(edited.EditDate, 0)
+	}
+
+	msg, err := s.messageService.RecordEdit(ctx, chat.ID, messageID, text, editedAt)
+	if err != nil {
+		log.Printf("Edited message %d in chat %d is not tracked: %v", messageID, chat.ID, err)
+		return nil, nil
+	}
+
+	s.publish(ctx, "edited_message", chat, msg)
+
+	return msg, nil
+}
+
+// DeleteMessage deletes a message from a chat and flags the stored copy as deleted.
+// nil is returned when the message was never stored by the gateway.
+func (s *OutboundService) DeleteMessage(ctx context.Context, chat *domain.Chat, messageID int64) (*MessageDTO, error) {
+	params := map[string]interface{}{
+		"chat_id":    chat.TelegramID,
+		"message_id": messageID,
+	}
+
+	if _, err := s.call(ctx, chat, "deleteMessage", params, nil); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.messageService.MarkDeleted(ctx, chat.ID, messageID)
+	if err != nil {
+		log.Printf("Deleted message %d in chat %d is not tracked: %v", messageID, chat.ID, err)
+		return nil, nil
+	}
+
+	s.publish(ctx, "deleted_message", chat, msg)
+
+	return msg, nil
+}
+
+// PinMessage pins a message in a chat
+func (s *OutboundService) PinMessage(ctx context.Context, chat *domain.Chat, messageID int64, disableNotification bool) error {
+	params := map[string]interface{}{
+		"chat_id":    chat.TelegramID,
+		"message_id": messageID,
+	}
+	if disableNotification {
+		params["disable_notification"] = true
+	}
+
+	_, err := s.call(ctx, chat, "pinChatMessage", params, nil)
+	return err
+}
+
+// UnpinMessage unpins a message in a chat
+func (s *OutboundService) UnpinMessage(ctx context.Context, chat *domain.Chat, messageID int64) error {
+	params := map[string]interface{}{
+		"chat_id":    chat.TelegramID,
+		"message_id": messageID,
+	}
+
+	_, err := s.call(ctx, chat, "unpinChatMessage", params, nil)
+	return err
+}
+
+// ForwardMessage forwards a message from one chat to another with the forward header
+func (s *OutboundService) ForwardMessage(ctx context.Context, chat, fromChat *domain.Chat, messageID int64, opts *SendOptions) (*MessageDTO, error) {
+	call, err := NewForwardCall(chat, fromChat, messageID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.sendOne(ctx, call)
+}
+
+// CopyMessage copies a message from one chat to another without the forward header.
+// An empty caption keeps the original caption.
+func (s *OutboundService) CopyMessage(ctx context.Context, chat, fromChat *domain.Chat, messageID int64, caption string, opts *SendOptions) (*MessageDTO, error) {
+	call, err := NewCopyCall(chat, fromChat, messageID, caption, opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.sendOne(ctx, call)
+}
+
+// sendOne sends a call that produces a single message
+func (s *OutboundService) sendOne(ctx context.Context, call *OutboundCall) (*MessageDTO, error) {
+	messages, err := s.Send(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+// record stores the messages returned by a send call
+func (s *OutboundService) record(ctx context.Context, call *OutboundCall, result json.RawMessage) ([]MessageDTO, error) {
+	switch call.method {
+	case "sendMediaGroup":
+		var sent []json.RawMessage
+		if err := json.Unmarshal(result, &sent); err != nil {
+			return nil, fmt.Errorf("failed to decode sent messages: %w", err)
+		}
+
+		messages := make([]MessageDTO, 0, len(sent))
+		for _, raw := range sent {
+			msg, err := s.recordSent(ctx, call.chat, raw, call.opts)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, *msg)
+		}
+		return messages, nil
+
+	case "copyMessage":
+		msg, err := s.recordCopy(ctx, call, result)
+		if err != nil {
+			return nil, err
+		}
+		return []MessageDTO{*msg}, nil
+
+	default:
+		msg, err := s.recordSent(ctx, call.chat, result, call.opts)
+		if err != nil {
+			return nil, err
+		}
+		return []MessageDTO{*msg}, nil
+	}
+}
+
+// recordCopy stores a copied message. copyMessage only returns the new message_id,
+// so the content is taken from the stored original.
+func (s *OutboundService) recordCopy(ctx context.Context, call *OutboundCall, result json.RawMessage) (*MessageDTO, error) {
+	var copied struct {
+		MessageID int64 `json:"message_id"`
+	}
+	if err := json.Unmarshal(result, &copied); err != nil {
+		return nil, fmt.Errorf("failed to decode copied message: %w", err)
+	}
+
+	caption, _ := call.params["caption"].(string)
+	msgType, text := "text", caption
+	if messageID, ok := paramInt64(call.params, "message_id"); ok && call.sourceChat != nil {
+		if original, err := s.messageService.GetMessageByTelegramID(ctx, call.sourceChat.ID, messageID); err == nil {
+			msgType = original.MessageType
+			if text == "" {
+				text = original.Text
+			}
+		}
+	}
+
+	stored, err := s.messageService.StoreMessage(ctx, &CreateMessageRequest{
+		ChatID:           call.chat.ID,
+		TelegramID:       copied.MessageID,
+		Direction:        "outgoing",
+		SentByAPIKeyID:   call.opts.SentByAPIKeyID,
+		MessageType:      msgType,
+		Text:             text,
+		RawData:          string(result),
+		ReplyToMessageID: call.opts.ReplyToMessageID,
+		SentAt:           time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("message copied but not recorded: %w", err)
+	}
+
+	s.publish(ctx, "new_message", call.chat, stored)
+
+	return stored, nil
+}
+
+// paramInt64 reads an integer Bot API param, which is a json.Number in params loaded from the queue
+func paramInt64(params map[string]interface{}, key string) (int64, bool) {
+	switch v := params[key].(type) {
+	case int64:
+		return v, true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// sendParams builds the Bot API params shared by every send method
+func sendParams(chat *domain.Chat, opts *SendOptions) map[string]interface{} {
+	params := map[string]interface{}{
+		"chat_id": chat.TelegramID,
+	}
+	if opts.ParseMode != "" {
+		params["parse_mode"] = opts.ParseMode
+	}
+	if opts.ReplyToMessageID != nil {
+		params["reply_to_message_id"] = *opts.ReplyToMessageID
+	}
+	if opts.DisableNotification {
+		params["disable_notification"] = true
+	}
+	if opts.MessageThreadID != nil {
+		params["message_thread_id"] = *opts.MessageThreadID
+	}
+	if len(opts.ReplyMarkup) > 0 {
+		params["reply_markup"] = opts.ReplyMarkup
+	}
+	return params
+}
+
+// sentMessage holds the fields of a Bot API Message result that the gateway stores
+type sentMessage struct {
+	MessageID      int64  `json:"message_id"`
+	Date           int64  `json:"date"`
+	Text           string `json:"text"`
+	Caption        string `json:"caption"`
+	ReplyToMessage *struct {
+		MessageID int64 `json:"message_id"`
+	} `json:"reply_to_message"`
+}
+
+// sentMessageTypes lists the content fields used to detect a message type, in priority order
+var sentMessageTypes = []string{"photo", "video", "animation", "document", "audio", "voice", "video_note", "sticker", "venue", "location", "contact", "poll", "dice"}
+
+// recordSent stores a Bot API Message result as an outgoing message and publishes it
+func (s *OutboundService) recordSent(ctx context.Context, chat *domain.Chat, raw json.RawMessage, opts *SendOptions) (*MessageDTO, error) {
+	var msg sentMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, fmt.Errorf("failed to decode sent message: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(raw, &fields)
+
+	msgType := "text"
+	for _, t := range sentMessageTypes {
+		if _, ok := fields[t]; ok {
+			msgType = t
+			break
+		}
+	}
+
+	text := msg.Text
+	if text == "" {
+		text = msg.Caption
+	}
+
+	var replyTo *int64
+	if msg.ReplyToMessage != nil {
+		replyTo = &msg.ReplyToMessage.MessageID
+	}
+
+	stored, err := s.messageService.StoreMessage(ctx, &CreateMessageRequest{
+		ChatID:           chat.ID,
+		TelegramID:       msg.MessageID,
+		Direction:        "outgoing",
+		SentByAPIKeyID:   opts.SentByAPIKeyID,
+		MessageType:      msgType,
+		Text:             text,
+		RawData:          string(raw),
+		ReplyToMessageID: replyTo,
+		SentAt:           time.Unix(msg.Date, 0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("message sent but not recorded: %w", err)
+	}
+
+	s.publish(ctx, "new_message", chat, stored)
+
+	return stored, nil
+}
+
+// publish publishes a message event for a stored message to real-time subscribers
+func (s *OutboundService) publish(ctx context.Context, eventType string, chat *domain.Chat, msg *MessageDTO) {
+	if s.messageBroker == nil {
+		return
+	}
+
+	timestamp := msg.SentAt
+	switch {
+	case eventType == "deleted_message":
+		timestamp = time.Now()
+	case msg.EditedAt != nil:
+		timestamp = *msg.EditedAt
+	}
+
+	event := &pubsub.MessageEvent{
+		Type:       eventType,
+		ChatID:     chat.ID,
+		MessageID:  msg.ID,
+		TelegramID: msg.TelegramID,
+		BotID:      chat.BotID,
+		Direction:  msg.Direction,
+		Text:       msg.Text,
+		Timestamp:  timestamp,
+		Payload: map[string]interface{}{
+			"message_type": msg.MessageType,
+		},
+	}
+	if s.eventBuilder != nil {
+		envelope, err := s.eventBuilder.MessageEvent(ctx, eventType, msg.ID)
+		if err != nil {
+			log.Printf("Failed to build %s event envelope: %v", eventType, err)
+		}
+		event.Envelope = envelope
+	}
+	if err := s.messageBroker.PublishMessage(ctx, event); err != nil {
+		// Log error but don't fail the request
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+	}
+}
+
+// call calls a Bot API method for a chat within Telegram's flood limits. A 429 response
+// blocks the bot for its retry_after; flood waits and other transient failures are
+// retried up to the policy's attempts while the wait fits in MaxSyncWait.
+func (s *OutboundService) call(ctx context.Context, chat *domain.Chat, method string, params map[string]interface{}, files []InputFile) (json.RawMessage, error) {
+	attempts := max(s.policy.MaxAttempts, 1)
+	if attempts > 1 && len(files) > 0 {
+		buffered, err := bufferFiles(files)
+		if err != nil {
+			return nil, err
+		}
+		files = buffered
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := s.awaitSlot(ctx, chat); err != nil {
+			return nil, err
+		}
+
+		result, err := s.botService.CallBotAPI(ctx, chat.BotID, method, params, files)
+		if err == nil {
+			return result, nil
+		}
+
+		delay, retry := s.retryDelay(ctx, chat, err, attempt)
+		if !retry || attempt >= attempts || delay > s.policy.MaxSyncWait {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		rewindFiles(files)
+	}
+}
+
+// awaitSlot waits for the next flood control slot of a chat. A slot further away than
+// MaxSyncWait is reported as a 429 so the caller can retry later or send asynchronously.
+func (s *OutboundService) awaitSlot(ctx context.Context, chat *domain.Chat) error {
+	wait, ok, err := s.floodControl.Reserve(ctx, chat, s.policy.MaxSyncWait)
+	if err != nil {
+		// Flood control is best effort; Telegram's own 429 responses still apply
+		log.Printf("Flood control unavailable for chat %d: %v", chat.ID, err)
+		return nil
+	}
+	if !ok {
+		return &APIError{
+			Code:        http.StatusTooManyRequests,
+			Description: "Too Many Requests: flood control of the gateway, retry later or send asynchronously",
+			RetryAfter:  int(math.Ceil(wait.Seconds())),
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// retryDelay reports whether a failed call may be retried and after how long.
+// A 429 response also blocks the bot in flood control for its retry_after.
+// Network failures are only retried when Telegram cannot have carried out the call:
+// a method that posts a message and got no answer may have posted it, and retrying
+// would post it twice. Other errors, such as an undecodable response or a missing
+// bot token, are not retried.
+func (s *OutboundService) retryDelay(ctx context.Context, chat *domain.Chat, err error, attempt int) (time.Duration, bool) {
+	if ctx.Err() != nil {
+		return 0, false
+	}
+
+	var netErr *NetworkError
+	if errors.As(err, &netErr) {
+		if netErr.NotSent || !postsMessage(netErr.Method) {
+			return retryBackoff(attempt), true
+		}
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return 0, false
+	}
+
+	switch {
+	case apiErr.Code == http.StatusTooManyRequests:
+		delay := time.Duration(max(apiErr.RetryAfter, 1)) * time.Second
+		if err := s.floodControl.Block(ctx, chat, delay); err != nil {
+			log.Printf("Failed to block bot %d in flood control: %v", chat.BotID, err)
+		}
+		return delay, true
+	case apiErr.Code >= 500:
+		return retryBackoff(attempt), true
+	default:
+		return 0, false
+	}
+}
+
+// postsMessage reports whether a Bot API method posts a new message, so calling it twice
+// posts it twice
+func postsMessage(method string) bool {
+	return strings.HasPrefix(method, "send") || method == "forwardMessage" || method == "copyMessage"
+}
+
+// retryBackoff returns the exponential delay before retrying a transient failure
+func retryBackoff(attempt int) time.Duration {
+	if attempt > 9 {
+		return 5 * time.Minute
+	}
+	return min(time.Second<<(attempt-1), 5*time.Minute)
+}
+
+// bufferFiles reads uploads into memory so a retried call can send them again
+func bufferFiles(files []InputFile) ([]InputFile, error) {
+	buffered := make([]InputFile, len(files))
+	for i, file := range files {
+		data, err := io.ReadAll(file.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.FileName, err)
+		}
+		file.Reader = bytes.NewReader(data)
+		buffered[i] = file
+	}
+	return buffered, nil
+}
+
+// rewindFiles rewinds buffered uploads before they are sent again
+func rewindFiles(files []InputFile) {
+	for _, file := range files {
+		if seeker, ok := file.Reader.(io.Seeker); ok {
+			_, _ = seeker.Seek(0, io.SeekStart)
+		}
+	}
+}
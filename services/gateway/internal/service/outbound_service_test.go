@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// fakeChatRepository serves chats from memory
+type fakeChatRepository struct {
+	repository.ChatRepository
+	chats map[uint]*domain.Chat
+}
+
+func (r *fakeChatRepository) GetByID(ctx context.Context, id uint) (*domain.Chat, error) {
+	chat, ok := r.chats[id]
+	if !ok {
+		return nil, assert.AnError
+	}
+	return chat, nil
+}
+
+// fakeMessageRepository keeps created messages in memory
+type fakeMessageRepository struct {
+	repository.MessageRepository
+	messages []*domain.Message
+	edits    []*domain.MessageEdit
+}
+
+func (r *fakeMessageRepository) Create(ctx context.Context, message *domain.Message) error {
+	message.ID = uint(len(r.messages) + 1)
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func (r *fakeMessageRepository) GetByID(ctx context.Context, id uint) (*domain.Message, error) {
+	for _, message := range r.messages {
+		if message.ID == id {
+			return message, nil
+		}
+	}
+	return nil, assert.AnError
+}
+
+func (r *fakeMessageRepository) GetByChatAndTelegramID(ctx context.Context, chatID uint, telegramID int64) (*domain.Message, error) {
+	for _, message := range r.messages {
+		if message.ChatID == chatID && message.TelegramID == telegramID {
+			return message, nil
+		}
+	}
+	return nil, assert.AnError
+}
+
+func (r *fakeMessageRepository) Update(ctx context.Context, message *domain.Message) error {
+	return nil
+}
+
+func (r *fakeMessageRepository) RecordEdit(ctx context.Context, message *domain.Message, edit *domain.MessageEdit) error {
+	r.edits = append(r.edits, edit)
+	return nil
+}
+
+// newOutboundTestService wires an OutboundService to a fake Bot API server
+func newOutboundTestService(t *testing.T, handler http.HandlerFunc) (*OutboundService, *fakeMessageRepository, *domain.Chat) {
+	api := httptest.NewServer(handler)
+	t.Cleanup(api.Close)
+
+	botRepo := &fakeBotRepository{bots: map[uint]*domain.Bot{}}
+	botService := NewBotService(botRepo, testKeyring(t), "", api.URL, nil)
+	encrypted, err := botService.encryptToken("123:abc")
+	require.NoError(t, err)
+	botRepo.bots[1] = &domain.Bot{ID: 1, Token: encrypted}
+
+	chat := &domain.Chat{ID: 9, BotID: 1, TelegramID: -100500}
+	chatRepo := &fakeChatRepository{chats: map[uint]*domain.Chat{chat.ID: chat}}
+	messageRepo := &fakeMessageRepository{}
+
+	return NewOutboundService(botService, NewMessageService(messageRepo, chatRepo), nil, nil, SendPolicy{}, nil, nil), messageRepo, chat
+}
+
+func TestSendTextRecordsOutgoingMessage(t *testing.T) {
+	var gotPayload map[string]interface{}
+	svc, messageRepo, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&gotPayload)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":321,"date":1700000000,"chat":{"id":-100500,"type":"supergroup"},"text":"hello","reply_to_message":{"message_id":300}}}`))
+	})
+
+	replyTo := int64(300)
+	thread := int64(12)
+	apiKeyID := uint(4)
+	msg, err := svc.SendText(context.Background(), chat, "hello", &SendOptions{
+		ReplyToMessageID:    &replyTo,
+		MessageThreadID:     &thread,
+		DisableNotification: true,
+		ReplyMarkup:         json.RawMessage(`{"inline_keyboard":[]}`),
+		SentByAPIKeyID:      &apiKeyID,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, float64(-100500), gotPayload["chat_id"])
+	assert.Equal(t, float64(12), gotPayload["message_thread_id"])
+	assert.Equal(t, true, gotPayload["disable_notification"])
+	assert.NotNil(t, gotPayload["reply_markup"])
+	assert.NotContains(t, gotPayload, "SentByAPIKeyID")
+
+	assert.Equal(t, int64(321), msg.TelegramID)
+	require.Len(t, messageRepo.messages, 1)
+	stored := messageRepo.messages[0]
+	assert.Equal(t, chat.ID, stored.ChatID)
+	assert.Equal(t, "outgoing", stored.Direction)
+	assert.Equal(t, "text", stored.MessageType)
+	assert.Equal(t, "hello", stored.Text)
+	require.NotNil(t, stored.ReplyToMessageID)
+	assert.Equal(t, int64(300), *stored.ReplyToMessageID)
+	require.NotNil(t, stored.SentByAPIKeyID)
+	assert.Equal(t, apiKeyID, *stored.SentByAPIKeyID)
+}
+
+func TestSendMediaGroupRecordsEveryMessage(t *testing.T) {
+	svc, messageRepo, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true,"result":[
+			{"message_id":1,"date":0,"photo":[{"file_id":"a"}],"caption":"first"},
+			{"message_id":2,"date":0,"document":{"file_id":"b"}}
+		]}`))
+	})
+
+	messages, err := svc.SendMediaGroup(context.Background(), chat, &SendMediaGroupRequest{
+		Media: []MediaGroupItem{{Type: "photo", Media: "a"}, {Type: "document", Media: "b"}},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, messages, 2)
+	require.Len(t, messageRepo.messages, 2)
+	assert.Equal(t, "photo", messageRepo.messages[0].MessageType)
+	assert.Equal(t, "first", messageRepo.messages[0].Text)
+	assert.Equal(t, "document", messageRepo.messages[1].MessageType)
+}
+
+func TestEditTextKeepsPreviousText(t *testing.T) {
+	var method string
+	svc, messageRepo, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		method = r.URL.Path
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":5,"date":0,"edit_date":1700000100,"text":"after"}}`))
+	})
+	messageRepo.messages = []*domain.Message{{ID: 1, ChatID: chat.ID, TelegramID: 5, Text: "before"}}
+
+	msg, err := svc.EditText(context.Background(), chat, 5, "after", &EditOptions{})
+	require.NoError(t, err)
+
+	assert.Contains(t, method, "/editMessageText")
+	require.NotNil(t, msg)
+	assert.Equal(t, "after", msg.Text)
+	require.NotNil(t, msg.EditedAt)
+	assert.Equal(t, int64(1700000100), msg.EditedAt.Unix())
+	require.Len(t, messageRepo.edits, 1)
+	assert.Equal(t, "before", messageRepo.edits[0].Text)
+}
+
+func TestDeleteMessageOfUntrackedMessage(t *testing.T) {
+	svc, messageRepo, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+	})
+	messageRepo.messages = []*domain.Message{{ID: 1, ChatID: chat.ID, TelegramID: 7}}
+
+	msg, err := svc.DeleteMessage(context.Background(), chat, 8)
+	require.NoError(t, err)
+	assert.Nil(t, msg)
+
+	msg, err = svc.DeleteMessage(context.Background(), chat, 7)
+	require.NoError(t, err)
+	require.NotNil(t, msg)
+	assert.True(t, msg.IsDeleted)
+}
+
+func TestSendRetriesServerErrors(t *testing.T) {
+	calls := 0
+	svc, messageRepo, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":502,"description":"Bad Gateway"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":11,"date":0,"text":"retried"}}`))
+	})
+	svc.policy = SendPolicy{MaxAttempts: 2, MaxSyncWait: 5 * time.Second}
+
+	msg, err := svc.SendText(context.Background(), chat, "retried", &SendOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, int64(11), msg.TelegramID)
+	assert.Len(t, messageRepo.messages, 1)
+}
+
+func TestSendRejectsInvalidRequestWithoutCallingTelegram(t *testing.T) {
+	calls := 0
+	svc, _, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+
+	_, err := svc.SendText(context.Background(), chat, "", &SendOptions{})
+
+	var invalidErr *InvalidSendError
+	require.ErrorAs(t, err, &invalidErr)
+	assert.Equal(t, "text is required", err.Error())
+	assert.Equal(t, 0, calls)
+}
+
+func TestSendDoesNotRetryUnansweredSend(t *testing.T) {
+	var calls atomic.Int32 // The handler still runs after the client gave up
+	svc, messageRepo, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":11,"date":0,"text":"late"}}`))
+	})
+	svc.botService.httpClient.Timeout = 50 * time.Millisecond
+	svc.policy = SendPolicy{MaxAttempts: 3, MaxSyncWait: 5 * time.Second}
+
+	_, err := svc.SendText(context.Background(), chat, "late", &SendOptions{})
+
+	var netErr *NetworkError
+	require.ErrorAs(t, err, &netErr)
+	assert.False(t, netErr.NotSent)
+	assert.Equal(t, int32(1), calls.Load(), "Telegram may have posted the message")
+	assert.Empty(t, messageRepo.messages)
+}
+
+func TestRetryDelayOnlyRetriesCallsThatCannotHaveBeenCarriedOut(t *testing.T) {
+	svc, _, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {})
+	ctx := context.Background()
+
+	_, retry := svc.retryDelay(ctx, chat, &NetworkError{Method: "sendMessage", NotSent: true, Err: assert.AnError}, 1)
+	assert.True(t, retry, "connection refused")
+
+	_, retry = svc.retryDelay(ctx, chat, &NetworkError{Method: "sendMessage", Err: assert.AnError}, 1)
+	assert.False(t, retry, "response timed out")
+
+	_, retry = svc.retryDelay(ctx, chat, &NetworkError{Method: "editMessageText", Err: assert.AnError}, 1)
+	assert.True(t, retry, "edits may be repeated")
+
+	_, retry = svc.retryDelay(ctx, chat, fmt.Errorf("failed to get bot token: %w", assert.AnError), 1)
+	assert.False(t, retry)
+}
+
+func TestSendReturnsFloodWaitBeyondMaxSyncWait(t *testing.T) {
+	calls := 0
+	svc, messageRepo, chat := newOutboundTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 30","parameters":{"retry_after":30}}`))
+	})
+	svc.policy = SendPolicy{MaxAttempts: 5, MaxSyncWait: time.Second}
+
+	_, err := svc.SendText(context.Background(), chat, "hello", &SendOptions{})
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.Code)
+	assert.Equal(t, 30, apiErr.RetryAfter)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, messageRepo.messages)
+}
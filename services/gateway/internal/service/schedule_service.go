@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// Scheduled message errors
+var (
+	ErrScheduledNotFound = errors.New("scheduled message not found")
+	ErrNotScheduled      = errors.New("message is no longer scheduled")
+)
+
+// ScheduleOwner identifies the API key or user that scheduled a message.
+// Scheduled messages are only visible to and managed by their owner.
+type ScheduleOwner struct {
+	APIKeyID *uint
+	UserID   *uint
+}
+
+// owns reports whether a send was scheduled by the owner
+func (o ScheduleOwner) owns(msg *domain.OutboundMessage) bool {
+	if msg.ScheduledAt == nil {
+		return false
+	}
+	if o.APIKeyID != nil {
+		return msg.SentByAPIKeyID != nil && *msg.SentByAPIKeyID == *o.APIKeyID
+	}
+	return o.UserID != nil && msg.SentByUserID != nil && *msg.SentByUserID == *o.UserID
+}
+
+// ScheduleService manages messages scheduled with send_at and hands them to the outbound
+// queue once their send time has come. The sender's permissions are checked again at
+// that point, so revoking a grant also stops the messages scheduled under it.
+type ScheduleService struct {
+	outboundService *OutboundService
+	outboundRepo    repository.OutboundMessageRepository
+	chatPermRepo    repository.ChatPermissionRepository
+	botPermRepo     repository.APIKeyBotPermissionRepository
+	apiKeyRepo      repository.APIKeyRepository
+	userRepo        repository.UserRepository
+}
+
+// NewScheduleService creates a new schedule service
+func NewScheduleService(
+	outboundService *OutboundService,
+	outboundRepo repository.OutboundMessageRepository,
+	chatPermRepo repository.ChatPermissionRepository,
+	botPermRepo repository.APIKeyBotPermissionRepository,
+	apiKeyRepo repository.APIKeyRepository,
+	userRepo repository.UserRepository,
+) *ScheduleService {
+	return &ScheduleService{
+		outboundService: outboundService,
+		outboundRepo:    outboundRepo,
+		chatPermRepo:    chatPermRepo,
+		botPermRepo:     botPermRepo,
+		apiKeyRepo:      apiKeyRepo,
+		userRepo:        userRepo,
+	}
+}
+
+// ListScheduled lists the messages scheduled by the owner, earliest send time first.
+// An empty status lists the messages still waiting for their send time.
+func (s *ScheduleService) ListScheduled(ctx context.Context, owner ScheduleOwner, chatID *uint, status string, offset, limit int) ([]OutboundMessageDTO, int64, error) {
+	if status == "" {
+		status = domain.OutboundStatusScheduled
+	}
+
+	filter := repository.OutboundFilter{
+		SentByAPIKeyID: owner.APIKeyID,
+		SentByUserID:   owner.UserID,
+		ChatID:         chatID,
+		Status:         status,
+		Scheduled:      true,
+	}
+	if owner.APIKeyID != nil {
+		filter.SentByUserID = nil
+	}
+
+	msgs, total, err := s.outboundRepo.ListFiltered(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list scheduled messages: %w", err)
+	}
+
+	result := make([]OutboundMessageDTO, len(msgs))
+	for i := range msgs {
+		result[i] = *s.outboundService.toOutboundDTO(ctx, &msgs[i])
+	}
+
+	return result, total, nil
+}
+
+// GetScheduled returns a message scheduled by the owner
+func (s *ScheduleService) GetScheduled(ctx context.Context, owner ScheduleOwner, id uint) (*OutboundMessageDTO, error) {
+	msg, err := s.getOwned(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.outboundService.toOutboundDTO(ctx, msg), nil
+}
+
+// Cancel cancels a message that is still waiting for its send time
+func (s *ScheduleService) Cancel(ctx context.Context, owner ScheduleOwner, id uint) (*OutboundMessageDTO, error) {
+	if _, err := s.getOwned(ctx, owner, id); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.outboundRepo.Transition(ctx, id, domain.OutboundStatusScheduled, domain.OutboundStatusCanceled, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel scheduled message: %w", err)
+	}
+	if !ok {
+		return nil, ErrNotScheduled
+	}
+
+	return s.GetScheduled(ctx, owner, id)
+}
+
+// Reschedule moves the send time of a message that is still waiting for it
+func (s *ScheduleService) Reschedule(ctx context.Context, owner ScheduleOwner, id uint, sendAt time.Time) (*OutboundMessageDTO, error) {
+	if !sendAt.After(time.Now()) {
+		return nil, ErrSendAtPast
+	}
+	if _, err := s.getOwned(ctx, owner, id); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.outboundRepo.Reschedule(ctx, id, sendAt.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to reschedule message: %w", err)
+	}
+	if !ok {
+		return nil, ErrNotScheduled
+	}
+
+	return s.GetScheduled(ctx, owner, id)
+}
+
+// ReleaseDue queues up to limit messages whose send time has come and returns how many
+// were handled. Messages whose sender may no longer send to the chat fail instead.
+func (s *ScheduleService) ReleaseDue(ctx context.Context, limit int) (int, error) {
+	due, err := s.outboundRepo.ListDueScheduled(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due scheduled messages: %w", err)
+	}
+
+	for i := range due {
+		msg := &due[i]
+
+		to, reason := domain.OutboundStatusQueued, ""
+		if denied := s.checkSender(ctx, msg); denied != "" {
+			to, reason = domain.OutboundStatusFailed, denied
+		}
+
+		// A message canceled in the meantime stays canceled
+		if _, err := s.outboundRepo.Transition(ctx, msg.ID, domain.OutboundStatusScheduled, to, reason); err != nil {
+			log.Printf("Failed to release scheduled message %d: %v", msg.ID, err)
+		}
+	}
+
+	return len(due), nil
+}
+
+// checkSender checks that the sender of a scheduled message may still send to its chat,
+// as the chat ACL did when it was scheduled. It returns why not, or "" when allowed.
+func (s *ScheduleService) checkSender(ctx context.Context, msg *domain.OutboundMessage) string {
+	switch {
+	case msg.SentByAPIKeyID != nil:
+		apiKeyID := *msg.SentByAPIKeyID
+		apiKey, err := s.apiKeyRepo.GetByID(ctx, apiKeyID)
+		if err != nil || !apiKey.IsActive {
+			return "API key is no longer active"
+		}
+		if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
+			return "API key has expired"
+		}
+
+		perm, err := s.chatPermRepo.GetByAPIKeyAndChat(ctx, apiKeyID, msg.ChatID)
+		if err != nil || !perm.CanSend {
+			return "API key no longer has can_send on the chat"
+		}
+
+		allowed := apiKey.AllBots
+		if !allowed {
+			allowed, err = s.botPermRepo.HasBotAccess(ctx, apiKeyID, msg.BotID)
+		}
+		if err != nil || !allowed {
+			return fmt.Sprintf("API key no longer has a grant for bot %d", msg.BotID)
+		}
+
+	case msg.SentByUserID != nil:
+		user, err := s.userRepo.GetByID(ctx, *msg.SentByUserID)
+		if err != nil || !user.IsActive {
+			return "user is no longer active"
+		}
+
+		perm, err := s.chatPermRepo.GetByUserAndChat(ctx, *msg.SentByUserID, msg.ChatID)
+		if err != nil || !perm.CanSend {
+			return "user no longer has can_send on the chat"
+		}
+
+	default:
+		// The API key or user was deleted
+		return "sender no longer exists"
+	}
+
+	return ""
+}
+
+// getOwned loads a message scheduled by the owner
+func (s *ScheduleService) getOwned(ctx context.Context, owner ScheduleOwner, id uint) (*domain.OutboundMessage, error) {
+	msg, err := s.outboundRepo.GetByID(ctx, id)
+	if err != nil || !owner.owns(msg) {
+		return nil, ErrScheduledNotFound
+	}
+	return msg, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// fakeAPIKeyRepository serves API keys from memory
+type fakeAPIKeyRepository struct {
+	repository.APIKeyRepository
+	keys map[uint]*domain.APIKey
+}
+
+func (r *fakeAPIKeyRepository) GetByID(ctx context.Context, id uint) (*domain.APIKey, error) {
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, assert.AnError
+	}
+	return key, nil
+}
+
+// fakeChatPermissionRepository serves API key chat permissions from memory, keyed by API key ID
+type fakeChatPermissionRepository struct {
+	repository.ChatPermissionRepository
+	perms map[uint]*domain.ChatPermission
+}
+
+func (r *fakeChatPermissionRepository) GetByAPIKeyAndChat(ctx context.Context, apiKeyID, chatID uint) (*domain.ChatPermission, error) {
+	perm, ok := r.perms[apiKeyID]
+	if !ok || perm.ChatID != chatID {
+		return nil, assert.AnError
+	}
+	return perm, nil
+}
+
+// fakeBotPermissionRepository grants every bot
+type fakeBotPermissionRepository struct {
+	repository.APIKeyBotPermissionRepository
+}
+
+func (r *fakeBotPermissionRepository) HasBotAccess(ctx context.Context, apiKeyID, botID uint) (bool, error) {
+	return true, nil
+}
+
+// newScheduleTestService wires a ScheduleService to in-memory repositories with API key 4
+// holding can_send on chat 9
+func newScheduleTestService(t *testing.T) (*ScheduleService, *OutboundService, *fakeOutboundMessageRepository, *fakeChatPermissionRepository) {
+	chat := &domain.Chat{ID: 9, BotID: 1, TelegramID: -100500}
+	outboundRepo := &fakeOutboundMessageRepository{chat: chat, messages: map[uint]*domain.OutboundMessage{}}
+	chatPermRepo := &fakeChatPermissionRepository{perms: map[uint]*domain.ChatPermission{
+		4: {ChatID: chat.ID, CanRead: true, CanSend: true},
+	}}
+	apiKeyRepo := &fakeAPIKeyRepository{keys: map[uint]*domain.APIKey{4: {ID: 4, IsActive: true}}}
+
+	outboundService := NewOutboundService(nil, nil, outboundRepo, nil, SendPolicy{}, nil, nil)
+	scheduleService := NewScheduleService(outboundService, outboundRepo, chatPermRepo, &fakeBotPermissionRepository{}, apiKeyRepo, nil)
+	return scheduleService, outboundService, outboundRepo, chatPermRepo
+}
+
+// scheduleText schedules a text message by API key 4
+func scheduleText(t *testing.T, outboundService *OutboundService, sendAt time.Time) *OutboundMessageDTO {
+	apiKeyID := uint(4)
+	call, err := NewTextCall(&domain.Chat{ID: 9, BotID: 1, TelegramID: -100500}, "good morning", &SendOptions{SentByAPIKeyID: &apiKeyID, SendAt: &sendAt})
+	require.NoError(t, err)
+
+	scheduled, err := outboundService.Queue(context.Background(), call)
+	require.NoError(t, err)
+	return scheduled
+}
+
+func TestScheduledMessageIsQueuedAtSendTime(t *testing.T) {
+	svc, outboundService, repo, _ := newScheduleTestService(t)
+	ctx := context.Background()
+
+	scheduled := scheduleText(t, outboundService, time.Now().Add(time.Hour))
+	assert.Equal(t, domain.OutboundStatusScheduled, scheduled.Status)
+	require.NotNil(t, scheduled.ScheduledAt)
+
+	released, err := svc.ReleaseDue(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, released)
+
+	repo.messages[scheduled.ID].NextAttemptAt = time.Now()
+	released, err = svc.ReleaseDue(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, released)
+	assert.Equal(t, domain.OutboundStatusQueued, repo.messages[scheduled.ID].Status)
+}
+
+func TestScheduledMessageFailsWithoutSendPermission(t *testing.T) {
+	svc, outboundService, repo, chatPermRepo := newScheduleTestService(t)
+
+	scheduled := scheduleText(t, outboundService, time.Now().Add(time.Hour))
+	chatPermRepo.perms[4].CanSend = false
+	repo.messages[scheduled.ID].NextAttemptAt = time.Now()
+
+	_, err := svc.ReleaseDue(context.Background(), 10)
+	require.NoError(t, err)
+
+	msg := repo.messages[scheduled.ID]
+	assert.Equal(t, domain.OutboundStatusFailed, msg.Status)
+	assert.Contains(t, msg.LastError, "can_send")
+}
+
+func TestCancelAndRescheduleScheduledMessage(t *testing.T) {
+	svc, outboundService, _, _ := newScheduleTestService(t)
+	ctx := context.Background()
+
+	apiKeyID, otherKeyID := uint(4), uint(5)
+	owner := ScheduleOwner{APIKeyID: &apiKeyID}
+	scheduled := scheduleText(t, outboundService, time.Now().Add(time.Hour))
+
+	_, err := svc.Cancel(ctx, ScheduleOwner{APIKeyID: &otherKeyID}, scheduled.ID)
+	assert.ErrorIs(t, err, ErrScheduledNotFound)
+
+	_, err = svc.Reschedule(ctx, owner, scheduled.ID, time.Now().Add(-time.Minute))
+	assert.ErrorIs(t, err, ErrSendAtPast)
+
+	sendAt := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	rescheduled, err := svc.Reschedule(ctx, owner, scheduled.ID, sendAt)
+	require.NoError(t, err)
+	require.NotNil(t, rescheduled.ScheduledAt)
+	assert.True(t, sendAt.Equal(*rescheduled.ScheduledAt))
+
+	canceled, err := svc.Cancel(ctx, owner, scheduled.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OutboundStatusCanceled, canceled.Status)
+
+	_, err = svc.Cancel(ctx, owner, scheduled.ID)
+	assert.ErrorIs(t, err, ErrNotScheduled)
+}
+
+func TestQueueRejectsSendAtInThePast(t *testing.T) {
+	_, outboundService, _, _ := newScheduleTestService(t)
+
+	sendAt := time.Now().Add(-time.Minute)
+	call, err := NewTextCall(&domain.Chat{ID: 9, BotID: 1}, "too late", &SendOptions{SendAt: &sendAt})
+	require.NoError(t, err)
+
+	_, err = outboundService.Queue(context.Background(), call)
+	assert.ErrorIs(t, err, ErrSendAtPast)
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// UpdateEventService stores Telegram updates that are neither messages nor callback queries
+type UpdateEventService struct {
+	eventRepo repository.UpdateEventRepository
+}
+
+// NewUpdateEventService creates a new update event service
+func NewUpdateEventService(eventRepo repository.UpdateEventRepository) *UpdateEventService {
+	return &UpdateEventService{
+		eventRepo: eventRepo,
+	}
+}
+
+// UpdateEventDTO represents an update event data transfer object
+type UpdateEventDTO struct {
+	ID           uint            `json:"id"`
+	BotID        uint            `json:"bot_id"`
+	ChatID       *uint           `json:"chat_id,omitempty"`
+	MessageID    *uint           `json:"message_id,omitempty"`
+	EventType    string          `json:"event_type"`
+	FromUserID   *int64          `json:"from_user_id,omitempty"`
+	FromUsername string          `json:"from_username,omitempty"`
+	Data         json.RawMessage `json:"data,omitempty"` // The update object as sent by Telegram
+	OccurredAt   time.Time       `json:"occurred_at"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// RecordUpdateEventRequest represents a received update event
+type RecordUpdateEventRequest struct {
+	BotID        uint
+	ChatID       *uint
+	MessageID    *uint
+	EventType    string
+	FromUserID   *int64
+	FromUsername string
+	RawData      string
+	OccurredAt   time.Time
+}
+
+// RecordEvent stores an update event
+func (s *UpdateEventService) RecordEvent(ctx context.Context, req *RecordUpdateEventRequest) (*UpdateEventDTO, error) {
+	event := &domain.UpdateEvent{
+		BotID:        req.BotID,
+		ChatID:       req.ChatID,
+		MessageID:    req.MessageID,
+		EventType:    req.EventType,
+		FromUserID:   req.FromUserID,
+		FromUsername: req.FromUsername,
+		RawData:      req.RawData,
+		OccurredAt:   req.OccurredAt,
+	}
+
+	if err := s.eventRepo.Create(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to store %s event: %w", req.EventType, err)
+	}
+
+	return toUpdateEventDTO(event), nil
+}
+
+// ListChatEvents retrieves a chat's update events with cursor-based pagination.
+// An empty eventType lists events of every type.
+func (s *UpdateEventService) ListChatEvents(ctx context.Context, chatID uint, eventType string, cursor *time.Time, limit int) ([]UpdateEventDTO, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50 // Default limit
+	}
+
+	events, err := s.eventRepo.ListByChat(ctx, chatID, eventType, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	result := make([]UpdateEventDTO, len(events))
+	for i := range events {
+		result[i] = *toUpdateEventDTO(&events[i])
+	}
+
+	return result, nil
+}
+
+// toUpdateEventDTO converts a stored update event to its DTO
+func toUpdateEventDTO(event *domain.UpdateEvent) *UpdateEventDTO {
+	dto := &UpdateEventDTO{
+		ID:           event.ID,
+		BotID:        event.BotID,
+		ChatID:       event.ChatID,
+		MessageID:    event.MessageID,
+		EventType:    event.EventType,
+		FromUserID:   event.FromUserID,
+		FromUsername: event.FromUsername,
+		OccurredAt:   event.OccurredAt,
+		CreatedAt:    event.CreatedAt,
+	}
+	if json.Valid([]byte(event.RawData)) {
+		dto.Data = json.RawMessage(event.RawData)
+	}
+	return dto
+}
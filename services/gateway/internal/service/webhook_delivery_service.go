@@ -0,0 +1,405 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/pubsub"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// Webhook delivery errors
+var (
+	ErrWebhookNotFound    = errors.New("webhook not found")
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	ErrNotDeadLetter      = errors.New("delivery is no longer a dead letter")
+)
+
+// deadLetterReplayBatch is how many dead letters a bulk replay loads at a time
+const deadLetterReplayBatch = 100
+
+// webhookHealthWindow is how far back the health of a webhook is summarized
+const webhookHealthWindow = 24 * time.Hour
+
+// webhookHealthLatencySamples is how many recent deliveries the latency percentiles are taken from
+const webhookHealthLatencySamples = 1000
+
+// webhookBroker queues deliveries for the webhook workers and shares their circuit breakers
+type webhookBroker interface {
+	QueueWebhookDelivery(ctx context.Context, deliveryID uint) error
+	GetWebhookCircuit(ctx context.Context, url string) (*time.Time, error)
+}
+
+// WebhookDeliveryService exposes webhook deliveries and the health of webhooks. Deliveries
+// whose retries are used up stay behind as dead letters with the response of their last
+// attempt until replayed.
+type WebhookDeliveryService struct {
+	deliveryRepo  repository.WebhookDeliveryRepository
+	webhookRepo   repository.WebhookRepository
+	webhookSender *WebhookSender
+	broker        webhookBroker
+}
+
+// NewWebhookDeliveryService creates a new webhook delivery service
+func NewWebhookDeliveryService(
+	deliveryRepo repository.WebhookDeliveryRepository,
+	webhookRepo repository.WebhookRepository,
+	webhookSender *WebhookSender,
+	messageBroker *pubsub.MessageBroker,
+) *WebhookDeliveryService {
+	return &WebhookDeliveryService{
+		deliveryRepo:  deliveryRepo,
+		webhookRepo:   webhookRepo,
+		webhookSender: webhookSender,
+		broker:        messageBroker,
+	}
+}
+
+// WebhookDeliveryDTO reports a webhook delivery and the outcome of its last attempt
+type WebhookDeliveryDTO struct {
+	ID              uint       `json:"id"`
+	WebhookID       uint       `json:"webhook_id"`
+	WebhookURL      string     `json:"webhook_url,omitempty"`
+	EventType       string     `json:"event_type"`
+	MessageID       *uint      `json:"message_id,omitempty"`
+	CallbackQueryID *uint      `json:"callback_query_id,omitempty"`
+	UpdateEventID   *uint      `json:"update_event_id,omitempty"`
+	Status          string     `json:"status"`
+	AttemptCount    int        `json:"attempt_count"`
+	LastError       string     `json:"last_error,omitempty"`
+	ResponseStatus  *int       `json:"response_status,omitempty"`
+	ResponseBody    string     `json:"response_body,omitempty"`
+	LatencyMs       *int64     `json:"latency_ms,omitempty"`
+	LastAttemptAt   *time.Time `json:"last_attempt_at,omitempty"`
+	NextRetryAt     *time.Time `json:"next_retry_at,omitempty"`
+	DeliveredAt     *time.Time `json:"delivered_at,omitempty"`
+	FailedAt        *time.Time `json:"failed_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// DeadLetterFilter selects dead letters by webhook and by when they failed. Zero values do not filter.
+type DeadLetterFilter struct {
+	WebhookID *uint
+	From      *time.Time
+	To        *time.Time
+}
+
+// DeliveryLogFilter selects the deliveries of a webhook. Zero values do not filter.
+type DeliveryLogFilter struct {
+	Status    string
+	EventType string
+	From      *time.Time // Deliveries created at or after this time
+	To        *time.Time // Deliveries created before this time
+}
+
+// WebhookHealthDTO summarizes the recent deliveries of a webhook
+type WebhookHealthDTO struct {
+	WebhookID        uint       `json:"webhook_id"`
+	URL              string     `json:"url"`
+	Window           string     `json:"window"`
+	Deliveries       int64      `json:"deliveries"`
+	Delivered        int64      `json:"delivered"`
+	Failed           int64      `json:"failed"`
+	Pending          int64      `json:"pending"`
+	SuccessRate      *float64   `json:"success_rate"` // Share of finished deliveries that were delivered; null without any
+	LatencyP50Ms     *int64     `json:"latency_p50_ms"`
+	LatencyP95Ms     *int64     `json:"latency_p95_ms"`
+	BreakerState     string     `json:"breaker_state"` // "closed", "open" or "half-open"
+	BreakerOpenUntil *time.Time `json:"breaker_open_until,omitempty"`
+	LastFailureAt    *time.Time `json:"last_failure_at,omitempty"`
+	LastFailure      string     `json:"last_failure,omitempty"`
+}
+
+// WebhookPingResult reports how a webhook endpoint answered a ping
+type WebhookPingResult struct {
+	OK           bool   `json:"ok"`
+	StatusCode   *int   `json:"status_code,omitempty"`
+	LatencyMs    int64  `json:"latency_ms"`
+	ResponseBody string `json:"response_body,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ListDeliveries lists the deliveries of a webhook, newest first
+func (s *WebhookDeliveryService) ListDeliveries(ctx context.Context, webhookID uint, filter DeliveryLogFilter, offset, limit int) ([]WebhookDeliveryDTO, int64, error) {
+	if _, err := s.getWebhook(ctx, webhookID); err != nil {
+		return nil, 0, err
+	}
+
+	deliveries, total, err := s.deliveryRepo.ListFiltered(ctx, repository.WebhookDeliveryFilter{
+		WebhookID:   &webhookID,
+		Status:      filter.Status,
+		EventType:   filter.EventType,
+		CreatedFrom: filter.From,
+		CreatedTo:   filter.To,
+	}, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+
+	result := make([]WebhookDeliveryDTO, len(deliveries))
+	for i := range deliveries {
+		result[i] = *toWebhookDeliveryDTO(&deliveries[i])
+	}
+
+	return result, total, nil
+}
+
+// GetHealth summarizes the deliveries of a webhook over the last day with the state of
+// its circuit breaker and its last failure
+func (s *WebhookDeliveryService) GetHealth(ctx context.Context, webhookID uint) (*WebhookHealthDTO, error) {
+	webhook, err := s.getWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-webhookHealthWindow)
+	counts, err := s.deliveryRepo.CountByStatus(ctx, webhookID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count deliveries: %w", err)
+	}
+	latencies, err := s.deliveryRepo.ListLatencies(ctx, webhookID, since, webhookHealthLatencySamples)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list latencies: %w", err)
+	}
+
+	health := &WebhookHealthDTO{
+		WebhookID:     webhook.ID,
+		URL:           webhook.URL,
+		Window:        webhookHealthWindow.String(),
+		Delivered:     counts["delivered"],
+		Failed:        counts["failed"],
+		Pending:       counts["pending"],
+		LatencyP50Ms:  percentile(latencies, 0.50),
+		LatencyP95Ms:  percentile(latencies, 0.95),
+		BreakerState:  "closed",
+		LastFailureAt: webhook.LastFailureAt,
+		LastFailure:   webhook.LastFailure,
+	}
+	for _, count := range counts {
+		health.Deliveries += count
+	}
+	if finished := health.Delivered + health.Failed; finished > 0 {
+		rate := float64(health.Delivered) / float64(finished)
+		health.SuccessRate = &rate
+	}
+
+	openUntil, err := s.broker.GetWebhookCircuit(ctx, webhook.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit breaker state: %w", err)
+	}
+	if openUntil != nil {
+		health.BreakerState = "half-open"
+		if openUntil.After(time.Now()) {
+			health.BreakerState = "open"
+			health.BreakerOpenUntil = openUntil
+		}
+	}
+
+	return health, nil
+}
+
+// Ping sends a signed "ping" event to a webhook and reports how its endpoint answered.
+// Pings are not stored and do not count towards the health of the webhook.
+func (s *WebhookDeliveryService) Ping(ctx context.Context, webhookID uint) (*WebhookPingResult, error) {
+	webhook, err := s.getWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deliveryID := fmt.Sprintf("ping_%d", now.UnixNano())
+
+	var ping interface{} = map[string]interface{}{
+		"event":      "ping",
+		"timestamp":  now.Unix(),
+		"webhook_id": webhook.ID,
+	}
+	if webhook.SchemaVersion >= domain.EventSchemaEnvelope {
+		ping = &domain.EventEnvelope{
+			Version:   domain.EventSchemaEnvelope,
+			Event:     "ping",
+			ID:        deliveryID,
+			CreatedAt: now,
+		}
+	}
+
+	payload, err := json.Marshal(ping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	resp, err := s.webhookSender.Send(ctx, webhook, deliveryID, "ping", payload)
+	result := &WebhookPingResult{
+		OK:           err == nil,
+		StatusCode:   resp.StatusCode,
+		LatencyMs:    resp.Latency.Milliseconds(),
+		ResponseBody: resp.Body,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result, nil
+}
+
+// ListDeadLetters lists failed deliveries, newest first
+func (s *WebhookDeliveryService) ListDeadLetters(ctx context.Context, filter DeadLetterFilter, offset, limit int) ([]WebhookDeliveryDTO, int64, error) {
+	deliveries, total, err := s.deliveryRepo.ListFiltered(ctx, filter.repositoryFilter(), offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	result := make([]WebhookDeliveryDTO, len(deliveries))
+	for i := range deliveries {
+		result[i] = *toWebhookDeliveryDTO(&deliveries[i])
+	}
+
+	return result, total, nil
+}
+
+// GetDeadLetter returns a failed delivery
+func (s *WebhookDeliveryService) GetDeadLetter(ctx context.Context, id uint) (*WebhookDeliveryDTO, error) {
+	delivery, err := s.deliveryRepo.GetByID(ctx, id)
+	if err != nil || delivery.Status != "failed" || delivery.Webhook.Scope == "feedback" {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	return toWebhookDeliveryDTO(delivery), nil
+}
+
+// ReplayDeadLetter queues a failed delivery again with a fresh set of retries
+func (s *WebhookDeliveryService) ReplayDeadLetter(ctx context.Context, id uint) (*WebhookDeliveryDTO, error) {
+	if _, err := s.GetDeadLetter(ctx, id); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.replay(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotDeadLetter
+	}
+
+	delivery, err := s.deliveryRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+
+	return toWebhookDeliveryDTO(delivery), nil
+}
+
+// ReplayDeadLetters queues every failed delivery matching the filter again and returns
+// how many were queued
+func (s *WebhookDeliveryService) ReplayDeadLetters(ctx context.Context, filter DeadLetterFilter) (int, error) {
+	replayed := 0
+
+	for {
+		// Replayed deliveries leave the failed set, so the first page always holds the rest
+		deliveries, _, err := s.deliveryRepo.ListFiltered(ctx, filter.repositoryFilter(), 0, deadLetterReplayBatch)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to list dead letters: %w", err)
+		}
+
+		for i := range deliveries {
+			ok, err := s.replay(ctx, deliveries[i].ID)
+			if err != nil {
+				return replayed, err
+			}
+			if ok {
+				replayed++
+			}
+		}
+
+		if len(deliveries) < deadLetterReplayBatch {
+			return replayed, nil
+		}
+	}
+}
+
+// replay makes a failed delivery pending again and queues it. It reports false when
+// the delivery was no longer failed.
+func (s *WebhookDeliveryService) replay(ctx context.Context, id uint) (bool, error) {
+	ok, err := s.deliveryRepo.Replay(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to replay delivery %d: %w", id, err)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	if err := s.broker.QueueWebhookDelivery(ctx, id); err != nil {
+		// The delivery stays pending and is recovered on the next start
+		log.Printf("Failed to queue replayed delivery %d: %v", id, err)
+	}
+
+	return true, nil
+}
+
+// getWebhook loads a webhook whose deliveries may be inspected here. Feedback webhooks
+// belong to their API key and are reported as not found, like in WebhookService.
+func (s *WebhookDeliveryService) getWebhook(ctx context.Context, id uint) (*domain.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByID(ctx, id)
+	if err != nil || webhook.Scope == "feedback" {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// repositoryFilter selects the failed deliveries matching the filter, leaving out those
+// of feedback webhooks
+func (f DeadLetterFilter) repositoryFilter() repository.WebhookDeliveryFilter {
+	return repository.WebhookDeliveryFilter{
+		WebhookID:       f.WebhookID,
+		Status:          "failed",
+		FailedFrom:      f.From,
+		FailedTo:        f.To,
+		ExcludeFeedback: true,
+	}
+}
+
+// toWebhookDeliveryDTO converts a delivery to its report
+func toWebhookDeliveryDTO(delivery *domain.WebhookDelivery) *WebhookDeliveryDTO {
+	return &WebhookDeliveryDTO{
+		ID:              delivery.ID,
+		WebhookID:       delivery.WebhookID,
+		WebhookURL:      delivery.Webhook.URL,
+		EventType:       delivery.EventType,
+		MessageID:       delivery.MessageID,
+		CallbackQueryID: delivery.CallbackQueryID,
+		UpdateEventID:   delivery.UpdateEventID,
+		Status:          delivery.Status,
+		AttemptCount:    delivery.AttemptCount,
+		LastError:       delivery.LastError,
+		ResponseStatus:  delivery.ResponseStatus,
+		ResponseBody:    delivery.ResponseBody,
+		LatencyMs:       delivery.LatencyMs,
+		LastAttemptAt:   delivery.LastAttemptAt,
+		NextRetryAt:     delivery.NextRetryAt,
+		DeliveredAt:     delivery.DeliveredAt,
+		FailedAt:        delivery.FailedAt,
+		CreatedAt:       delivery.CreatedAt,
+	}
+}
+
+// percentile returns the nearest-rank percentile of the values, nil without values
+func percentile(values []int64, p float64) *int64 {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := make([]int64, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	value := sorted[max(rank, 1)-1]
+	return &value
+}
@@ -0,0 +1,449 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+	"github.com/kexi/telegram-bot-gateway/pkg/webhooksig"
+)
+
+// fakeWebhookDeliveryRepository keeps deliveries in memory
+type fakeWebhookDeliveryRepository struct {
+	repository.WebhookDeliveryRepository
+	deliveries map[uint]*domain.WebhookDelivery
+}
+
+func (r *fakeWebhookDeliveryRepository) GetByID(ctx context.Context, id uint) (*domain.WebhookDelivery, error) {
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, assert.AnError
+	}
+	copied := *delivery
+	return &copied, nil
+}
+
+func (r *fakeWebhookDeliveryRepository) ListFiltered(ctx context.Context, filter repository.WebhookDeliveryFilter, offset, limit int) ([]domain.WebhookDelivery, int64, error) {
+	var matched []domain.WebhookDelivery
+	for _, d := range r.deliveries {
+		if filter.WebhookID != nil && d.WebhookID != *filter.WebhookID {
+			continue
+		}
+		if filter.Status != "" && d.Status != filter.Status {
+			continue
+		}
+		if filter.EventType != "" && d.EventType != filter.EventType {
+			continue
+		}
+		if filter.CreatedFrom != nil && d.CreatedAt.Before(*filter.CreatedFrom) {
+			continue
+		}
+		if filter.CreatedTo != nil && !d.CreatedAt.Before(*filter.CreatedTo) {
+			continue
+		}
+		if filter.FailedFrom != nil && (d.FailedAt == nil || d.FailedAt.Before(*filter.FailedFrom)) {
+			continue
+		}
+		if filter.FailedTo != nil && (d.FailedAt == nil || !d.FailedAt.Before(*filter.FailedTo)) {
+			continue
+		}
+		if filter.ExcludeFeedback && d.Webhook.Scope == "feedback" {
+			continue
+		}
+		matched = append(matched, *d)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+
+	total := int64(len(matched))
+	if offset >= len(matched) {
+		return nil, total, nil
+	}
+	matched = matched[offset:]
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched, total, nil
+}
+
+func (r *fakeWebhookDeliveryRepository) Replay(ctx context.Context, id uint) (bool, error) {
+	d, ok := r.deliveries[id]
+	if !ok || d.Status != "failed" {
+		return false, nil
+	}
+	d.Status = "pending"
+	d.AttemptCount = 0
+	d.NextRetryAt = nil
+	d.FailedAt = nil
+	return true, nil
+}
+
+func (r *fakeWebhookDeliveryRepository) CountByStatus(ctx context.Context, webhookID uint, since time.Time) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID && !d.CreatedAt.Before(since) {
+			counts[d.Status]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *fakeWebhookDeliveryRepository) ListLatencies(ctx context.Context, webhookID uint, since time.Time, limit int) ([]int64, error) {
+	var latencies []int64
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID && !d.CreatedAt.Before(since) && d.LatencyMs != nil {
+			latencies = append(latencies, *d.LatencyMs)
+		}
+	}
+	return latencies, nil
+}
+
+// fakeWebhookRepository serves webhooks from memory
+type fakeWebhookRepository struct {
+	repository.WebhookRepository
+	webhooks map[uint]*domain.Webhook
+}
+
+func (r *fakeWebhookRepository) GetByID(ctx context.Context, id uint) (*domain.Webhook, error) {
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return nil, assert.AnError
+	}
+	copied := *webhook
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepository) Update(ctx context.Context, webhook *domain.Webhook) error {
+	copied := *webhook
+	r.webhooks[webhook.ID] = &copied
+	return nil
+}
+
+// fakeWebhookBroker records the deliveries handed to the webhook workers
+type fakeWebhookBroker struct {
+	queued    []uint
+	openUntil map[string]time.Time
+}
+
+func (b *fakeWebhookBroker) QueueWebhookDelivery(ctx context.Context, deliveryID uint) error {
+	b.queued = append(b.queued, deliveryID)
+	return nil
+}
+
+func (b *fakeWebhookBroker) GetWebhookCircuit(ctx context.Context, url string) (*time.Time, error) {
+	until, ok := b.openUntil[url]
+	if !ok {
+		return nil, nil
+	}
+	return &until, nil
+}
+
+func newDeliveryTestService(deliveries ...domain.WebhookDelivery) (*WebhookDeliveryService, *fakeWebhookDeliveryRepository, *fakeWebhookBroker) {
+	repo := &fakeWebhookDeliveryRepository{deliveries: make(map[uint]*domain.WebhookDelivery)}
+	for i := range deliveries {
+		repo.deliveries[deliveries[i].ID] = &deliveries[i]
+	}
+	webhookRepo := &fakeWebhookRepository{webhooks: map[uint]*domain.Webhook{
+		7: {ID: 7, URL: "https://example.com/hooks/7", Secret: "secret", IsActive: true},
+	}}
+	broker := &fakeWebhookBroker{openUntil: make(map[string]time.Time)}
+	return &WebhookDeliveryService{
+		deliveryRepo:  repo,
+		webhookRepo:   webhookRepo,
+		webhookSender: NewWebhookSender(NewWebhookService(webhookRepo, nil), 5*time.Second),
+		broker:        broker,
+	}, repo, broker
+}
+
+func deadLetter(id, webhookID uint, failedAt time.Time) domain.WebhookDelivery {
+	status := 502
+	return domain.WebhookDelivery{
+		ID:             id,
+		WebhookID:      webhookID,
+		EventType:      "new_message",
+		Status:         "failed",
+		AttemptCount:   5,
+		LastError:      "webhook returned status 502: bad gateway",
+		ResponseStatus: &status,
+		ResponseBody:   "bad gateway",
+		FailedAt:       &failedAt,
+	}
+}
+
+func TestGetDeadLetter(t *testing.T) {
+	now := time.Now()
+	svc, _, _ := newDeliveryTestService(
+		deadLetter(1, 7, now),
+		domain.WebhookDelivery{ID: 2, WebhookID: 7, Status: "delivered"},
+	)
+	ctx := context.Background()
+
+	got, err := svc.GetDeadLetter(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", got.Status)
+	require.NotNil(t, got.ResponseStatus)
+	assert.Equal(t, 502, *got.ResponseStatus)
+	assert.Equal(t, "bad gateway", got.ResponseBody)
+
+	_, err = svc.GetDeadLetter(ctx, 2)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+
+	_, err = svc.GetDeadLetter(ctx, 3)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	svc, repo, queue := newDeliveryTestService(deadLetter(1, 7, time.Now()))
+	ctx := context.Background()
+
+	got, err := svc.ReplayDeadLetter(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", got.Status)
+	assert.Equal(t, 0, got.AttemptCount)
+	assert.Nil(t, got.FailedAt)
+	// The last error stays until the next attempt
+	assert.NotEmpty(t, got.LastError)
+	assert.Equal(t, []uint{1}, queue.queued)
+	assert.Equal(t, "pending", repo.deliveries[1].Status)
+
+	// A replayed delivery is no longer a dead letter
+	_, err = svc.ReplayDeadLetter(ctx, 1)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+	assert.Len(t, queue.queued, 1)
+}
+
+func TestReplayDeadLettersByWebhookAndTimeRange(t *testing.T) {
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	var deliveries []domain.WebhookDelivery
+	for i := uint(1); i <= 150; i++ {
+		deliveries = append(deliveries, deadLetter(i, 7, base.Add(time.Duration(i)*time.Minute)))
+	}
+	deliveries = append(deliveries,
+		deadLetter(200, 8, base.Add(10*time.Minute)), // Other webhook
+		deadLetter(201, 7, base.Add(-time.Hour)),     // Before the range
+		deadLetter(202, 7, base.Add(10*time.Hour)),   // After the range
+		domain.WebhookDelivery{ID: 203, WebhookID: 7, Status: "delivered"},
+	)
+	svc, repo, queue := newDeliveryTestService(deliveries...)
+
+	webhookID := uint(7)
+	from, to := base, base.Add(5*time.Hour)
+	replayed, err := svc.ReplayDeadLetters(context.Background(), DeadLetterFilter{WebhookID: &webhookID, From: &from, To: &to})
+	require.NoError(t, err)
+	assert.Equal(t, 150, replayed)
+	assert.Len(t, queue.queued, 150)
+
+	for _, id := range []uint{200, 201, 202} {
+		assert.Equal(t, "failed", repo.deliveries[id].Status, "delivery %d", id)
+	}
+	assert.Equal(t, "delivered", repo.deliveries[203].Status)
+}
+
+func TestListDeadLetters(t *testing.T) {
+	now := time.Now()
+	svc, _, _ := newDeliveryTestService(
+		deadLetter(1, 7, now),
+		deadLetter(2, 7, now),
+		deadLetter(3, 8, now),
+		domain.WebhookDelivery{ID: 4, WebhookID: 7, Status: "pending"},
+	)
+
+	webhookID := uint(7)
+	deadLetters, total, err := svc.ListDeadLetters(context.Background(), DeadLetterFilter{WebhookID: &webhookID}, 0, 20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, deadLetters, 2)
+	assert.Equal(t, uint(2), deadLetters[0].ID)
+	assert.Equal(t, uint(1), deadLetters[1].ID)
+}
+
+func TestDeadLettersOfFeedbackWebhooksAreHidden(t *testing.T) {
+	feedback := deadLetter(2, 8, time.Now())
+	feedback.Webhook = domain.Webhook{ID: 8, Scope: "feedback"}
+	svc, repo, _ := newDeliveryTestService(deadLetter(1, 7, time.Now()), feedback)
+	ctx := context.Background()
+
+	deadLetters, total, err := svc.ListDeadLetters(ctx, DeadLetterFilter{}, 0, 20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, uint(1), deadLetters[0].ID)
+
+	_, err = svc.GetDeadLetter(ctx, 2)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+
+	_, err = svc.ReplayDeadLetter(ctx, 2)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+	assert.Equal(t, "failed", repo.deliveries[2].Status)
+}
+
+func TestListDeliveries(t *testing.T) {
+	now := time.Now()
+	svc, _, _ := newDeliveryTestService(
+		domain.WebhookDelivery{ID: 1, WebhookID: 7, EventType: "new_message", Status: "delivered", CreatedAt: now.Add(-2 * time.Hour)},
+		domain.WebhookDelivery{ID: 2, WebhookID: 7, EventType: "edited_message", Status: "delivered", CreatedAt: now.Add(-time.Hour)},
+		domain.WebhookDelivery{ID: 3, WebhookID: 7, EventType: "new_message", Status: "pending", CreatedAt: now},
+		domain.WebhookDelivery{ID: 4, WebhookID: 8, EventType: "new_message", Status: "delivered", CreatedAt: now},
+	)
+	ctx := context.Background()
+
+	deliveries, total, err := svc.ListDeliveries(ctx, 7, DeliveryLogFilter{}, 0, 20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, uint(3), deliveries[0].ID)
+
+	from := now.Add(-90 * time.Minute)
+	deliveries, total, err = svc.ListDeliveries(ctx, 7, DeliveryLogFilter{Status: "delivered", From: &from}, 0, 20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, uint(2), deliveries[0].ID)
+
+	deliveries, _, err = svc.ListDeliveries(ctx, 7, DeliveryLogFilter{EventType: "new_message"}, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, uint(1), deliveries[0].ID)
+
+	_, _, err = svc.ListDeliveries(ctx, 99, DeliveryLogFilter{}, 0, 20)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
+
+func TestGetHealth(t *testing.T) {
+	now := time.Now()
+	latency := func(ms int64) *int64 { return &ms }
+
+	var deliveries []domain.WebhookDelivery
+	for i := uint(1); i <= 18; i++ {
+		deliveries = append(deliveries, domain.WebhookDelivery{ID: i, WebhookID: 7, Status: "delivered", LatencyMs: latency(int64(i) * 10), CreatedAt: now})
+	}
+	deliveries = append(deliveries,
+		domain.WebhookDelivery{ID: 19, WebhookID: 7, Status: "failed", LatencyMs: latency(5000), CreatedAt: now},
+		domain.WebhookDelivery{ID: 20, WebhookID: 7, Status: "failed", LatencyMs: latency(6000), CreatedAt: now},
+		domain.WebhookDelivery{ID: 21, WebhookID: 7, Status: "pending", CreatedAt: now},
+		domain.WebhookDelivery{ID: 22, WebhookID: 7, Status: "failed", CreatedAt: now.Add(-48 * time.Hour)}, // Outside the window
+	)
+	svc, _, broker := newDeliveryTestService(deliveries...)
+	ctx := context.Background()
+
+	health, err := svc.GetHealth(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(21), health.Deliveries)
+	assert.Equal(t, int64(18), health.Delivered)
+	assert.Equal(t, int64(2), health.Failed)
+	assert.Equal(t, int64(1), health.Pending)
+	require.NotNil(t, health.SuccessRate)
+	assert.InDelta(t, 0.9, *health.SuccessRate, 0.001)
+	require.NotNil(t, health.LatencyP50Ms)
+	assert.Equal(t, int64(100), *health.LatencyP50Ms)
+	require.NotNil(t, health.LatencyP95Ms)
+	assert.Equal(t, int64(5000), *health.LatencyP95Ms)
+	assert.Equal(t, "closed", health.BreakerState)
+
+	broker.openUntil["https://example.com/hooks/7"] = now.Add(time.Minute)
+	health, err = svc.GetHealth(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "open", health.BreakerState)
+	assert.NotNil(t, health.BreakerOpenUntil)
+
+	broker.openUntil["https://example.com/hooks/7"] = now.Add(-time.Second)
+	health, err = svc.GetHealth(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "half-open", health.BreakerState)
+
+	_, err = svc.GetHealth(ctx, 99)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
+
+func TestGetHealthWithoutDeliveries(t *testing.T) {
+	svc, _, _ := newDeliveryTestService()
+
+	health, err := svc.GetHealth(context.Background(), 7)
+	require.NoError(t, err)
+	assert.Zero(t, health.Deliveries)
+	assert.Nil(t, health.SuccessRate)
+	assert.Nil(t, health.LatencyP50Ms)
+	assert.Nil(t, health.LatencyP95Ms)
+}
+
+func TestPing(t *testing.T) {
+	var received map[string]interface{}
+	var event, deliveryID string
+	var verifyErr error
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = webhooksig.VerifyRequest(r, body, 0, "secret")
+		event = r.Header.Get("X-Webhook-Event")
+		deliveryID = r.Header.Get(webhooksig.HeaderID)
+		require.NoError(t, json.Unmarshal(body, &received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer endpoint.Close()
+
+	svc, repo, _ := newDeliveryTestService()
+	svc.webhookRepo.(*fakeWebhookRepository).webhooks[7].URL = endpoint.URL
+
+	result, err := svc.Ping(context.Background(), 7)
+	require.NoError(t, err)
+	assert.True(t, result.OK)
+	require.NotNil(t, result.StatusCode)
+	assert.Equal(t, http.StatusNoContent, *result.StatusCode)
+	assert.Equal(t, "ping", received["event"])
+	assert.Equal(t, float64(7), received["webhook_id"])
+	assert.Equal(t, "ping", event)
+	assert.NoError(t, verifyErr)
+	assert.True(t, strings.HasPrefix(deliveryID, "ping_"))
+	assert.Empty(t, repo.deliveries, "pings are not stored")
+}
+
+func TestPingSendsEnvelopeForSchemaVersion2(t *testing.T) {
+	var received map[string]interface{}
+	var deliveryID string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveryID = r.Header.Get(webhooksig.HeaderID)
+		require.NoError(t, json.Unmarshal(body, &received))
+	}))
+	defer endpoint.Close()
+
+	svc, _, _ := newDeliveryTestService()
+	webhook := svc.webhookRepo.(*fakeWebhookRepository).webhooks[7]
+	webhook.URL = endpoint.URL
+	webhook.SchemaVersion = domain.EventSchemaEnvelope
+
+	_, err := svc.Ping(context.Background(), 7)
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), received["version"])
+	assert.Equal(t, "ping", received["event"])
+	assert.Equal(t, deliveryID, received["id"])
+	assert.Contains(t, received, "created_at")
+}
+
+func TestPingReportsRejection(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+	}))
+	defer endpoint.Close()
+
+	svc, _, _ := newDeliveryTestService()
+	svc.webhookRepo.(*fakeWebhookRepository).webhooks[7].URL = endpoint.URL
+
+	result, err := svc.Ping(context.Background(), 7)
+	require.NoError(t, err)
+	assert.False(t, result.OK)
+	require.NotNil(t, result.StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, *result.StatusCode)
+	assert.Contains(t, result.ResponseBody, "invalid signature")
+	assert.Contains(t, result.Error, "status 401")
+}
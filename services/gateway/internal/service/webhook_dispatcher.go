@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/pubsub"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// WebhookDispatcher fans out stored messages to matching webhooks
+type WebhookDispatcher struct {
+	webhookRepo      repository.WebhookRepository
+	deliveryRepo     repository.WebhookDeliveryRepository
+	chatPermRepo     repository.ChatPermissionRepository
+	feedbackPermRepo repository.APIKeyFeedbackPermissionRepository
+	messageBroker    *pubsub.MessageBroker
+}
+
+// NewWebhookDispatcher creates a new webhook dispatcher
+func NewWebhookDispatcher(
+	webhookRepo repository.WebhookRepository,
+	deliveryRepo repository.WebhookDeliveryRepository,
+	chatPermRepo repository.ChatPermissionRepository,
+	feedbackPermRepo repository.APIKeyFeedbackPermissionRepository,
+	messageBroker *pubsub.MessageBroker,
+) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhookRepo:      webhookRepo,
+		deliveryRepo:     deliveryRepo,
+		chatPermRepo:     chatPermRepo,
+		feedbackPermRepo: feedbackPermRepo,
+		messageBroker:    messageBroker,
+	}
+}
+
+// Dispatch creates a delivery for every active webhook matching the message
+// and queues it for the webhook workers. Incoming messages are also pushed to the
+// feedback webhooks of API keys allowed to receive them. Returns the number of queued deliveries.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context, eventType string, message *MessageDTO) (int, error) {
+	queued, err := d.dispatch(ctx, eventType, message, nil)
+	if err != nil {
+		return queued, err
+	}
+
+	feedback, err := d.dispatchFeedback(ctx, eventType, message)
+	return queued + feedback, err
+}
+
+// DispatchCallbackQuery queues "callback_query" deliveries for a button press on a stored message
+func (d *WebhookDispatcher) DispatchCallbackQuery(ctx context.Context, message *MessageDTO, callbackQueryID uint) (int, error) {
+	return d.dispatch(ctx, "callback_query", message, &callbackQueryID)
+}
+
+// DispatchInlineCallbackQuery queues "callback_query" deliveries for a button press on an
+// inline-mode message. Such presses have no chat, so they only go to global webhooks.
+func (d *WebhookDispatcher) DispatchInlineCallbackQuery(ctx context.Context, callbackQueryID uint) (int, error) {
+	webhooks, err := d.webhookRepo.ListActiveGlobal(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	queued := 0
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if !GlobalWebhookMatches(webhook, "callback_query") {
+			continue
+		}
+
+		delivery := &domain.WebhookDelivery{
+			WebhookID:       webhook.ID,
+			CallbackQueryID: &callbackQueryID,
+			EventType:       "callback_query",
+			Status:          "pending",
+		}
+		if d.queue(ctx, delivery) {
+			queued++
+		}
+	}
+
+	return queued, nil
+}
+
+// DispatchUpdateEvent queues deliveries of a non-message update event. Events without a chat
+// (inline queries, polls) only go to global webhooks.
+func (d *WebhookDispatcher) DispatchUpdateEvent(ctx context.Context, event *UpdateEventDTO) (int, error) {
+	var webhooks []domain.Webhook
+	var err error
+	if event.ChatID != nil {
+		webhooks, err = d.webhookRepo.ListActiveForChat(ctx, *event.ChatID)
+	} else {
+		webhooks, err = d.webhookRepo.ListActiveGlobal(ctx)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	queued := 0
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if !UpdateEventMatches(webhook, event) {
+			continue
+		}
+
+		delivery := &domain.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			MessageID:     event.MessageID,
+			UpdateEventID: &event.ID,
+			EventType:     event.EventType,
+			Status:        "pending",
+		}
+		if d.queue(ctx, delivery) {
+			queued++
+		}
+	}
+
+	return queued, nil
+}
+
+// dispatch creates and queues deliveries; callbackQueryID is set for callback query events
+func (d *WebhookDispatcher) dispatch(ctx context.Context, eventType string, message *MessageDTO, callbackQueryID *uint) (int, error) {
+	webhooks, err := d.webhookRepo.ListActiveForChat(ctx, message.ChatID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	queued := 0
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if !WebhookMatches(webhook, eventType, message) {
+			continue
+		}
+
+		delivery := &domain.WebhookDelivery{
+			WebhookID:       webhook.ID,
+			MessageID:       &message.ID,
+			CallbackQueryID: callbackQueryID,
+			EventType:       eventType,
+			Status:          "pending",
+		}
+		if d.queue(ctx, delivery) {
+			queued++
+		}
+	}
+
+	return queued, nil
+}
+
+// dispatchFeedback queues deliveries of an incoming message to feedback webhooks
+func (d *WebhookDispatcher) dispatchFeedback(ctx context.Context, eventType string, message *MessageDTO) (int, error) {
+	if message.Direction != "incoming" {
+		return 0, nil
+	}
+
+	webhooks, err := d.webhookRepo.ListActiveFeedback(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list feedback webhooks: %w", err)
+	}
+
+	queued := 0
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if !FeedbackWebhookMatches(webhook, eventType) {
+			continue
+		}
+
+		allowed, err := d.canReceiveFeedback(ctx, *webhook.APIKeyID, message.ChatID)
+		if err != nil {
+			log.Printf("Failed to check feedback permission of API key %d: %v", *webhook.APIKeyID, err)
+			continue
+		}
+		if !allowed {
+			continue
+		}
+
+		delivery := &domain.WebhookDelivery{
+			WebhookID: webhook.ID,
+			MessageID: &message.ID,
+			EventType: eventType,
+			Status:    "pending",
+		}
+		if d.queue(ctx, delivery) {
+			queued++
+		}
+	}
+
+	return queued, nil
+}
+
+// canReceiveFeedback reports whether an API key may receive messages from a chat:
+// it needs can_read on the chat, and a feedback grant once it has any
+func (d *WebhookDispatcher) canReceiveFeedback(ctx context.Context, apiKeyID, chatID uint) (bool, error) {
+	perm, err := d.chatPermRepo.GetByAPIKeyAndChat(ctx, apiKeyID, chatID)
+	if err != nil || !perm.CanRead {
+		return false, nil
+	}
+
+	return d.feedbackPermRepo.CanReceiveFeedback(ctx, apiKeyID, chatID)
+}
+
+// queue stores a delivery and hands it to the webhook workers
+func (d *WebhookDispatcher) queue(ctx context.Context, delivery *domain.WebhookDelivery) bool {
+	if err := d.deliveryRepo.Create(ctx, delivery); err != nil {
+		log.Printf("Failed to create delivery for webhook %d: %v", delivery.WebhookID, err)
+		return false
+	}
+
+	if err := d.messageBroker.QueueWebhookDelivery(ctx, delivery.ID); err != nil {
+		// The delivery row stays pending and is recovered on the next start
+		log.Printf("Failed to queue delivery %d: %v", delivery.ID, err)
+		return false
+	}
+
+	return true
+}
+
+// WebhookMatches reports whether a webhook should receive the given event for a message
+func WebhookMatches(webhook *domain.Webhook, eventType string, message *MessageDTO) bool {
+	if !webhook.IsActive {
+		return false
+	}
+
+	switch webhook.Scope {
+	case "chat":
+		// Global webhooks have no chat binding
+		if webhook.ChatID != nil && *webhook.ChatID != message.ChatID {
+			return false
+		}
+	case "reply":
+		if webhook.ChatID == nil || *webhook.ChatID != message.ChatID {
+			return false
+		}
+		if webhook.ReplyToMessageID == nil || message.ReplyToMessageID == nil {
+			return false
+		}
+		if *webhook.ReplyToMessageID != *message.ReplyToMessageID {
+			return false
+		}
+	default:
+		return false
+	}
+
+	return webhookWantsEvent(webhook, eventType)
+}
+
+// FeedbackWebhookMatches reports whether a feedback webhook should receive a message event.
+// Feedback webhooks only receive messages.
+func FeedbackWebhookMatches(webhook *domain.Webhook, eventType string) bool {
+	if !webhook.IsActive || webhook.Scope != "feedback" || webhook.APIKeyID == nil {
+		return false
+	}
+
+	return webhookWantsEvent(webhook, eventType)
+}
+
+// GlobalWebhookMatches reports whether a webhook should receive an event without a chat.
+// Only global webhooks receive them.
+func GlobalWebhookMatches(webhook *domain.Webhook, eventType string) bool {
+	if !webhook.IsActive || webhook.Scope != "chat" || webhook.ChatID != nil {
+		return false
+	}
+
+	return webhookWantsEvent(webhook, eventType)
+}
+
+// UpdateEventMatches reports whether a webhook should receive a non-message update event.
+// Reply-scoped webhooks only receive messages.
+func UpdateEventMatches(webhook *domain.Webhook, event *UpdateEventDTO) bool {
+	if !webhook.IsActive || webhook.Scope != "chat" {
+		return false
+	}
+
+	if webhook.ChatID != nil && (event.ChatID == nil || *webhook.ChatID != *event.ChatID) {
+		return false
+	}
+
+	return webhookWantsEvent(webhook, event.EventType)
+}
+
+// webhookWantsEvent checks an event type against the webhook's Events filter
+func webhookWantsEvent(webhook *domain.Webhook, eventType string) bool {
+	events := ParseWebhookEvents(webhook.Events)
+	if len(events) == 0 {
+		return true
+	}
+	for _, event := range events {
+		if event == "*" || event == eventType {
+			return true
+		}
+		// "message" is accepted as shorthand for new messages
+		if event == "message" && eventType == "new_message" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseWebhookEvents parses the Events field of a webhook.
+// Accepts a JSON array (["new_message","edited_message"]) or a comma-separated list.
+func ParseWebhookEvents(events string) []string {
+	events = strings.TrimSpace(events)
+	if events == "" {
+		return nil
+	}
+
+	var parsed []string
+	if err := json.Unmarshal([]byte(events), &parsed); err != nil {
+		parsed = strings.Split(events, ",")
+	}
+
+	result := make([]string, 0, len(parsed))
+	for _, event := range parsed {
+		event = strings.TrimSpace(event)
+		if event != "" {
+			result = append(result, event)
+		}
+	}
+	return result
+}
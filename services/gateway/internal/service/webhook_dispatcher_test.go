@@ -0,0 +1,127 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+)
+
+func TestWebhookMatches(t *testing.T) {
+	chatID := uint(7)
+	otherChatID := uint(8)
+	replyTo := int64(1001)
+	otherReplyTo := int64(1002)
+
+	message := &MessageDTO{ID: 1, ChatID: chatID, ReplyToMessageID: &replyTo}
+	plain := &MessageDTO{ID: 2, ChatID: chatID}
+
+	tests := []struct {
+		name    string
+		webhook domain.Webhook
+		event   string
+		message *MessageDTO
+		want    bool
+	}{
+		{"global webhook", domain.Webhook{Scope: "chat", IsActive: true}, "new_message", plain, true},
+		{"chat webhook same chat", domain.Webhook{Scope: "chat", ChatID: &chatID, IsActive: true}, "new_message", plain, true},
+		{"chat webhook other chat", domain.Webhook{Scope: "chat", ChatID: &otherChatID, IsActive: true}, "new_message", plain, false},
+		{"inactive webhook", domain.Webhook{Scope: "chat", IsActive: false}, "new_message", plain, false},
+		{"reply webhook matching reply", domain.Webhook{Scope: "reply", ChatID: &chatID, ReplyToMessageID: &replyTo, IsActive: true}, "new_message", message, true},
+		{"reply webhook other reply", domain.Webhook{Scope: "reply", ChatID: &chatID, ReplyToMessageID: &otherReplyTo, IsActive: true}, "new_message", message, false},
+		{"reply webhook non-reply message", domain.Webhook{Scope: "reply", ChatID: &chatID, ReplyToMessageID: &replyTo, IsActive: true}, "new_message", plain, false},
+		{"events filter match", domain.Webhook{Scope: "chat", Events: `["edited_message"]`, IsActive: true}, "edited_message", plain, true},
+		{"events filter miss", domain.Webhook{Scope: "chat", Events: `["edited_message"]`, IsActive: true}, "new_message", plain, false},
+		{"message alias", domain.Webhook{Scope: "chat", Events: `["message"]`, IsActive: true}, "new_message", plain, true},
+		{"comma separated events", domain.Webhook{Scope: "chat", Events: "channel_post, new_message", IsActive: true}, "new_message", plain, true},
+		{"unknown scope", domain.Webhook{Scope: "bot", IsActive: true}, "new_message", plain, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, WebhookMatches(&tt.webhook, tt.event, tt.message))
+		})
+	}
+}
+
+func TestUpdateEventMatches(t *testing.T) {
+	chatID := uint(7)
+	otherChatID := uint(8)
+
+	member := &UpdateEventDTO{ID: 1, ChatID: &chatID, EventType: "my_chat_member"}
+	inline := &UpdateEventDTO{ID: 2, EventType: "inline_query"}
+
+	tests := []struct {
+		name    string
+		webhook domain.Webhook
+		event   *UpdateEventDTO
+		want    bool
+	}{
+		{"global webhook", domain.Webhook{Scope: "chat", IsActive: true}, member, true},
+		{"global webhook chatless event", domain.Webhook{Scope: "chat", IsActive: true}, inline, true},
+		{"chat webhook same chat", domain.Webhook{Scope: "chat", ChatID: &chatID, IsActive: true}, member, true},
+		{"chat webhook other chat", domain.Webhook{Scope: "chat", ChatID: &otherChatID, IsActive: true}, member, false},
+		{"chat webhook chatless event", domain.Webhook{Scope: "chat", ChatID: &chatID, IsActive: true}, inline, false},
+		{"reply webhook", domain.Webhook{Scope: "reply", ChatID: &chatID, IsActive: true}, member, false},
+		{"events filter match", domain.Webhook{Scope: "chat", Events: `["my_chat_member"]`, IsActive: true}, member, true},
+		{"events filter miss", domain.Webhook{Scope: "chat", Events: `["new_message"]`, IsActive: true}, member, false},
+		{"message alias", domain.Webhook{Scope: "chat", Events: `["message"]`, IsActive: true}, member, false},
+		{"inactive webhook", domain.Webhook{Scope: "chat", IsActive: false}, member, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, UpdateEventMatches(&tt.webhook, tt.event))
+		})
+	}
+}
+
+func TestFeedbackWebhookMatches(t *testing.T) {
+	apiKeyID := uint(3)
+
+	tests := []struct {
+		name    string
+		webhook domain.Webhook
+		event   string
+		want    bool
+	}{
+		{"feedback webhook", domain.Webhook{Scope: "feedback", APIKeyID: &apiKeyID, IsActive: true}, "new_message", true},
+		{"edited message", domain.Webhook{Scope: "feedback", APIKeyID: &apiKeyID, IsActive: true}, "edited_message", true},
+		{"events filter miss", domain.Webhook{Scope: "feedback", APIKeyID: &apiKeyID, Events: `["new_message"]`, IsActive: true}, "edited_message", false},
+		{"without api key", domain.Webhook{Scope: "feedback", IsActive: true}, "new_message", false},
+		{"chat webhook", domain.Webhook{Scope: "chat", APIKeyID: &apiKeyID, IsActive: true}, "new_message", false},
+		{"inactive webhook", domain.Webhook{Scope: "feedback", APIKeyID: &apiKeyID, IsActive: false}, "new_message", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FeedbackWebhookMatches(&tt.webhook, tt.event))
+		})
+	}
+}
+
+func TestGlobalWebhookMatches(t *testing.T) {
+	chatID := uint(7)
+	apiKeyID := uint(3)
+
+	tests := []struct {
+		name    string
+		webhook domain.Webhook
+		want    bool
+	}{
+		{"global webhook", domain.Webhook{Scope: "chat", IsActive: true}, true},
+		{"chat webhook", domain.Webhook{Scope: "chat", ChatID: &chatID, IsActive: true}, false},
+		{"reply webhook", domain.Webhook{Scope: "reply", IsActive: true}, false},
+		{"feedback webhook", domain.Webhook{Scope: "feedback", APIKeyID: &apiKeyID, IsActive: true}, false},
+		{"events filter match", domain.Webhook{Scope: "chat", Events: `["callback_query"]`, IsActive: true}, true},
+		{"events filter miss", domain.Webhook{Scope: "chat", Events: `["new_message"]`, IsActive: true}, false},
+		{"inactive webhook", domain.Webhook{Scope: "chat", IsActive: false}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GlobalWebhookMatches(&tt.webhook, "callback_query"))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/pkg/webhooksig"
+)
+
+// responseSnippetSize is how much of a webhook response body is kept
+const responseSnippetSize = 1024
+
+// WebhookResponse is the outcome of posting a payload to a webhook endpoint
+type WebhookResponse struct {
+	StatusCode *int // nil when no response was received
+	Body       string
+	Latency    time.Duration
+}
+
+// OK reports whether the endpoint accepted the payload
+func (r *WebhookResponse) OK() bool {
+	return r.StatusCode != nil && *r.StatusCode >= 200 && *r.StatusCode < 300
+}
+
+// WebhookSender posts signed payloads to webhook endpoints
+type WebhookSender struct {
+	webhookService *WebhookService
+	httpClient     *http.Client
+}
+
+// NewWebhookSender creates a webhook sender whose requests time out after timeout
+func NewWebhookSender(webhookService *WebhookService, timeout time.Duration) *WebhookSender {
+	return &WebhookSender{
+		webhookService: webhookService,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Send posts a payload signed with the webhook's secrets. deliveryID identifies the delivery
+// to the receiver and must stay the same across retries. The response is returned with
+// the start of its body even when the endpoint rejected the payload; the error is set
+// whenever the response is not 2xx.
+func (s *WebhookSender) Send(ctx context.Context, webhook *domain.Webhook, deliveryID, event string, payload []byte) (*WebhookResponse, error) {
+	result := &WebhookResponse{}
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return result, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Add headers
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TelegramBotGateway/1.0")
+	req.Header.Set("X-Webhook-Event", event)
+
+	// Sign the timestamped payload so captured requests can't be replayed later
+	now := time.Now()
+	req.Header.Set(webhooksig.HeaderID, deliveryID)
+	req.Header.Set(webhooksig.HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(webhooksig.HeaderSignature, webhooksig.Header(now, payload, s.webhookService.SigningSecrets(webhook)...))
+
+	// Send request
+	start := time.Now()
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		result.Latency = time.Since(start)
+		return result, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body (limit to 1MB)
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	result.Latency = time.Since(start)
+
+	statusCode := resp.StatusCode
+	result.StatusCode = &statusCode
+	result.Body = responseSnippet(body)
+
+	// Check response status
+	if !result.OK() {
+		return result, fmt.Errorf("webhook returned status %d: %s", statusCode, result.Body)
+	}
+
+	return result, nil
+}
+
+// responseSnippet returns the start of a response body as valid UTF-8
+func responseSnippet(body []byte) string {
+	if len(body) > responseSnippetSize {
+		body = body[:responseSnippetSize]
+	}
+	return strings.ToValidUTF8(string(body), "")
+}
@@ -2,16 +2,22 @@ package service
 
 import (
 	"context"
-	"crypto/hmac"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/kexi/telegram-bot-gateway/internal/domain"
 	"github.com/kexi/telegram-bot-gateway/internal/repository"
 )
 
+// Secret rotation overlaps, during which deliveries are signed with the old and the new secret
+const (
+	DefaultSecretOverlap = 24 * time.Hour
+	MaxSecretOverlap     = 7 * 24 * time.Hour
+)
+
 // WebhookService handles webhook operations
 type WebhookService struct {
 	webhookRepo repository.WebhookRepository
@@ -37,8 +43,13 @@ type WebhookDTO struct {
 	Scope            string `json:"scope"`
 	ChatID           *uint  `json:"chat_id,omitempty"`
 	ReplyToMessageID *int64 `json:"reply_to_message_id,omitempty"`
+	APIKeyID         *uint  `json:"api_key_id,omitempty"`
 	Events           string `json:"events,omitempty"`
 	IsActive         bool   `json:"is_active"`
+	SchemaVersion    int    `json:"schema_version"`
+	IncludeRaw       bool   `json:"include_raw"`
+
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"` // End of the rotation overlap
 }
 
 // CreateWebhookRequest represents a webhook creation request
@@ -48,6 +59,8 @@ type CreateWebhookRequest struct {
 	ChatID           *uint  `json:"chat_id"`
 	ReplyToMessageID *int64 `json:"reply_to_message_id"`
 	Events           string `json:"events"`
+	SchemaVersion    int    `json:"schema_version" binding:"omitempty,oneof=1 2"` // Payload schema: 1 (flat, default) or 2 (event envelope)
+	IncludeRaw       bool   `json:"include_raw"`                                  // Add the raw Telegram object to envelopes
 }
 
 // CreateWebhook registers a new webhook
@@ -90,6 +103,8 @@ func (s *WebhookService) CreateWebhook(ctx context.Context, req *CreateWebhookRe
 		ReplyToMessageID: req.ReplyToMessageID,
 		Events:           req.Events,
 		IsActive:         true,
+		SchemaVersion:    payloadSchemaVersion(req.SchemaVersion),
+		IncludeRaw:       req.IncludeRaw,
 	}
 
 	if err := s.webhookRepo.Create(ctx, webhook); err != nil {
@@ -105,25 +120,29 @@ func (s *WebhookService) CreateWebhook(ctx context.Context, req *CreateWebhookRe
 		ReplyToMessageID: webhook.ReplyToMessageID,
 		Events:           webhook.Events,
 		IsActive:         webhook.IsActive,
+		SchemaVersion:    webhook.SchemaVersion,
+		IncludeRaw:       webhook.IncludeRaw,
 	}, nil
 }
 
 // GetWebhook retrieves a webhook by ID
 func (s *WebhookService) GetWebhook(ctx context.Context, id uint) (*WebhookDTO, error) {
-	webhook, err := s.webhookRepo.GetByID(ctx, id)
+	webhook, err := s.getWebhook(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("webhook not found: %w", err)
+		return nil, err
 	}
 
-	return &WebhookDTO{
-		ID:               webhook.ID,
-		URL:              webhook.URL,
-		Scope:            webhook.Scope,
-		ChatID:           webhook.ChatID,
-		ReplyToMessageID: webhook.ReplyToMessageID,
-		Events:           webhook.Events,
-		IsActive:         webhook.IsActive,
-	}, nil
+	return toWebhookDTO(webhook, ""), nil
+}
+
+// getWebhook loads a chat or reply webhook. Feedback webhooks belong to their API key and
+// are only managed through the feedback methods, so they are reported as not found.
+func (s *WebhookService) getWebhook(ctx context.Context, id uint) (*domain.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByID(ctx, id)
+	if err != nil || webhook.Scope == "feedback" {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
 }
 
 // ListWebhooksByChat retrieves webhooks for a specific chat
@@ -133,9 +152,12 @@ func (s *WebhookService) ListWebhooksByChat(ctx context.Context, chatID uint) ([
 		return nil, fmt.Errorf("failed to list webhooks: %w", err)
 	}
 
-	result := make([]WebhookDTO, len(webhooks))
-	for i, wh := range webhooks {
-		result[i] = WebhookDTO{
+	result := make([]WebhookDTO, 0, len(webhooks))
+	for _, wh := range webhooks {
+		if wh.Scope == "feedback" {
+			continue
+		}
+		result = append(result, WebhookDTO{
 			ID:               wh.ID,
 			URL:              wh.URL,
 			Scope:            wh.Scope,
@@ -143,48 +165,237 @@ func (s *WebhookService) ListWebhooksByChat(ctx context.Context, chatID uint) ([
 			ReplyToMessageID: wh.ReplyToMessageID,
 			Events:           wh.Events,
 			IsActive:         wh.IsActive,
-		}
+			SchemaVersion:    wh.SchemaVersion,
+			IncludeRaw:       wh.IncludeRaw,
+		})
 	}
 
 	return result, nil
 }
 
+// SetFeedbackWebhookRequest represents a feedback webhook registration request
+type SetFeedbackWebhookRequest struct {
+	URL           string `json:"url" binding:"required"`
+	Events        string `json:"events"`                                       // Defaults to all message events
+	SchemaVersion int    `json:"schema_version" binding:"omitempty,oneof=1 2"` // Payload schema: 1 (flat, default) or 2 (event envelope)
+	IncludeRaw    bool   `json:"include_raw"`                                  // Add the raw Telegram message to envelopes
+}
+
+// SetFeedbackWebhook registers the URL that receives incoming messages for an API key,
+// replacing the previous one. The secret is only generated, and returned, on first registration.
+func (s *WebhookService) SetFeedbackWebhook(ctx context.Context, apiKeyID uint, req *SetFeedbackWebhookRequest) (*WebhookDTO, error) {
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url: must be an absolute http(s) URL")
+	}
+
+	webhook, err := s.webhookRepo.GetFeedbackByAPIKey(ctx, apiKeyID)
+	if err == nil {
+		webhook.URL = req.URL
+		webhook.Events = req.Events
+		webhook.IsActive = true
+		webhook.SchemaVersion = payloadSchemaVersion(req.SchemaVersion)
+		webhook.IncludeRaw = req.IncludeRaw
+		if err := s.webhookRepo.Update(ctx, webhook); err != nil {
+			return nil, fmt.Errorf("failed to update webhook: %w", err)
+		}
+		return toFeedbackWebhookDTO(webhook, ""), nil
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	webhook = &domain.Webhook{
+		URL:      req.URL,
+		Secret:   secret,
+		Scope:    "feedback",
+		APIKeyID: &apiKeyID,
+		Events:   req.Events,
+		IsActive: true,
+
+		SchemaVersion: payloadSchemaVersion(req.SchemaVersion),
+		IncludeRaw:    req.IncludeRaw,
+	}
+	if err := s.webhookRepo.Create(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return toFeedbackWebhookDTO(webhook, secret), nil
+}
+
+// GetFeedbackWebhook retrieves the feedback webhook of an API key
+func (s *WebhookService) GetFeedbackWebhook(ctx context.Context, apiKeyID uint) (*WebhookDTO, error) {
+	webhook, err := s.webhookRepo.GetFeedbackByAPIKey(ctx, apiKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("webhook not found: %w", err)
+	}
+
+	return toFeedbackWebhookDTO(webhook, ""), nil
+}
+
+// DeleteFeedbackWebhook removes the feedback webhook of an API key
+func (s *WebhookService) DeleteFeedbackWebhook(ctx context.Context, apiKeyID uint) error {
+	webhook, err := s.webhookRepo.GetFeedbackByAPIKey(ctx, apiKeyID)
+	if err != nil {
+		return fmt.Errorf("webhook not found: %w", err)
+	}
+
+	return s.webhookRepo.Delete(ctx, webhook.ID)
+}
+
 // ListActiveWebhooks retrieves all active webhooks
 func (s *WebhookService) ListActiveWebhooks(ctx context.Context) ([]domain.Webhook, error) {
 	return s.webhookRepo.ListActive(ctx)
 }
 
-// UpdateWebhook updates webhook settings
-func (s *WebhookService) UpdateWebhook(ctx context.Context, id uint, url string, isActive bool) error {
-	webhook, err := s.webhookRepo.GetByID(ctx, id)
+// UpdateWebhook updates webhook settings. A zero schemaVersion and a nil includeRaw keep
+// the payload format.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id uint, url string, isActive bool, schemaVersion int, includeRaw *bool) error {
+	webhook, err := s.getWebhook(ctx, id)
 	if err != nil {
-		return fmt.Errorf("webhook not found: %w", err)
+		return err
 	}
 
 	if url != "" {
 		webhook.URL = url
 	}
 	webhook.IsActive = isActive
+	if schemaVersion != 0 {
+		webhook.SchemaVersion = schemaVersion
+	}
+	if includeRaw != nil {
+		webhook.IncludeRaw = *includeRaw
+	}
 
 	return s.webhookRepo.Update(ctx, webhook)
 }
 
+// RecordDeliveryFailure stores the last failed delivery attempt of a webhook for its health report
+func (s *WebhookService) RecordDeliveryFailure(ctx context.Context, webhookID uint, message string) error {
+	return s.webhookRepo.RecordFailure(ctx, webhookID, time.Now(), message)
+}
+
 // DeleteWebhook deletes a webhook
 func (s *WebhookService) DeleteWebhook(ctx context.Context, id uint) error {
+	if _, err := s.getWebhook(ctx, id); err != nil {
+		return err
+	}
 	return s.webhookRepo.Delete(ctx, id)
 }
 
-// SignPayload creates an HMAC-SHA256 signature for webhook payload
-func (s *WebhookService) SignPayload(secret string, payload []byte) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write(payload)
-	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+// SigningSecrets returns the secrets the deliveries of a webhook are signed with: its
+// current secret and, until the rotation overlap ends, the secret it replaced
+func (s *WebhookService) SigningSecrets(webhook *domain.Webhook) []string {
+	secrets := []string{webhook.Secret}
+	if webhook.PreviousSecret != "" && webhook.PreviousSecretExpiresAt != nil && webhook.PreviousSecretExpiresAt.After(time.Now()) {
+		secrets = append(secrets, webhook.PreviousSecret)
+	}
+	return secrets
+}
+
+// RotateSecret replaces the secret of a webhook and returns the new one. Deliveries stay
+// signed with the old secret as well for the overlap, giving the receiver time to switch.
+// Rotating again during an overlap drops the oldest secret right away.
+// Feedback webhooks are rotated by their API key through RotateFeedbackSecret.
+func (s *WebhookService) RotateSecret(ctx context.Context, id uint, overlap time.Duration) (*WebhookDTO, error) {
+	webhook, err := s.getWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := s.rotateSecret(ctx, webhook, overlap)
+	if err != nil {
+		return nil, err
+	}
+
+	return toWebhookDTO(webhook, secret), nil
 }
 
-// VerifySignature verifies webhook signature
-func (s *WebhookService) VerifySignature(secret string, payload []byte, signature string) bool {
-	expectedSignature := s.SignPayload(secret, payload)
-	return hmac.Equal([]byte(expectedSignature), []byte(signature))
+// RotateFeedbackSecret replaces the secret of the feedback webhook of an API key, like RotateSecret
+func (s *WebhookService) RotateFeedbackSecret(ctx context.Context, apiKeyID uint, overlap time.Duration) (*WebhookDTO, error) {
+	webhook, err := s.webhookRepo.GetFeedbackByAPIKey(ctx, apiKeyID)
+	if err != nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	secret, err := s.rotateSecret(ctx, webhook, overlap)
+	if err != nil {
+		return nil, err
+	}
+
+	return toFeedbackWebhookDTO(webhook, secret), nil
+}
+
+// rotateSecret stores a new secret on a webhook, keeping the current one for the overlap
+func (s *WebhookService) rotateSecret(ctx context.Context, webhook *domain.Webhook, overlap time.Duration) (string, error) {
+	if overlap < 0 || overlap > MaxSecretOverlap {
+		return "", fmt.Errorf("invalid overlap: must be between 0 and %s", MaxSecretOverlap)
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	webhook.PreviousSecret = ""
+	webhook.PreviousSecretExpiresAt = nil
+	if overlap > 0 {
+		expiresAt := time.Now().Add(overlap)
+		webhook.PreviousSecret = webhook.Secret
+		webhook.PreviousSecretExpiresAt = &expiresAt
+	}
+	webhook.Secret = secret
+
+	if err := s.webhookRepo.Update(ctx, webhook); err != nil {
+		return "", fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	return secret, nil
+}
+
+// toWebhookDTO converts a webhook to its DTO; secret is only set when it was just generated
+func toWebhookDTO(webhook *domain.Webhook, secret string) *WebhookDTO {
+	return &WebhookDTO{
+		ID:               webhook.ID,
+		URL:              webhook.URL,
+		Secret:           secret,
+		Scope:            webhook.Scope,
+		ChatID:           webhook.ChatID,
+		ReplyToMessageID: webhook.ReplyToMessageID,
+		APIKeyID:         webhook.APIKeyID,
+		Events:           webhook.Events,
+		IsActive:         webhook.IsActive,
+		SchemaVersion:    webhook.SchemaVersion,
+		IncludeRaw:       webhook.IncludeRaw,
+
+		PreviousSecretExpiresAt: webhook.PreviousSecretExpiresAt,
+	}
+}
+
+// toFeedbackWebhookDTO converts a feedback webhook to its DTO; secret is only set on creation
+func toFeedbackWebhookDTO(webhook *domain.Webhook, secret string) *WebhookDTO {
+	return &WebhookDTO{
+		ID:       webhook.ID,
+		URL:      webhook.URL,
+		Secret:   secret,
+		Scope:    webhook.Scope,
+		APIKeyID: webhook.APIKeyID,
+		Events:   webhook.Events,
+		IsActive: webhook.IsActive,
+
+		SchemaVersion:           webhook.SchemaVersion,
+		IncludeRaw:              webhook.IncludeRaw,
+		PreviousSecretExpiresAt: webhook.PreviousSecretExpiresAt,
+	}
+}
+
+// payloadSchemaVersion returns the requested payload schema version, the flat payload by default
+func payloadSchemaVersion(version int) int {
+	if version == 0 {
+		return domain.EventSchemaFlat
+	}
+	return version
 }
 
 // generateSecret generates a random secret for webhook signing
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/pkg/webhooksig"
+)
+
+func TestRotateSecretSignsWithBothSecretsDuringOverlap(t *testing.T) {
+	var verifyOld, verifyNew error
+	var newSecret string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyOld = webhooksig.VerifyRequest(r, body, 0, "secret")
+		verifyNew = webhooksig.VerifyRequest(r, body, 0, newSecret)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer endpoint.Close()
+
+	deliveries, _, _ := newDeliveryTestService()
+	webhookRepo := deliveries.webhookRepo.(*fakeWebhookRepository)
+	webhookRepo.webhooks[7].URL = endpoint.URL
+	svc := NewWebhookService(webhookRepo, nil)
+
+	rotated, err := svc.RotateSecret(context.Background(), 7, time.Hour)
+	require.NoError(t, err)
+	newSecret = rotated.Secret
+	assert.NotEmpty(t, newSecret)
+	assert.NotEqual(t, "secret", newSecret)
+	require.NotNil(t, rotated.PreviousSecretExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *rotated.PreviousSecretExpiresAt, time.Minute)
+
+	stored := webhookRepo.webhooks[7]
+	assert.Equal(t, newSecret, stored.Secret)
+	assert.Equal(t, "secret", stored.PreviousSecret)
+
+	result, err := deliveries.Ping(context.Background(), 7)
+	require.NoError(t, err)
+	assert.True(t, result.OK)
+	assert.NoError(t, verifyOld, "receivers still on the old secret")
+	assert.NoError(t, verifyNew, "receivers already on the new secret")
+}
+
+func TestRotateSecretWithoutOverlap(t *testing.T) {
+	deliveries, _, _ := newDeliveryTestService()
+	webhookRepo := deliveries.webhookRepo.(*fakeWebhookRepository)
+	svc := NewWebhookService(webhookRepo, nil)
+
+	rotated, err := svc.RotateSecret(context.Background(), 7, 0)
+	require.NoError(t, err)
+	assert.Nil(t, rotated.PreviousSecretExpiresAt)
+	assert.Equal(t, []string{rotated.Secret}, svc.SigningSecrets(webhookRepo.webhooks[7]))
+
+	_, err = svc.RotateSecret(context.Background(), 99, time.Hour)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
+
+func TestRotateSecretRejectsFeedbackWebhook(t *testing.T) {
+	apiKeyID := uint(3)
+	webhookRepo := &fakeWebhookRepository{webhooks: map[uint]*domain.Webhook{
+		8: {ID: 8, URL: "https://example.com/feedback", Secret: "secret", Scope: "feedback", APIKeyID: &apiKeyID, IsActive: true},
+	}}
+	svc := NewWebhookService(webhookRepo, nil)
+
+	_, err := svc.RotateSecret(context.Background(), 8, time.Hour)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	assert.Equal(t, "secret", webhookRepo.webhooks[8].Secret)
+}
+
+func TestSigningSecretsDropsExpiredPreviousSecret(t *testing.T) {
+	svc := NewWebhookService(&fakeWebhookRepository{}, nil)
+	expired := time.Now().Add(-time.Minute)
+	webhook := &domain.Webhook{Secret: "new", PreviousSecret: "old", PreviousSecretExpiresAt: &expired}
+
+	assert.Equal(t, []string{"new"}, svc.SigningSecrets(webhook))
+}
+
+func TestFeedbackWebhookIsHiddenFromGenericMethods(t *testing.T) {
+	apiKeyID := uint(3)
+	chatID := uint(5)
+	webhookRepo := &fakeWebhookRepository{webhooks: map[uint]*domain.Webhook{
+		8: {ID: 8, URL: "https://example.com/feedback", Secret: "secret", Scope: "feedback", APIKeyID: &apiKeyID, ChatID: &chatID, IsActive: true},
+	}}
+	svc := NewWebhookService(webhookRepo, nil)
+	ctx := context.Background()
+
+	_, err := svc.GetWebhook(ctx, 8)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+
+	err = svc.UpdateWebhook(ctx, 8, "https://attacker.example.com", true, 0, nil)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	assert.Equal(t, "https://example.com/feedback", webhookRepo.webhooks[8].URL)
+
+	err = svc.DeleteWebhook(ctx, 8)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
@@ -8,8 +8,25 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
 
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/middleware"
 	"github.com/kexi/telegram-bot-gateway/internal/pubsub"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// permissionRecheckInterval is how often subscriptions are revalidated against the ACLs,
+// as a fallback for permission changes that were not announced on the broker
+const permissionRecheckInterval = time.Minute
+
+// Error codes sent in error frames
+const (
+	ErrorCodeInvalidMessage   = "invalid_message"
+	ErrorCodeUnknownAction    = "unknown_action"
+	ErrorCodeInvalidChat      = "invalid_chat"
+	ErrorCodePermissionDenied = "permission_denied"
+	ErrorCodeInternal         = "internal_error"
 )
 
 // Hub manages WebSocket connections
@@ -19,15 +36,19 @@ type Hub struct {
 	register      chan *Client
 	unregister    chan *Client
 	messageBroker *pubsub.MessageBroker
+	chatPermRepo  repository.ChatPermissionRepository
+	redisClient   *redis.Client
 }
 
 // NewHub creates a new WebSocket hub
-func NewHub(messageBroker *pubsub.MessageBroker) *Hub {
+func NewHub(messageBroker *pubsub.MessageBroker, chatPermRepo repository.ChatPermissionRepository, redisClient *redis.Client) *Hub {
 	return &Hub{
 		clients:       make(map[*Client]bool),
 		register:      make(chan *Client),
 		unregister:    make(chan *Client),
 		messageBroker: messageBroker,
+		chatPermRepo:  chatPermRepo,
+		redisClient:   redisClient,
 	}
 }
 
@@ -35,6 +56,21 @@ func NewHub(messageBroker *pubsub.MessageBroker) *Hub {
 func (h *Hub) Run(ctx context.Context) {
 	log.Println("WebSocket hub starting")
 
+	// Receive all message events and fan them out to subscribed clients
+	events, err := h.messageBroker.SubscribeToAll(ctx)
+	if err != nil {
+		log.Printf("WebSocket hub failed to subscribe to messages: %v", err)
+	}
+
+	// Receive permission changes so revoked subscriptions are dropped immediately
+	permChanges, err := h.messageBroker.SubscribeToPermissionChanges(ctx)
+	if err != nil {
+		log.Printf("WebSocket hub failed to subscribe to permission changes: %v", err)
+	}
+
+	recheckTicker := time.NewTicker(permissionRecheckInterval)
+	defer recheckTicker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -42,6 +78,33 @@ func (h *Hub) Run(ctx context.Context) {
 			h.closeAllClients()
 			return
 
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			data, err := encodeEvent(event)
+			if err != nil {
+				log.Printf("Failed to marshal event for WebSocket clients: %v", err)
+				continue
+			}
+			if event.APIKeyID != nil {
+				// Callback queries only go to the API key that sent the keyboard
+				h.BroadcastToAPIKey(*event.APIKeyID, event.ChatID, data)
+				continue
+			}
+			h.BroadcastToChat(event.ChatID, data)
+
+		case change, ok := <-permChanges:
+			if !ok {
+				permChanges = nil
+				continue
+			}
+			go h.revalidateSubscriptions(ctx, change)
+
+		case <-recheckTicker.C:
+			go h.revalidateSubscriptions(ctx, nil)
+
 		case client := <-h.register:
 			h.clientsMu.Lock()
 			h.clients[client] = true
@@ -70,13 +133,17 @@ func (h *Hub) UnregisterClient(client *Client) {
 	h.unregister <- client
 }
 
-// BroadcastToChat broadcasts a message to all clients subscribed to a chat
-func (h *Hub) BroadcastToChat(chatID uint, message []byte) {
+// BroadcastToChat broadcasts an event to all clients subscribed to a chat
+func (h *Hub) BroadcastToChat(chatID uint, event *EncodedEvent) {
 	h.clientsMu.RLock()
 	defer h.clientsMu.RUnlock()
 
 	for client := range h.clients {
 		if client.IsSubscribedToChat(chatID) {
+			message := event.forClient(client)
+			if message == nil {
+				continue
+			}
 			select {
 			case client.send <- message:
 			default:
@@ -87,6 +154,128 @@ func (h *Hub) BroadcastToChat(chatID uint, message []byte) {
 	}
 }
 
+// BroadcastToAPIKey broadcasts an event to the clients of one API key subscribed to a chat
+func (h *Hub) BroadcastToAPIKey(apiKeyID, chatID uint, event *EncodedEvent) {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+
+	for client := range h.clients {
+		if !client.isAPIKey(apiKeyID) || !client.IsSubscribedToChat(chatID) {
+			continue
+		}
+		message := event.forClient(client)
+		if message == nil {
+			continue
+		}
+		select {
+		case client.send <- message:
+		default:
+			log.Printf("Skipping slow client %s", client.id)
+		}
+	}
+}
+
+// EncodedEvent is a broker event encoded in each format clients can ask for
+type EncodedEvent struct {
+	flat        []byte
+	envelope    []byte // nil when the event has no envelope
+	envelopeRaw []byte
+}
+
+// encodeEvent encodes a broker event for WebSocket clients
+func encodeEvent(event *pubsub.MessageEvent) (*EncodedEvent, error) {
+	flat := *event
+	flat.Envelope = nil
+
+	var encoded EncodedEvent
+	var err error
+	if encoded.flat, err = json.Marshal(&flat); err != nil {
+		return nil, err
+	}
+	if event.Envelope != nil {
+		if encoded.envelope, err = json.Marshal(event.Envelope.WithoutRaw()); err != nil {
+			return nil, err
+		}
+		if encoded.envelopeRaw, err = json.Marshal(event.Envelope); err != nil {
+			return nil, err
+		}
+	}
+	return &encoded, nil
+}
+
+// forClient returns the event in the format of a client, nil when it can't be sent in it
+func (e *EncodedEvent) forClient(client *Client) []byte {
+	if client.format.SchemaVersion < domain.EventSchemaEnvelope {
+		return e.flat
+	}
+	if client.format.IncludeRaw {
+		return e.envelopeRaw
+	}
+	return e.envelope
+}
+
+// revalidateSubscriptions drops subscriptions the clients are no longer allowed to read.
+// With a nil change every subscription of every client is checked.
+func (h *Hub) revalidateSubscriptions(ctx context.Context, change *pubsub.PermissionChangeEvent) {
+	h.clientsMu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		if change == nil || client.affectedBy(change) {
+			clients = append(clients, client)
+		}
+	}
+	h.clientsMu.RUnlock()
+
+	for _, client := range clients {
+		var chatIDs []uint
+		if change != nil {
+			if client.IsSubscribedToChat(change.ChatID) {
+				chatIDs = []uint{change.ChatID}
+			}
+		} else {
+			chatIDs = client.subscribedChats()
+		}
+
+		for _, chatID := range chatIDs {
+			allowed, err := h.canRead(ctx, client, chatID)
+			if err != nil {
+				log.Printf("Failed to revalidate subscription of client %s to chat %d: %v", client.id, chatID, err)
+				continue
+			}
+			if allowed {
+				continue
+			}
+
+			client.UnsubscribeFromChat(chatID)
+			h.sendToClient(client, subscriptionRevokedFrame(chatID))
+		}
+	}
+}
+
+// canRead checks whether a client may read a chat using the same rules as ChatACLMiddleware
+func (h *Hub) canRead(ctx context.Context, client *Client, chatID uint) (bool, error) {
+	if client.authCtx == nil {
+		return false, nil
+	}
+	return middleware.CheckChatPermission(ctx, client.authCtx, chatID, middleware.PermissionRead, h.chatPermRepo, h.redisClient)
+}
+
+// sendToClient queues a frame for a client that is still registered
+func (h *Hub) sendToClient(client *Client, message []byte) {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+
+	if !h.clients[client] {
+		return
+	}
+
+	select {
+	case client.send <- message:
+	default:
+		log.Printf("Skipping slow client %s", client.id)
+	}
+}
+
 // GetClientCount returns the number of connected clients
 func (h *Hub) GetClientCount() int {
 	h.clientsMu.RLock()
@@ -113,23 +302,57 @@ type Client struct {
 	send          chan []byte
 	subscriptions map[uint]bool // chat IDs
 	subMu         sync.RWMutex
-	userID        *uint
-	apiKeyID      *uint
+	authCtx       *middleware.AuthContext
+	format        EventFormat
 }
 
-// NewClient creates a new WebSocket client
-func NewClient(id string, hub *Hub, conn *websocket.Conn, userID *uint, apiKeyID *uint) *Client {
+// EventFormat selects the shape of the events sent to a client
+type EventFormat struct {
+	SchemaVersion int  // domain.EventSchemaFlat or domain.EventSchemaEnvelope
+	IncludeRaw    bool // Keep the raw Telegram object in envelopes
+}
+
+// NewClient creates a new WebSocket client for an authenticated user or API key
+func NewClient(id string, hub *Hub, conn *websocket.Conn, authCtx *middleware.AuthContext, format EventFormat) *Client {
 	return &Client{
 		id:            id,
 		hub:           hub,
 		conn:          conn,
 		send:          make(chan []byte, 256),
 		subscriptions: make(map[uint]bool),
-		userID:        userID,
-		apiKeyID:      apiKeyID,
+		authCtx:       authCtx,
+		format:        format,
 	}
 }
 
+// isAPIKey reports whether the client authenticated with the given API key
+func (c *Client) isAPIKey(apiKeyID uint) bool {
+	return c.authCtx != nil && c.authCtx.IsAPIKey && c.authCtx.APIKeyID != nil && *c.authCtx.APIKeyID == apiKeyID
+}
+
+// affectedBy reports whether a permission change concerns this client
+func (c *Client) affectedBy(change *pubsub.PermissionChangeEvent) bool {
+	if c.authCtx == nil {
+		return false
+	}
+	if c.authCtx.IsAPIKey {
+		return change.APIKeyID != nil && c.authCtx.APIKeyID != nil && *change.APIKeyID == *c.authCtx.APIKeyID
+	}
+	return change.UserID != nil && *change.UserID == c.authCtx.UserID
+}
+
+// subscribedChats returns the chat IDs the client is subscribed to
+func (c *Client) subscribedChats() []uint {
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
+
+	chatIDs := make([]uint, 0, len(c.subscriptions))
+	for chatID := range c.subscriptions {
+		chatIDs = append(chatIDs, chatID)
+	}
+	return chatIDs
+}
+
 // IsSubscribedToChat checks if client is subscribed to a chat
 func (c *Client) IsSubscribedToChat(chatID uint) bool {
 	c.subMu.RLock()
@@ -229,18 +452,35 @@ func (c *Client) handleClientMessage(message []byte) {
 	var msg ClientMessage
 	if err := json.Unmarshal(message, &msg); err != nil {
 		log.Printf("Failed to parse client message: %v", err)
-		c.sendError("Invalid message format")
+		c.sendError(ErrorCodeInvalidMessage, "Invalid message format", 0)
 		return
 	}
 
 	switch msg.Action {
 	case "subscribe":
-		if msg.ChatID > 0 {
-			// TODO: Check ACL permissions before subscribing
-			c.SubscribeToChat(msg.ChatID)
-			c.sendAck("subscribed", msg.ChatID)
+		if msg.ChatID == 0 {
+			c.sendError(ErrorCodeInvalidChat, "Chat ID required", 0)
+			return
+		}
+		if c.authCtx != nil && !c.authCtx.HasScope(middleware.ScopeWSSubscribe) {
+			c.sendError(ErrorCodePermissionDenied, middleware.ScopeDeniedMessage(middleware.ScopeWSSubscribe), msg.ChatID)
+			return
 		}
 
+		allowed, err := c.hub.canRead(context.Background(), c, msg.ChatID)
+		if err != nil {
+			log.Printf("Failed to check permissions for client %s on chat %d: %v", c.id, msg.ChatID, err)
+			c.sendError(ErrorCodeInternal, "Failed to check permissions", msg.ChatID)
+			return
+		}
+		if !allowed {
+			c.sendError(ErrorCodePermissionDenied, middleware.ChatPermissionDeniedMessage(middleware.PermissionRead), msg.ChatID)
+			return
+		}
+
+		c.SubscribeToChat(msg.ChatID)
+		c.sendAck("subscribed", msg.ChatID)
+
 	case "unsubscribe":
 		if msg.ChatID > 0 {
 			c.UnsubscribeFromChat(msg.ChatID)
@@ -251,7 +491,7 @@ func (c *Client) handleClientMessage(message []byte) {
 		c.sendAck("pong", 0)
 
 	default:
-		c.sendError("Unknown action")
+		c.sendError(ErrorCodeUnknownAction, "Unknown action", 0)
 	}
 }
 
@@ -266,16 +506,32 @@ func (c *Client) sendAck(action string, chatID uint) {
 	c.send <- data
 }
 
-// sendError sends an error message to the client
-func (c *Client) sendError(errorMsg string) {
+// sendError sends a structured error frame to the client
+func (c *Client) sendError(code, errorMsg string, chatID uint) {
 	response := map[string]interface{}{
 		"type":  "error",
+		"code":  code,
 		"error": errorMsg,
 	}
+	if chatID > 0 {
+		response["chat_id"] = chatID
+	}
 	data, _ := json.Marshal(response)
 	c.send <- data
 }
 
+// subscriptionRevokedFrame builds the frame sent when a subscription is dropped
+func subscriptionRevokedFrame(chatID uint) []byte {
+	response := map[string]interface{}{
+		"type":    "subscription_revoked",
+		"code":    ErrorCodePermissionDenied,
+		"error":   "Read permission for this chat was revoked",
+		"chat_id": chatID,
+	}
+	data, _ := json.Marshal(response)
+	return data
+}
+
 // ClientMessage represents a message from the client
 type ClientMessage struct {
 	Action string `json:"action"` // "subscribe", "unsubscribe", "ping"
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+// MediaWorker downloads files attached to received messages into blob storage
+type MediaWorker struct {
+	mediaService *service.MediaService
+	interval     time.Duration
+	batchSize    int
+}
+
+// NewMediaWorker creates a new media worker
+func NewMediaWorker(mediaService *service.MediaService) *MediaWorker {
+	return &MediaWorker{
+		mediaService: mediaService,
+		interval:     5 * time.Second,
+		batchSize:    20,
+	}
+}
+
+// Start downloads pending files in batches until the context is cancelled
+func (w *MediaWorker) Start(ctx context.Context) {
+	log.Println("Media worker: Starting")
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Media worker: Stopped")
+			return
+		case <-ticker.C:
+			if _, err := w.mediaService.FetchPending(ctx, w.batchSize); err != nil && ctx.Err() == nil {
+				log.Printf("Media worker: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+// OutboundWorker delivers messages queued for asynchronous sending
+type OutboundWorker struct {
+	outboundService *service.OutboundService
+	interval        time.Duration
+	batchSize       int
+}
+
+// NewOutboundWorker creates a new outbound worker
+func NewOutboundWorker(outboundService *service.OutboundService, interval time.Duration, batchSize int) *OutboundWorker {
+	return &OutboundWorker{
+		outboundService: outboundService,
+		interval:        interval,
+		batchSize:       batchSize,
+	}
+}
+
+// Start delivers due messages until the context is cancelled. A full batch is
+// followed by the next one right away instead of waiting for the next tick.
+func (w *OutboundWorker) Start(ctx context.Context) {
+	log.Println("Outbound worker: Starting")
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Outbound worker: Stopped")
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				delivered, err := w.outboundService.DeliverQueued(ctx, w.batchSize)
+				if err != nil && ctx.Err() == nil {
+					log.Printf("Outbound worker: %v", err)
+				}
+				if err != nil || delivered < w.batchSize {
+					break
+				}
+			}
+		}
+	}
+}
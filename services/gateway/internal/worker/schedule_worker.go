@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+// ScheduleWorker hands scheduled messages to the outbound queue once their send time has come
+type ScheduleWorker struct {
+	scheduleService *service.ScheduleService
+	interval        time.Duration
+	batchSize       int
+}
+
+// NewScheduleWorker creates a new schedule worker
+func NewScheduleWorker(scheduleService *service.ScheduleService, interval time.Duration, batchSize int) *ScheduleWorker {
+	return &ScheduleWorker{
+		scheduleService: scheduleService,
+		interval:        interval,
+		batchSize:       batchSize,
+	}
+}
+
+// Start releases due messages until the context is cancelled
+func (w *ScheduleWorker) Start(ctx context.Context) {
+	log.Println("Schedule worker: Starting")
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Schedule worker: Stopped")
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				released, err := w.scheduleService.ReleaseDue(ctx, w.batchSize)
+				if err != nil && ctx.Err() == nil {
+					log.Printf("Schedule worker: %v", err)
+				}
+				if err != nil || released < w.batchSize {
+					break
+				}
+			}
+		}
+	}
+}
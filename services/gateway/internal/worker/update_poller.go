@@ -0,0 +1,199 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+// maxUpdateAttempts is how often a polled update is processed before it is skipped, so
+// one update that keeps failing does not hold back the updates after it
+const maxUpdateAttempts = 5
+
+// UpdateProcessor processes a raw Telegram update for a bot. Updates that can never be
+// processed are reported with an error wrapping service.ErrInvalidUpdate.
+type UpdateProcessor interface {
+	ProcessRawUpdate(ctx context.Context, botID uint, data []byte) error
+}
+
+// UpdatePoller pulls updates with getUpdates for bots in polling mode
+// and feeds them into the same pipeline as the Telegram webhook endpoint
+type UpdatePoller struct {
+	botService      *service.BotService
+	processor       UpdateProcessor
+	pollTimeout     time.Duration
+	refreshInterval time.Duration
+	retryDelay      time.Duration
+	pollers         map[uint]context.CancelFunc
+	pollersMu       sync.Mutex
+	wg              sync.WaitGroup
+}
+
+// NewUpdatePoller creates a new update poller
+func NewUpdatePoller(botService *service.BotService, processor UpdateProcessor, pollTimeout time.Duration) *UpdatePoller {
+	return &UpdatePoller{
+		botService:      botService,
+		processor:       processor,
+		pollTimeout:     pollTimeout,
+		refreshInterval: 30 * time.Second,
+		retryDelay:      5 * time.Second,
+		pollers:         make(map[uint]context.CancelFunc),
+	}
+}
+
+// Start polls every bot in polling mode until the context is cancelled.
+// The bot list is refreshed periodically so bots switched to or from polling mode are picked up.
+func (p *UpdatePoller) Start(ctx context.Context) {
+	log.Println("Update poller: Starting")
+
+	ticker := time.NewTicker(p.refreshInterval)
+	defer ticker.Stop()
+
+	p.refresh(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.stopAll()
+			log.Println("Update poller: Stopped")
+			return
+		case <-ticker.C:
+			p.refresh(ctx)
+		}
+	}
+}
+
+// refresh starts pollers for new polling bots and stops pollers for bots that left polling mode
+func (p *UpdatePoller) refresh(ctx context.Context) {
+	bots, err := p.botService.ListPollingBots(ctx)
+	if err != nil {
+		log.Printf("Update poller: failed to list polling bots: %v", err)
+		return
+	}
+
+	active := make(map[uint]bool, len(bots))
+	for _, bot := range bots {
+		active[bot.ID] = true
+	}
+
+	p.pollersMu.Lock()
+	defer p.pollersMu.Unlock()
+
+	for botID, cancel := range p.pollers {
+		if !active[botID] {
+			cancel()
+			delete(p.pollers, botID)
+			log.Printf("Update poller: stopped polling bot %d", botID)
+		}
+	}
+
+	for _, bot := range bots {
+		if _, ok := p.pollers[bot.ID]; ok {
+			continue
+		}
+
+		botCtx, cancel := context.WithCancel(ctx)
+		p.pollers[bot.ID] = cancel
+		p.wg.Add(1)
+		go func(botID uint) {
+			defer p.wg.Done()
+			p.pollBot(botCtx, botID)
+		}(bot.ID)
+		log.Printf("Update poller: polling bot %d (@%s)", bot.ID, bot.Username)
+	}
+}
+
+// stopAll stops every bot poller and waits for them to exit
+func (p *UpdatePoller) stopAll() {
+	p.pollersMu.Lock()
+	for botID, cancel := range p.pollers {
+		cancel()
+		delete(p.pollers, botID)
+	}
+	p.pollersMu.Unlock()
+
+	p.wg.Wait()
+}
+
+// pollBot runs the getUpdates loop for a single bot
+func (p *UpdatePoller) pollBot(ctx context.Context, botID uint) {
+	// The poller stays registered for the bot, so keep trying until the offset loads
+	offset, err := p.botService.GetPollingOffset(ctx, botID)
+	for err != nil {
+		log.Printf("Update poller: failed to load offset for bot %d: %v", botID, err)
+		p.sleep(ctx, p.retryDelay)
+		if ctx.Err() != nil {
+			return
+		}
+		offset, err = p.botService.GetPollingOffset(ctx, botID)
+	}
+
+	// Failed attempts at the first update not yet processed
+	var failingUpdateID int64
+	attempts := 0
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		updates, err := p.botService.GetUpdates(ctx, botID, offset, p.pollTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Update poller: getUpdates failed for bot %d: %v", botID, err)
+			p.sleep(ctx, p.retryDelay)
+			continue
+		}
+
+		lastProcessed := offset
+		failed := false
+		for _, update := range updates {
+			if update.UpdateID <= lastProcessed {
+				continue
+			}
+			if err := p.processor.ProcessRawUpdate(ctx, botID, update.Raw); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if update.UpdateID != failingUpdateID {
+					failingUpdateID, attempts = update.UpdateID, 0
+				}
+				attempts++
+
+				if !errors.Is(err, service.ErrInvalidUpdate) && attempts < maxUpdateAttempts {
+					// Leave the update unconfirmed so it is fetched again, like a failed webhook call
+					log.Printf("Update poller: failed to process update %d for bot %d (attempt %d): %v", update.UpdateID, botID, attempts, err)
+					failed = true
+					break
+				}
+				log.Printf("Update poller: skipping update %d for bot %d after %d attempts: %v", update.UpdateID, botID, attempts, err)
+			}
+			lastProcessed = update.UpdateID
+		}
+
+		if lastProcessed != offset {
+			if err := p.botService.SavePollingOffset(ctx, botID, lastProcessed); err != nil {
+				log.Printf("Update poller: failed to save offset for bot %d: %v", botID, err)
+			}
+			offset = lastProcessed
+		}
+
+		if failed {
+			p.sleep(ctx, p.retryDelay)
+		}
+	}
+}
+
+// sleep waits for the given duration or until the context is cancelled
+func (p *UpdatePoller) sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
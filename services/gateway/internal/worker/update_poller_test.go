@@ -0,0 +1,258 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kexi/telegram-bot-gateway/internal/domain"
+	"github.com/kexi/telegram-bot-gateway/internal/pkg/encryption"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+	"github.com/kexi/telegram-bot-gateway/internal/service"
+)
+
+const testEncryptionKey = "dGVzdC1lbmNyeXB0aW9uLWtleS1vZi0zMi1ieXRlcyE=" // "test-encryption-key-of-32-bytes!"
+
+// fakeBotRepository serves polling bots from memory
+type fakeBotRepository struct {
+	repository.BotRepository
+	mu   sync.Mutex
+	bots map[uint]*domain.Bot
+}
+
+func (r *fakeBotRepository) GetByID(ctx context.Context, id uint) (*domain.Bot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bot, ok := r.bots[id]
+	if !ok {
+		return nil, assert.AnError
+	}
+	copied := *bot
+	return &copied, nil
+}
+
+func (r *fakeBotRepository) ListActiveByIngestionMode(ctx context.Context, mode string) ([]domain.Bot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var bots []domain.Bot
+	for _, bot := range r.bots {
+		if bot.IsActive && bot.IngestionMode == mode {
+			bots = append(bots, *bot)
+		}
+	}
+	return bots, nil
+}
+
+func (r *fakeBotRepository) UpdateLastUpdateID(ctx context.Context, id uint, updateID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bots[id].LastUpdateID = updateID
+	return nil
+}
+
+func (r *fakeBotRepository) setActive(id uint, active bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bots[id].IsActive = active
+}
+
+func (r *fakeBotRepository) lastUpdateID(id uint) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bots[id].LastUpdateID
+}
+
+// offsetFailingBotRepository fails the first offset loads, as a database outage would
+type offsetFailingBotRepository struct {
+	*fakeBotRepository
+	failures int
+	calls    int
+}
+
+func (r *offsetFailingBotRepository) GetByID(ctx context.Context, id uint) (*domain.Bot, error) {
+	r.mu.Lock()
+	r.calls++
+	fail := r.calls <= r.failures
+	r.mu.Unlock()
+	if fail {
+		return nil, assert.AnError
+	}
+	return r.fakeBotRepository.GetByID(ctx, id)
+}
+
+// recordingProcessor records processed update IDs and fails some attempts
+type recordingProcessor struct {
+	mu        sync.Mutex
+	processed []int64
+	fail      map[int64]error // Update ID -> error of its first attempt
+}
+
+func (p *recordingProcessor) ProcessRawUpdate(ctx context.Context, botID uint, data []byte) error {
+	var update struct {
+		UpdateID int64 `json:"update_id"`
+	}
+	if err := json.Unmarshal(data, &update); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processed = append(p.processed, update.UpdateID)
+	if err, ok := p.fail[update.UpdateID]; ok {
+		delete(p.fail, update.UpdateID)
+		return err
+	}
+	return nil
+}
+
+func (p *recordingProcessor) processedIDs() []int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]int64(nil), p.processed...)
+}
+
+// fakeBotAPI serves getUpdates from a fixed list of update IDs and records the offsets asked for
+type fakeBotAPI struct {
+	mu      sync.Mutex
+	offsets []int64
+	calls   int
+}
+
+func (a *fakeBotAPI) handler(updateIDs ...int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Offset int64 `json:"offset"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+
+		a.mu.Lock()
+		a.calls++
+		a.offsets = append(a.offsets, payload.Offset)
+		a.mu.Unlock()
+
+		var updates []string
+		for _, id := range updateIDs {
+			if id >= payload.Offset {
+				updates = append(updates, fmt.Sprintf(`{"update_id":%d,"message":{"message_id":%d,"date":0,"chat":{"id":5,"type":"private"},"text":"hi"}}`, id, id))
+			}
+		}
+		if len(updates) == 0 {
+			// Nothing new; answer like an expired long poll, just sooner
+			time.Sleep(10 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"result":[` + strings.Join(updates, ",") + `]}`))
+	}
+}
+
+func (a *fakeBotAPI) askedOffsets() []int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]int64(nil), a.offsets...)
+}
+
+func (a *fakeBotAPI) callCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.calls
+}
+
+// newTestPoller wires an UpdatePoller to a fake Bot API with polling bot 1
+func newTestPoller(t *testing.T, handler http.HandlerFunc, processor UpdateProcessor, wrap func(*fakeBotRepository) repository.BotRepository) (*UpdatePoller, *fakeBotRepository) {
+	api := httptest.NewServer(handler)
+	t.Cleanup(api.Close)
+
+	keyring, err := encryption.NewKeyring("test", map[string]string{"test": testEncryptionKey}, "")
+	require.NoError(t, err)
+	token, err := keyring.Encrypt("123:abc")
+	require.NoError(t, err)
+
+	repo := &fakeBotRepository{bots: map[uint]*domain.Bot{
+		1: {ID: 1, Username: "poll_bot", Token: token, IsActive: true, IngestionMode: domain.BotModePolling, LastUpdateID: 41},
+	}}
+	var botRepo repository.BotRepository = repo
+	if wrap != nil {
+		botRepo = wrap(repo)
+	}
+
+	poller := NewUpdatePoller(service.NewBotService(botRepo, keyring, "", api.URL, nil), processor, time.Second)
+	poller.retryDelay = 10 * time.Millisecond
+	return poller, repo
+}
+
+func TestPollBotAdvancesOffsetAndRetriesFailedUpdates(t *testing.T) {
+	api := &fakeBotAPI{}
+	processor := &recordingProcessor{fail: map[int64]error{43: assert.AnError}}
+	poller, repo := newTestPoller(t, api.handler(42, 43, 44), processor, func(repo *fakeBotRepository) repository.BotRepository {
+		return &offsetFailingBotRepository{fakeBotRepository: repo, failures: 2}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		poller.pollBot(ctx, 1)
+	}()
+
+	require.Eventually(t, func() bool { return repo.lastUpdateID(1) == 44 }, 5*time.Second, 10*time.Millisecond,
+		"polling continues after the offset failed to load")
+	cancel()
+	<-done
+
+	// Update 43 failed once and was fetched again before 44
+	assert.Equal(t, []int64{42, 43, 43, 44}, processor.processedIDs()[:4])
+	offsets := api.askedOffsets()
+	assert.Equal(t, int64(42), offsets[0], "offset starts after the stored update ID")
+	assert.Contains(t, offsets, int64(43), "update 42 is confirmed once processed")
+}
+
+func TestPollBotSkipsInvalidUpdates(t *testing.T) {
+	api := &fakeBotAPI{}
+	processor := &recordingProcessor{fail: map[int64]error{42: fmt.Errorf("%w: broken", service.ErrInvalidUpdate)}}
+	poller, repo := newTestPoller(t, api.handler(42, 43), processor, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		poller.pollBot(ctx, 1)
+	}()
+
+	require.Eventually(t, func() bool { return repo.lastUpdateID(1) == 43 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, []int64{42, 43}, processor.processedIDs()[:2])
+}
+
+func TestRefreshStopsPollingDeactivatedBot(t *testing.T) {
+	api := &fakeBotAPI{}
+	poller, repo := newTestPoller(t, api.handler(), &recordingProcessor{}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	poller.refresh(ctx)
+	require.Eventually(t, func() bool { return api.callCount() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	repo.setActive(1, false)
+	poller.refresh(ctx)
+
+	poller.pollersMu.Lock()
+	assert.Empty(t, poller.pollers)
+	poller.pollersMu.Unlock()
+
+	poller.wg.Wait()
+	calls := api.callCount()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, calls, api.callCount(), "no getUpdates calls after the bot was deactivated")
+}
@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kexi/telegram-bot-gateway/internal/pubsub"
+	"github.com/kexi/telegram-bot-gateway/internal/repository"
+)
+
+// webhookRetryBatchSize is how many deliveries the scheduler moves or recovers at a time
+const webhookRetryBatchSize = 100
+
+// WebhookRetryScheduler hands failed webhook deliveries back to the webhook workers once
+// their backoff has passed. On start and then every staleAfter it also recovers pending
+// deliveries that were lost from Redis, such as those being delivered when a gateway
+// instance crashed.
+type WebhookRetryScheduler struct {
+	messageBroker *pubsub.MessageBroker
+	deliveryRepo  repository.WebhookDeliveryRepository
+	interval      time.Duration
+	staleAfter    time.Duration // How long a pending delivery must be left untouched before it counts as lost
+}
+
+// NewWebhookRetryScheduler creates a new webhook retry scheduler. staleAfter must be
+// longer than a delivery attempt can take, so that deliveries another gateway instance
+// is sending, or has just created and not queued yet, are not recovered.
+func NewWebhookRetryScheduler(messageBroker *pubsub.MessageBroker, deliveryRepo repository.WebhookDeliveryRepository, interval, staleAfter time.Duration) *WebhookRetryScheduler {
+	return &WebhookRetryScheduler{
+		messageBroker: messageBroker,
+		deliveryRepo:  deliveryRepo,
+		interval:      interval,
+		staleAfter:    staleAfter,
+	}
+}
+
+// Start recovers lost deliveries, then releases due retries until the context is cancelled
+func (s *WebhookRetryScheduler) Start(ctx context.Context) {
+	log.Println("Webhook retry scheduler: Starting")
+
+	s.recover(ctx)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	recoverTicker := time.NewTicker(s.staleAfter)
+	defer recoverTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Webhook retry scheduler: Stopped")
+			return
+		case <-recoverTicker.C:
+			s.recover(ctx)
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				released, err := s.messageBroker.ReleaseDueWebhookRetries(ctx, time.Now(), webhookRetryBatchSize)
+				if err != nil && ctx.Err() == nil {
+					log.Printf("Webhook retry scheduler: %v", err)
+				}
+				if err != nil || released < webhookRetryBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// recover recovers lost deliveries, logging the outcome
+func (s *WebhookRetryScheduler) recover(ctx context.Context) {
+	if recovered, err := s.recoverPending(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("Webhook retry scheduler: Failed to recover pending deliveries: %v", err)
+	} else if recovered > 0 {
+		log.Printf("Webhook retry scheduler: Recovered %d pending deliveries", recovered)
+	}
+}
+
+// recoverPending schedules every pending delivery that has been left untouched for
+// staleAfter and is neither queued nor waiting for a retry, at its retry time or right
+// away when it has none. Workers touch a delivery when they take it, so one being sent
+// is not recovered. Returns how many were recovered.
+func (s *WebhookRetryScheduler) recoverPending(ctx context.Context) (int, error) {
+	recovered := 0
+	var afterID uint
+	updatedBefore := time.Now().Add(-s.staleAfter)
+
+	for {
+		pending, err := s.deliveryRepo.ListPending(ctx, updatedBefore, afterID, webhookRetryBatchSize)
+		if err != nil {
+			return recovered, err
+		}
+
+		for _, delivery := range pending {
+			at := time.Now()
+			if delivery.NextRetryAt != nil {
+				at = *delivery.NextRetryAt
+			}
+
+			lost, err := s.messageBroker.RecoverWebhookDelivery(ctx, delivery.ID, at)
+			if err != nil {
+				return recovered, err
+			}
+			if lost {
+				recovered++
+			}
+			afterID = delivery.ID
+		}
+
+		if len(pending) < webhookRetryBatchSize {
+			return recovered, nil
+		}
+	}
+}
@@ -1,13 +1,11 @@
 package worker
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -19,37 +17,39 @@ import (
 
 // WebhookWorker processes webhook deliveries
 type WebhookWorker struct {
-	workerID          int
-	messageBroker     *pubsub.MessageBroker
-	webhookService    *service.WebhookService
-	messageService    *service.MessageService
-	deliveryRepo      repository.WebhookDeliveryRepository
-	circuitBreakers   map[string]*CircuitBreaker
-	circuitBreakersMu sync.RWMutex
-	httpClient        *http.Client
-	maxRetries        int
+	workerID        int
+	messageBroker   *pubsub.MessageBroker
+	webhookService  *service.WebhookService
+	webhookSender   *service.WebhookSender
+	messageService  *service.MessageService
+	eventBuilder    *service.EventBuilder
+	deliveryRepo    repository.WebhookDeliveryRepository
+	circuitBreakers *CircuitBreakers
+	maxRetries      int
 }
 
-// NewWebhookWorker creates a new webhook worker
+// NewWebhookWorker creates a new webhook worker. Workers share the circuit breakers of the instance.
 func NewWebhookWorker(
 	workerID int,
 	messageBroker *pubsub.MessageBroker,
 	webhookService *service.WebhookService,
+	webhookSender *service.WebhookSender,
 	messageService *service.MessageService,
+	eventBuilder *service.EventBuilder,
 	deliveryRepo repository.WebhookDeliveryRepository,
+	circuitBreakers *CircuitBreakers,
 	maxRetries int,
 ) *WebhookWorker {
 	return &WebhookWorker{
 		workerID:        workerID,
 		messageBroker:   messageBroker,
 		webhookService:  webhookService,
+		webhookSender:   webhookSender,
 		messageService:  messageService,
+		eventBuilder:    eventBuilder,
 		deliveryRepo:    deliveryRepo,
-		circuitBreakers: make(map[string]*CircuitBreaker),
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		maxRetries: maxRetries,
+		circuitBreakers: circuitBreakers,
+		maxRetries:      maxRetries,
 	}
 }
 
@@ -86,24 +86,29 @@ func (w *WebhookWorker) processDelivery(ctx context.Context, deliveryID uint) er
 		return fmt.Errorf("failed to get delivery: %w", err)
 	}
 
+	// A delivery recovered after a restart may have been finished in the meantime
+	if delivery.Status != "pending" {
+		return nil
+	}
+
+	// Mark the delivery as in flight so that recovery leaves it alone while it is sent
+	if err := w.deliveryRepo.Touch(ctx, delivery.ID); err != nil {
+		return fmt.Errorf("failed to mark delivery: %w", err)
+	}
+
 	// Check if we should retry
 	if delivery.AttemptCount >= w.maxRetries {
-		delivery.Status = "failed"
-		w.deliveryRepo.Update(ctx, delivery)
-		w.messageBroker.PublishWebhookDeliveryResult(ctx, deliveryID, false, "Max retries exceeded")
+		w.failDelivery(ctx, delivery)
 		return fmt.Errorf("max retries exceeded")
 	}
 
 	// Get circuit breaker for this URL
-	cb := w.getCircuitBreaker(delivery.Webhook.URL)
+	cb := w.circuitBreakers.Get(delivery.Webhook.URL)
 
 	// Check if circuit is open
 	if !cb.CanAttempt() {
-		// Circuit is open, requeue for later
-		nextRetry := time.Now().Add(w.getRetryDelay(delivery.AttemptCount))
-		delivery.NextRetryAt = &nextRetry
-		w.deliveryRepo.Update(ctx, delivery)
-		w.messageBroker.QueueWebhookDelivery(ctx, deliveryID)
+		// Circuit is open, try again once it lets requests through without using up an attempt
+		w.scheduleRetry(ctx, delivery, circuitRetryAt(cb.OpenUntil()))
 		return fmt.Errorf("circuit breaker open for %s", delivery.Webhook.URL)
 	}
 
@@ -112,9 +117,14 @@ func (w *WebhookWorker) processDelivery(ctx context.Context, deliveryID uint) er
 
 	// Update circuit breaker
 	if success {
-		cb.RecordSuccess()
+		w.circuitBreakers.RecordSuccess(ctx, delivery.Webhook.URL)
 	} else {
-		cb.RecordFailure()
+		w.circuitBreakers.RecordFailure(ctx, delivery.Webhook.URL)
+		if err != nil {
+			if recordErr := w.webhookService.RecordDeliveryFailure(ctx, delivery.WebhookID, err.Error()); recordErr != nil {
+				log.Printf("Worker #%d: Failed to record failure of webhook %d: %v", w.workerID, delivery.WebhookID, recordErr)
+			}
+		}
 	}
 
 	// Update delivery status
@@ -123,51 +133,55 @@ func (w *WebhookWorker) processDelivery(ctx context.Context, deliveryID uint) er
 		delivery.Status = "delivered"
 		now := time.Now()
 		delivery.DeliveredAt = &now
+		delivery.NextRetryAt = nil
 		w.deliveryRepo.Update(ctx, delivery)
 		w.messageBroker.PublishWebhookDeliveryResult(ctx, deliveryID, true, "")
 		log.Printf("Worker #%d: Successfully delivered webhook %d", w.workerID, deliveryID)
-	} else {
-		delivery.Status = "pending"
-		if err != nil {
-			delivery.LastError = err.Error()
-		}
+		return nil
+	}
 
-		// Calculate next retry time with exponential backoff
-		nextRetry := time.Now().Add(w.getRetryDelay(delivery.AttemptCount))
-		delivery.NextRetryAt = &nextRetry
+	if err != nil {
+		delivery.LastError = err.Error()
+	}
+	if delivery.AttemptCount >= w.maxRetries {
+		w.failDelivery(ctx, delivery)
+		return nil
+	}
 
-		w.deliveryRepo.Update(ctx, delivery)
+	w.scheduleRetry(ctx, delivery, time.Now().Add(w.getRetryDelay(delivery.AttemptCount)))
+	log.Printf("Worker #%d: Failed delivery %d, retry at %s", w.workerID, deliveryID, delivery.NextRetryAt.Format(time.RFC3339))
 
-		// Requeue for retry
-		w.messageBroker.QueueWebhookDelivery(ctx, deliveryID)
+	return nil
+}
+
+// scheduleRetry keeps a delivery pending and hands it back to the queue at nextRetry
+func (w *WebhookWorker) scheduleRetry(ctx context.Context, delivery *domain.WebhookDelivery, nextRetry time.Time) {
+	delivery.Status = "pending"
+	delivery.NextRetryAt = &nextRetry
+	w.deliveryRepo.Update(ctx, delivery)
 
-		log.Printf("Worker #%d: Failed delivery %d, retry at %s", w.workerID, deliveryID, nextRetry.Format(time.RFC3339))
+	if err := w.messageBroker.ScheduleWebhookRetry(ctx, delivery.ID, nextRetry); err != nil {
+		// The delivery stays pending and is recovered on the next start
+		log.Printf("Worker #%d: Failed to schedule retry of delivery %d: %v", w.workerID, delivery.ID, err)
 	}
+}
 
-	return nil
+// failDelivery gives up on a delivery whose retries are used up, leaving it as a dead letter
+func (w *WebhookWorker) failDelivery(ctx context.Context, delivery *domain.WebhookDelivery) {
+	now := time.Now()
+	delivery.Status = "failed"
+	delivery.NextRetryAt = nil
+	delivery.FailedAt = &now
+	w.deliveryRepo.Update(ctx, delivery)
+	w.messageBroker.PublishWebhookDeliveryResult(ctx, delivery.ID, false, "Max retries exceeded")
+	log.Printf("Worker #%d: Gave up on delivery %d after %d attempts", w.workerID, delivery.ID, delivery.AttemptCount)
 }
 
-// attemptDelivery attempts to deliver a webhook
+// attemptDelivery attempts to deliver a webhook, recording the response on the delivery
 func (w *WebhookWorker) attemptDelivery(ctx context.Context, delivery *domain.WebhookDelivery) (bool, error) {
-	// Get message details
-	message, err := w.messageService.GetMessage(ctx, delivery.MessageID)
+	event, payload, err := w.buildPayload(ctx, delivery)
 	if err != nil {
-		return false, fmt.Errorf("failed to get message: %w", err)
-	}
-
-	// Build payload
-	payload := map[string]interface{}{
-		"event":      "message",
-		"message_id": message.ID,
-		"chat_id":    message.ChatID,
-		"telegram_id": message.TelegramID,
-		"text":       message.Text,
-		"from_username": message.FromUsername,
-		"from_first_name": message.FromFirstName,
-		"direction":  message.Direction,
-		"message_type": message.MessageType,
-		"sent_at":    message.SentAt,
-		"timestamp":  time.Now().Unix(),
+		return false, err
 	}
 
 	payloadBytes, err := json.Marshal(payload)
@@ -175,36 +189,131 @@ func (w *WebhookWorker) attemptDelivery(ctx context.Context, delivery *domain.We
 		return false, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", delivery.Webhook.URL, bytes.NewReader(payloadBytes))
+	resp, err := w.webhookSender.Send(ctx, &delivery.Webhook, fmt.Sprintf("dlv_%d", delivery.ID), event, payloadBytes)
+
+	// Keep the outcome for the delivery log and for inspecting dead letters
+	now := time.Now()
+	latency := resp.Latency.Milliseconds()
+	delivery.LastAttemptAt = &now
+	delivery.LatencyMs = &latency
+	delivery.ResponseStatus = resp.StatusCode
+	delivery.ResponseBody = resp.Body
+
 	if err != nil {
-		return false, fmt.Errorf("failed to create request: %w", err)
+		return false, err
 	}
 
-	// Add headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "TelegramBotGateway/1.0")
+	return true, nil
+}
 
-	// Sign payload with HMAC
-	signature := w.webhookService.SignPayload(delivery.Webhook.Secret, payloadBytes)
-	req.Header.Set("X-Webhook-Signature", signature)
+// buildPayload builds the body of a delivery in the schema version chosen by the webhook
+func (w *WebhookWorker) buildPayload(ctx context.Context, delivery *domain.WebhookDelivery) (string, interface{}, error) {
+	event := delivery.EventType
+	if event == "" {
+		event = "new_message"
+	}
 
-	// Send request
-	resp, err := w.httpClient.Do(req)
+	if delivery.Webhook.SchemaVersion < domain.EventSchemaEnvelope {
+		payload, err := w.buildFlatPayload(ctx, event, delivery)
+		return event, payload, err
+	}
+
+	envelope, err := w.buildEnvelope(ctx, event, delivery)
 	if err != nil {
-		return false, fmt.Errorf("failed to send request: %w", err)
+		return "", nil, err
+	}
+	if !delivery.Webhook.IncludeRaw {
+		envelope = envelope.WithoutRaw()
+	}
+	return event, envelope, nil
+}
+
+// buildEnvelope builds the event envelope of a delivery
+func (w *WebhookWorker) buildEnvelope(ctx context.Context, event string, delivery *domain.WebhookDelivery) (*domain.EventEnvelope, error) {
+	switch {
+	case delivery.UpdateEventID != nil:
+		return w.eventBuilder.UpdateEvent(ctx, *delivery.UpdateEventID)
+	case delivery.CallbackQueryID != nil:
+		return w.eventBuilder.CallbackQueryEvent(ctx, *delivery.CallbackQueryID)
+	case delivery.MessageID != nil:
+		return w.eventBuilder.MessageEvent(ctx, event, *delivery.MessageID)
+	}
+	return nil, fmt.Errorf("delivery %d has no message or event", delivery.ID)
+}
+
+// buildFlatPayload builds the flat payload of schema version 1
+func (w *WebhookWorker) buildFlatPayload(ctx context.Context, event string, delivery *domain.WebhookDelivery) (map[string]interface{}, error) {
+	payload := map[string]interface{}{
+		"event":     event,
+		"timestamp": time.Now().Unix(),
 	}
-	defer resp.Body.Close()
 
-	// Read response body (limit to 1MB)
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if delivery.MessageID != nil {
+		message, err := w.messageService.GetMessage(ctx, *delivery.MessageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message: %w", err)
+		}
+
+		payload["message_id"] = message.ID
+		payload["chat_id"] = message.ChatID
+		payload["telegram_id"] = message.TelegramID
+		payload["text"] = message.Text
+		payload["from_username"] = message.FromUsername
+		payload["from_first_name"] = message.FromFirstName
+		payload["direction"] = message.Direction
+		payload["message_type"] = message.MessageType
+		payload["sent_at"] = message.SentAt
+		if message.ReplyToMessageID != nil {
+			// Telegram message ID of the replied-to message, as returned when sending
+			payload["reply_to_message_id"] = *message.ReplyToMessageID
+		}
+	}
 
-	// Check response status
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return true, nil
+	// Button presses carry the callback query next to the message holding the keyboard
+	if cq := delivery.CallbackQuery; cq != nil {
+		callbackQuery := map[string]interface{}{
+			"id":              cq.QueryID,
+			"data":            cq.Data,
+			"from_user_id":    cq.FromUserID,
+			"from_username":   cq.FromUsername,
+			"from_first_name": cq.FromFirstName,
+			"chat_instance":   cq.ChatInstance,
+			"api_key_id":      cq.APIKeyID,
+		}
+		if cq.InlineMessageID != "" {
+			// Buttons on inline-mode messages have no message or chat
+			callbackQuery["inline_message_id"] = cq.InlineMessageID
+		}
+		payload["callback_query"] = callbackQuery
 	}
 
-	return false, fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(body))
+	// Other updates carry the Telegram update object as received
+	if ue := delivery.UpdateEvent; ue != nil {
+		if ue.ChatID != nil {
+			payload["chat_id"] = *ue.ChatID
+		}
+		payload["bot_id"] = ue.BotID
+		payload["from_user_id"] = ue.FromUserID
+		payload["from_username"] = ue.FromUsername
+		payload["occurred_at"] = ue.OccurredAt
+		if json.Valid([]byte(ue.RawData)) {
+			payload["update"] = json.RawMessage(ue.RawData)
+		}
+	}
+
+	return payload, nil
+}
+
+// circuitRetryJitter spreads the retries held back by an open circuit so they
+// don't all hit the webhook the moment it reopens
+const circuitRetryJitter = 5 * time.Second
+
+// circuitRetryAt returns when to retry a delivery held back by a circuit that is open until openUntil
+func circuitRetryAt(openUntil time.Time) time.Time {
+	if now := time.Now(); openUntil.Before(now) {
+		openUntil = now
+	}
+	return openUntil.Add(time.Duration(rand.Int63n(int64(circuitRetryJitter))))
 }
 
 // getRetryDelay calculates the retry delay with exponential backoff
@@ -225,31 +334,73 @@ func (w *WebhookWorker) getRetryDelay(attemptCount int) time.Duration {
 	return delays[attemptCount]
 }
 
-// getCircuitBreaker gets or creates a circuit breaker for a URL
-func (w *WebhookWorker) getCircuitBreaker(url string) *CircuitBreaker {
-	w.circuitBreakersMu.RLock()
-	cb, exists := w.circuitBreakers[url]
-	w.circuitBreakersMu.RUnlock()
+// CircuitBreakers holds the circuit breaker of every webhook URL for the webhook workers
+// of an instance. Opening and closing is shared through Redis so the health of a webhook
+// can be reported outside the workers.
+type CircuitBreakers struct {
+	messageBroker *pubsub.MessageBroker
+	breakers      map[string]*CircuitBreaker
+	mu            sync.RWMutex
+}
+
+// NewCircuitBreakers creates an empty set of circuit breakers
+func NewCircuitBreakers(messageBroker *pubsub.MessageBroker) *CircuitBreakers {
+	return &CircuitBreakers{
+		messageBroker: messageBroker,
+		breakers:      make(map[string]*CircuitBreaker),
+	}
+}
+
+// Get gets or creates the circuit breaker for a URL
+func (r *CircuitBreakers) Get(url string) *CircuitBreaker {
+	r.mu.RLock()
+	cb, exists := r.breakers[url]
+	r.mu.RUnlock()
 
 	if exists {
 		return cb
 	}
 
-	w.circuitBreakersMu.Lock()
-	defer w.circuitBreakersMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
 	// Check again after acquiring write lock
-	if cb, exists := w.circuitBreakers[url]; exists {
+	if cb, exists := r.breakers[url]; exists {
 		return cb
 	}
 
 	// Create new circuit breaker
 	cb = NewCircuitBreaker(5, 1*time.Minute) // 5 failures, 1 minute timeout
-	w.circuitBreakers[url] = cb
+	r.breakers[url] = cb
 
 	return cb
 }
 
+// RecordSuccess records a successful delivery to a URL
+func (r *CircuitBreakers) RecordSuccess(ctx context.Context, url string) {
+	cb := r.Get(url)
+	wasOpen := cb.GetState() != "closed"
+	cb.RecordSuccess()
+
+	if wasOpen {
+		if err := r.messageBroker.ClearWebhookCircuit(ctx, url); err != nil {
+			log.Printf("Failed to share closed circuit of %s: %v", url, err)
+		}
+	}
+}
+
+// RecordFailure records a failed delivery to a URL
+func (r *CircuitBreakers) RecordFailure(ctx context.Context, url string) {
+	cb := r.Get(url)
+	cb.RecordFailure()
+
+	if cb.GetState() == "open" {
+		if err := r.messageBroker.SetWebhookCircuitOpen(ctx, url, cb.OpenUntil()); err != nil {
+			log.Printf("Failed to share open circuit of %s: %v", url, err)
+		}
+	}
+}
+
 // CircuitBreaker implements a simple circuit breaker pattern
 type CircuitBreaker struct {
 	failureThreshold int
@@ -314,6 +465,13 @@ func (cb *CircuitBreaker) RecordFailure() {
 	}
 }
 
+// OpenUntil returns when an open circuit lets a request through again
+func (cb *CircuitBreaker) OpenUntil() time.Time {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	return cb.lastFailureTime.Add(cb.timeout)
+}
+
 // GetState returns the current circuit breaker state
 func (cb *CircuitBreaker) GetState() string {
 	cb.mu.RLock()
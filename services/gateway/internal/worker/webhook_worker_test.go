@@ -0,0 +1,28 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitRetryWaitsForOpenCircuit(t *testing.T) {
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.RecordFailure()
+	cb.RecordFailure()
+	assert.False(t, cb.CanAttempt())
+
+	openUntil := cb.OpenUntil()
+	for i := 0; i < 100; i++ {
+		nextRetry := circuitRetryAt(openUntil)
+		assert.False(t, nextRetry.Before(openUntil), "retry is not scheduled while the circuit is open")
+		assert.True(t, nextRetry.Before(openUntil.Add(circuitRetryJitter)))
+	}
+}
+
+func TestCircuitRetryOfElapsedCircuit(t *testing.T) {
+	before := time.Now()
+	nextRetry := circuitRetryAt(before.Add(-time.Hour))
+	assert.False(t, nextRetry.Before(before), "retry is never scheduled in the past")
+}
@@ -1,7 +1,11 @@
 -- Migration: 001_initial_schema_down
 -- Description: Rollback initial schema
 
+DROP TABLE IF EXISTS media_files;
+DROP TABLE IF EXISTS message_edits;
 DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS callback_queries;
+DROP TABLE IF EXISTS update_events;
 DROP TABLE IF EXISTS webhooks;
 DROP TABLE IF EXISTS messages;
 DROP TABLE IF EXISTS chat_permissions;
@@ -0,0 +1,4 @@
+-- Record which event triggered each webhook delivery
+-- Migration: 004_webhook_delivery_event_type
+
+ALTER TABLE webhook_deliveries ADD COLUMN event_type VARCHAR(50) NOT NULL DEFAULT 'new_message' AFTER message_id;
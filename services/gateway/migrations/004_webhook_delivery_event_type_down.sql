@@ -0,0 +1,3 @@
+-- Rollback migration 004_webhook_delivery_event_type
+
+ALTER TABLE webhook_deliveries DROP COLUMN event_type;
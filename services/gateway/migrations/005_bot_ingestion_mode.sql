@@ -0,0 +1,5 @@
+-- Per-bot ingestion mode: Telegram webhook or getUpdates long polling
+-- Migration: 005_bot_ingestion_mode
+
+ALTER TABLE bots ADD COLUMN ingestion_mode VARCHAR(20) NOT NULL DEFAULT 'webhook' AFTER webhook_secret;
+ALTER TABLE bots ADD COLUMN last_update_id BIGINT NOT NULL DEFAULT 0 AFTER ingestion_mode;
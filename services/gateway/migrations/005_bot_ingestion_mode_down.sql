@@ -0,0 +1,4 @@
+-- Rollback migration 005_bot_ingestion_mode
+
+ALTER TABLE bots DROP COLUMN last_update_id;
+ALTER TABLE bots DROP COLUMN ingestion_mode;
@@ -0,0 +1,15 @@
+-- Track edits and deletions of stored messages
+-- Migration: 006_message_edits
+
+ALTER TABLE messages ADD COLUMN edited_at TIMESTAMP NULL AFTER sent_at;
+ALTER TABLE messages ADD COLUMN is_deleted BOOLEAN DEFAULT FALSE AFTER edited_at;
+
+CREATE TABLE IF NOT EXISTS message_edits (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    message_id BIGINT UNSIGNED NOT NULL,
+    text TEXT,
+    edited_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    INDEX idx_message_edits_message (message_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,5 @@
+-- Rollback migration 006_message_edits
+
+DROP TABLE IF EXISTS message_edits;
+ALTER TABLE messages DROP COLUMN is_deleted;
+ALTER TABLE messages DROP COLUMN edited_at;
@@ -0,0 +1,37 @@
+-- Store inline keyboard callback queries and route them to the sending API key
+-- Migration: 007_callback_queries
+
+ALTER TABLE messages ADD COLUMN sent_by_api_key_id BIGINT UNSIGNED NULL AFTER direction;
+ALTER TABLE messages ADD INDEX idx_messages_sent_by_api_key (sent_by_api_key_id);
+ALTER TABLE messages ADD CONSTRAINT fk_messages_sent_by_api_key FOREIGN KEY (sent_by_api_key_id) REFERENCES api_keys(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS callback_queries (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    query_id VARCHAR(64) NOT NULL UNIQUE,
+    bot_id BIGINT UNSIGNED NOT NULL,
+    chat_id BIGINT UNSIGNED NULL,
+    message_id BIGINT UNSIGNED NULL,
+    inline_message_id VARCHAR(255),
+    api_key_id BIGINT UNSIGNED NULL,
+    from_user_id BIGINT NOT NULL,
+    from_username VARCHAR(100),
+    from_first_name VARCHAR(255),
+    chat_instance VARCHAR(100),
+    data TEXT,
+    game_short_name VARCHAR(255),
+    raw_data LONGTEXT,
+    answered_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (bot_id) REFERENCES bots(id) ON DELETE CASCADE,
+    FOREIGN KEY (chat_id) REFERENCES chats(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE SET NULL,
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE SET NULL,
+    INDEX idx_callback_queries_bot (bot_id),
+    INDEX idx_callback_queries_chat (chat_id),
+    INDEX idx_callback_queries_message (message_id),
+    INDEX idx_callback_queries_api_key (api_key_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE webhook_deliveries ADD COLUMN callback_query_id BIGINT UNSIGNED NULL AFTER message_id;
+ALTER TABLE webhook_deliveries ADD INDEX idx_webhook_deliveries_callback_query (callback_query_id);
+ALTER TABLE webhook_deliveries ADD CONSTRAINT fk_webhook_deliveries_callback_query FOREIGN KEY (callback_query_id) REFERENCES callback_queries(id) ON DELETE CASCADE;
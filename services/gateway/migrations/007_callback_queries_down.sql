@@ -0,0 +1,7 @@
+-- Rollback migration 007_callback_queries
+
+ALTER TABLE webhook_deliveries DROP FOREIGN KEY fk_webhook_deliveries_callback_query;
+ALTER TABLE webhook_deliveries DROP COLUMN callback_query_id;
+DROP TABLE IF EXISTS callback_queries;
+ALTER TABLE messages DROP FOREIGN KEY fk_messages_sent_by_api_key;
+ALTER TABLE messages DROP COLUMN sent_by_api_key_id;
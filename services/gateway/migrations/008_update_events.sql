@@ -0,0 +1,27 @@
+-- Store non-message Telegram updates and deliver them to webhooks
+-- Migration: 008_update_events
+
+CREATE TABLE IF NOT EXISTS update_events (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    bot_id BIGINT UNSIGNED NOT NULL,
+    chat_id BIGINT UNSIGNED NULL,
+    message_id BIGINT UNSIGNED NULL,
+    event_type VARCHAR(50) NOT NULL,
+    from_user_id BIGINT NULL,
+    from_username VARCHAR(100),
+    raw_data LONGTEXT,
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (bot_id) REFERENCES bots(id) ON DELETE CASCADE,
+    FOREIGN KEY (chat_id) REFERENCES chats(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE SET NULL,
+    INDEX idx_update_events_chat (chat_id, occurred_at DESC),
+    INDEX idx_update_events_bot_type (bot_id, event_type),
+    INDEX idx_update_events_message (message_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Update events without a message are delivered without message_id
+ALTER TABLE webhook_deliveries MODIFY COLUMN message_id BIGINT UNSIGNED NULL;
+ALTER TABLE webhook_deliveries ADD COLUMN update_event_id BIGINT UNSIGNED NULL AFTER callback_query_id;
+ALTER TABLE webhook_deliveries ADD INDEX idx_webhook_deliveries_update_event (update_event_id);
+ALTER TABLE webhook_deliveries ADD CONSTRAINT fk_webhook_deliveries_update_event FOREIGN KEY (update_event_id) REFERENCES update_events(id) ON DELETE CASCADE;
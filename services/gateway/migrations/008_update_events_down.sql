@@ -0,0 +1,7 @@
+-- Rollback migration 008_update_events
+
+ALTER TABLE webhook_deliveries DROP FOREIGN KEY fk_webhook_deliveries_update_event;
+ALTER TABLE webhook_deliveries DROP COLUMN update_event_id;
+DELETE FROM webhook_deliveries WHERE message_id IS NULL;
+ALTER TABLE webhook_deliveries MODIFY COLUMN message_id BIGINT UNSIGNED NOT NULL;
+DROP TABLE IF EXISTS update_events;
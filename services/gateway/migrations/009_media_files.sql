@@ -0,0 +1,24 @@
+-- Store files attached to received messages
+-- Migration: 009_media_files
+
+CREATE TABLE IF NOT EXISTS media_files (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    message_id BIGINT UNSIGNED NOT NULL UNIQUE,
+    bot_id BIGINT UNSIGNED NOT NULL,
+    file_id VARCHAR(255) NOT NULL,
+    file_unique_id VARCHAR(100),
+    file_name VARCHAR(255),
+    mime_type VARCHAR(100),
+    file_size BIGINT,
+    storage_key VARCHAR(512),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    error TEXT,
+    stored_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (bot_id) REFERENCES bots(id) ON DELETE CASCADE,
+    INDEX idx_media_files_bot (bot_id),
+    INDEX idx_media_files_unique (file_unique_id),
+    INDEX idx_media_files_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+-- Rollback migration 009_media_files
+
+DROP TABLE IF EXISTS media_files;
@@ -0,0 +1,6 @@
+-- Feedback webhooks push incoming messages to the API key that registered them
+-- Migration: 010_feedback_webhooks
+
+ALTER TABLE webhooks ADD COLUMN api_key_id BIGINT UNSIGNED NULL AFTER reply_to_message_id;
+ALTER TABLE webhooks ADD UNIQUE INDEX idx_webhooks_api_key (api_key_id);
+ALTER TABLE webhooks ADD CONSTRAINT fk_webhooks_api_key FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE;
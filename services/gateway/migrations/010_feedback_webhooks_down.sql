@@ -0,0 +1,6 @@
+-- Rollback migration 010_feedback_webhooks
+
+DELETE FROM webhooks WHERE scope = 'feedback';
+ALTER TABLE webhooks DROP FOREIGN KEY fk_webhooks_api_key;
+ALTER TABLE webhooks DROP INDEX idx_webhooks_api_key;
+ALTER TABLE webhooks DROP COLUMN api_key_id;
@@ -0,0 +1,34 @@
+-- Permissions for message moderation and role management
+-- Migration: 011_rbac_permissions
+
+INSERT INTO permissions (name, description, resource, action) VALUES
+    ('messages:manage', 'Delete and pin messages', 'messages', 'manage'),
+    ('roles:read', 'View roles', 'roles', 'read'),
+    ('roles:create', 'Create roles', 'roles', 'create'),
+    ('roles:update', 'Change role permissions and assignments', 'roles', 'update'),
+    ('roles:delete', 'Delete roles', 'roles', 'delete')
+ON DUPLICATE KEY UPDATE description=VALUES(description);
+
+-- Admins hold every permission
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin'
+ON DUPLICATE KEY UPDATE role_id=role_id;
+
+-- Viewers hold every read permission
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'viewer' AND p.action = 'read'
+ON DUPLICATE KEY UPDATE role_id=role_id;
+
+-- Operators moderate messages
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'operator' AND p.name = 'messages:manage'
+ON DUPLICATE KEY UPDATE role_id=role_id;
@@ -0,0 +1,3 @@
+-- Rollback migration 011_rbac_permissions
+
+DELETE FROM permissions WHERE name IN ('messages:manage', 'roles:read', 'roles:create', 'roles:update', 'roles:delete');
@@ -0,0 +1,11 @@
+-- Hourly request quotas with separate budgets for reads and sends
+-- Migration: 012_rate_limit_quotas
+
+-- api_keys.rate_limit is the read budget; 0 here means the same budget for sends
+ALTER TABLE api_keys
+    ADD COLUMN send_rate_limit INT NOT NULL DEFAULT 0 AFTER rate_limit;
+
+-- 0 means the configured default (rate_limit.user_requests_per_hour / user_sends_per_hour)
+ALTER TABLE users
+    ADD COLUMN rate_limit INT NOT NULL DEFAULT 0 AFTER is_active,
+    ADD COLUMN send_rate_limit INT NOT NULL DEFAULT 0 AFTER rate_limit;
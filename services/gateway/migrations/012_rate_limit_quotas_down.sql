@@ -0,0 +1,8 @@
+-- Rollback migration 012_rate_limit_quotas
+
+ALTER TABLE users
+    DROP COLUMN send_rate_limit,
+    DROP COLUMN rate_limit;
+
+ALTER TABLE api_keys
+    DROP COLUMN send_rate_limit;
@@ -0,0 +1,27 @@
+-- Queue for asynchronous sends through the Bot API
+-- Migration: 013_outbound_messages
+
+CREATE TABLE IF NOT EXISTS outbound_messages (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    chat_id BIGINT UNSIGNED NOT NULL,
+    bot_id BIGINT UNSIGNED NOT NULL,
+    source_chat_id BIGINT UNSIGNED NULL,
+    method VARCHAR(50) NOT NULL,
+    params TEXT NOT NULL,
+    sent_by_api_key_id BIGINT UNSIGNED NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    message_ids TEXT,
+    sent_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (chat_id) REFERENCES chats(id) ON DELETE CASCADE,
+    FOREIGN KEY (bot_id) REFERENCES bots(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_chat_id) REFERENCES chats(id) ON DELETE SET NULL,
+    FOREIGN KEY (sent_by_api_key_id) REFERENCES api_keys(id) ON DELETE SET NULL,
+    INDEX idx_outbound_due (status, next_attempt_at),
+    INDEX idx_outbound_chat (chat_id, status),
+    INDEX idx_outbound_bot (bot_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+-- Rollback migration 013_outbound_messages
+
+DROP TABLE IF EXISTS outbound_messages;
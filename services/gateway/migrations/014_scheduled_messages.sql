@@ -0,0 +1,11 @@
+-- Scheduled sends: outbound messages held back until their send time
+-- Migration: 014_scheduled_messages
+
+-- Scheduled messages wait with status 'scheduled' and next_attempt_at = scheduled_at.
+-- sent_by_user_id records JWT callers so chat permissions can be checked at send time.
+ALTER TABLE outbound_messages
+    ADD COLUMN sent_by_user_id BIGINT UNSIGNED NULL AFTER sent_by_api_key_id,
+    ADD COLUMN scheduled_at TIMESTAMP NULL AFTER next_attempt_at,
+    ADD CONSTRAINT fk_outbound_sent_by_user FOREIGN KEY (sent_by_user_id) REFERENCES users(id) ON DELETE SET NULL,
+    ADD INDEX idx_outbound_api_key (sent_by_api_key_id, status),
+    ADD INDEX idx_outbound_user (sent_by_user_id, status);
@@ -0,0 +1,8 @@
+-- Rollback migration 014_scheduled_messages
+
+ALTER TABLE outbound_messages
+    DROP FOREIGN KEY fk_outbound_sent_by_user,
+    DROP INDEX idx_outbound_user,
+    DROP INDEX idx_outbound_api_key,
+    DROP COLUMN scheduled_at,
+    DROP COLUMN sent_by_user_id;
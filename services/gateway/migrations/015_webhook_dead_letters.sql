@@ -0,0 +1,10 @@
+-- Webhook dead letters: failed deliveries kept with the response of their last attempt
+-- Migration: 015_webhook_dead_letters
+
+-- Deliveries whose retries are used up keep status 'failed' with failed_at set until replayed.
+-- response_body holds the first 1 KB of the response body.
+ALTER TABLE webhook_deliveries
+    ADD COLUMN response_status INT NULL AFTER last_error,
+    ADD COLUMN response_body TEXT NULL AFTER response_status,
+    ADD COLUMN failed_at TIMESTAMP NULL AFTER delivered_at,
+    ADD INDEX idx_webhook_deliveries_failed (status, failed_at);
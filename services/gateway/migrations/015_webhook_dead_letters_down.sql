@@ -0,0 +1,7 @@
+-- Rollback migration 015_webhook_dead_letters
+
+ALTER TABLE webhook_deliveries
+    DROP INDEX idx_webhook_deliveries_failed,
+    DROP COLUMN failed_at,
+    DROP COLUMN response_body,
+    DROP COLUMN response_status;
@@ -0,0 +1,12 @@
+-- Webhook health: attempt latency on deliveries and the last failure on webhooks
+-- Migration: 016_webhook_health
+
+-- latency_ms and last_attempt_at describe the last attempt of a delivery.
+ALTER TABLE webhook_deliveries
+    ADD COLUMN latency_ms INT NULL AFTER response_body,
+    ADD COLUMN last_attempt_at TIMESTAMP NULL AFTER latency_ms,
+    ADD INDEX idx_webhook_deliveries_webhook_created (webhook_id, created_at);
+
+ALTER TABLE webhooks
+    ADD COLUMN last_failure_at TIMESTAMP NULL AFTER is_active,
+    ADD COLUMN last_failure TEXT NULL AFTER last_failure_at;
@@ -0,0 +1,10 @@
+-- Rollback migration 016_webhook_health
+
+ALTER TABLE webhooks
+    DROP COLUMN last_failure,
+    DROP COLUMN last_failure_at;
+
+ALTER TABLE webhook_deliveries
+    DROP INDEX idx_webhook_deliveries_webhook_created,
+    DROP COLUMN last_attempt_at,
+    DROP COLUMN latency_ms;
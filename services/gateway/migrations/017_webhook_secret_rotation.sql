@@ -0,0 +1,7 @@
+-- Webhook secret rotation: the replaced secret keeps signing deliveries for an overlap window
+-- Migration: 017_webhook_secret_rotation
+
+-- Deliveries are signed with both secrets until previous_secret_expires_at.
+ALTER TABLE webhooks
+    ADD COLUMN previous_secret VARCHAR(255) NULL AFTER secret,
+    ADD COLUMN previous_secret_expires_at TIMESTAMP NULL AFTER previous_secret;
@@ -0,0 +1,5 @@
+-- Rollback migration 017_webhook_secret_rotation
+
+ALTER TABLE webhooks
+    DROP COLUMN previous_secret_expires_at,
+    DROP COLUMN previous_secret;
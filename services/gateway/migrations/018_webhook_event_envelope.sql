@@ -0,0 +1,7 @@
+-- Webhook event envelope: per-webhook payload schema version and raw Telegram payloads
+-- Migration: 018_webhook_event_envelope
+
+-- Existing webhooks keep the flat payload (version 1) until their owners opt in.
+ALTER TABLE webhooks
+    ADD COLUMN schema_version INT NOT NULL DEFAULT 1 AFTER is_active,
+    ADD COLUMN include_raw BOOLEAN NOT NULL DEFAULT FALSE AFTER schema_version;
@@ -0,0 +1,5 @@
+-- Rollback migration 018_webhook_event_envelope
+
+ALTER TABLE webhooks
+    DROP COLUMN include_raw,
+    DROP COLUMN schema_version;
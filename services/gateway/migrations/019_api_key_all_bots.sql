@@ -0,0 +1,6 @@
+-- API key bot grants: keys without grants no longer use every bot
+-- Migration: 019_api_key_all_bots
+
+-- Keys that must keep using every bot opt in with "apikey grant-bot <id> all".
+ALTER TABLE api_keys
+    ADD COLUMN all_bots BOOLEAN NOT NULL DEFAULT FALSE AFTER send_rate_limit;
@@ -0,0 +1,4 @@
+-- Rollback migration 019_api_key_all_bots
+
+ALTER TABLE api_keys
+    DROP COLUMN all_bots;
@@ -0,0 +1,159 @@
+// Package webhooksig signs and verifies the webhook requests sent by the Telegram Bot Gateway.
+//
+// Every delivery carries three headers:
+//
+//	X-Webhook-Id:        unique ID of the delivery, the same on every retry
+//	X-Webhook-Timestamp: Unix time the request was signed
+//	X-Webhook-Signature: t=<timestamp>,v1=<signature>
+//
+// A v1 signature is the hex-encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the
+// webhook secret. While a secret is being rotated the header carries one v1 signature
+// per valid secret, so receivers can switch to the new secret at any time during the overlap.
+//
+// Receivers should reject requests whose timestamp is outside a small tolerance, which
+// Verify does, and may remember the IDs of deliveries they accepted within that tolerance
+// to drop replays entirely.
+package webhooksig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header names
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// DefaultTolerance is how far the signing time of a request may be from the receiver's clock
+const DefaultTolerance = 5 * time.Minute
+
+// Verification errors
+var (
+	ErrMissingSignature = errors.New("webhooksig: missing signature header")
+	ErrInvalidHeader    = errors.New("webhooksig: malformed signature header")
+	ErrTimestamp        = errors.New("webhooksig: timestamp outside the tolerance")
+	ErrNoMatch          = errors.New("webhooksig: no signature matches")
+)
+
+// Sign returns the v1 signature of a body signed at timestamp with secret
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Header builds the X-Webhook-Signature value of a body signed at timestamp, with one
+// v1 signature per secret
+func Header(timestamp time.Time, body []byte, secrets ...string) string {
+	var b strings.Builder
+	b.WriteString("t=")
+	b.WriteString(strconv.FormatInt(timestamp.Unix(), 10))
+	for _, secret := range secrets {
+		b.WriteString(",v1=")
+		b.WriteString(Sign(secret, timestamp, body))
+	}
+	return b.String()
+}
+
+// Verify checks an X-Webhook-Signature value against the raw request body. It succeeds when
+// the timestamp is within tolerance of now and a v1 signature matches any of the secrets.
+// Pass both the old and the new secret while rotating. A tolerance of 0 uses DefaultTolerance.
+func Verify(header string, body []byte, tolerance time.Duration, secrets ...string) error {
+	return verify(header, body, tolerance, time.Now(), secrets)
+}
+
+// VerifyRequest checks the signature headers of a webhook request whose body was already read
+func VerifyRequest(r *http.Request, body []byte, tolerance time.Duration, secrets ...string) error {
+	header := r.Header.Get(HeaderSignature)
+	if header == "" {
+		return ErrMissingSignature
+	}
+
+	if err := verify(header, body, tolerance, time.Now(), secrets); err != nil {
+		return err
+	}
+
+	// The timestamp header must be the signed one
+	if ts := r.Header.Get(HeaderTimestamp); ts != "" {
+		signed, _, _ := parse(header)
+		if ts != strconv.FormatInt(signed.Unix(), 10) {
+			return ErrInvalidHeader
+		}
+	}
+
+	return nil
+}
+
+// verify checks a signature header as of now
+func verify(header string, body []byte, tolerance time.Duration, now time.Time, secrets []string) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+
+	timestamp, signatures, err := parse(header)
+	if err != nil {
+		return err
+	}
+
+	if diff := now.Sub(timestamp); diff > tolerance || diff < -tolerance {
+		return ErrTimestamp
+	}
+
+	for _, secret := range secrets {
+		expected := []byte(Sign(secret, timestamp, body))
+		for _, signature := range signatures {
+			if hmac.Equal(expected, []byte(signature)) {
+				return nil
+			}
+		}
+	}
+
+	return ErrNoMatch
+}
+
+// parse splits a signature header into its timestamp and v1 signatures. Unknown
+// versions are skipped so new schemes can be added next to v1.
+func parse(header string) (time.Time, []string, error) {
+	var timestamp time.Time
+	var haveTimestamp bool
+	var signatures []string
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return time.Time{}, nil, ErrInvalidHeader
+		}
+
+		switch key {
+		case "t":
+			unix, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return time.Time{}, nil, ErrInvalidHeader
+			}
+			timestamp = time.Unix(unix, 0)
+			haveTimestamp = true
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	if !haveTimestamp || len(signatures) == 0 {
+		return time.Time{}, nil, ErrInvalidHeader
+	}
+
+	return timestamp, signatures, nil
+}
@@ -0,0 +1,80 @@
+package webhooksig
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignKnownValue(t *testing.T) {
+	// HMAC-SHA256 of "1700000000.{}" keyed with "secret"
+	ts := time.Unix(1700000000, 0)
+	assert.Equal(t, "b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163", Sign("secret", ts, []byte("{}")))
+	assert.Equal(t, "t=1700000000,v1=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163", Header(ts, []byte("{}"), "secret"))
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"event":"new_message"}`)
+	now := time.Unix(1700000000, 0)
+	header := Header(now, body, "secret")
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		now     time.Time
+		secrets []string
+		want    error
+	}{
+		{"valid", header, body, now, []string{"secret"}, nil},
+		{"valid within tolerance", header, body, now.Add(4 * time.Minute), []string{"secret"}, nil},
+		{"one of several secrets", header, body, now, []string{"new", "secret"}, nil},
+		{"replayed later", header, body, now.Add(6 * time.Minute), []string{"secret"}, ErrTimestamp},
+		{"from the future", header, body, now.Add(-6 * time.Minute), []string{"secret"}, ErrTimestamp},
+		{"wrong secret", header, body, now, []string{"other"}, ErrNoMatch},
+		{"tampered body", header, []byte(`{"event":"edited_message"}`), now, []string{"secret"}, ErrNoMatch},
+		{"tampered timestamp", strings.Replace(header, "t=1700000000", "t=1700000100", 1), body, now, []string{"secret"}, ErrNoMatch},
+		{"missing", "", body, now, []string{"secret"}, ErrMissingSignature},
+		{"no timestamp", "v1=abc", body, now, []string{"secret"}, ErrInvalidHeader},
+		{"no signature", "t=1700000000", body, now, []string{"secret"}, ErrInvalidHeader},
+		{"legacy format", "sha256=abc", body, now, []string{"secret"}, ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, verify(tt.header, tt.body, 0, tt.now, tt.secrets), tt.want)
+		})
+	}
+}
+
+func TestHeaderDuringRotation(t *testing.T) {
+	body := []byte(`{}`)
+	now := time.Unix(1700000000, 0)
+	header := Header(now, body, "new", "old")
+
+	assert.Equal(t, 2, strings.Count(header, "v1="))
+	// Receivers still on the old secret and those already on the new one both verify
+	assert.NoError(t, verify(header, body, 0, now, []string{"old"}))
+	assert.NoError(t, verify(header, body, 0, now, []string{"new"}))
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := []byte(`{}`)
+	now := time.Now()
+
+	req := httptest.NewRequest("POST", "/hook", nil)
+	req.Header.Set(HeaderID, "dlv_1")
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Header(now, body, "secret"))
+	assert.NoError(t, VerifyRequest(req, body, 0, "secret"))
+
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix()+1, 10))
+	assert.ErrorIs(t, VerifyRequest(req, body, 0, "secret"), ErrInvalidHeader)
+
+	req.Header.Del(HeaderSignature)
+	assert.ErrorIs(t, VerifyRequest(req, body, 0, "secret"), ErrMissingSignature)
+}